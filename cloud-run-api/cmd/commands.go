@@ -0,0 +1,170 @@
+// cmd/commands.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"your-module-name/internal/audit"
+	"your-module-name/internal/auth"
+	"your-module-name/internal/config"
+)
+
+// runCommand dispatches a subcommand such as `config docs` or `migrate`.
+func runCommand(args []string) error {
+	switch args[0] {
+	case "apikey":
+		if len(args) < 2 || args[1] != "create" {
+			return fmt.Errorf("usage: %s apikey create -id id -scopes scope,... [-name name] [-expires-in duration]", os.Args[0])
+		}
+		return runAPIKeyCreate(args[2:], os.Stdout)
+	case "audit":
+		if len(args) != 3 || args[1] != "verify" {
+			return fmt.Errorf("usage: %s audit verify file", os.Args[0])
+		}
+		return runAuditVerify(args[2], os.Stdout)
+	case "migrate":
+		if len(args) > 1 {
+			return fmt.Errorf("usage: %s migrate", os.Args[0])
+		}
+		return runMigrate(os.Stdout)
+	case "config":
+		if len(args) < 2 || args[1] != "docs" {
+			return fmt.Errorf("usage: %s config docs [-readme path] [-env path]", os.Args[0])
+		}
+		return runConfigDocs(args[2:])
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// runConfigDocs regenerates the configuration table in the README and the
+// .env.example file from the struct tags on config.Config.
+func runConfigDocs(args []string) error {
+	fs := flag.NewFlagSet("config docs", flag.ContinueOnError)
+	readmePath := fs.String("readme", "README.md", "Markdown file containing the config-docs markers")
+	envPath := fs.String("env", ".env.example", "path of the .env.example file to write")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	readme, err := os.ReadFile(*readmePath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", *readmePath, err)
+	}
+	updated, err := config.ReplaceMarkdownSection(readme)
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %w", *readmePath, err)
+	}
+	if err := os.WriteFile(*readmePath, updated, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", *readmePath, err)
+	}
+
+	var envExample bytes.Buffer
+	if err := config.WriteEnvExample(&envExample); err != nil {
+		return fmt.Errorf("failed to render %s: %w", *envPath, err)
+	}
+	if err := os.WriteFile(*envPath, envExample.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", *envPath, err)
+	}
+	return nil
+}
+
+// runAPIKeyCreate generates an API key and prints it with the entry to add
+// to API_KEYS_FILE. The key itself is not stored anywhere and cannot be
+// recovered later.
+func runAPIKeyCreate(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("apikey create", flag.ContinueOnError)
+	id := fs.String("id", "", "key ID, shown in logs and recorded as the caller (required)")
+	name := fs.String("name", "", "human-readable description of the key")
+	scopes := fs.String("scopes", "", "comma-separated scopes: "+strings.Join(auth.KnownScopes, ", "))
+	expiresIn := fs.Duration("expires-in", 0, "lifetime of the key, e.g. 2160h; zero never expires")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *id == "" {
+		return fmt.Errorf("apikey create: -id is required")
+	}
+
+	entry := auth.APIKey{ID: *id, Name: *name, Scopes: []string{}}
+	for _, scope := range strings.Split(*scopes, ",") {
+		if scope = strings.TrimSpace(scope); scope == "" {
+			continue
+		}
+		if !slices.Contains(auth.KnownScopes, scope) {
+			return fmt.Errorf("apikey create: unknown scope %q", scope)
+		}
+		entry.Scopes = append(entry.Scopes, scope)
+	}
+	if *expiresIn > 0 {
+		entry.ExpiresAt = time.Now().Add(*expiresIn).UTC().Truncate(time.Second)
+	}
+	key, err := auth.GenerateAPIKey()
+	if err != nil {
+		return err
+	}
+	entry.Hash = auth.HashAPIKey(key)
+
+	doc, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "API key (shown once): %s\n\nAdd this entry to the \"keys\" array of API_KEYS_FILE:\n%s\n", key, doc)
+	return nil
+}
+
+// runAuditVerify checks the hash chain of an audit file written with
+// AUDIT_HASH_CHAIN and prints the number of records and the last hash, which
+// can be kept elsewhere to detect a rewrite of the whole file later.
+func runAuditVerify(path string, out io.Writer) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("audit verify: %w", err)
+	}
+	defer f.Close()
+	records, lastHash, err := audit.Verify(f)
+	if err != nil {
+		return fmt.Errorf("audit verify: %s: %w (%d intact records before it)", path, err, records)
+	}
+	fmt.Fprintf(out, "%s: %d records, hash chain intact\nLast hash: %s\n", path, records, lastHash)
+	return nil
+}
+
+// runMigrate applies pending schema migrations to the configured database
+// and exits. It only reads the store settings (see config.Store), so it runs
+// without the rest of the server's environment.
+func runMigrate(out io.Writer) error {
+	cfg, err := config.LoadStore()
+	if err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	if cfg.StoreBackend == config.StoreBackendMemory {
+		fmt.Fprintln(out, "Nothing to migrate for the memory store backend")
+		return nil
+	}
+
+	ctx := context.Background()
+	repo, err := openDatabase(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+	applied, err := repo.Migrate(ctx)
+	if err != nil {
+		repo.Close()
+		return fmt.Errorf("migration failed: %w", err)
+	}
+	if len(applied) == 0 {
+		fmt.Fprintf(out, "The %s database is up to date\n", cfg.StoreBackend)
+	} else {
+		fmt.Fprintf(out, "Applied %d migrations to the %s database: %s\n", len(applied), cfg.StoreBackend, strings.Join(applied, ", "))
+	}
+	return repo.Close()
+}
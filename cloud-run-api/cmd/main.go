@@ -2,27 +2,56 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
+	"slices"
+	"strings"
 	"time"
 
 	"your-module-name/internal/api"
-	// Old import: "your-module-name/internal/cloudlogging"
-	"github.com/duizendstra/dui-go/logging/cloudlogging" // New import
+	"your-module-name/internal/audit"
+	"your-module-name/internal/auth"
+	"your-module-name/internal/authz"
 	"your-module-name/internal/config"
+	"your-module-name/internal/cors"
+	"your-module-name/internal/flags"
+	"your-module-name/internal/loadshed"
+	"your-module-name/internal/logging"
+	"your-module-name/internal/metrics"
+	"your-module-name/internal/proxy"
+	"your-module-name/internal/ratelimit"
+	"your-module-name/internal/search"
+	"your-module-name/internal/store"
+	"your-module-name/internal/store/postgres"
+	"your-module-name/internal/store/sqlite"
+	"your-module-name/internal/tlsconfig"
 )
 
 // Package-level variables for application config and the logger.
 var (
 	appConfig config.Config
 	logger    *slog.Logger
+	flushLogs logging.ShutdownFunc
+	auditLog  *audit.Logger
 )
 
-// init runs before main and is used for essential setup like loading configuration
-// and initializing the global logger.
-func init() {
+// fatal logs msg at error level, flushes buffered logs and exits.
+func fatal(msg string, args ...any) {
+	logger.Error(msg, args...)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = flushLogs(ctx)
+	os.Exit(1)
+}
+
+// setup is used for essential server setup like loading configuration
+// and initializing the global logger. Subcommands that must work without a
+// complete environment, such as `config docs`, do not run it (see commands.go).
+func setup() {
 	var err error
 	appConfig, err = config.Load()
 	if err != nil {
@@ -30,24 +59,147 @@ func init() {
 		os.Exit(1)
 	}
 
-	// Initialize the structured logger with the dui-go CloudLoggingHandler.
-	// The NewCloudLoggingHandler from dui-go is expected to return an slog.Handler.
-	// It will internally handle LOG_LEVEL and Project ID detection if designed that way.
-	cloudHandler := cloudlogging.NewCloudLoggingHandler(appConfig.ServiceName)
-	logger = slog.New(cloudHandler) // Pass the handler directly
+	// Build the slog handler selected by LOG_FORMAT (cloud, json, text, otlp).
+	// The cloud format uses the dui-go CloudLoggingHandler, which handles
+	// LOG_LEVEL and Cloud Trace correlation itself.
+	handler, shutdown, err := logging.NewHandler(appConfig, logging.Options{
+		InstanceID: logging.DetectInstanceID(context.Background(), appConfig),
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "FATAL: Logging setup error: %v\n", err)
+		os.Exit(1)
+	}
+	logger = slog.New(handler)
+	flushLogs = shutdown
 
 	slog.SetDefault(logger)
-	logger.Debug("Configuration loaded successfully")
+	logger.Debug("Configuration loaded successfully", "runtime_mode", appConfig.RuntimeMode, "project_id", appConfig.ProjectID)
 	logger.Debug("Initialization complete.")
 }
 
-// main is the entry point of the application.
+// main is the entry point of the application. Without arguments it runs the
+// HTTP server; otherwise the arguments select a subcommand.
 func main() {
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "FATAL: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	setup()
 	logger.Info(fmt.Sprintf("%s starting...", appConfig.ServiceName))
 
-	apiHandler := api.NewHandler(logger, appConfig)
+	var err error
+	auditLog, err = newAuditLogger()
+	if err != nil {
+		fatal("Failed to open audit log", "path", appConfig.AuditLogFile, "error", err)
+	}
+	defer auditLog.Close()
+
+	flagProvider, err := newFlagProvider(context.Background())
+	if err != nil {
+		fatal("Failed to configure feature flags", "error", err)
+	}
+
+	authenticator, err := newAuthenticator(context.Background())
+	if err != nil {
+		fatal("Failed to set up authentication", "error", err)
+	}
+
+	policy, err := newAuthzSource(context.Background())
+	if err != nil {
+		fatal("Failed to load authorization policy", "path", appConfig.AuthzPolicyFile, "error", err)
+	}
+
+	var tlsFiles *tlsconfig.Reloader
+	if appConfig.TLSCertFile != "" {
+		tlsFiles, err = newTLSReloader(context.Background())
+		if err != nil {
+			fatal("Failed to load TLS certificate", "cert_file", appConfig.TLSCertFile, "error", err)
+		}
+	}
+
+	messages, closeStore, err := newMessageRepository(context.Background(), appConfig.DBMigrateOnStart)
+	if err != nil {
+		fatal("Failed to open message store", "backend", appConfig.StoreBackend, "error", err)
+	}
+	defer closeStore()
+
+	// The database stores search with their full-text index, which every
+	// instance shares. Other stores get an in-process index, built from the
+	// store on startup and kept in sync with this instance's writes.
+	searcher, ok := messages.(search.Searcher)
+	if !ok {
+		indexed := search.NewIndexedRepository(messages)
+		indexedCount, err := indexed.Rebuild(context.Background())
+		if err != nil {
+			fatal("Failed to build search index", "error", err)
+		}
+		logger.Info("Search index built", "messages", indexedCount)
+		if mem, ok := messages.(*store.MemoryRepository); ok {
+			// Messages evicted from memory leave the index too.
+			mem.OnEvict = func(m store.Message) { indexed.Index.Remove(m.ID) }
+		}
+		messages, searcher = indexed, indexed
+	}
+
+	apiHandler := api.NewHandler(logger, appConfig, messages)
+	apiHandler.Flags = flagProvider
+	apiHandler.Search = searcher
+	apiHandler.Auth = authenticator
+	apiHandler.Authz = policy
+	apiHandler.Audit = auditLog
+	apiHandler.RateLimit, err = newRateLimiter()
+	if err != nil {
+		fatal("Failed to configure rate limits", "error", err)
+	}
+	apiHandler.TrustedProxies.Prefixes, _ = appConfig.TrustedProxyPrefixes() // Validated by config.Load.
+	apiHandler.TrustedProxies.Header, err = proxy.ParseHeader(appConfig.TrustedProxyHeader)
+	if err != nil {
+		fatal("Failed to configure trusted proxies", "error", err)
+	}
+
+	routeTimeouts, _ := appConfig.RouteTimeouts() // Validated by config.Load.
+	apiHandler.Timeouts = api.Timeouts{
+		Default: time.Duration(appConfig.RequestTimeoutMillis) * time.Millisecond,
+		Routes:  routeTimeouts,
+		Max:     time.Duration(appConfig.RequestTimeoutMaxMillis) * time.Millisecond,
+	}
+
+	apiHandler.CORS, err = newCORS()
+	if err != nil {
+		fatal("Failed to configure CORS", "error", err)
+	}
+	apiHandler.SecurityHeaders, err = newSecurityHeaders()
+	if err != nil {
+		fatal("Failed to configure security headers", "error", err)
+	}
+
+	registry := metrics.NewRegistry()
+	if appConfig.ConcurrencyMaxLimit > 0 {
+		apiHandler.Shedder = loadshed.New(loadshed.Options{
+			MinLimit:         appConfig.ConcurrencyMinLimit,
+			MaxLimit:         appConfig.ConcurrencyMaxLimit,
+			LatencyThreshold: time.Duration(appConfig.ConcurrencyLatencyThresholdMS) * time.Millisecond,
+			QueueSize:        appConfig.ConcurrencyQueueSize,
+			QueueTimeout:     time.Duration(appConfig.ConcurrencyQueueTimeoutMS) * time.Millisecond,
+		}, registry)
+		logger.Info("Concurrency limiting enabled", "min_limit", appConfig.ConcurrencyMinLimit, "max_limit", appConfig.ConcurrencyMaxLimit, "queue_size", appConfig.ConcurrencyQueueSize)
+	}
+	if appConfig.MetricsPort != "" {
+		go serveMetrics(registry)
+	}
 	httpHandler := api.SetupRoutes(apiHandler)
 
+	auditLog.Record(context.Background(), audit.Event{
+		Action:   audit.ActionConfigLoad,
+		Outcome:  audit.OutcomeSuccess,
+		Resource: appConfig.ServiceName,
+		Reason:   fmt.Sprintf("runtime_mode=%s authz_mode=%s auth_required=%t", appConfig.RuntimeMode, appConfig.AuthzMode, appConfig.AuthRequired),
+	})
+
 	addr := ":" + appConfig.Port
 	logger.Info("Server listening", "address", addr)
 
@@ -55,12 +207,346 @@ func main() {
 		Addr:              addr,
 		Handler:           httpHandler,
 		ReadHeaderTimeout: 5 * time.Second,
-		WriteTimeout:      10 * time.Second,
-		IdleTimeout:       60 * time.Second,
+		// Longer than any request deadline, so that withTimeout answers
+		// with 504 before the server drops the connection.
+		WriteTimeout: apiHandler.Timeouts.Max + 5*time.Second,
+		IdleTimeout:  60 * time.Second,
 	}
 
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		logger.Error("Server failed to start", "error", err)
-		os.Exit(1)
+	if tlsFiles != nil {
+		server.TLSConfig = tlsFiles.Config()
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
+		fatal("Server failed to start", "error", err)
+	}
+}
+
+// newTLSReloader loads the TLS certificate, key and client CAs, which are
+// watched for changes for the lifetime of ctx. With a client CA bundle the
+// listener verifies client certificates according to TLS_CLIENT_AUTH.
+func newTLSReloader(ctx context.Context) (*tlsconfig.Reloader, error) {
+	files := tlsconfig.Files{
+		CertFile:     appConfig.TLSCertFile,
+		KeyFile:      appConfig.TLSKeyFile,
+		ClientCAFile: appConfig.TLSClientCAFile,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+	if appConfig.TLSClientAuth == config.TLSClientAuthVerifyIfGiven {
+		files.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	reloader, err := tlsconfig.NewReloader(files, logger)
+	if err != nil {
+		return nil, err
+	}
+	reloader.OnReload = auditReload(appConfig.TLSCertFile)
+	go reloader.Watch(ctx, time.Duration(appConfig.TLSReloadSeconds)*time.Second)
+	logger.Info("Serving TLS", "cert_file", appConfig.TLSCertFile, "client_ca_file", appConfig.TLSClientCAFile, "client_auth", appConfig.TLSClientAuth)
+	return reloader, nil
+}
+
+// newFlagProvider builds the feature flag provider from configuration. A flags
+// file is watched for changes for the lifetime of ctx; otherwise the static
+// FEATURE_FLAGS specification is used.
+func newFlagProvider(ctx context.Context) (flags.Provider, error) {
+	if appConfig.FeatureFlagsFile != "" {
+		provider, err := flags.NewFileProvider(appConfig.FeatureFlagsFile, logger)
+		if err != nil {
+			return nil, err
+		}
+		provider.OnReload = auditReload(appConfig.FeatureFlagsFile)
+		go provider.Watch(ctx, time.Duration(appConfig.FeatureFlagsReloadSeconds)*time.Second)
+		logger.Info("Feature flags loaded from file", "path", appConfig.FeatureFlagsFile)
+		return provider, nil
+	}
+
+	defs, err := flags.ParseStatic(appConfig.FeatureFlags)
+	if err != nil {
+		return nil, err
+	}
+	return flags.NewStaticProvider(defs...), nil
+}
+
+// newAuthenticator builds the authenticators for the configured credentials:
+// client certificates verified by the TLS listener, API keys from
+// API_KEYS_FILE, which is watched for changes for the lifetime of ctx, and
+// bearer tokens from OIDC_ISSUER and Google. Without any of them
+// authentication is disabled, which config.Load only allows in local mode or
+// with AUTH_REQUIRED=false.
+func newAuthenticator(ctx context.Context) (auth.Authenticator, error) {
+	var chain auth.Chain
+	if appConfig.TLSClientCAFile != "" {
+		chain = append(chain, auth.NewCertificateAuthenticator())
+	}
+	if appConfig.APIKeysFile != "" {
+		keys, err := auth.NewFileKeyStore(appConfig.APIKeysFile, logger)
+		if err != nil {
+			return nil, err
+		}
+		keys.OnReload = auditReload(appConfig.APIKeysFile)
+		go keys.Watch(ctx, time.Duration(appConfig.APIKeysReloadSeconds)*time.Second)
+		logger.Info("API keys loaded from file", "path", appConfig.APIKeysFile, "count", keys.Len())
+		chain = append(chain, auth.NewAPIKeyAuthenticator(keys))
+	}
+
+	skew := time.Duration(appConfig.JWTClockSkewSeconds) * time.Second
+	var verifiers []*auth.JWTVerifier
+	if appConfig.GoogleIDTokenAudience != "" {
+		google := auth.NewGoogleIDTokenVerifier(splitList(appConfig.GoogleIDTokenAudience), splitList(appConfig.GoogleIDTokenEmails), nil)
+		google.ClockSkew = skew
+		verifiers = append(verifiers, google)
+		logger.Info("Google ID tokens accepted", "audience", appConfig.GoogleIDTokenAudience)
+	}
+	if appConfig.OIDCIssuer != "" {
+		keys := auth.NewOIDCJWKS(appConfig.OIDCIssuer, nil)
+		if appConfig.OIDCJWKSURL != "" {
+			keys = auth.NewJWKS(appConfig.OIDCJWKSURL, nil)
+		}
+		verifiers = append(verifiers, &auth.JWTVerifier{
+			Name:       "oidc",
+			Issuers:    []string{appConfig.OIDCIssuer},
+			Audiences:  splitList(appConfig.OIDCAudience),
+			Keys:       keys,
+			ClockSkew:  skew,
+			RolesClaim: appConfig.OIDCRolesClaim,
+		})
+		logger.Info("OIDC tokens accepted", "issuer", appConfig.OIDCIssuer, "audience", appConfig.OIDCAudience)
+	}
+	if len(verifiers) > 0 {
+		chain = append(chain, auth.NewBearerAuthenticator(verifiers...))
+	}
+
+	switch len(chain) {
+	case 0:
+		logger.Warn("No API_KEYS_FILE, OIDC_ISSUER, GOOGLE_ID_TOKEN_AUDIENCE or TLS_CLIENT_CA_FILE is set; authentication is disabled")
+		return nil, nil
+	case 1:
+		return chain[0], nil
+	}
+	return chain, nil
+}
+
+// newAuthzSource loads the authorization policy from AUTHZ_POLICY_FILE,
+// which is watched for changes for the lifetime of ctx, or returns the
+// default policy.
+func newAuthzSource(ctx context.Context) (authz.Source, error) {
+	if appConfig.AuthzPolicyFile == "" {
+		return authz.DefaultPolicy(), nil
+	}
+	source, err := authz.NewFileSource(appConfig.AuthzPolicyFile, logger)
+	if err != nil {
+		return nil, err
+	}
+	source.OnReload = auditReload(appConfig.AuthzPolicyFile)
+	go source.Watch(ctx, time.Duration(appConfig.AuthzPolicyReloadSeconds)*time.Second)
+	logger.Info("Authorization policy loaded from file", "path", appConfig.AuthzPolicyFile, "roles", len(source.Policy().Roles), "mode", appConfig.AuthzMode)
+	return source, nil
+}
+
+// serveMetrics serves the registry at /metrics on METRICS_PORT. The
+// listener is separate from the API's so that metrics are not exposed on
+// the service URL; a failure to listen is logged, not fatal.
+func serveMetrics(registry *metrics.Registry) {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", registry.Handler())
+	server := &http.Server{
+		Addr:              ":" + appConfig.MetricsPort,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	logger.Info("Metrics listening", "address", server.Addr)
+	if err := server.ListenAndServe(); err != nil {
+		logger.Error("Metrics server failed", "error", err)
+	}
+}
+
+// newRateLimiter returns the rate limiter for RATE_LIMITS, or nil if no
+// limits are set. Buckets are kept per instance.
+func newRateLimiter() (*ratelimit.Limiter, error) {
+	rules, err := ratelimit.ParseRules(appConfig.RateLimits)
+	if err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	for _, name := range rules.Names() {
+		if name != ratelimit.DefaultRule && !slices.Contains(authz.KnownPermissions, name) {
+			return nil, fmt.Errorf("rate limit %q: not a route permission or %q", name, ratelimit.DefaultRule)
+		}
+		logger.Info("Rate limit configured", "rule", name, "limit", rules[name].String())
+	}
+	return ratelimit.NewLimiter(rules, ratelimit.NewMemoryStore()), nil
+}
+
+// newCORS returns the CORS policies of CORS_ALLOWED_ORIGINS and
+// CORS_ROUTE_ORIGINS. Routes without allowed origins get no policy.
+func newCORS() (api.CORS, error) {
+	policy := func(origins []string) (*cors.Policy, error) {
+		if len(origins) == 0 {
+			return nil, nil
+		}
+		return cors.New(cors.Options{
+			AllowedOrigins:   origins,
+			AllowedMethods:   splitList(appConfig.CORSAllowedMethods),
+			AllowedHeaders:   splitList(appConfig.CORSAllowedHeaders),
+			ExposedHeaders:   splitList(appConfig.CORSExposedHeaders),
+			AllowCredentials: appConfig.CORSAllowCredentials,
+			MaxAge:           time.Duration(appConfig.CORSMaxAgeSeconds) * time.Second,
+		})
+	}
+	var c api.CORS
+	var err error
+	if c.Default, err = policy(splitList(appConfig.CORSAllowedOrigins)); err != nil {
+		return api.CORS{}, err
+	}
+	routeOrigins, _ := appConfig.RouteCORSOrigins() // Validated by config.Load.
+	if len(routeOrigins) > 0 {
+		c.Routes = make(map[string]*cors.Policy, len(routeOrigins))
+	}
+	for name, origins := range routeOrigins {
+		if !slices.Contains(authz.KnownPermissions, name) {
+			return api.CORS{}, fmt.Errorf("CORS origins for %q: not a route permission", name)
+		}
+		if c.Routes[name], err = policy(origins); err != nil {
+			return api.CORS{}, fmt.Errorf("CORS origins for %s: %w", name, err)
+		}
+	}
+	if c.Default != nil || len(c.Routes) > 0 {
+		logger.Info("CORS enabled", "origins", appConfig.CORSAllowedOrigins, "route_origins", appConfig.CORSRouteOrigins)
+	}
+	return c, nil
+}
+
+// newSecurityHeaders returns the response headers of
+// STRICT_TRANSPORT_SECURITY, CONTENT_SECURITY_POLICY, REFERRER_POLICY and
+// SECURITY_HEADERS_ROUTES. Responses are never sniffed for another content
+// type.
+func newSecurityHeaders() (api.SecurityHeaders, error) {
+	headers := api.SecurityHeaders{Default: http.Header{}}
+	headers.Default.Set("X-Content-Type-Options", "nosniff")
+	for name, value := range map[string]string{
+		"Strict-Transport-Security": appConfig.StrictTransportSecurity,
+		"Content-Security-Policy":   appConfig.ContentSecurityPolicy,
+		"Referrer-Policy":           appConfig.ReferrerPolicy,
+	} {
+		if value != "" {
+			headers.Default.Set(name, value)
+		}
+	}
+	routes, _ := appConfig.RouteSecurityHeaders() // Validated by config.Load.
+	for name, overrides := range routes {
+		if !slices.Contains(authz.KnownPermissions, name) {
+			return api.SecurityHeaders{}, fmt.Errorf("security headers for %q: not a route permission", name)
+		}
+		if headers.Routes == nil {
+			headers.Routes = make(map[string]http.Header, len(routes))
+		}
+		headers.Routes[name] = http.Header{}
+		for header, value := range overrides {
+			headers.Routes[name].Set(header, value)
+		}
+	}
+	return headers, nil
+}
+
+// newAuditLogger returns the audit logger, which writes to the log stream
+// and, with AUDIT_LOG_FILE, to a local file.
+func newAuditLogger() (*audit.Logger, error) {
+	sinks := []audit.Sink{audit.NewSlogSink(logger.Handler())}
+	if appConfig.AuditLogFile != "" {
+		file, err := audit.NewFileSink(appConfig.AuditLogFile, appConfig.AuditHashChain)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, file)
+		logger.Info("Audit events written to file", "path", appConfig.AuditLogFile, "hash_chain", appConfig.AuditHashChain)
+	}
+	return audit.New(logger, sinks...), nil
+}
+
+// auditReload returns an OnReload hook recording reloads of the
+// configuration file at path.
+func auditReload(path string) func(context.Context, error) {
+	return func(ctx context.Context, err error) {
+		e := audit.Event{Action: audit.ActionConfigReload, Outcome: audit.OutcomeSuccess, Resource: path}
+		if err != nil {
+			e.Outcome, e.Reason = audit.OutcomeFailure, err.Error()
+		}
+		auditLog.Record(ctx, e)
+	}
+}
+
+// splitList splits a comma-separated configuration value, dropping empty
+// entries.
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// databaseRepository is a MessageRepository backed by a database with a
+// migrated schema.
+type databaseRepository interface {
+	store.MessageRepository
+	Migrate(ctx context.Context) ([]string, error)
+	Close() error
+}
+
+// newMessageRepository opens the message store selected by STORE_BACKEND and
+// returns it with a function that releases it. For database backends pending
+// migrations are applied when migrate is true.
+func newMessageRepository(ctx context.Context, migrate bool) (store.MessageRepository, func() error, error) {
+	if appConfig.StoreBackend == config.StoreBackendMemory {
+		repo := store.NewMemoryRepository()
+		repo.MaxMessages = appConfig.MemoryStoreMaxMessages
+		repo.MaxBytes = appConfig.MemoryStoreMaxBytes
+		return repo, func() error { return nil }, nil
+	}
+	repo, err := openDatabase(ctx, appConfig.Store)
+	if err != nil {
+		return nil, nil, err
+	}
+	if migrate {
+		applied, err := repo.Migrate(ctx)
+		if err != nil {
+			repo.Close()
+			return nil, nil, err
+		}
+		logger.Info("Database migrations applied", "backend", appConfig.StoreBackend, "applied", applied)
+	}
+	return repo, repo.Close, nil
+}
+
+// openDatabase connects to the database of the sqlite or postgres store
+// backend described by cfg.
+func openDatabase(ctx context.Context, cfg config.Store) (databaseRepository, error) {
+	switch cfg.StoreBackend {
+	case config.StoreBackendSQLite:
+		return sqlite.Open(ctx, sqlite.Options{
+			Path:         cfg.SQLitePath,
+			MaxOpenConns: cfg.DBMaxOpenConns,
+		})
+	case config.StoreBackendPostgres:
+		return postgres.Open(ctx, postgres.Options{
+			DSN:              cfg.PostgresDSN,
+			CloudSQLInstance: cfg.CloudSQLInstance,
+			User:             cfg.PostgresUser,
+			Password:         cfg.PostgresPassword,
+			Database:         cfg.PostgresDatabase,
+			MaxOpenConns:     cfg.DBMaxOpenConns,
+			MaxIdleConns:     cfg.DBMaxIdleConns,
+			ConnMaxLifetime:  time.Duration(cfg.DBConnMaxLifetimeSeconds) * time.Second,
+			StatementTimeout: time.Duration(cfg.DBStatementTimeoutMillis) * time.Millisecond,
+			MaxRetries:       cfg.DBMaxRetries,
+		})
+	default:
+		return nil, fmt.Errorf("store backend %q has no database", cfg.StoreBackend)
 	}
 }
@@ -0,0 +1,29 @@
+// internal/api/audit.go
+package api
+
+import (
+	"net/http"
+
+	"your-module-name/internal/audit"
+	"your-module-name/internal/auth"
+)
+
+// audit records e to h.Audit with the caller's principal, request ID and
+// source IP.
+func (h *Handler) audit(r *http.Request, e audit.Event) {
+	if principal, ok := auth.FromContext(r.Context()); ok && e.Principal == "" {
+		e.Principal = principal.ID
+	}
+	e.RequestID = requestID(r)
+	e.SourceIP = h.clientIP(r)
+	h.Audit.Record(r.Context(), e)
+}
+
+// requestID identifies a request across logs: its trace ID, which Cloud
+// Run's front end assigns to every request, or a client's X-Request-Id.
+func requestID(r *http.Request) string {
+	if id := traceIDFromRequest(r); id != "" {
+		return id
+	}
+	return r.Header.Get("X-Request-Id")
+}
@@ -0,0 +1,124 @@
+// internal/api/audit_test.go
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"your-module-name/internal/audit"
+	"your-module-name/internal/auth"
+	"your-module-name/internal/authz"
+	"your-module-name/internal/config"
+)
+
+// recordingSink keeps the audit events written to it.
+type recordingSink struct {
+	mu     sync.Mutex
+	events []audit.Event
+}
+
+func (s *recordingSink) Write(_ context.Context, e audit.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, e)
+	return nil
+}
+
+// take returns the events written so far and forgets them.
+func (s *recordingSink) take() []audit.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	events := s.events
+	s.events = nil
+	return events
+}
+
+func TestAuditEvents(t *testing.T) {
+	deps := newTestDeps(t, config.Config{ServiceName: "TestService", RuntimeMode: config.RuntimeModeLocal, AuthRequired: true, AuthzMode: config.AuthzModeEnforce})
+	seedMessages(t, deps.messages, 2)
+	sink := &recordingSink{}
+	deps.handler.Audit = audit.New(deps.handler.Logger, sink)
+	deps.handler.Auth = principalAuthenticator{
+		"admin":  {ID: "oidc:admin", Roles: []string{auth.RoleAdmin}},
+		"viewer": {ID: "oidc:viewer", Roles: []string{"viewer"}},
+	}
+	deps.handler.Authz = &authz.Policy{Roles: map[string][]string{
+		auth.RoleAdmin: {"*"},
+		"viewer":       {authz.PermHelloRead, authz.PermMessagesRead},
+	}}
+	router := SetupRoutes(deps.handler)
+	do := func(method, target, principal string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.RemoteAddr = "203.0.113.7:4711"
+		req.Header.Set("X-Request-Id", "req-1")
+		if principal != "" {
+			req.Header.Set("X-Test-Principal", principal)
+		}
+		return serve(router, req)
+	}
+
+	t.Run("Authentication failures", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/hello", "").Code)
+		assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/hello", "stranger").Code)
+		events := sink.take()
+		require.Len(t, events, 2)
+		assert.Equal(t, audit.ActionAuthenticate, events[0].Action)
+		assert.Equal(t, audit.OutcomeFailure, events[0].Outcome)
+		assert.Equal(t, "no credentials", events[0].Reason)
+		assert.Equal(t, "GET /hello", events[0].Resource)
+		assert.Equal(t, "203.0.113.7", events[0].SourceIP)
+		assert.Equal(t, "req-1", events[0].RequestID)
+		assert.False(t, events[0].Time.IsZero())
+		assert.Equal(t, audit.OutcomeFailure, events[1].Outcome)
+		assert.NotEmpty(t, events[1].Reason)
+	})
+
+	t.Run("Denials", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, do(http.MethodDelete, "/messages/m0", "viewer").Code)
+		events := sink.take()
+		require.Len(t, events, 1)
+		assert.Equal(t, audit.Event{
+			Time:      events[0].Time,
+			Action:    audit.ActionAuthorize,
+			Outcome:   audit.OutcomeDenied,
+			Principal: "oidc:viewer",
+			Resource:  "DELETE /messages/m0",
+			Reason:    "lacks " + authz.PermMessagesWrite,
+			RequestID: "req-1",
+			SourceIP:  "203.0.113.7",
+		}, events[0])
+	})
+
+	t.Run("Ordinary requests are not audited", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, do(http.MethodGet, "/hello", "viewer").Code)
+		assert.Empty(t, sink.take())
+	})
+
+	t.Run("Admin requests and deletions", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/messages/m0", "admin").Code)
+		assert.Equal(t, http.StatusGone, do(http.MethodDelete, "/messages/m0", "admin").Code)
+		events := sink.take()
+		require.Len(t, events, 4)
+
+		assert.Equal(t, audit.ActionAdminRequest, events[0].Action)
+		assert.Equal(t, audit.OutcomeSuccess, events[0].Outcome)
+		assert.Equal(t, "oidc:admin", events[0].Principal)
+		assert.Equal(t, "messages:write granted by admin=*", events[0].Reason)
+
+		assert.Equal(t, audit.ActionMessageDelete, events[1].Action)
+		assert.Equal(t, audit.OutcomeSuccess, events[1].Outcome)
+		assert.Equal(t, "messages/m0", events[1].Resource)
+		assert.Equal(t, "oidc:admin", events[1].Principal)
+
+		assert.Equal(t, audit.ActionAdminRequest, events[2].Action)
+		assert.Equal(t, audit.ActionMessageDelete, events[3].Action)
+		assert.Equal(t, audit.OutcomeFailure, events[3].Outcome)
+		assert.NotEmpty(t, events[3].Reason)
+	})
+}
@@ -0,0 +1,53 @@
+// internal/api/auth.go
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"your-module-name/internal/audit"
+	"your-module-name/internal/auth"
+)
+
+// withAuth authenticates requests with h.Auth and stores the principal on the
+// request context for withPermission, callerIdentity and handlers.
+//
+// Requests without credentials get 401 unless AUTH_REQUIRED is false, in
+// which case they are served anonymously; invalid credentials always get 401.
+// A nil h.Auth disables authentication.
+func (h *Handler) withAuth(next http.Handler) http.Handler {
+	if h.Auth == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		principal, err := h.Auth.Authenticate(r)
+		switch {
+		case errors.Is(err, auth.ErrNoCredentials) && !h.AppConfig.AuthRequired:
+			next.ServeHTTP(w, r)
+			return
+		case errors.Is(err, auth.ErrNoCredentials):
+			h.audit(r, audit.Event{Action: audit.ActionAuthenticate, Outcome: audit.OutcomeFailure, Resource: r.Method + " " + r.URL.Path, Reason: "no credentials"})
+			h.unauthorized(w, r, "Credentials are required")
+			return
+		case errors.Is(err, auth.ErrInvalidCredentials):
+			h.Logger.WarnContext(ctx, "Authentication failed", "error", err, "remote_addr", r.RemoteAddr)
+			h.audit(r, audit.Event{Action: audit.ActionAuthenticate, Outcome: audit.OutcomeFailure, Resource: r.Method + " " + r.URL.Path, Reason: err.Error()})
+			h.unauthorized(w, r, "Invalid credentials")
+			return
+		case err != nil:
+			h.Logger.ErrorContext(ctx, "Failed to authenticate request", "error", err)
+			writeProblem(w, r, http.StatusInternalServerError, "Failed to authenticate request")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(auth.NewContext(ctx, principal)))
+	})
+}
+
+// unauthorized answers 401 with challenges for the accepted credentials.
+func (h *Handler) unauthorized(w http.ResponseWriter, r *http.Request, detail string) {
+	if challenge := h.Auth.Challenge(h.AppConfig.ServiceName); challenge != "" {
+		w.Header().Set("WWW-Authenticate", challenge)
+	}
+	writeProblem(w, r, http.StatusUnauthorized, detail)
+}
@@ -0,0 +1,215 @@
+// internal/api/auth_test.go
+package api
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"your-module-name/internal/auth"
+	"your-module-name/internal/auth/authtest"
+	"your-module-name/internal/authz"
+	"your-module-name/internal/config"
+	"your-module-name/internal/store"
+	"your-module-name/internal/tlsconfig"
+	"your-module-name/internal/tlsconfig/tlstest"
+)
+
+func newAuthTestRouter(t *testing.T, required bool) (http.Handler, testDeps) {
+	t.Helper()
+	deps := newTestDeps(t, config.Config{ServiceName: "TestService", RuntimeMode: config.RuntimeModeLocal, AuthRequired: required})
+	deps.handler.Auth = auth.NewAPIKeyAuthenticator(auth.NewMemoryKeyStore(
+		auth.APIKey{ID: "writer", Hash: auth.HashAPIKey("writer-key"), Scopes: []string{auth.ScopeEchoWrite, auth.ScopeMessagesRead}},
+		auth.APIKey{ID: "reader", Hash: auth.HashAPIKey("reader-key"), Scopes: []string{auth.ScopeHelloRead}},
+	))
+	return SetupRoutes(deps.handler), deps
+}
+
+func TestAuthRoutes(t *testing.T) {
+	router, deps := newAuthTestRouter(t, true)
+	do := func(method, target, key string) *httptest.ResponseRecorder {
+		body := ""
+		if method == http.MethodPost {
+			body = `{"text_to_echo": "authenticated"}`
+		}
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(auth.APIKeyHeader, key)
+		}
+		return serve(router, req)
+	}
+
+	t.Run("Missing credentials", func(t *testing.T) {
+		for _, target := range []string{"/hello", "/version", "/messages", "/"} {
+			rr := do(http.MethodGet, target, "")
+			assert.Equal(t, http.StatusUnauthorized, rr.Code, target)
+			assert.Equal(t, `ApiKey realm="TestService"`, rr.Header().Get("WWW-Authenticate"), target)
+		}
+		p := decodeProblem(t, do(http.MethodPost, "/echo", ""))
+		assert.Equal(t, http.StatusUnauthorized, p.Status)
+	})
+
+	t.Run("Invalid credentials", func(t *testing.T) {
+		rr := do(http.MethodGet, "/hello", "guessed-key")
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		assert.Equal(t, "Invalid credentials", decodeProblem(t, rr).Detail)
+	})
+
+	t.Run("Missing scope", func(t *testing.T) {
+		rr := do(http.MethodPost, "/echo", "reader-key")
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+		assert.Contains(t, decodeProblem(t, rr).Detail, auth.ScopeEchoWrite)
+
+		rr = do(http.MethodDelete, "/messages/m0", "writer-key")
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("Scoped access", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, do(http.MethodGet, "/hello", "reader-key").Code)
+		assert.Equal(t, http.StatusOK, do(http.MethodGet, "/version", "reader-key").Code, "any principal may read the version")
+
+		rr := do(http.MethodPost, "/echo", "writer-key")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		page, err := deps.messages.List(t.Context(), store.ListOptions{})
+		require.NoError(t, err)
+		require.Len(t, page.Messages, 1)
+		assert.Equal(t, "apikey:writer", page.Messages[0].Caller, "the principal is recorded as the caller")
+
+		req := httptest.NewRequest(http.MethodGet, "/messages", nil)
+		req.Header.Set("Authorization", "ApiKey writer-key")
+		assert.Equal(t, http.StatusOK, serve(router, req).Code)
+	})
+
+	t.Run("Probes", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, do(http.MethodGet, "/healthz", "").Code)
+		assert.Equal(t, http.StatusOK, do(http.MethodGet, "/readyz", "").Code)
+	})
+}
+
+func TestAuthRoutes_Optional(t *testing.T) {
+	router, deps := newAuthTestRouter(t, false)
+	seedMessages(t, deps.messages, 1)
+	do := func(method, target, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if key != "" {
+			req.Header.Set(auth.APIKeyHeader, key)
+		}
+		return serve(router, req)
+	}
+
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/version", "").Code, "anonymous requests are served")
+	assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "/hello", "").Code, "the anonymous role is granted nothing by default")
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/hello", "guessed-key").Code, "invalid credentials are still rejected")
+
+	// Dropping a credential that lacks a permission does not gain it.
+	assert.Equal(t, http.StatusForbidden, do(http.MethodDelete, "/messages/m0", "writer-key").Code)
+	assert.Equal(t, http.StatusForbidden, do(http.MethodDelete, "/messages/m0", "").Code)
+	_, err := deps.messages.Get(t.Context(), "m0")
+	assert.NoError(t, err, "not deleted")
+
+	deps.handler.Authz = &authz.Policy{Roles: map[string][]string{auth.RoleAnonymous: {authz.PermHelloRead}}}
+	router = SetupRoutes(deps.handler)
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/hello", "").Code, "the policy may grant anonymous callers permissions")
+	assert.Equal(t, http.StatusForbidden, do(http.MethodDelete, "/messages/m0", "").Code)
+}
+
+func TestAuthRoutes_BearerTokens(t *testing.T) {
+	iss := authtest.NewIssuer(t)
+	deps := newTestDeps(t, config.Config{ServiceName: "TestService", RuntimeMode: config.RuntimeModeLocal, AuthRequired: true})
+	deps.handler.Auth = auth.Chain{
+		auth.NewAPIKeyAuthenticator(auth.NewMemoryKeyStore()),
+		auth.NewBearerAuthenticator(&auth.JWTVerifier{
+			Name:       "oidc",
+			Issuers:    []string{iss.URL},
+			Audiences:  []string{"api"},
+			Keys:       auth.NewJWKS(iss.URL+"/jwks.json", nil),
+			RolesClaim: "roles",
+		}),
+	}
+	router := SetupRoutes(deps.handler)
+	do := func(method, target string, claims map[string]any) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(`{"text_to_echo": "authenticated"}`))
+		if claims != nil {
+			req.Header.Set("Authorization", "Bearer "+iss.Sign(t, claims))
+		}
+		return serve(router, req)
+	}
+	withClaim := func(name string, value any) map[string]any {
+		claims := iss.Claims("user-1", "api")
+		claims[name] = value
+		return claims
+	}
+
+	rr := do(http.MethodGet, "/hello", nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Equal(t, `ApiKey realm="TestService", Bearer realm="TestService"`, rr.Header().Get("WWW-Authenticate"))
+
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/hello", withClaim("aud", "other")).Code)
+	assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "/hello", iss.Claims("user-1", "api")).Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/version", iss.Claims("user-1", "api")).Code)
+
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/hello", withClaim("scope", auth.ScopeHelloRead)).Code)
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/echo", withClaim("scope", auth.ScopeHelloRead)).Code)
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/echo", withClaim("roles", []string{"viewer"})).Code)
+
+	rr = do(http.MethodPost, "/echo", withClaim("roles", []string{auth.RoleAdmin}))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	page, err := deps.messages.List(t.Context(), store.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, page.Messages, 1)
+	assert.Equal(t, "oidc:user-1", page.Messages[0].Caller)
+}
+
+func TestAuthRoutes_ClientCertificates(t *testing.T) {
+	serverCA := tlstest.NewCA(t, "server CA")
+	clientCA := tlstest.NewCA(t, "client CA")
+	dir := t.TempDir()
+	certFile, keyFile := tlstest.WriteKeyPair(t, dir, serverCA.ServerCert(t, "127.0.0.1"))
+	caFile := filepath.Join(dir, "clients.pem")
+	tlstest.WriteFile(t, caFile, clientCA.PEM())
+	tlsFiles, err := tlsconfig.NewReloader(tlsconfig.Files{
+		CertFile:     certFile,
+		KeyFile:      keyFile,
+		ClientCAFile: caFile,
+		ClientAuth:   tls.VerifyClientCertIfGiven,
+	}, slog.New(slog.DiscardHandler))
+	require.NoError(t, err)
+
+	deps := newTestDeps(t, config.Config{ServiceName: "TestService", RuntimeMode: config.RuntimeModeLocal, AuthRequired: true})
+	deps.handler.Auth = auth.Chain{auth.NewCertificateAuthenticator(), auth.NewAPIKeyAuthenticator(auth.NewMemoryKeyStore())}
+	srv := httptest.NewUnstartedServer(SetupRoutes(deps.handler))
+	srv.TLS = tlsFiles.Config()
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	post := func(cert *tls.Certificate) *http.Response {
+		tlsConfig := &tls.Config{RootCAs: serverCA.Pool()}
+		if cert != nil {
+			tlsConfig.Certificates = []tls.Certificate{*cert}
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+		t.Cleanup(client.CloseIdleConnections)
+		resp, err := client.Post(srv.URL+"/echo", "application/json", strings.NewReader(`{"text_to_echo": "over mTLS"}`))
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	resp := post(nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, `ApiKey realm="TestService"`, resp.Header.Get("WWW-Authenticate"))
+
+	cert := clientCA.ClientCert(t, "billing", "spiffe://example.org/ns/prod/sa/billing")
+	assert.Equal(t, http.StatusOK, post(&cert).StatusCode)
+	page, err := deps.messages.List(t.Context(), store.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, page.Messages, 1)
+	assert.Equal(t, "cert:spiffe://example.org/ns/prod/sa/billing", page.Messages[0].Caller, "the SPIFFE ID is the caller")
+}
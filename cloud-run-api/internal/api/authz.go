@@ -0,0 +1,64 @@
+// internal/api/authz.go
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"your-module-name/internal/audit"
+	"your-module-name/internal/auth"
+	"your-module-name/internal/authz"
+	"your-module-name/internal/config"
+)
+
+// withPermission requires the principal authenticated by withAuth to hold
+// permission under h.Authz, answering 403 otherwise. An empty permission
+// only requires authentication. Anonymous requests, which withAuth lets
+// through when authentication is optional, are evaluated as a principal
+// holding only the anonymous role, so dropping a credential never gains
+// permissions. With authentication disabled, nothing is checked.
+//
+// With AUTHZ_MODE=audit, requests that would be denied are logged and
+// served, so a new policy can be tried against real traffic.
+func (h *Handler) withPermission(permission string, next http.Handler) http.Handler {
+	if permission == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.FromContext(r.Context())
+		if !ok {
+			if h.Auth == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			principal = auth.Principal{ID: auth.RoleAnonymous, Roles: []string{auth.RoleAnonymous}}
+		}
+		resource := r.Method + " " + r.URL.Path
+		decision := h.policy().Evaluate(principal, permission)
+		if decision.Allowed {
+			if decision.Reason == authz.ReasonRole && strings.HasPrefix(decision.Grant, auth.RoleAdmin+"=") {
+				h.audit(r, audit.Event{Action: audit.ActionAdminRequest, Outcome: audit.OutcomeSuccess, Resource: resource, Reason: permission + " granted by " + decision.Grant})
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		attrs := []any{"principal", principal.ID, "roles", principal.Roles, "scopes", principal.Scopes, "permission", permission, "method", r.Method, "path", r.URL.Path}
+		if h.AppConfig.AuthzMode == config.AuthzModeAudit {
+			h.Logger.WarnContext(r.Context(), "Authorization would deny request; allowed in audit mode", attrs...)
+			h.audit(r, audit.Event{Action: audit.ActionAuthorize, Outcome: audit.OutcomeDenied, Resource: resource, Reason: "lacks " + permission + "; allowed in audit mode"})
+			next.ServeHTTP(w, r)
+			return
+		}
+		h.Logger.WarnContext(r.Context(), "Authorization denied request", attrs...)
+		h.audit(r, audit.Event{Action: audit.ActionAuthorize, Outcome: audit.OutcomeDenied, Resource: resource, Reason: "lacks " + permission})
+		writeProblem(w, r, http.StatusForbidden, "Credentials lack the "+permission+" permission")
+	})
+}
+
+// policy returns the authorization policy in effect.
+func (h *Handler) policy() *authz.Policy {
+	if h.Authz == nil {
+		return authz.DefaultPolicy()
+	}
+	return h.Authz.Policy()
+}
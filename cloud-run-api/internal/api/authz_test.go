@@ -0,0 +1,98 @@
+// internal/api/authz_test.go
+package api
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"your-module-name/internal/auth"
+	"your-module-name/internal/authz"
+	"your-module-name/internal/config"
+)
+
+// principalAuthenticator authenticates every request with credentials as
+// the principal in principals named by the X-Test-Principal header.
+type principalAuthenticator map[string]auth.Principal
+
+func (a principalAuthenticator) Authenticate(r *http.Request) (auth.Principal, error) {
+	name := r.Header.Get("X-Test-Principal")
+	if name == "" {
+		return auth.Principal{}, auth.ErrNoCredentials
+	}
+	p, ok := a[name]
+	if !ok {
+		return auth.Principal{}, auth.ErrInvalidCredentials
+	}
+	return p, nil
+}
+
+func (a principalAuthenticator) Challenge(string) string { return "" }
+
+func TestWithPermission(t *testing.T) {
+	principals := principalAuthenticator{
+		"viewer":   {ID: "oidc:viewer", Roles: []string{"viewer"}},
+		"operator": {ID: "oidc:operator", Roles: []string{"operator"}},
+		"service":  {ID: "google:caller", Roles: []string{auth.RoleService}},
+	}
+	policy := &authz.Policy{Roles: map[string][]string{
+		"viewer":   {authz.PermHelloRead, authz.PermMessagesRead},
+		"operator": {"messages:*", authz.PermEchoWrite},
+	}}
+	newRouter := func(mode string) (http.Handler, *bytes.Buffer) {
+		deps := newTestDeps(t, config.Config{ServiceName: "TestService", RuntimeMode: config.RuntimeModeLocal, AuthRequired: true, AuthzMode: mode})
+		var logs bytes.Buffer
+		deps.handler.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+		deps.handler.Auth = principals
+		deps.handler.Authz = policy
+		return SetupRoutes(deps.handler), &logs
+	}
+	do := func(router http.Handler, method, target, principal string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(`{"text_to_echo": "authorized"}`))
+		req.Header.Set("X-Test-Principal", principal)
+		return serve(router, req)
+	}
+
+	t.Run("Enforce", func(t *testing.T) {
+		router, logs := newRouter(config.AuthzModeEnforce)
+		assert.Equal(t, http.StatusOK, do(router, http.MethodGet, "/hello", "viewer").Code)
+		assert.Equal(t, http.StatusOK, do(router, http.MethodGet, "/messages", "viewer").Code)
+		assert.Equal(t, http.StatusOK, do(router, http.MethodPost, "/echo", "operator").Code)
+		assert.Equal(t, http.StatusNotFound, do(router, http.MethodDelete, "/messages/missing", "operator").Code, "operator may delete")
+
+		rr := do(router, http.MethodPost, "/echo", "viewer")
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+		p := decodeProblem(t, rr)
+		assert.Equal(t, "Credentials lack the echo:write permission", p.Detail)
+		assert.Contains(t, logs.String(), "Authorization denied request")
+		assert.Contains(t, logs.String(), "principal=oidc:viewer")
+
+		assert.Equal(t, http.StatusForbidden, do(router, http.MethodGet, "/hello", "operator").Code)
+		assert.Equal(t, http.StatusForbidden, do(router, http.MethodGet, "/hello", "service").Code, "the file policy replaces the default")
+		assert.Equal(t, http.StatusOK, do(router, http.MethodGet, "/version", "service").Code, "version only requires authentication")
+	})
+
+	t.Run("Audit", func(t *testing.T) {
+		router, logs := newRouter(config.AuthzModeAudit)
+		rr := do(router, http.MethodPost, "/echo", "viewer")
+		assert.Equal(t, http.StatusOK, rr.Code, "audit mode serves the request")
+		assert.Contains(t, logs.String(), "Authorization would deny request")
+		assert.Contains(t, logs.String(), "permission=echo:write")
+
+		rr = do(router, http.MethodGet, "/hello", "unknown")
+		assert.Equal(t, http.StatusUnauthorized, rr.Code, "audit mode still authenticates")
+	})
+
+	t.Run("Default policy", func(t *testing.T) {
+		deps := newTestDeps(t, config.Config{ServiceName: "TestService", RuntimeMode: config.RuntimeModeLocal, AuthRequired: true})
+		deps.handler.Auth = principals
+		router := SetupRoutes(deps.handler)
+		assert.Equal(t, http.StatusOK, do(router, http.MethodPost, "/echo", "service").Code)
+		assert.Equal(t, http.StatusForbidden, do(router, http.MethodGet, "/hello", "viewer").Code)
+	})
+}
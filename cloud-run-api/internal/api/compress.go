@@ -0,0 +1,406 @@
+// internal/api/compress.go
+package api
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// defaultCompressionMinBytes applies when COMPRESSION_MIN_BYTES is unset,
+	// as in handlers built from a zero config.Config. Smaller bodies gain
+	// little and can even grow.
+	defaultCompressionMinBytes = 1024
+	// defaultMaxRequestBodyBytes applies when MAX_REQUEST_BODY_BYTES is unset.
+	defaultMaxRequestBodyBytes = 1 << 20
+	// brotliLevel trades ratio for speed; the default level 6 is meant for
+	// static assets compressed once.
+	brotliLevel = 4
+)
+
+// contentEncoding is a content coding (RFC 9110, section 8.4.1) the service
+// can compress responses with and decompress request bodies with.
+type contentEncoding struct {
+	name      string
+	writers   sync.Pool // of encodingWriter
+	newWriter func() encodingWriter
+	newReader func(io.Reader) (io.ReadCloser, error)
+}
+
+// encodingWriter is implemented by the gzip, zstd and brotli writers.
+type encodingWriter interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+// contentEncodings are the supported content codings in order of
+// preference: zstd and brotli compress better than gzip at similar speed.
+var contentEncodings = []*contentEncoding{
+	{
+		name: "zstd",
+		newWriter: func() encodingWriter {
+			// NewWriter only fails on invalid options.
+			w, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+			return w
+		},
+		newReader: func(r io.Reader) (io.ReadCloser, error) {
+			d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1), zstd.WithDecoderLowmem(true))
+			if err != nil {
+				return nil, err
+			}
+			return d.IOReadCloser(), nil
+		},
+	},
+	{
+		name:      "br",
+		newWriter: func() encodingWriter { return brotli.NewWriterLevel(nil, brotliLevel) },
+		newReader: func(r io.Reader) (io.ReadCloser, error) { return io.NopCloser(brotli.NewReader(r)), nil },
+	},
+	{
+		name:      "gzip",
+		newWriter: func() encodingWriter { return gzip.NewWriter(nil) },
+		newReader: func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+	},
+}
+
+// lookupEncoding returns the supported content coding called name.
+func lookupEncoding(name string) (*contentEncoding, bool) {
+	for _, e := range contentEncodings {
+		if e.name == name {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+func (e *contentEncoding) getWriter(w io.Writer) encodingWriter {
+	ew, ok := e.writers.Get().(encodingWriter)
+	if !ok {
+		ew = e.newWriter()
+	}
+	ew.Reset(w)
+	return ew
+}
+
+func (e *contentEncoding) putWriter(ew encodingWriter) {
+	ew.Reset(nil)
+	e.writers.Put(ew)
+}
+
+// negotiateEncoding returns the supported content coding with the highest
+// q-value in an Accept-Encoding header, ties going to the preferred one, or
+// nil to send the response unencoded: when there is no acceptable coding or
+// identity is explicitly preferred.
+func negotiateEncoding(header string) *contentEncoding {
+	if header == "" {
+		return nil
+	}
+	qualities := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(name, "q") {
+				if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		qualities[coding] = q
+	}
+
+	var best *contentEncoding
+	bestQ := 0.0
+	for _, e := range contentEncodings {
+		q, ok := qualities[e.name]
+		if !ok {
+			q = qualities["*"]
+		}
+		if q > bestQ {
+			best, bestQ = e, q
+		}
+	}
+	if identityQ, ok := qualities["identity"]; ok && identityQ > bestQ {
+		return nil
+	}
+	return best
+}
+
+// compressible reports whether a response with the given Content-Type is
+// worth compressing. Media types that are compressed already are not.
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	major, minor, _ := strings.Cut(mediaType, "/")
+	switch major {
+	case "text":
+		return true
+	case "image":
+		return minor == "svg+xml"
+	case "video", "audio", "font":
+		return false
+	}
+	switch mediaType {
+	case "application/octet-stream", "application/pdf", "application/zip", "application/gzip",
+		"application/x-gzip", "application/zstd", "application/x-brotli", "application/x-7z-compressed":
+		return false
+	}
+	return true
+}
+
+// encodedETag derives the entity tag of the encoded representation from the
+// one of the unencoded representation, as "tag" becomes "tag-gzip".
+func encodedETag(etag string, e *contentEncoding) string {
+	if !strings.HasSuffix(etag, `"`) || len(etag) < 2 {
+		return etag
+	}
+	return etag[:len(etag)-1] + "-" + e.name + `"`
+}
+
+// decodeETagHeader removes content coding suffixes added by encodedETag from
+// the entity tags in a conditional request header, so that handlers compare
+// them with the tags of their unencoded responses.
+func decodeETagHeader(h http.Header, name string) {
+	value := h.Get(name)
+	if value == "" {
+		return
+	}
+	for _, e := range contentEncodings {
+		value = strings.ReplaceAll(value, "-"+e.name+`"`, `"`)
+	}
+	h.Set(name, value)
+}
+
+// withCompression compresses response bodies of next with the content
+// coding negotiated from Accept-Encoding.
+//
+// Responses are compressed once their body reaches COMPRESSION_MIN_BYTES or
+// the handler flushes, so streamed responses are compressed too. Responses
+// that are already encoded, marked no-transform, or of media types that are
+// compressed already pass through. Compressible responses carry
+// Vary: Accept-Encoding whether or not they were compressed, and compressed
+// ones an ETag with the coding appended; such ETags are accepted back in
+// If-None-Match and If-Match.
+func (h *Handler) withCompression(next http.Handler) http.Handler {
+	minBytes := h.AppConfig.CompressionMinBytes
+	if minBytes <= 0 {
+		minBytes = defaultCompressionMinBytes
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cw := &compressWriter{
+			ResponseWriter: w,
+			minBytes:       minBytes,
+			ifNoneMatch:    r.Header.Get("If-None-Match"),
+		}
+		if r.Method != http.MethodHead {
+			cw.encoding = negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		}
+		decodeETagHeader(r.Header, "If-None-Match")
+		decodeETagHeader(r.Header, "If-Match")
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// compressWriter buffers the start of a response body until it knows
+// whether to compress it.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    *contentEncoding // Nil when the request accepts no coding.
+	minBytes    int
+	ifNoneMatch string // As sent, with content coding suffixes.
+
+	status  int // Zero until WriteHeader.
+	decided bool
+	buf     []byte
+	ew      encodingWriter // Non-nil while compressing.
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.status != 0 {
+		return
+	}
+	if status < http.StatusOK {
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	cw.status = status
+
+	h := cw.Header()
+	eligible := h.Get("Content-Encoding") == "" &&
+		!strings.Contains(h.Get("Cache-Control"), "no-transform") &&
+		(status == http.StatusNotModified || compressible(h.Get("Content-Type")))
+	if eligible {
+		h.Add("Vary", "Accept-Encoding")
+	}
+	if status == http.StatusNotModified && eligible && cw.encoding != nil {
+		// Keep the ETag of the representation the client has cached.
+		if etag := encodedETag(h.Get("ETag"), cw.encoding); strings.Contains(cw.ifNoneMatch, strings.TrimPrefix(etag, "W/")) {
+			h.Set("ETag", etag)
+		}
+	}
+
+	switch {
+	case !eligible || cw.encoding == nil || status == http.StatusNoContent || status == http.StatusNotModified:
+		cw.passThrough()
+	case h.Get("Content-Length") != "":
+		if n, err := strconv.Atoi(h.Get("Content-Length")); err == nil && n < cw.minBytes {
+			cw.passThrough()
+		}
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if cw.status == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.decided {
+		if cw.ew != nil {
+			return cw.ew.Write(b)
+		}
+		return cw.ResponseWriter.Write(b)
+	}
+	cw.buf = append(cw.buf, b...)
+	if len(cw.buf) >= cw.minBytes {
+		if err := cw.startCompression(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush sends what has been written so far, compressing it unless the
+// response has already been sent unencoded.
+func (cw *compressWriter) Flush() {
+	if cw.status == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.decided {
+		if err := cw.startCompression(); err != nil {
+			return
+		}
+	}
+	if cw.ew != nil {
+		if err := cw.ew.Flush(); err != nil {
+			return
+		}
+	}
+	_ = http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+func (cw *compressWriter) passThrough() {
+	cw.decided = true
+	cw.ResponseWriter.WriteHeader(cw.status)
+}
+
+func (cw *compressWriter) startCompression() error {
+	cw.decided = true
+	h := cw.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Encoding", cw.encoding.name)
+	if etag := h.Get("ETag"); etag != "" {
+		h.Set("ETag", encodedETag(etag, cw.encoding))
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+	cw.ew = cw.encoding.getWriter(cw.ResponseWriter)
+	buf := cw.buf
+	cw.buf = nil
+	_, err := cw.ew.Write(buf)
+	return err
+}
+
+// close sends a buffered body too small to compress, or finishes the
+// compressed stream.
+func (cw *compressWriter) close() {
+	switch {
+	case cw.status == 0:
+		// Nothing was written; net/http sends an empty 200.
+	case !cw.decided:
+		cw.passThrough()
+		if len(cw.buf) > 0 {
+			_, _ = cw.ResponseWriter.Write(cw.buf)
+		}
+	case cw.ew != nil:
+		_ = cw.ew.Close()
+		cw.encoding.putWriter(cw.ew)
+		cw.ew = nil
+	}
+}
+
+// withRequestDecoding limits request bodies to MAX_REQUEST_BODY_BYTES and
+// decompresses bodies sent with a supported Content-Encoding. The limit also
+// applies to the decompressed body, so a small compressed body cannot expand
+// into an unbounded one; reading past it fails with *http.MaxBytesError.
+// Other content codings get 415 with the supported ones in an
+// Accept-Encoding header (RFC 7694).
+func (h *Handler) withRequestDecoding(next http.Handler) http.Handler {
+	limit := int64(h.AppConfig.MaxRequestBodyBytes)
+	if limit <= 0 {
+		limit = defaultMaxRequestBodyBytes
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		coding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+		if coding == "" || coding == "identity" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		e, ok := lookupEncoding(coding)
+		if !ok {
+			var names []string
+			for _, e := range contentEncodings {
+				names = append(names, e.name)
+			}
+			w.Header().Set("Accept-Encoding", strings.Join(names, ", "))
+			writeProblem(w, r, http.StatusUnsupportedMediaType, "Supported content codings are "+strings.Join(names, ", "))
+			return
+		}
+		decoded, err := e.newReader(r.Body)
+		if err != nil {
+			r.Body.Close()
+			writeProblem(w, r, http.StatusBadRequest, "Request body is not valid "+e.name)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, decodedBody{Reader: decoded, decoder: decoded, body: r.Body}, limit)
+		r.Header.Del("Content-Encoding")
+		r.Header.Del("Content-Length")
+		r.ContentLength = -1
+		next.ServeHTTP(w, r)
+	})
+}
+
+// decodedBody reads a decompressed request body and closes both the
+// decompressor and the original body.
+type decodedBody struct {
+	io.Reader
+	decoder io.Closer
+	body    io.Closer
+}
+
+func (b decodedBody) Close() error {
+	b.decoder.Close()
+	return b.body.Close()
+}
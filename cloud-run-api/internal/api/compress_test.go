@@ -0,0 +1,257 @@
+// internal/api/compress_test.go
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"your-module-name/internal/config"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string // "" for identity
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"gzip, deflate, br", "br"},
+		{"gzip, deflate, br, zstd", "zstd"},
+		{"GZIP;q=0.5, br;q=0.8", "br"},
+		{"zstd;q=0, gzip", "gzip"},
+		{"*", "zstd"},
+		{"*;q=0.1, gzip;q=0.5", "gzip"},
+		{"deflate, compress", ""},
+		{"gzip;q=0.5, identity", ""},
+		{"gzip, identity;q=0.5", "gzip"},
+	}
+	for _, tt := range tests {
+		got := ""
+		if e := negotiateEncoding(tt.header); e != nil {
+			got = e.name
+		}
+		assert.Equal(t, tt.want, got, "Accept-Encoding: %q", tt.header)
+	}
+}
+
+func TestCompressible(t *testing.T) {
+	for contentType, want := range map[string]bool{
+		"application/json":                true,
+		"application/problem+json":        true,
+		"text/plain; charset=utf-8":       true,
+		"application/cbor":                true,
+		"image/svg+xml":                   true,
+		"image/png":                       false,
+		"video/mp4":                       false,
+		"application/zip":                 false,
+		"application/gzip":                false,
+		"application/octet-stream":        false,
+		"":                                false,
+		"not a media type; charset=utf-8": false,
+	} {
+		assert.Equal(t, want, compressible(contentType), contentType)
+	}
+}
+
+// decompress decodes body with a content coding.
+func decompress(t *testing.T, coding string, body []byte) string {
+	t.Helper()
+	e, ok := lookupEncoding(coding)
+	require.True(t, ok, coding)
+	r, err := e.newReader(bytes.NewReader(body))
+	require.NoError(t, err)
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(b)
+}
+
+// compress encodes s with a content coding.
+func compress(t *testing.T, coding, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch coding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	case "zstd":
+		var err error
+		w, err = zstd.NewWriter(&buf)
+		require.NoError(t, err)
+	}
+	_, err := io.WriteString(w, s)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestWithCompression(t *testing.T) {
+	h := newTestDeps(t, config.Config{CompressionMinBytes: 100}).handler
+	body := strings.Repeat("compress me ", 50)
+	serveWith := func(contentType, body string, req *http.Request) *httptest.ResponseRecorder {
+		return serve(h.withCompression(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			w.Header().Set("ETag", `"v1"`)
+			io.WriteString(w, body)
+		})), req)
+	}
+	get := func(acceptEncoding string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		return req
+	}
+
+	t.Run("Codings", func(t *testing.T) {
+		for _, coding := range []string{"gzip", "br", "zstd"} {
+			rr := serveWith("text/plain", body, get(coding))
+			assert.Equal(t, coding, rr.Header().Get("Content-Encoding"))
+			assert.Equal(t, "Accept-Encoding", rr.Header().Get("Vary"))
+			assert.Equal(t, `"v1-`+coding+`"`, rr.Header().Get("ETag"))
+			assert.Empty(t, rr.Header().Get("Content-Length"))
+			assert.Less(t, rr.Body.Len(), len(body))
+			assert.Equal(t, body, decompress(t, coding, rr.Body.Bytes()))
+		}
+	})
+
+	t.Run("Not accepted", func(t *testing.T) {
+		rr := serveWith("text/plain", body, get(""))
+		assert.Empty(t, rr.Header().Get("Content-Encoding"))
+		assert.Equal(t, "Accept-Encoding", rr.Header().Get("Vary"))
+		assert.Equal(t, `"v1"`, rr.Header().Get("ETag"))
+		assert.Equal(t, body, rr.Body.String())
+	})
+
+	t.Run("Small body", func(t *testing.T) {
+		rr := serveWith("application/json", `{"ok":true}`, get("gzip"))
+		assert.Empty(t, rr.Header().Get("Content-Encoding"))
+		assert.Equal(t, "Accept-Encoding", rr.Header().Get("Vary"))
+		assert.Equal(t, `{"ok":true}`, rr.Body.String())
+	})
+
+	t.Run("Incompressible media type", func(t *testing.T) {
+		rr := serveWith("image/png", body, get("gzip"))
+		assert.Empty(t, rr.Header().Get("Content-Encoding"))
+		assert.Empty(t, rr.Header().Get("Vary"))
+		assert.Equal(t, body, rr.Body.String())
+	})
+
+	t.Run("Already encoded", func(t *testing.T) {
+		encoded := string(compress(t, "gzip", body))
+		rr := serve(h.withCompression(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			w.Header().Set("Content-Encoding", "gzip")
+			io.WriteString(w, encoded)
+		})), get("zstd"))
+		assert.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+		assert.Equal(t, encoded, rr.Body.String())
+	})
+
+	t.Run("Streaming", func(t *testing.T) {
+		flushed := make(chan string, 1)
+		rr := httptest.NewRecorder()
+		h.withCompression(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: first\n\n")
+			http.NewResponseController(w).Flush()
+			// What the client has received so far decodes to the first event.
+			zr, err := gzip.NewReader(bytes.NewReader(rr.Body.Bytes()))
+			require.NoError(t, err)
+			first := make([]byte, len("data: first\n\n"))
+			_, err = io.ReadFull(zr, first)
+			require.NoError(t, err)
+			flushed <- string(first)
+			io.WriteString(w, "data: second\n\n")
+		})).ServeHTTP(rr, get("gzip"))
+
+		assert.True(t, rr.Flushed)
+		assert.Equal(t, "data: first\n\n", <-flushed)
+		assert.Equal(t, "data: first\n\ndata: second\n\n", decompress(t, "gzip", rr.Body.Bytes()))
+	})
+}
+
+func TestCompressionRoutes(t *testing.T) {
+	deps := newTestDeps(t, config.Config{ServiceName: "TestService", RuntimeMode: config.RuntimeModeLocal})
+	seedMessages(t, deps.messages, 40)
+	router := SetupRoutes(deps.handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/messages", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := serve(router, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+	assert.Contains(t, rr.Header().Values("Vary"), "Accept")
+	assert.Contains(t, rr.Header().Values("Vary"), "Accept-Encoding")
+	assert.Contains(t, decompress(t, "gzip", rr.Body.Bytes()), `"messages"`)
+	etag := rr.Header().Get("ETag")
+	require.True(t, strings.HasSuffix(etag, `-gzip"`), etag)
+
+	// The encoded ETag revalidates the cached encoded representation.
+	req = httptest.NewRequest(http.MethodGet, "/messages", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("If-None-Match", etag)
+	rr = serve(router, req)
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+	assert.Equal(t, etag, rr.Header().Get("ETag"))
+	assert.Zero(t, rr.Body.Len())
+}
+
+func TestWithRequestDecoding(t *testing.T) {
+	deps := newTestDeps(t, config.Config{ServiceName: "TestService", RuntimeMode: config.RuntimeModeLocal, MaxRequestBodyBytes: 1024})
+	router := SetupRoutes(deps.handler)
+	post := func(coding string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/echo", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Encoding", coding)
+		return serve(router, req)
+	}
+
+	for _, coding := range []string{"gzip", "br", "zstd"} {
+		rr := post(coding, compress(t, coding, `{"text_to_echo": "squeezed"}`))
+		require.Equal(t, http.StatusOK, rr.Code, coding+": "+rr.Body.String())
+		assert.Contains(t, rr.Body.String(), `"received_text":"squeezed"`)
+	}
+
+	t.Run("Unsupported coding", func(t *testing.T) {
+		rr := post("compress", []byte(`{"text_to_echo": "x"}`))
+		assert.Equal(t, http.StatusUnsupportedMediaType, rr.Code)
+		assert.Equal(t, "zstd, br, gzip", rr.Header().Get("Accept-Encoding"))
+		decodeProblem(t, rr)
+	})
+
+	t.Run("Corrupt body", func(t *testing.T) {
+		rr := post("gzip", []byte("not gzip at all"))
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("Decompression bomb", func(t *testing.T) {
+		// A few hundred bytes that expand far beyond the limit.
+		bomb := compress(t, "gzip", `{"text_to_echo": "`+strings.Repeat("a", 256<<10)+`"}`)
+		require.Less(t, len(bomb), 1024)
+		rr := post("gzip", bomb)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+		assert.Contains(t, decodeProblem(t, rr).Detail, "1024 bytes")
+
+		req := httptest.NewRequest(http.MethodPost, "/echo", bytes.NewReader(bomb))
+		req.Header.Set("Content-Encoding", "gzip")
+		req.Header.Set(IdempotencyKeyHeader, "bomb")
+		rr = serve(router, req)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code, "idempotent requests are bounded too")
+	})
+
+	t.Run("Uncompressed body", func(t *testing.T) {
+		rr := post("", []byte(`{"text_to_echo": "`+strings.Repeat("a", 2048)+`"}`))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	})
+}
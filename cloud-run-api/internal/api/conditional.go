@@ -0,0 +1,188 @@
+// internal/api/conditional.go
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"your-module-name/internal/codec"
+)
+
+// CachePolicy is the Cache-Control policy of a route.
+type CachePolicy struct {
+	// Private responses may only be stored by the client, not shared caches.
+	Private bool
+	// NoCache requires caches to revalidate with the server before every
+	// reuse; with an ETag that is a cheap 304.
+	NoCache bool
+	// MaxAge is how long a response is fresh.
+	MaxAge time.Duration
+}
+
+// String renders the policy as a Cache-Control header value.
+func (p CachePolicy) String() string {
+	parts := []string{"public"}
+	if p.Private {
+		parts[0] = "private"
+	}
+	if p.NoCache {
+		parts = append(parts, "no-cache")
+	}
+	parts = append(parts, "max-age="+strconv.Itoa(int(p.MaxAge/time.Second)))
+	return strings.Join(parts, ", ")
+}
+
+// withConditional makes GET and HEAD responses of next cacheable and
+// conditional.
+//
+// Successful responses get the Cache-Control header of policy and an ETag:
+// the one set by next, or a strong ETag over the body. Requests whose
+// If-None-Match matches the ETag, or, without If-None-Match, whose
+// If-Modified-Since is not before the Last-Modified set by next, get 304
+// Not Modified without a body. Other methods pass through.
+func withConditional(policy CachePolicy, next http.Handler) http.Handler {
+	cacheControl := policy.String()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		buf := &bufferingWriter{ResponseWriter: w}
+		next.ServeHTTP(buf, r)
+		if buf.status == 0 {
+			buf.status = http.StatusOK
+		}
+		if buf.status != http.StatusOK {
+			buf.flush()
+			return
+		}
+
+		h := w.Header()
+		h.Set("Cache-Control", cacheControl)
+		etag := h.Get("ETag")
+		if etag == "" {
+			etag = strongETag(buf.body.Bytes())
+			h.Set("ETag", etag)
+		}
+
+		if notModified(r, etag, h.Get("Last-Modified")) {
+			h.Del("Content-Type")
+			h.Del("Content-Length")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		buf.flush()
+	})
+}
+
+// notModified evaluates If-None-Match and If-Modified-Since (RFC 9110
+// section 13.2.2) for a response with the given validators.
+func notModified(r *http.Request, etag, lastModified string) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagListMatches(inm, etag, false)
+	}
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || lastModified == "" {
+		return false
+	}
+	modified, err := http.ParseTime(lastModified)
+	return err == nil && !modified.After(ims)
+}
+
+// checkIfMatch evaluates the If-Match precondition of a request that changes
+// a resource whose current representations have the given ETags. It reports
+// true if there is no precondition or it holds for one of them.
+func checkIfMatch(r *http.Request, etags ...string) bool {
+	im := r.Header.Get("If-Match")
+	if im == "" {
+		return true
+	}
+	for _, etag := range etags {
+		if etagListMatches(im, etag, true) {
+			return true
+		}
+	}
+	return false
+}
+
+// etagListMatches reports whether etag is in list, a comma-separated list of
+// entity tags or "*". Strong comparison never matches weak tags; weak
+// comparison ignores the W/ prefix.
+func etagListMatches(list, etag string, strong bool) bool {
+	if strings.TrimSpace(list) == "*" {
+		return true
+	}
+	if strong && strings.HasPrefix(etag, "W/") {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if strings.HasPrefix(candidate, "W/") {
+			if strong {
+				continue
+			}
+			candidate = candidate[2:]
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// strongETag returns a strong entity tag for a representation.
+func strongETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + base64.RawURLEncoding.EncodeToString(sum[:12]) + `"`
+}
+
+// weakETag returns a weak entity tag over parts, for representations that
+// are equivalent but not byte-for-byte identical.
+func weakETag(parts ...string) string {
+	return "W/" + strongETag([]byte(strings.Join(parts, "\x00")))
+}
+
+// mediaETag derives the entity tag of the representation in media type c
+// from the media-independent one, as "tag" becomes "tag-xml". Each media type
+// is a different representation, so a tag cached from a JSON response does
+// not revalidate an XML one.
+func mediaETag(etag string, c codec.Codec) string {
+	if !strings.HasSuffix(etag, `"`) || len(etag) < 2 {
+		return etag
+	}
+	subtype := c.MediaType()[strings.IndexByte(c.MediaType(), '/')+1:]
+	return etag[:len(etag)-1] + "-" + subtype + `"`
+}
+
+// bufferingWriter holds a response back until flush.
+type bufferingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferingWriter) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *bufferingWriter) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(p)
+}
+
+// flush writes the held response.
+func (b *bufferingWriter) flush() {
+	b.ResponseWriter.WriteHeader(b.status)
+	_, _ = b.ResponseWriter.Write(b.body.Bytes())
+}
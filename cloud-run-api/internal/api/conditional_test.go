@@ -0,0 +1,160 @@
+// internal/api/conditional_test.go
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"your-module-name/internal/codec"
+	"your-module-name/internal/config"
+)
+
+func TestCachePolicy_String(t *testing.T) {
+	assert.Equal(t, "public, max-age=300", CachePolicy{MaxAge: 5 * time.Minute}.String())
+	assert.Equal(t, "private, no-cache, max-age=0", CachePolicy{Private: true, NoCache: true}.String())
+}
+
+func TestETagListMatches(t *testing.T) {
+	tests := []struct {
+		list, etag string
+		strong     bool
+		want       bool
+	}{
+		{`"a"`, `"a"`, true, true},
+		{`"b", "a"`, `"a"`, true, true},
+		{`"b"`, `"a"`, false, false},
+		{`*`, `"a"`, true, true},
+		{`W/"a"`, `"a"`, false, true},
+		{`W/"a"`, `"a"`, true, false},
+		{`"a"`, `W/"a"`, false, true},
+		{`"a"`, `W/"a"`, true, false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, etagListMatches(tt.list, tt.etag, tt.strong), "%s vs %s strong=%v", tt.list, tt.etag, tt.strong)
+	}
+}
+
+func TestWithConditional(t *testing.T) {
+	lastModified := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	status := http.StatusOK
+	h := withConditional(CachePolicy{MaxAge: time.Minute}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+		w.WriteHeader(status)
+		w.Write([]byte(`{"a":1}`))
+	}))
+	get := func(header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		for i := 0; i < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get()
+	require.Equal(t, http.StatusOK, rr.Code)
+	etag := rr.Header().Get("ETag")
+	assert.Regexp(t, `^"[A-Za-z0-9_-]+"$`, etag)
+	assert.Equal(t, "public, max-age=60", rr.Header().Get("Cache-Control"))
+	assert.Equal(t, `{"a":1}`, rr.Body.String())
+	assert.Equal(t, etag, get().Header().Get("ETag"), "the ETag is stable")
+
+	rr = get("If-None-Match", `"other", `+etag)
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+	assert.Empty(t, rr.Body.String())
+	assert.Equal(t, etag, rr.Header().Get("ETag"))
+	assert.Equal(t, "public, max-age=60", rr.Header().Get("Cache-Control"))
+	assert.Empty(t, rr.Header().Get("Content-Type"))
+
+	assert.Equal(t, http.StatusNotModified, get("If-None-Match", "W/"+etag).Code, "If-None-Match uses weak comparison")
+	assert.Equal(t, http.StatusOK, get("If-None-Match", `"other"`).Code)
+
+	assert.Equal(t, http.StatusNotModified, get("If-Modified-Since", lastModified.Format(http.TimeFormat)).Code)
+	assert.Equal(t, http.StatusOK, get("If-Modified-Since", lastModified.Add(-time.Second).Format(http.TimeFormat)).Code)
+	assert.Equal(t, http.StatusOK, get("If-None-Match", `"other"`, "If-Modified-Since", lastModified.Format(http.TimeFormat)).Code,
+		"If-Modified-Since is ignored when If-None-Match is present")
+
+	status = http.StatusNotFound
+	rr = get("If-None-Match", "*")
+	assert.Equal(t, http.StatusNotFound, rr.Code, "errors are never 304")
+	assert.Empty(t, rr.Header().Get("ETag"))
+	assert.Empty(t, rr.Header().Get("Cache-Control"))
+}
+
+func TestConditionalRoutes(t *testing.T) {
+	deps := newTestDeps(t, config.Config{ServiceName: "TestService", RuntimeMode: config.RuntimeModeLocal})
+	seedMessages(t, deps.messages, 3)
+	router := SetupRoutes(deps.handler)
+	do := func(method, target string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		for i := 0; i < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Hello", func(t *testing.T) {
+		first := do(http.MethodGet, "/hello")
+		etag := first.Header().Get("ETag")
+		assert.Regexp(t, `^W/"`, etag)
+		assert.Equal(t, "private, max-age=60", first.Header().Get("Cache-Control"))
+		time.Sleep(time.Millisecond)
+		assert.Equal(t, etag, do(http.MethodGet, "/hello").Header().Get("ETag"), "the timestamp does not change the ETag")
+		assert.Equal(t, http.StatusNotModified, do(http.MethodGet, "/hello", "If-None-Match", etag).Code)
+		assert.Equal(t, http.StatusOK, do(http.MethodGet, "/hello", "If-None-Match", etag, "Accept", codec.XMLType).Code,
+			"a JSON ETag does not revalidate XML")
+	})
+
+	t.Run("Message", func(t *testing.T) {
+		rr := do(http.MethodGet, "/messages/m0")
+		require.Equal(t, http.StatusOK, rr.Code)
+		etag := rr.Header().Get("ETag")
+		assert.NotEmpty(t, etag)
+		assert.Equal(t, "Sun, 01 Jun 2025 12:00:00 GMT", rr.Header().Get("Last-Modified"))
+		assert.Equal(t, "private, no-cache, max-age=0", rr.Header().Get("Cache-Control"))
+		assert.Equal(t, http.StatusNotModified, do(http.MethodGet, "/messages/m0", "If-None-Match", etag).Code)
+		assert.Equal(t, http.StatusNotModified, do(http.MethodGet, "/messages/m0", "If-Modified-Since", "Sun, 01 Jun 2025 12:00:00 GMT").Code)
+
+		// Each media type is a representation with its own ETag.
+		xml := do(http.MethodGet, "/messages/m0", "If-None-Match", etag, "Accept", codec.XMLType)
+		require.Equal(t, http.StatusOK, xml.Code, "a JSON ETag does not revalidate XML")
+		assert.Equal(t, codec.XMLType, xml.Header().Get("Content-Type"))
+		xmlETag := xml.Header().Get("ETag")
+		assert.NotEqual(t, etag, xmlETag)
+		assert.Equal(t, http.StatusNotModified, do(http.MethodGet, "/messages/m0", "If-None-Match", xmlETag, "Accept", codec.XMLType).Code)
+		assert.Equal(t, http.StatusOK, do(http.MethodGet, "/messages/m0", "If-None-Match", xmlETag).Code)
+
+		list := do(http.MethodGet, "/messages")
+		listETag := list.Header().Get("ETag")
+		assert.Equal(t, http.StatusNotModified, do(http.MethodGet, "/messages", "If-None-Match", listETag).Code)
+
+		// If-Match protects deletes.
+		assert.Equal(t, http.StatusPreconditionFailed, do(http.MethodDelete, "/messages/m0", "If-Match", `"stale"`).Code)
+		assert.Equal(t, http.StatusPreconditionFailed, do(http.MethodDelete, "/messages/m0", "If-Match", "W/"+etag).Code, "If-Match uses strong comparison")
+		assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/messages/m0", "If-Match", etag).Code)
+		assert.Equal(t, http.StatusPreconditionFailed, do(http.MethodDelete, "/messages/m0", "If-Match", "*").Code, "deleted messages fail If-Match: *")
+		assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/messages/m1", "If-Match", "*").Code)
+		m2 := do(http.MethodGet, "/messages/m2", "Accept", codec.XMLType).Header().Get("ETag")
+		assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/messages/m2", "If-Match", m2).Code, "If-Match accepts the ETag of any representation")
+
+		assert.NotEqual(t, listETag, do(http.MethodGet, "/messages").Header().Get("ETag"), "the list ETag changes with its contents")
+	})
+
+	t.Run("Version", func(t *testing.T) {
+		rr := do(http.MethodGet, "/version")
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), `"service":"TestService"`)
+		assert.Contains(t, rr.Body.String(), `"go_version":"go`)
+		assert.Equal(t, "public, max-age=300", rr.Header().Get("Cache-Control"))
+		assert.Equal(t, http.StatusNotModified, do(http.MethodGet, "/version", "If-None-Match", rr.Header().Get("ETag")).Code)
+	})
+}
@@ -0,0 +1,72 @@
+// internal/api/cors.go
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"your-module-name/internal/cors"
+)
+
+// CORS holds the cross-origin policies applied by withCORS.
+type CORS struct {
+	// Default applies to routes without an entry in Routes. Nil disables
+	// CORS.
+	Default *cors.Policy
+	// Routes holds policies by rule name, the route's permission. A nil
+	// policy disables CORS on the route.
+	Routes map[string]*cors.Policy
+}
+
+// forRule returns the policy of the route with the given rule name, or nil.
+func (c CORS) forRule(rule string) *cors.Policy {
+	if p, ok := c.Routes[rule]; ok {
+		return p
+	}
+	return c.Default
+}
+
+// withCORS applies the CORS policy of the route with the given rule name. It
+// answers preflight requests itself, before authentication and the route's
+// method check, since browsers send them without credentials; other requests
+// get the Access-Control-Allow-* headers if their origin is allowed. It is
+// the outermost route middleware, so that error responses carry the headers
+// too and browsers let scripts read them.
+func (h *Handler) withCORS(rule string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		policy := h.CORS.forRule(rule)
+		if policy == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if cors.IsPreflight(r) {
+			policy.Preflight(w, r)
+			return
+		}
+		policy.Apply(w.Header(), r.Header.Get("Origin"))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handlePreflight answers OPTIONS requests to a path whose routes are
+// registered per method, which the mux would otherwise answer with 405.
+// rules maps each method of the path to its route's rule name, so that a
+// preflight is answered with the policy of the route it asks about. Other
+// OPTIONS requests still get 405.
+func (h *Handler) handlePreflight(rules map[string]string) http.Handler {
+	methods := make([]string, 0, len(rules))
+	for method := range rules {
+		methods = append(methods, method)
+	}
+	slices.Sort(methods)
+	allow := strings.Join(methods, ", ")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rule, ok := rules[r.Header.Get("Access-Control-Request-Method")]
+		if policy := h.CORS.forRule(rule); ok && policy != nil && cors.IsPreflight(r) {
+			policy.Preflight(w, r)
+			return
+		}
+		methodNotAllowed(w, r, allow)
+	})
+}
@@ -0,0 +1,129 @@
+// internal/api/cors_test.go
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"your-module-name/internal/auth"
+	"your-module-name/internal/authz"
+	"your-module-name/internal/config"
+	"your-module-name/internal/cors"
+)
+
+func TestWithCORS(t *testing.T) {
+	policy := func(origins ...string) *cors.Policy {
+		p, err := cors.New(cors.Options{
+			AllowedOrigins: origins,
+			AllowedMethods: []string{http.MethodGet, http.MethodPost, http.MethodDelete},
+			AllowedHeaders: []string{"Authorization", "Content-Type", IdempotencyKeyHeader},
+			ExposedHeaders: []string{"ETag", IdempotentReplayedHeader},
+		})
+		require.NoError(t, err)
+		return p
+	}
+	deps := newTestDeps(t, config.Config{ServiceName: "TestService", RuntimeMode: config.RuntimeModeLocal, AuthRequired: true})
+	deps.handler.Auth = principalAuthenticator{"operator": {ID: "oidc:operator", Roles: []string{auth.RoleAdmin}}}
+	deps.handler.CORS = CORS{
+		Default: policy("https://app.example.com", "https://*.preview.example.com"),
+		Routes: map[string]*cors.Policy{
+			authz.PermMessagesWrite: policy("https://admin.example.com"),
+			authz.PermHelloRead:     nil,
+		},
+	}
+	router := SetupRoutes(deps.handler)
+	seedMessages(t, deps.messages, 1)
+
+	preflight := func(path, origin, method string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, path, nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", method)
+		req.Header.Set("Access-Control-Request-Headers", "authorization, content-type")
+		return serve(router, req)
+	}
+
+	t.Run("Preflight before authentication and method checks", func(t *testing.T) {
+		rr := preflight("/echo", "https://pr-7.preview.example.com", http.MethodPost)
+		assert.Equal(t, http.StatusNoContent, rr.Code)
+		assert.Equal(t, "https://pr-7.preview.example.com", rr.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "GET, POST, DELETE", rr.Header().Get("Access-Control-Allow-Methods"))
+		assert.Equal(t, "Authorization, Content-Type, Idempotency-Key", rr.Header().Get("Access-Control-Allow-Headers"))
+		assert.Contains(t, rr.Header().Values("Vary"), "Origin")
+
+		rr = preflight("/echo", "https://evil.test", http.MethodPost)
+		assert.Equal(t, http.StatusNoContent, rr.Code)
+		assert.Empty(t, rr.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("Preflight to method-qualified routes", func(t *testing.T) {
+		rr := preflight("/messages", "https://app.example.com", http.MethodGet)
+		assert.Equal(t, http.StatusNoContent, rr.Code)
+		assert.Equal(t, "https://app.example.com", rr.Header().Get("Access-Control-Allow-Origin"))
+
+		rr = preflight("/messages/m0", "https://app.example.com", http.MethodDelete)
+		assert.Equal(t, http.StatusNoContent, rr.Code)
+		assert.Empty(t, rr.Header().Get("Access-Control-Allow-Origin"), "DELETE has its own policy")
+		rr = preflight("/messages/m0", "https://admin.example.com", http.MethodDelete)
+		assert.Equal(t, "https://admin.example.com", rr.Header().Get("Access-Control-Allow-Origin"))
+
+		rr = preflight("/version", "https://app.example.com", http.MethodGet)
+		assert.Equal(t, "https://app.example.com", rr.Header().Get("Access-Control-Allow-Origin"))
+
+		rr = preflight("/messages", "https://app.example.com", http.MethodPut)
+		assert.Equal(t, http.StatusMethodNotAllowed, rr.Code, "no route to ask about")
+		rr = serve(router, httptest.NewRequest(http.MethodOptions, "/messages/m0", nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, rr.Code, "not a preflight")
+		assert.Equal(t, "DELETE, GET", rr.Header().Get("Allow"))
+	})
+
+	t.Run("Actual requests", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/messages", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		req.Header.Set("X-Test-Principal", "operator")
+		rr := serve(router, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "https://app.example.com", rr.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "ETag, Idempotent-Replayed", rr.Header().Get("Access-Control-Expose-Headers"))
+		assert.Contains(t, rr.Header().Values("Vary"), "Origin")
+
+		req.Header.Del("X-Test-Principal")
+		rr = serve(router, req)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		assert.Equal(t, "https://app.example.com", rr.Header().Get("Access-Control-Allow-Origin"), "errors are readable too")
+
+		req = httptest.NewRequest(http.MethodGet, "/messages", nil)
+		req.Header.Set("Origin", "https://evil.test")
+		req.Header.Set("X-Test-Principal", "operator")
+		rr = serve(router, req)
+		assert.Equal(t, http.StatusOK, rr.Code, "CORS is enforced by browsers, not the server")
+		assert.Empty(t, rr.Header().Get("Access-Control-Allow-Origin"))
+		assert.Contains(t, rr.Header().Values("Vary"), "Origin")
+	})
+
+	t.Run("Disabled on a route", func(t *testing.T) {
+		rr := preflight("/hello", "https://app.example.com", http.MethodGet)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code, "handled like any other request")
+		assert.Empty(t, rr.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("Idempotent replays", func(t *testing.T) {
+		echo := func(origin string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(`{"text_to_echo":"hi"}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Origin", origin)
+			req.Header.Set("X-Test-Principal", "operator")
+			req.Header.Set(IdempotencyKeyHeader, "cors-1")
+			return serve(router, req)
+		}
+		first := echo("https://app.example.com")
+		require.Equal(t, http.StatusOK, first.Code)
+		replayed := echo("https://pr-1.preview.example.com")
+		assert.Equal(t, "true", replayed.Header().Get(IdempotentReplayedHeader))
+		assert.Equal(t, "https://pr-1.preview.example.com", replayed.Header().Get("Access-Control-Allow-Origin"))
+	})
+}
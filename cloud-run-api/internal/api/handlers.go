@@ -2,94 +2,521 @@
 package api
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
 	// "reflect" // No longer needed
-	// "strings"   // No longer needed for dataset name
+	"strconv"
+	"strings"
 	"time"
 
 	// "cloud.google.com/go/bigquery" // No longer needed
 
+	"your-module-name/internal/audit"
+	"your-module-name/internal/auth"
+	"your-module-name/internal/authz"
+	"your-module-name/internal/codec"
 	"your-module-name/internal/config"
+	"your-module-name/internal/flags"
+	"your-module-name/internal/idempotency"
+	"your-module-name/internal/loadshed"
 	"your-module-name/internal/models" // Keep for our new models
+	"your-module-name/internal/proxy"
+	"your-module-name/internal/ratelimit"
+	"your-module-name/internal/search"
+	"your-module-name/internal/store"
+	"your-module-name/internal/version"
+)
+
+// Feature flags consulted by the handlers. See internal/flags for how they are
+// configured and evaluated.
+const (
+	// FlagEchoUppercase makes HandleEcho reply with the echoed text in upper case.
+	FlagEchoUppercase = "echo-uppercase"
+	// FlagHelloFriendlyGreeting switches HandleHelloWorld to a friendlier greeting.
+	FlagHelloFriendlyGreeting = "hello-friendly-greeting"
 )
 
 // Handler holds dependencies required by the HTTP handlers.
 type Handler struct {
 	Logger    *slog.Logger
 	AppConfig config.Config
+	Flags     flags.Provider
+	Messages  store.MessageRepository
+	Search    search.Searcher // Nil disables GET /messages:search.
+	// Codecs encode responses and decode request bodies; see withNegotiation.
+	Codecs *codec.Registry
+	// Idempotency records responses to requests with an Idempotency-Key.
+	// Nil disables the header.
+	Idempotency idempotency.Store
+	// Auth authenticates callers; see withAuth. Nil disables authentication.
+	Auth auth.Authenticator
+	// Authz maps the roles of authenticated callers to permissions; see
+	// withPermission. Nil means authz.DefaultPolicy.
+	Authz authz.Source
+	// Audit records authentication failures, denials, admin requests and
+	// message deletions. Nil records nothing.
+	Audit *audit.Logger
+	// RateLimit limits request rates per client; see withRateLimit. Nil
+	// disables rate limiting.
+	RateLimit *ratelimit.Limiter
+	// Shedder bounds the requests served at once; see withLoadShedding.
+	// Nil disables concurrency limiting.
+	Shedder *loadshed.Limiter
+	// Timeouts are the request deadlines; see withTimeout. A zero Default
+	// disables them.
+	Timeouts Timeouts
+	// CORS holds the cross-origin policies; see withCORS. The zero value
+	// disables CORS.
+	CORS CORS
+	// SecurityHeaders are added to every response; see withSecurityHeaders.
+	SecurityHeaders SecurityHeaders
+	// TrustedProxies are the proxies whose forwarding header names the
+	// client; see proxy.Trusted.Resolve.
+	TrustedProxies proxy.Trusted
 	// BQClient BQClientInterface // Removed
 	// SchemaTypeMap map[string]reflect.Type // Removed
 }
 
 // NewHandler creates and returns a new Handler instance with its dependencies initialized.
-func NewHandler(logger *slog.Logger, appConfig config.Config) *Handler { // Removed bqClient
+func NewHandler(logger *slog.Logger, appConfig config.Config, messages store.MessageRepository) *Handler { // Removed bqClient
 	return &Handler{
 		Logger:    logger,
 		AppConfig: appConfig,
+		Flags:     flags.NewStaticProvider(), // Replaced by main when flags are configured
+		Messages:  messages,
+		Codecs:    codec.Default(),
+		// Per instance; a store shared between instances also catches
+		// duplicates routed to different instances.
+		Idempotency: newIdempotencyStore(appConfig),
+	}
+}
+
+// newIdempotencyStore returns an in-memory idempotency store with the
+// configured limits.
+func newIdempotencyStore(appConfig config.Config) *idempotency.MemoryStore {
+	s := idempotency.NewMemoryStore()
+	s.MaxKeys = appConfig.IdempotencyMaxKeys
+	s.MaxBytes = appConfig.IdempotencyMaxBytes
+	return s
+}
+
+// callerIdentity returns the identity of the caller, used to target feature
+// flags, scope idempotency keys and recorded with stored messages. This is
+// the authenticated principal or, for anonymous requests, the client's IP
+// address.
+func callerIdentity(r *http.Request) string {
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		return principal.ID
+	}
+	if c, ok := proxy.FromContext(r.Context()); ok {
+		return c.IP
+	}
+	return remoteIP(r)
+}
+
+// remoteIP returns the IP address of the peer that sent r.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// client returns the client that sent r, as resolved by the proxy
+// middleware in SetupRoutes or, for handlers called without it, from
+// h.TrustedProxies.
+func (h *Handler) client(r *http.Request) proxy.Client {
+	if c, ok := proxy.FromContext(r.Context()); ok {
+		return c
+	}
+	return h.TrustedProxies.Resolve(r)
+}
+
+// clientIP returns the IP address of the client that sent r; see
+// proxy.Trusted.Resolve.
+func (h *Handler) clientIP(r *http.Request) string {
+	return h.client(r).IP
+}
+
+// traceIDFromRequest returns the trace ID from the X-Cloud-Trace-Context
+// header ("TRACE_ID/SPAN_ID;o=OPTIONS") or, failing that, the W3C
+// traceparent header ("00-TRACE_ID-SPAN_ID-FLAGS"). It returns "" if neither is present.
+func traceIDFromRequest(r *http.Request) string {
+	if h := r.Header.Get("X-Cloud-Trace-Context"); h != "" {
+		traceID, _, _ := strings.Cut(h, "/")
+		traceID, _, _ = strings.Cut(traceID, ";")
+		return traceID
+	}
+	if parts := strings.Split(r.Header.Get("traceparent"), "-"); len(parts) == 4 {
+		return parts[1]
 	}
+	return ""
 }
 
 // HandleHelloWorld is a simple GET handler.
 func (h *Handler) HandleHelloWorld(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	if r.Method != http.MethodGet {
-		w.Header().Set("Allow", http.MethodGet)
-		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		methodNotAllowed(w, r, http.MethodGet)
 		return
 	}
 
 	h.Logger.InfoContext(ctx, "Hello world request received", "path", r.URL.Path)
 
+	message := fmt.Sprintf("Hello, World from %s!", h.AppConfig.ServiceName)
+	if flags.Enabled(ctx, FlagHelloFriendlyGreeting) {
+		message = fmt.Sprintf("Hello, World from %s! Great to see you.", h.AppConfig.ServiceName)
+	}
+
 	response := models.HelloWorldResponse{
-		Message:   fmt.Sprintf("Hello, World from %s!", h.AppConfig.ServiceName),
+		Message:   message,
 		Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
 	}
+	// Only the timestamp changes between calls, so responses with the same
+	// message and media type are equivalent and share a weak ETag.
+	w.Header().Set("ETag", mediaETag(weakETag(message), responseCodec(r)))
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		h.Logger.ErrorContext(ctx, "Failed to encode hello world response", "error", err)
-		// Hard to send an error to client if headers already sent and partially written.
-	}
+	respond(w, r, h.Logger, http.StatusOK, response)
 }
 
 // HandleEcho is a POST handler that echoes back part of the request.
 func (h *Handler) HandleEcho(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	if r.Method != http.MethodPost {
-		w.Header().Set("Allow", http.MethodPost)
-		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		methodNotAllowed(w, r, http.MethodPost)
 		return
 	}
 
-	h.Logger.InfoContext(ctx, "Echo request received", "path", r.URL.Path)
+	h.Logger.InfoContext(ctx, "Echo request received", "path", r.URL.Path, "caller", callerIdentity(r))
 
 	var echoReq models.EchoRequest
-	if err := json.NewDecoder(r.Body).Decode(&echoReq); err != nil {
+	if err := h.decodeBody(r, &echoReq); err != nil {
+		if errors.Is(err, errUnsupportedMediaType) {
+			h.writeUnsupportedMediaType(w, r)
+			return
+		}
+		if writeBodyTooLarge(w, r, err) {
+			return
+		}
 		h.Logger.ErrorContext(ctx, "Failed to decode echo request body", "error", err)
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, "Invalid request payload")
 		return
 	}
-	defer r.Body.Close()
 
 	if echoReq.TextToEcho == "" {
 		h.Logger.WarnContext(ctx, "Echo request with empty text_to_echo")
-		http.Error(w, "text_to_echo is required", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, "text_to_echo is required")
+		return
+	}
+
+	echoed := echoReq.TextToEcho
+	if flags.Enabled(ctx, FlagEchoUppercase) {
+		echoed = strings.ToUpper(echoed)
+	}
+
+	msg, err := h.Messages.Create(ctx, store.Message{
+		Text:      echoReq.TextToEcho,
+		Caller:    callerIdentity(r),
+		TraceID:   traceIDFromRequest(r),
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		h.Logger.ErrorContext(ctx, "Failed to store echo message", "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, "Failed to store message")
 		return
 	}
+	h.Logger.InfoContext(ctx, "Echo message stored", "message_id", msg.ID)
 
 	response := models.EchoResponse{
+		MessageID:    msg.ID,
 		ReceivedText: echoReq.TextToEcho,
-		Reply:        fmt.Sprintf("Service '%s' received your message: '%s'", h.AppConfig.ServiceName, echoReq.TextToEcho),
-		Timestamp:    time.Now().UTC().Format(time.RFC3339Nano),
+		Reply:        fmt.Sprintf("Service '%s' received your message: '%s'", h.AppConfig.ServiceName, echoed),
+		Timestamp:    msg.CreatedAt.Format(time.RFC3339Nano),
+	}
+
+	respond(w, r, h.Logger, http.StatusOK, response)
+}
+
+// HandleVersion reports the build version of the service.
+func (h *Handler) HandleVersion(w http.ResponseWriter, r *http.Request) {
+	info := version.Get()
+	respond(w, r, h.Logger, http.StatusOK, models.VersionResponse{
+		Service:   h.AppConfig.ServiceName,
+		Version:   info.Version,
+		Commit:    info.Commit,
+		CommitAt:  info.CommitAt,
+		Modified:  info.Modified,
+		GoVersion: info.GoVersion,
+		Revision:  h.AppConfig.Revision,
+	})
+}
+
+// HandleReady reports whether the service can serve traffic. When the message
+// store is backed by a database it must answer a ping within two seconds.
+func (h *Handler) HandleReady(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+
+	if pinger, ok := h.Messages.(store.Pinger); ok {
+		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+		defer cancel()
+		if err := pinger.Ping(ctx); err != nil {
+			h.Logger.ErrorContext(ctx, "Readiness check failed", "error", err)
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Type", "text/plain")
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		h.Logger.ErrorContext(ctx, "Failed to encode echo response", "error", err)
+	fmt.Fprintln(w, "ok")
+}
+
+// toMessageModel converts a stored message to its API representation.
+func toMessageModel(m store.Message) models.Message {
+	return models.Message{
+		ID:         m.ID,
+		Text:       m.Text,
+		Caller:     m.Caller,
+		TraceID:    m.TraceID,
+		CreateTime: m.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+}
+
+// pageSizeFromQuery parses the optional page_size query parameter.
+func pageSizeFromQuery(q url.Values) (int, error) {
+	s := q.Get("page_size")
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, errors.New("page_size must be a non-negative integer")
+	}
+	return n, nil
+}
+
+// listOptionsFromQuery parses the query parameters of HandleListMessages.
+// The returned error is safe to show to the client.
+func listOptionsFromQuery(r *http.Request) (store.ListOptions, error) {
+	q := r.URL.Query()
+	opts := store.ListOptions{
+		PageToken: q.Get("page_token"),
+		Caller:    q.Get("caller"),
+	}
+
+	var err error
+	if opts.PageSize, err = pageSizeFromQuery(q); err != nil {
+		return opts, err
+	}
+
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{
+		{"start_time", &opts.Since},
+		{"end_time", &opts.Before},
+	} {
+		s := q.Get(p.name)
+		if s == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return opts, fmt.Errorf("%s must be an RFC 3339 timestamp", p.name)
+		}
+		*p.dst = t
+	}
+	if !opts.Since.IsZero() && !opts.Before.IsZero() && opts.Before.Before(opts.Since) {
+		return opts, errors.New("end_time must not be before start_time")
+	}
+
+	switch strings.Join(strings.Fields(strings.ToLower(q.Get("order_by"))), " ") {
+	case "", "create_time desc":
+		opts.Order = store.NewestFirst
+	case "create_time", "create_time asc":
+		opts.Order = store.OldestFirst
+	default:
+		return opts, errors.New(`order_by must be "create_time desc" or "create_time asc"`)
+	}
+	return opts, nil
+}
+
+// HandleListMessages lists stored messages, newest first by default.
+//
+// Query parameters follow AIP-158 and AIP-132: page_size, page_token, caller,
+// start_time and end_time (RFC 3339; start inclusive, end exclusive), and
+// order_by ("create_time desc" or "create_time asc"). A page token is only
+// valid with the filters and order it was issued for.
+func (h *Handler) HandleListMessages(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	opts, err := listOptionsFromQuery(r)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, err := h.Messages.List(ctx, opts)
+	switch {
+	case errors.Is(err, store.ErrInvalidPageToken):
+		writeProblem(w, r, http.StatusBadRequest, "page_token is invalid or does not match the request filters")
+		return
+	case err != nil:
+		h.Logger.ErrorContext(ctx, "Failed to list messages", "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, "Failed to list messages")
+		return
+	}
+
+	response := models.ListMessagesResponse{
+		Messages:      make([]models.Message, 0, len(page.Messages)),
+		NextPageToken: page.NextPageToken,
+	}
+	for _, m := range page.Messages {
+		response.Messages = append(response.Messages, toMessageModel(m))
+	}
+	respond(w, r, h.Logger, http.StatusOK, response)
+}
+
+// HandleSearchMessages searches the text of stored messages.
+//
+// The q parameter is a list of words, prefixes ("hel*") and quoted phrases,
+// all of which must match. Results are ordered by relevance and paged with
+// page_size and page_token; a page token is only valid for the same query.
+func (h *Handler) HandleSearchMessages(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if h.Search == nil {
+		writeProblem(w, r, http.StatusNotImplemented, "Search is not enabled")
+		return
+	}
+
+	query, err := search.ParseQuery(r.URL.Query().Get("q"))
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "q must contain at least one word, prefix or complete quoted phrase")
+		return
+	}
+	pageSize, err := pageSizeFromQuery(r.URL.Query())
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res, err := h.Search.Search(ctx, search.Request{
+		Query:     query,
+		PageSize:  pageSize,
+		PageToken: r.URL.Query().Get("page_token"),
+	})
+	switch {
+	case errors.Is(err, store.ErrInvalidPageToken):
+		writeProblem(w, r, http.StatusBadRequest, "page_token is invalid or was issued for another query")
+		return
+	case err != nil:
+		h.Logger.ErrorContext(ctx, "Failed to search messages", "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, "Failed to search messages")
+		return
+	}
+
+	response := models.SearchMessagesResponse{
+		Results:       make([]models.SearchResult, 0, len(res.Hits)),
+		TotalSize:     res.TotalSize,
+		NextPageToken: res.NextPageToken,
+	}
+	for _, hit := range res.Hits {
+		response.Results = append(response.Results, models.SearchResult{
+			Message:    toMessageModel(hit.Message),
+			Score:      hit.Score,
+			Highlights: hit.Highlights,
+		})
+	}
+	respond(w, r, h.Logger, http.StatusOK, response)
+}
+
+// HandleGetMessage returns the message named by the {id} path segment.
+func (h *Handler) HandleGetMessage(w http.ResponseWriter, r *http.Request) {
+	msg, err := h.Messages.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		h.writeStoreError(w, r, err, "Failed to get message")
+		return
+	}
+	w.Header().Set("ETag", mediaETag(messageETag(msg), responseCodec(r)))
+	w.Header().Set("Last-Modified", msg.CreatedAt.UTC().Format(http.TimeFormat))
+	respond(w, r, h.Logger, http.StatusOK, toMessageModel(msg))
+}
+
+// messageETag returns the strong, media-independent ETag of a stored message.
+// Messages do not change once created, so it only depends on their content.
+func messageETag(m store.Message) string {
+	return strongETag([]byte(strings.Join([]string{
+		m.ID, m.Text, m.Caller, m.TraceID, strconv.FormatInt(m.CreatedAt.UnixNano(), 10),
+	}, "\x00")))
+}
+
+// messageETags returns the ETags a client may hold for msg: the
+// media-independent one and that of each representation HandleGetMessage
+// serves.
+func (h *Handler) messageETags(msg store.Message) []string {
+	etag := messageETag(msg)
+	etags := []string{etag}
+	for _, c := range h.Codecs.Codecs() {
+		etags = append(etags, mediaETag(etag, c))
+	}
+	return etags
+}
+
+// HandleDeleteMessage deletes the message named by the {id} path segment.
+// With an If-Match header the message is only deleted if it still exists
+// and, unless the header is "*", has one of the listed ETags.
+func (h *Handler) HandleDeleteMessage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := r.PathValue("id")
+	auditDelete := func(outcome, reason string) {
+		h.audit(r, audit.Event{Action: audit.ActionMessageDelete, Outcome: outcome, Resource: "messages/" + id, Reason: reason})
+	}
+	if r.Header.Get("If-Match") != "" {
+		msg, err := h.Messages.Get(ctx, id)
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			auditDelete(audit.OutcomeFailure, "If-Match precondition failed")
+			writeProblem(w, r, http.StatusPreconditionFailed, "If-Match precondition failed: the message does not exist")
+			return
+		case err != nil:
+			auditDelete(audit.OutcomeFailure, err.Error())
+			h.writeStoreError(w, r, err, "Failed to get message")
+			return
+		case !checkIfMatch(r, h.messageETags(msg)...):
+			auditDelete(audit.OutcomeFailure, "If-Match precondition failed")
+			writeProblem(w, r, http.StatusPreconditionFailed, "If-Match precondition failed: the message has a different ETag")
+			return
+		}
+	}
+	if err := h.Messages.Delete(ctx, id); err != nil {
+		auditDelete(audit.OutcomeFailure, err.Error())
+		h.writeStoreError(w, r, err, "Failed to delete message")
+		return
+	}
+	auditDelete(audit.OutcomeSuccess, "")
+	h.Logger.InfoContext(ctx, "Message deleted", "message_id", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeStoreError maps a repository error for a single message to a problem
+// response: 410 for deleted messages, 404 for missing ones, and 500 with
+// internalDetail otherwise.
+func (h *Handler) writeStoreError(w http.ResponseWriter, r *http.Request, err error, internalDetail string) {
+	switch {
+	case errors.Is(err, store.ErrDeleted):
+		writeProblem(w, r, http.StatusGone, "Message was deleted")
+	case errors.Is(err, store.ErrNotFound):
+		writeProblem(w, r, http.StatusNotFound, "Message not found")
+	default:
+		h.Logger.ErrorContext(r.Context(), internalDetail, "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, internalDetail)
 	}
 }
@@ -3,33 +3,56 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"your-module-name/internal/config"
 	"your-module-name/internal/models"
+	"your-module-name/internal/search"
+	"your-module-name/internal/store"
 )
 
 // testDeps holds dependencies for handler tests. Simplified.
 type testDeps struct {
-	handler *Handler
+	handler  *Handler
+	messages *store.MemoryRepository
 }
 
-// newTestDeps creates a Handler for testing. Simplified.
+// newTestDeps creates a Handler backed by an in-memory message store.
 func newTestDeps(t *testing.T, cfg config.Config) testDeps {
 	t.Helper()
 	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
-	handler := NewHandler(logger, cfg) // No BQ client needed
-	return testDeps{handler: handler}
+	messages := store.NewMemoryRepository()
+	handler := NewHandler(logger, cfg, messages) // No BQ client needed
+	return testDeps{handler: handler, messages: messages}
 }
 
+// failingRepository is a MessageRepository whose every call fails.
+type failingRepository struct{ err error }
+
+func (f failingRepository) Create(context.Context, store.Message) (store.Message, error) {
+	return store.Message{}, f.err
+}
+func (f failingRepository) Get(context.Context, string) (store.Message, error) {
+	return store.Message{}, f.err
+}
+func (f failingRepository) List(context.Context, store.ListOptions) (store.ListResult, error) {
+	return store.ListResult{}, f.err
+}
+func (f failingRepository) Delete(context.Context, string) error { return f.err }
+func (f failingRepository) Ping(context.Context) error           { return f.err }
+
 // --- Handler Unit Tests ---
 
 func TestHandleHelloWorld(t *testing.T) {
@@ -117,6 +140,10 @@ func TestHandleEcho(t *testing.T) {
 				// --- End Improvement ---
 				assert.Contains(t, resp.Reply, tt.expectedReplyPart)
 				assert.NotEmpty(t, resp.Timestamp, "Timestamp should not be empty")
+
+				stored, err := deps.messages.Get(req.Context(), resp.MessageID)
+				require.NoError(t, err, "Echo should be stored under the returned message ID")
+				assert.Equal(t, tt.expectedReceivedText, stored.Text)
 			} else {
 				if tt.expectBodyContains != "" {
 					bodyStr := rr.Body.String()
@@ -132,3 +159,272 @@ func TestHandleEcho(t *testing.T) {
 	deps.handler.HandleEcho(rrWrongMethod, reqWrongMethod)
 	assert.Equal(t, http.StatusMethodNotAllowed, rrWrongMethod.Code)
 }
+
+func TestHandleEcho_StoresCallerAndTrace(t *testing.T) {
+	deps := newTestDeps(t, config.Config{ServiceName: "EchoService"})
+
+	req := httptest.NewRequest(http.MethodPost, "/echo", bytes.NewBufferString(`{"text_to_echo": "traced"}`))
+	req.RemoteAddr = "203.0.113.7:51234"
+	req.Header.Set("X-Cloud-Trace-Context", "105445aa7843bc8bf206b12000100000/1;o=1")
+	rr := httptest.NewRecorder()
+	deps.handler.HandleEcho(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var resp models.EchoResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	stored, err := deps.messages.Get(req.Context(), resp.MessageID)
+	require.NoError(t, err)
+	assert.Equal(t, "203.0.113.7", stored.Caller)
+	assert.Equal(t, "105445aa7843bc8bf206b12000100000", stored.TraceID)
+}
+
+func TestHandleEcho_StoreFailure(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	handler := NewHandler(logger, config.Config{ServiceName: "EchoService"}, failingRepository{err: errors.New("disk full")})
+
+	req := httptest.NewRequest(http.MethodPost, "/echo", bytes.NewBufferString(`{"text_to_echo": "lost"}`))
+	rr := httptest.NewRecorder()
+	handler.HandleEcho(rr, req)
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.NotContains(t, rr.Body.String(), "disk full", "internal errors must not leak to clients")
+}
+
+// seedMessages stores messages m0..m(n-1), one second apart, alternating
+// between two callers.
+func seedMessages(t *testing.T, repo store.MessageRepository, n int) time.Time {
+	t.Helper()
+	base := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		caller := "10.0.0.1"
+		if i%2 == 1 {
+			caller = "10.0.0.2"
+		}
+		_, err := repo.Create(context.Background(), store.Message{
+			ID:        "m" + string(rune('0'+i)),
+			Text:      "text",
+			Caller:    caller,
+			CreatedAt: base.Add(time.Duration(i) * time.Second),
+		})
+		require.NoError(t, err)
+	}
+	return base
+}
+
+func decodeProblem(t *testing.T, rr *httptest.ResponseRecorder) models.Problem {
+	t.Helper()
+	assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+	var p models.Problem
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&p))
+	assert.Equal(t, rr.Code, p.Status)
+	return p
+}
+
+func TestHandleListMessages(t *testing.T) {
+	deps := newTestDeps(t, config.Config{})
+	base := seedMessages(t, deps.messages, 5)
+
+	list := func(query url.Values) (*httptest.ResponseRecorder, models.ListMessagesResponse) {
+		rr := httptest.NewRecorder()
+		deps.handler.HandleListMessages(rr, httptest.NewRequest(http.MethodGet, "/messages?"+query.Encode(), nil))
+		var resp models.ListMessagesResponse
+		if rr.Code == http.StatusOK {
+			require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+		}
+		return rr, resp
+	}
+	ids := func(resp models.ListMessagesResponse) []string {
+		var out []string
+		for _, m := range resp.Messages {
+			out = append(out, m.ID)
+		}
+		return out
+	}
+
+	t.Run("Pages newest first", func(t *testing.T) {
+		rr, resp := list(url.Values{"page_size": {"2"}})
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, []string{"m4", "m3"}, ids(resp))
+		assert.Equal(t, base.Add(4*time.Second).Format(time.RFC3339Nano), resp.Messages[0].CreateTime)
+		require.NotEmpty(t, resp.NextPageToken)
+
+		_, resp = list(url.Values{"page_size": {"10"}, "page_token": {resp.NextPageToken}})
+		assert.Equal(t, []string{"m2", "m1", "m0"}, ids(resp))
+		assert.Empty(t, resp.NextPageToken)
+	})
+
+	t.Run("Filters and order", func(t *testing.T) {
+		_, resp := list(url.Values{
+			"caller":     {"10.0.0.1"},
+			"start_time": {base.Add(time.Second).Format(time.RFC3339)},
+			"order_by":   {"create_time asc"},
+		})
+		assert.Equal(t, []string{"m2", "m4"}, ids(resp))
+
+		_, resp = list(url.Values{"end_time": {base.Add(2 * time.Second).Format(time.RFC3339)}, "order_by": {"create_time"}})
+		assert.Equal(t, []string{"m0", "m1"}, ids(resp))
+	})
+
+	t.Run("Empty result is an empty list", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		deps.handler.HandleListMessages(rr, httptest.NewRequest(http.MethodGet, "/messages?caller=nobody", nil))
+		assert.JSONEq(t, `{"messages":[]}`, rr.Body.String())
+	})
+
+	for name, query := range map[string]url.Values{
+		"negative page_size":  {"page_size": {"-1"}},
+		"non-numeric size":    {"page_size": {"ten"}},
+		"bad start_time":      {"start_time": {"yesterday"}},
+		"inverted time range": {"start_time": {"2025-06-02T00:00:00Z"}, "end_time": {"2025-06-01T00:00:00Z"}},
+		"unknown order_by":    {"order_by": {"text"}},
+		"garbage page_token":  {"page_token": {"not a token!"}},
+	} {
+		t.Run("Rejects "+name, func(t *testing.T) {
+			rr, _ := list(query)
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+			assert.NotEmpty(t, decodeProblem(t, rr).Detail)
+		})
+	}
+
+	t.Run("Token reused with other filters", func(t *testing.T) {
+		_, first := list(url.Values{"page_size": {"1"}})
+		rr, _ := list(url.Values{"page_size": {"1"}, "page_token": {first.NextPageToken}, "caller": {"10.0.0.1"}})
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("Store failure", func(t *testing.T) {
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+		handler := NewHandler(logger, config.Config{}, failingRepository{err: errors.New("disk full")})
+		rr := httptest.NewRecorder()
+		handler.HandleListMessages(rr, httptest.NewRequest(http.MethodGet, "/messages", nil))
+		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+		assert.NotContains(t, rr.Body.String(), "disk full")
+	})
+}
+
+func TestHandleGetAndDeleteMessage(t *testing.T) {
+	deps := newTestDeps(t, config.Config{})
+	seedMessages(t, deps.messages, 1)
+
+	do := func(method, id string, h http.HandlerFunc) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/messages/"+id, nil)
+		req.SetPathValue("id", id)
+		rr := httptest.NewRecorder()
+		h(rr, req)
+		return rr
+	}
+
+	rr := do(http.MethodGet, "m0", deps.handler.HandleGetMessage)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var msg models.Message
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&msg))
+	assert.Equal(t, models.Message{ID: "m0", Text: "text", Caller: "10.0.0.1", CreateTime: "2025-06-01T12:00:00Z"}, msg)
+
+	rr = do(http.MethodGet, "missing", deps.handler.HandleGetMessage)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Equal(t, "/messages/missing", decodeProblem(t, rr).Instance)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "missing", deps.handler.HandleDeleteMessage).Code)
+
+	rr = do(http.MethodDelete, "m0", deps.handler.HandleDeleteMessage)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.Empty(t, rr.Body.String())
+
+	rr = do(http.MethodGet, "m0", deps.handler.HandleGetMessage)
+	assert.Equal(t, http.StatusGone, rr.Code)
+	assert.Equal(t, "Gone", decodeProblem(t, rr).Title)
+	assert.Equal(t, http.StatusGone, do(http.MethodDelete, "m0", deps.handler.HandleDeleteMessage).Code)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	failing := NewHandler(logger, config.Config{}, failingRepository{err: errors.New("disk full")})
+	rr = do(http.MethodGet, "m0", failing.HandleGetMessage)
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.NotContains(t, rr.Body.String(), "disk full")
+}
+
+func TestHandleSearchMessages(t *testing.T) {
+	deps := newTestDeps(t, config.Config{})
+	indexed := search.NewIndexedRepository(deps.messages)
+	deps.handler.Messages = indexed
+	deps.handler.Search = indexed
+	for _, text := range []string{"hello world", "hello there, world", "goodbye world"} {
+		_, err := indexed.Create(context.Background(), store.Message{Text: text})
+		require.NoError(t, err)
+	}
+
+	get := func(query url.Values) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		deps.handler.HandleSearchMessages(rr, httptest.NewRequest(http.MethodGet, "/messages:search?"+query.Encode(), nil))
+		return rr
+	}
+
+	rr := get(url.Values{"q": {`"hello world"`}})
+	require.Equal(t, http.StatusOK, rr.Code)
+	var resp models.SearchMessagesResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.Len(t, resp.Results, 1)
+	assert.Equal(t, 1, resp.TotalSize)
+	assert.Equal(t, "hello world", resp.Results[0].Message.Text)
+	assert.Equal(t, []string{"<em>hello world</em>"}, resp.Results[0].Highlights)
+	assert.Positive(t, resp.Results[0].Score)
+
+	rr = get(url.Values{"q": {"wor*"}, "page_size": {"2"}})
+	resp = models.SearchMessagesResponse{}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Len(t, resp.Results, 2)
+	assert.Equal(t, 3, resp.TotalSize)
+	require.NotEmpty(t, resp.NextPageToken)
+
+	rr = get(url.Values{"q": {"hello"}, "page_token": {resp.NextPageToken}})
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "token from another query")
+	decodeProblem(t, rr)
+
+	for _, query := range []url.Values{{}, {"q": {`"open`}}, {"q": {"x"}, "page_size": {"-2"}}} {
+		assert.Equal(t, http.StatusBadRequest, get(query).Code, query.Encode())
+	}
+
+	t.Run("Deleted messages are not found", func(t *testing.T) {
+		rr := get(url.Values{"q": {"goodbye"}})
+		resp := models.SearchMessagesResponse{}
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+		require.Len(t, resp.Results, 1)
+
+		require.NoError(t, deps.handler.Messages.Delete(context.Background(), resp.Results[0].Message.ID))
+		assert.JSONEq(t, `{"results":[],"total_size":0}`, get(url.Values{"q": {"goodbye"}}).Body.String())
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		deps := newTestDeps(t, config.Config{})
+		rr := httptest.NewRecorder()
+		deps.handler.HandleSearchMessages(rr, httptest.NewRequest(http.MethodGet, "/messages:search?q=x", nil))
+		assert.Equal(t, http.StatusNotImplemented, rr.Code)
+	})
+}
+
+func TestHandleReady(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	t.Run("Memory store is always ready", func(t *testing.T) {
+		handler := NewHandler(logger, config.Config{}, store.NewMemoryRepository())
+		rr := httptest.NewRecorder()
+		handler.HandleReady(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("Ping failure", func(t *testing.T) {
+		handler := NewHandler(logger, config.Config{}, failingRepository{err: errors.New("database is locked")})
+		rr := httptest.NewRecorder()
+		handler.HandleReady(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+		assert.NotContains(t, rr.Body.String(), "locked")
+	})
+}
+
+func TestTraceIDFromRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	assert.Empty(t, traceIDFromRequest(req))
+
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", traceIDFromRequest(req))
+
+	req.Header.Set("X-Cloud-Trace-Context", "abc123;o=0")
+	assert.Equal(t, "abc123", traceIDFromRequest(req), "X-Cloud-Trace-Context takes precedence")
+}
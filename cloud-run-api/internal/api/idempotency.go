@@ -0,0 +1,226 @@
+// internal/api/idempotency.go
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"your-module-name/internal/idempotency"
+)
+
+// Idempotency headers. A request carrying IdempotencyKeyHeader is executed at
+// most once per caller and key; retries receive the recorded response with
+// IdempotentReplayedHeader set.
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+const (
+	// defaultIdempotencyTTL applies when IDEMPOTENCY_TTL_SECONDS is unset,
+	// as in handlers built from a zero config.Config.
+	defaultIdempotencyTTL = 24 * time.Hour
+	// idempotencyPollInterval is how often a duplicate of an in-progress
+	// request checks whether the first one has finished.
+	idempotencyPollInterval = 25 * time.Millisecond
+	maxIdempotencyKeyLen    = 255
+	// maxIdempotentBodyBytes bounds the request bodies fingerprinted and the
+	// response bodies recorded. Larger responses are not recorded.
+	maxIdempotentBodyBytes = 1 << 20
+)
+
+// withIdempotency honours the Idempotency-Key header on mutating endpoints.
+//
+// The first request with a key runs next and its response is recorded for
+// IDEMPOTENCY_TTL_SECONDS. Later requests with the same key and the same
+// method, URL, body and response media type are answered with the recording. A duplicate that
+// arrives while the first is still running waits up to IDEMPOTENCY_WAIT_MS
+// for it, then gets 409; a key reused for a different request gets 422.
+// Server errors are not recorded, so the request can be retried. Requests
+// without the header pass through.
+func (h *Handler) withIdempotency(next http.Handler) http.Handler {
+	if h.Idempotency == nil {
+		return next
+	}
+	ttl := time.Duration(h.AppConfig.IdempotencyTTLSeconds) * time.Second
+	if ttl <= 0 {
+		ttl = defaultIdempotencyTTL
+	}
+	wait := time.Duration(h.AppConfig.IdempotencyWaitMillis) * time.Millisecond
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx := r.Context()
+		if !validIdempotencyKey(key) {
+			writeProblem(w, r, http.StatusBadRequest, "Idempotency-Key must be 1 to 255 printable ASCII characters")
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentBodyBytes+1))
+		r.Body.Close()
+		if writeBodyTooLarge(w, r, err) {
+			return
+		}
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, "Failed to read request body")
+			return
+		}
+		if len(body) > maxIdempotentBodyBytes {
+			writeProblem(w, r, http.StatusRequestEntityTooLarge, "Request body is too large for an idempotent request")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		fingerprint := requestFingerprint(r, body)
+		scopedKey := callerIdentity(r) + "\x00" + key
+		deadline := time.Now().Add(wait)
+		for {
+			rec, reserved, err := h.Idempotency.Reserve(ctx, scopedKey, fingerprint, ttl)
+			switch {
+			case errors.Is(err, idempotency.ErrFull):
+				h.Logger.WarnContext(ctx, "Idempotency store is full")
+				w.Header().Set("Retry-After", "1")
+				writeProblem(w, r, http.StatusServiceUnavailable, "Too many Idempotency-Keys are in use; retry later")
+				return
+			case err != nil:
+				h.Logger.ErrorContext(ctx, "Failed to reserve idempotency key", "error", err)
+				writeProblem(w, r, http.StatusInternalServerError, "Failed to check Idempotency-Key")
+				return
+			case reserved:
+				h.serveAndRecord(w, r, next, scopedKey, ttl)
+				return
+			case rec.Fingerprint != fingerprint:
+				writeProblem(w, r, http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request")
+				return
+			case rec.Response != nil:
+				h.Logger.InfoContext(ctx, "Replaying idempotent response", "status", rec.Response.Status)
+				replay(w, *rec.Response)
+				return
+			case !time.Now().Before(deadline):
+				w.Header().Set("Retry-After", "1")
+				writeProblem(w, r, http.StatusConflict, "A request with this Idempotency-Key is still in progress")
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(idempotencyPollInterval):
+			}
+		}
+	})
+}
+
+// serveAndRecord runs next for a reserved key and records its response. The
+// key is released if the response cannot be recorded or is a server error.
+func (h *Handler) serveAndRecord(w http.ResponseWriter, r *http.Request, next http.Handler, key string, ttl time.Duration) {
+	// Recording must outlive a client that disconnects mid-request.
+	ctx := context.WithoutCancel(r.Context())
+	rec := &recordingWriter{ResponseWriter: w}
+	completed := false
+	defer func() {
+		if !completed {
+			if err := h.Idempotency.Release(ctx, key); err != nil {
+				h.Logger.ErrorContext(ctx, "Failed to release idempotency key", "error", err)
+			}
+		}
+	}()
+
+	next.ServeHTTP(rec, r)
+
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+		rec.header = w.Header().Clone()
+	}
+	if rec.status >= 500 || rec.overflow {
+		return
+	}
+	resp := idempotency.Response{Status: rec.status, Header: rec.header, Body: rec.body.Bytes()}
+	if err := h.Idempotency.Complete(ctx, key, resp, ttl); err != nil {
+		h.Logger.ErrorContext(ctx, "Failed to record idempotent response", "error", err)
+		return
+	}
+	completed = true
+}
+
+// replay writes a recorded response. Its CORS headers were set for the
+// original request's origin, so those set by withCORS for this one are kept.
+func replay(w http.ResponseWriter, resp idempotency.Response) {
+	for k, v := range resp.Header {
+		if strings.HasPrefix(k, "Access-Control-") {
+			continue
+		}
+		w.Header()[k] = v
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.Header().Set("Content-Length", strconv.Itoa(len(resp.Body)))
+	w.WriteHeader(resp.Status)
+	_, _ = w.Write(resp.Body)
+}
+
+// requestFingerprint identifies a request by method, URL, body and the
+// negotiated response media type, so a response is never replayed in a
+// format the client did not ask for.
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+"\n"+r.URL.RequestURI()+"\n"+responseCodec(r).MediaType()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func validIdempotencyKey(key string) bool {
+	if len(key) > maxIdempotencyKeyLen {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// recordingWriter passes a response through while recording its status,
+// headers and up to maxIdempotentBodyBytes of body.
+type recordingWriter struct {
+	http.ResponseWriter
+	status   int
+	header   http.Header
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (rw *recordingWriter) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+		rw.header = rw.ResponseWriter.Header().Clone()
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *recordingWriter) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.WriteHeader(http.StatusOK)
+	}
+	if rw.body.Len()+len(b) > maxIdempotentBodyBytes {
+		rw.overflow = true
+	} else if !rw.overflow {
+		rw.body.Write(b)
+	}
+	return rw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (rw *recordingWriter) Unwrap() http.ResponseWriter { return rw.ResponseWriter }
@@ -0,0 +1,211 @@
+// internal/api/idempotency_test.go
+package api
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"your-module-name/internal/config"
+	"your-module-name/internal/store"
+)
+
+// countingHandler replies 201 with the request body and counts its calls.
+type countingHandler struct {
+	calls   atomic.Int32
+	status  int
+	release chan struct{} // If set, requests block until it is closed.
+}
+
+func (c *countingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.calls.Add(1)
+	if c.release != nil {
+		<-c.release
+	}
+	body, _ := io.ReadAll(r.Body)
+	w.Header().Set("X-Test", "recorded")
+	status := c.status
+	if status == 0 {
+		status = http.StatusCreated
+	}
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+func newIdempotentHandler(t *testing.T, cfg config.Config, next http.Handler) http.Handler {
+	t.Helper()
+	h := NewHandler(slog.New(slog.NewTextHandler(io.Discard, nil)), cfg, store.NewMemoryRepository())
+	return h.withIdempotency(next)
+}
+
+func idempotentRequest(key, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(body))
+	req.RemoteAddr = "203.0.113.7:1234"
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	return req
+}
+
+func serve(h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestIdempotency_Replay(t *testing.T) {
+	next := &countingHandler{}
+	h := newIdempotentHandler(t, config.Config{}, next)
+
+	first := serve(h, idempotentRequest("key-1", `{"n":1}`))
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Empty(t, first.Header().Get(IdempotentReplayedHeader))
+
+	second := serve(h, idempotentRequest("key-1", `{"n":1}`))
+	assert.Equal(t, http.StatusCreated, second.Code)
+	assert.Equal(t, "true", second.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, "recorded", second.Header().Get("X-Test"), "headers are replayed")
+	assert.Equal(t, `{"n":1}`, second.Body.String())
+	assert.EqualValues(t, 1, next.calls.Load(), "the handler runs once")
+
+	serve(h, idempotentRequest("key-2", `{"n":1}`))
+	serve(h, idempotentRequest("", `{"n":1}`))
+	serve(h, idempotentRequest("", `{"n":1}`))
+	assert.EqualValues(t, 4, next.calls.Load(), "other keys and requests without a key run")
+
+	other := idempotentRequest("key-1", `{"n":1}`)
+	other.RemoteAddr = "198.51.100.1:1234"
+	serve(h, other)
+	assert.EqualValues(t, 5, next.calls.Load(), "keys are scoped to the caller")
+}
+
+func TestIdempotency_DifferentRequest(t *testing.T) {
+	next := &countingHandler{}
+	h := newIdempotentHandler(t, config.Config{}, next)
+
+	serve(h, idempotentRequest("key", `{"n":1}`))
+	rr := serve(h, idempotentRequest("key", `{"n":2}`))
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	decodeProblem(t, rr)
+
+	req := idempotentRequest("key", `{"n":1}`)
+	req.URL.Path = "/elsewhere"
+	assert.Equal(t, http.StatusUnprocessableEntity, serve(h, req).Code, "the URL is part of the fingerprint")
+	assert.EqualValues(t, 1, next.calls.Load())
+}
+
+func TestIdempotency_ServerErrorsAreNotRecorded(t *testing.T) {
+	next := &countingHandler{status: http.StatusInternalServerError}
+	h := newIdempotentHandler(t, config.Config{}, next)
+
+	serve(h, idempotentRequest("key", "x"))
+	next.status = http.StatusOK
+	rr := serve(h, idempotentRequest("key", "x"))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Empty(t, rr.Header().Get(IdempotentReplayedHeader))
+	assert.EqualValues(t, 2, next.calls.Load())
+
+	panicking := newIdempotentHandler(t, config.Config{}, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	}))
+	assert.Panics(t, func() { serve(panicking, idempotentRequest("key", "x")) })
+	assert.Panics(t, func() { serve(panicking, idempotentRequest("key", "x")) }, "the key was released after the panic")
+}
+
+func TestIdempotency_ConcurrentDuplicates(t *testing.T) {
+	t.Run("Conflict without waiting", func(t *testing.T) {
+		next := &countingHandler{release: make(chan struct{})}
+		h := newIdempotentHandler(t, config.Config{IdempotencyWaitMillis: 0}, next)
+
+		done := make(chan *httptest.ResponseRecorder)
+		go func() { done <- serve(h, idempotentRequest("key", "x")) }()
+		require.Eventually(t, func() bool { return next.calls.Load() == 1 }, time.Second, time.Millisecond)
+
+		rr := serve(h, idempotentRequest("key", "x"))
+		assert.Equal(t, http.StatusConflict, rr.Code)
+		assert.Equal(t, "1", rr.Header().Get("Retry-After"))
+
+		close(next.release)
+		assert.Equal(t, http.StatusCreated, (<-done).Code)
+	})
+
+	t.Run("Duplicates wait for the first response", func(t *testing.T) {
+		next := &countingHandler{release: make(chan struct{})}
+		h := newIdempotentHandler(t, config.Config{IdempotencyWaitMillis: 5000}, next)
+
+		var wg sync.WaitGroup
+		results := make([]*httptest.ResponseRecorder, 5)
+		for i := range results {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results[i] = serve(h, idempotentRequest("key", "x"))
+			}()
+		}
+		require.Eventually(t, func() bool { return next.calls.Load() == 1 }, time.Second, time.Millisecond)
+		time.Sleep(3 * idempotencyPollInterval)
+		close(next.release)
+		wg.Wait()
+
+		replayed := 0
+		for _, rr := range results {
+			assert.Equal(t, http.StatusCreated, rr.Code)
+			if rr.Header().Get(IdempotentReplayedHeader) == "true" {
+				replayed++
+			}
+		}
+		assert.Equal(t, 4, replayed)
+		assert.EqualValues(t, 1, next.calls.Load())
+	})
+}
+
+func TestIdempotency_StoreFull(t *testing.T) {
+	next := &countingHandler{release: make(chan struct{})}
+	h := newIdempotentHandler(t, config.Config{IdempotencyMaxKeys: 1}, next)
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- serve(h, idempotentRequest("first", "x")) }()
+	require.Eventually(t, func() bool { return next.calls.Load() == 1 }, time.Second, time.Millisecond)
+
+	rr := serve(h, idempotentRequest("second", "x"))
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code, "keys in progress are not evicted")
+	assert.Equal(t, "1", rr.Header().Get("Retry-After"))
+
+	close(next.release)
+	assert.Equal(t, http.StatusCreated, (<-done).Code)
+	assert.Equal(t, http.StatusCreated, serve(h, idempotentRequest("second", "x")).Code, "the completed key is evicted")
+	assert.EqualValues(t, 2, next.calls.Load())
+}
+
+func TestIdempotency_InvalidKey(t *testing.T) {
+	next := &countingHandler{}
+	h := newIdempotentHandler(t, config.Config{}, next)
+	for _, key := range []string{strings.Repeat("k", 256), "tab\there", "ünï"} {
+		assert.Equal(t, http.StatusBadRequest, serve(h, idempotentRequest(key, "x")).Code, key)
+	}
+	assert.Zero(t, next.calls.Load())
+}
+
+func TestIdempotency_Echo(t *testing.T) {
+	deps := newTestDeps(t, config.Config{ServiceName: "EchoService", RuntimeMode: config.RuntimeModeLocal})
+	router := SetupRoutes(deps.handler)
+
+	first := serve(router, idempotentRequest("echo-key", `{"text_to_echo": "once"}`))
+	second := serve(router, idempotentRequest("echo-key", `{"text_to_echo": "once"}`))
+	require.Equal(t, http.StatusOK, first.Code)
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, "true", second.Header().Get(IdempotentReplayedHeader))
+
+	page, err := deps.messages.List(t.Context(), store.ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, page.Messages, 1, "the retried echo is stored once")
+}
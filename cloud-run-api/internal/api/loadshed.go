@@ -0,0 +1,96 @@
+// internal/api/loadshed.go
+package api
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"your-module-name/internal/auth"
+	"your-module-name/internal/loadshed"
+)
+
+// withLoadShedding admits requests through h.Shedder, which bounds how many
+// are served at once: requests over its adaptive limit wait in its queue,
+// and those it cannot admit in time get 503 with Retry-After. Requests of
+// admins are Critical and never shed; other requests have the route's
+// class. Responses of 503 and 504 count as overload and shrink the limit.
+// The slot is freed when the request is served, or, if withTimeout gave up
+// on the handler, when the handler returns; see detachSlot. Probes are not
+// wrapped, so they are never shed either.
+func (h *Handler) withLoadShedding(class loadshed.Class, next http.Handler) http.Handler {
+	if h.Shedder == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestClass := class
+		if principal, ok := auth.FromContext(r.Context()); ok && slices.Contains(principal.Roles, auth.RoleAdmin) {
+			requestClass = loadshed.Critical
+		}
+		release, err := h.Shedder.Acquire(r.Context(), requestClass)
+		if err != nil {
+			retryAfter := strconv.Itoa(ceilSeconds(h.Shedder.RetryAfter()))
+			h.Logger.InfoContext(r.Context(), "Request shed", "class", requestClass.String(), "reason", err, "limit", h.Shedder.Limit())
+			w.Header().Set("Retry-After", retryAfter)
+			writeProblem(w, r, http.StatusServiceUnavailable, "The service is overloaded; retry in "+retryAfter+" seconds")
+			return
+		}
+		sw := &statusWriter{ResponseWriter: w}
+		s := &slot{release: func() {
+			release(sw.status == http.StatusServiceUnavailable || sw.status == http.StatusGatewayTimeout)
+		}}
+		defer func() {
+			if !s.detached {
+				s.release()
+			}
+		}()
+		next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), slotKey{}, s)))
+	})
+}
+
+type slotKey struct{}
+
+// slot is a request's place under the concurrency limit.
+type slot struct {
+	release  func()
+	detached bool
+}
+
+// detachSlot takes over the slot of the request with ctx from
+// withLoadShedding and returns the function that frees it, or nil if the
+// request holds none. withTimeout calls it when it stops waiting for a
+// handler, and frees the slot once the handler returns, so that abandoned
+// handlers still count against the limit instead of piling up beyond it.
+// It must be called from the goroutine serving the request.
+func detachSlot(ctx context.Context) func() {
+	s, ok := ctx.Value(slotKey{}).(*slot)
+	if !ok || s.detached {
+		return nil
+	}
+	s.detached = true
+	return s.release
+}
+
+// statusWriter records the status of a response passed through it.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	if sw.status == 0 {
+		sw.status = status
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	return sw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (sw *statusWriter) Unwrap() http.ResponseWriter { return sw.ResponseWriter }
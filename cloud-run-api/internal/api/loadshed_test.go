@@ -0,0 +1,102 @@
+// internal/api/loadshed_test.go
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"your-module-name/internal/auth"
+	"your-module-name/internal/config"
+	"your-module-name/internal/loadshed"
+	"your-module-name/internal/metrics"
+)
+
+func TestWithLoadShedding(t *testing.T) {
+	deps := newTestDeps(t, config.Config{ServiceName: "TestService", RuntimeMode: config.RuntimeModeLocal, AuthRequired: true})
+	reg := metrics.NewRegistry()
+	deps.handler.Shedder = loadshed.New(loadshed.Options{MinLimit: 1, MaxLimit: 1}, reg)
+	deps.handler.Auth = principalAuthenticator{
+		"admin":   {ID: "oidc:admin", Roles: []string{auth.RoleAdmin}},
+		"service": {ID: "google:caller", Roles: []string{auth.RoleService}},
+	}
+	router := SetupRoutes(deps.handler)
+	do := func(target, principal string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("X-Test-Principal", principal)
+		return serve(router, req)
+	}
+
+	assert.Equal(t, http.StatusOK, do("/hello", "service").Code, "requests within the limit are served")
+
+	// Occupy the only slot.
+	release, err := deps.handler.Shedder.Acquire(context.Background(), loadshed.Normal)
+	require.NoError(t, err)
+
+	rr := do("/hello", "service")
+	require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Equal(t, "1", rr.Header().Get("Retry-After"))
+	assert.Equal(t, "The service is overloaded; retry in 1 seconds", decodeProblem(t, rr).Detail)
+
+	assert.Equal(t, http.StatusServiceUnavailable, do("/messages:search?q=hello", "service").Code)
+	assert.Equal(t, http.StatusOK, do("/hello", "admin").Code, "admin requests are never shed")
+	assert.Equal(t, http.StatusOK, do("/healthz", "").Code, "probes are never shed")
+
+	var b strings.Builder
+	require.NoError(t, reg.Write(&b))
+	assert.Contains(t, b.String(), `concurrency_requests_total{class="normal",outcome="shed"} 1`)
+	assert.Contains(t, b.String(), `concurrency_requests_total{class="sheddable",outcome="shed"} 1`, "search is sheddable")
+	assert.Contains(t, b.String(), `concurrency_requests_total{class="critical",outcome="admitted"} 1`)
+
+	release(false)
+	assert.Equal(t, http.StatusOK, do("/hello", "service").Code)
+}
+
+func TestWithLoadShedding_OverloadResponses(t *testing.T) {
+	deps := newTestDeps(t, config.Config{})
+	deps.handler.Shedder = loadshed.New(loadshed.Options{MinLimit: 1, MaxLimit: 10, InitialLimit: 10}, metrics.NewRegistry())
+	for _, tc := range []struct {
+		status int
+		limit  int
+	}{
+		{http.StatusOK, 10},
+		{http.StatusNotFound, 10},
+		{http.StatusGatewayTimeout, 9},
+		{http.StatusServiceUnavailable, 8},
+	} {
+		h := deps.handler.withLoadShedding(loadshed.Normal, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tc.status)
+		}))
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, tc.limit, deps.handler.Shedder.Limit(), "after %d", tc.status)
+	}
+	assert.Zero(t, deps.handler.Shedder.InFlight())
+}
+
+func TestWithLoadShedding_AbandonedHandlersKeepTheirSlot(t *testing.T) {
+	deps := newTestDeps(t, config.Config{})
+	deps.handler.Shedder = loadshed.New(loadshed.Options{MinLimit: 1, MaxLimit: 1}, metrics.NewRegistry())
+	deps.handler.Timeouts = Timeouts{Default: 10 * time.Millisecond, Max: time.Second}
+	unblock := make(chan struct{})
+	h := deps.handler.withLoadShedding(loadshed.Normal, deps.handler.withTimeout("", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-unblock // Ignores the context, like a stuck dependency.
+	})))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusGatewayTimeout, rr.Code)
+	assert.Equal(t, 1, deps.handler.Shedder.InFlight(), "the handler is still running")
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+
+	close(unblock)
+	assert.Eventually(t, func() bool { return deps.handler.Shedder.InFlight() == 0 }, time.Second, time.Millisecond)
+}
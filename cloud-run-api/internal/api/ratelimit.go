@@ -0,0 +1,60 @@
+// internal/api/ratelimit.go
+package api
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"your-module-name/internal/auth"
+)
+
+// withRateLimit limits how often each client may call the route under the
+// rate limit rule named rule, usually the route's permission. Clients are
+// the principals authenticated by withAuth, such as an API key, or for
+// anonymous requests the client's IP address; see clientIP.
+//
+// Limited responses carry the RateLimit-Limit, RateLimit-Remaining,
+// RateLimit-Reset and RateLimit-Policy headers of the IETF RateLimit header
+// fields draft, and requests over the limit get 429 with Retry-After. If the
+// rate limit store fails, requests are served rather than rejected.
+func (h *Handler) withRateLimit(rule string, next http.Handler) http.Handler {
+	if h.RateLimit == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := "ip:" + h.clientIP(r)
+		if principal, ok := auth.FromContext(r.Context()); ok {
+			client = principal.ID
+		}
+		res, limited, err := h.RateLimit.Take(r.Context(), rule, client)
+		if err != nil {
+			h.Logger.WarnContext(r.Context(), "Rate limit check failed; serving request", "client", client, "error", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !limited {
+			next.ServeHTTP(w, r)
+			return
+		}
+		header := w.Header()
+		header.Set("RateLimit-Limit", strconv.Itoa(res.Limit.Burst))
+		header.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+		header.Set("RateLimit-Policy", strconv.Itoa(res.Limit.Count)+";w="+strconv.Itoa(ceilSeconds(res.Limit.Period))+";burst="+strconv.Itoa(res.Limit.Burst))
+		if !res.Allowed {
+			retryAfter := strconv.Itoa(max(ceilSeconds(res.RetryAfter), 1))
+			header.Set("Retry-After", retryAfter)
+			h.Logger.InfoContext(r.Context(), "Rate limit exceeded", "client", client, "rule", rule, "limit", res.Limit.String())
+			writeProblem(w, r, http.StatusTooManyRequests, "Rate limit exceeded; retry in "+retryAfter+" seconds")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ceilSeconds rounds d up to whole seconds.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,149 @@
+// internal/api/ratelimit_test.go
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"your-module-name/internal/auth"
+	"your-module-name/internal/authz"
+	"your-module-name/internal/config"
+	"your-module-name/internal/proxy"
+	"your-module-name/internal/ratelimit"
+	"your-module-name/internal/ratelimit/ratelimittest"
+)
+
+func TestWithRateLimit(t *testing.T) {
+	newRouter := func(cfg config.Config, rules ratelimit.Rules) (http.Handler, *ratelimittest.Fake) {
+		deps := newTestDeps(t, cfg)
+		store := ratelimittest.NewFake()
+		deps.handler.RateLimit = ratelimit.NewLimiter(rules, store)
+		deps.handler.TrustedProxies = proxy.Trusted{Prefixes: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}}
+		deps.handler.Auth = principalAuthenticator{
+			"ci":    {ID: "apikey:ci", Roles: []string{auth.RoleAdmin}},
+			"batch": {ID: "apikey:batch", Roles: []string{auth.RoleAdmin}},
+		}
+		return SetupRoutes(deps.handler), store
+	}
+	hourly := ratelimit.Limit{Count: 1, Period: time.Hour, Burst: 2}
+	do := func(router http.Handler, target, principal string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if principal != "" {
+			req.Header.Set("X-Test-Principal", principal)
+		}
+		return serve(router, req)
+	}
+
+	t.Run("Headers and 429", func(t *testing.T) {
+		router, store := newRouter(
+			config.Config{ServiceName: "TestService", RuntimeMode: config.RuntimeModeLocal, AuthRequired: true},
+			ratelimit.Rules{authz.PermHelloRead: hourly},
+		)
+		rr := do(router, "/hello", "ci")
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "2", rr.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, "1", rr.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, "3600", rr.Header().Get("RateLimit-Reset"))
+		assert.Equal(t, "1;w=3600;burst=2", rr.Header().Get("RateLimit-Policy"))
+		assert.Empty(t, rr.Header().Get("Retry-After"))
+
+		assert.Equal(t, http.StatusOK, do(router, "/hello", "ci").Code)
+		rr = do(router, "/hello", "ci")
+		require.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.Equal(t, "0", rr.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, "3600", rr.Header().Get("Retry-After"))
+		p := decodeProblem(t, rr)
+		assert.Equal(t, "Too Many Requests", p.Title)
+		assert.Equal(t, "Rate limit exceeded; retry in 3600 seconds", p.Detail)
+
+		assert.Equal(t, http.StatusOK, do(router, "/hello", "batch").Code, "each API key has its own bucket")
+		assert.Equal(t, []string{"hello:read apikey:ci", "hello:read apikey:ci", "hello:read apikey:ci", "hello:read apikey:batch"}, store.Keys())
+
+		rr = do(router, "/version", "ci")
+		assert.Equal(t, http.StatusOK, rr.Code, "routes without a rule are not limited without a default")
+		assert.Empty(t, rr.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, http.StatusOK, do(router, "/healthz", "").Code)
+	})
+
+	t.Run("Default rule", func(t *testing.T) {
+		router, store := newRouter(
+			config.Config{ServiceName: "TestService", RuntimeMode: config.RuntimeModeLocal, AuthRequired: true},
+			ratelimit.Rules{ratelimit.DefaultRule: hourly},
+		)
+		assert.Equal(t, http.StatusOK, do(router, "/version", "ci").Code)
+		assert.Equal(t, http.StatusOK, do(router, "/hello", "ci").Code)
+		assert.Equal(t, http.StatusTooManyRequests, do(router, "/messages", "ci").Code, "routes without a rule share the default bucket")
+		assert.Equal(t, []string{"* apikey:ci", "* apikey:ci", "* apikey:ci"}, store.Keys())
+	})
+
+	t.Run("Anonymous clients are keyed by IP", func(t *testing.T) {
+		router, store := newRouter(
+			config.Config{ServiceName: "TestService", RuntimeMode: config.RuntimeModeLocal, AuthRequired: false},
+			ratelimit.Rules{authz.PermHelloRead: hourly},
+		)
+		get := func(remoteAddr, forwardedFor string) {
+			req := httptest.NewRequest(http.MethodGet, "/hello", nil)
+			req.RemoteAddr = remoteAddr
+			if forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", forwardedFor)
+			}
+			serve(router, req)
+		}
+		get("203.0.113.7:1234", "")
+		get("203.0.113.7:1234", "198.51.100.1")
+		get("10.1.2.3:1234", "198.51.100.1, 203.0.113.9")
+		assert.Equal(t, []string{"hello:read ip:203.0.113.7", "hello:read ip:203.0.113.7", "hello:read ip:203.0.113.9"}, store.Keys())
+	})
+
+	t.Run("Store failures serve the request", func(t *testing.T) {
+		router, store := newRouter(
+			config.Config{ServiceName: "TestService", RuntimeMode: config.RuntimeModeLocal, AuthRequired: true},
+			ratelimit.Rules{authz.PermHelloRead: {Count: 1, Period: time.Hour, Burst: 1}},
+		)
+		store.SetErr(errors.New("connection refused"))
+		for range 3 {
+			rr := do(router, "/hello", "ci")
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Empty(t, rr.Header().Get("RateLimit-Limit"))
+		}
+	})
+}
+
+func TestClientIP(t *testing.T) {
+	h := &Handler{TrustedProxies: proxy.Trusted{Prefixes: []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}}}
+	for _, tc := range []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		want         string
+	}{
+		{"Untrusted peer", "203.0.113.7:1234", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"Trusted peer", "10.0.0.1:1234", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"Forged left entries", "10.0.0.1:1234", []string{"1.1.1.1, 198.51.100.1"}, "198.51.100.1"},
+		{"Chain of proxies", "10.0.0.1:1234", []string{"198.51.100.1, 10.0.0.2", "10.0.0.3"}, "198.51.100.1"},
+		{"Only proxies", "10.0.0.1:1234", []string{"10.0.0.2"}, "10.0.0.2"},
+		{"No header", "10.0.0.1:1234", nil, "10.0.0.1"},
+		{"Malformed entry", "10.0.0.1:1234", []string{"198.51.100.1, junk"}, "10.0.0.1"},
+		{"IPv6 proxy", "[2001:db8::1]:1234", []string{"2001:db8:ffff::9, 198.51.100.1"}, "198.51.100.1"},
+		{"IPv4-mapped client", "10.0.0.1:1234", []string{"::ffff:198.51.100.1"}, "198.51.100.1"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tc.remoteAddr
+			for _, v := range tc.forwardedFor {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+			assert.Equal(t, tc.want, h.clientIP(req))
+		})
+	}
+}
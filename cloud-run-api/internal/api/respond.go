@@ -0,0 +1,126 @@
+// internal/api/respond.go
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"your-module-name/internal/codec"
+	"your-module-name/internal/models"
+)
+
+// errUnsupportedMediaType is returned by decodeBody for a request body in a
+// media type without a codec.
+var errUnsupportedMediaType = errors.New("unsupported media type")
+
+// problemMediaTypes are the RFC 9457 media types of problem details in
+// formats that define one. Other formats use the codec's media type.
+var problemMediaTypes = map[string]string{
+	codec.JSONType: "application/problem+json",
+	codec.XMLType:  "application/problem+xml",
+}
+
+type codecKey struct{}
+
+// withNegotiation selects the response codec from the Accept header and
+// stores it in the request context for respond and writeProblem. Requests
+// that accept none of the registered media types get 406 Not Acceptable
+// before next runs.
+func withNegotiation(codecs *codec.Registry, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+		c, ok := codecs.Negotiate(r.Header.Get("Accept"))
+		if !ok {
+			writeProblem(w, r, http.StatusNotAcceptable, "Acceptable media types are "+mediaTypeList(codecs))
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), codecKey{}, c)))
+	})
+}
+
+// responseCodec returns the codec negotiated for r, or JSON.
+func responseCodec(r *http.Request) codec.Codec {
+	if c, ok := r.Context().Value(codecKey{}).(codec.Codec); ok {
+		return c
+	}
+	return codec.JSON{}
+}
+
+// respond writes v with the given status code in the negotiated media type.
+func respond(w http.ResponseWriter, r *http.Request, logger *slog.Logger, status int, v any) {
+	c := responseCodec(r)
+	w.Header().Set("Content-Type", c.MediaType())
+	w.WriteHeader(status)
+	if err := c.Encode(w, v); err != nil {
+		// Hard to send an error to client if headers already sent and partially written.
+		logger.ErrorContext(r.Context(), "Failed to encode response", "error", err, "media_type", c.MediaType())
+	}
+}
+
+// writeProblem writes an RFC 9457 problem details response in the negotiated
+// media type. detail is shown to the client and must not contain internal
+// error messages.
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	c := responseCodec(r)
+	mediaType, ok := problemMediaTypes[c.MediaType()]
+	if !ok {
+		mediaType = c.MediaType()
+	}
+	w.Header().Set("Content-Type", mediaType)
+	w.WriteHeader(status)
+	_ = c.Encode(w, models.Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+	})
+}
+
+// methodNotAllowed rejects a request whose method is not allowed.
+func methodNotAllowed(w http.ResponseWriter, r *http.Request, allow string) {
+	w.Header().Set("Allow", allow)
+	writeProblem(w, r, http.StatusMethodNotAllowed, "")
+}
+
+// decodeBody decodes the request body into v with the codec for its
+// Content-Type, JSON if none is given, and closes it. It returns
+// errUnsupportedMediaType if there is no such codec.
+func (h *Handler) decodeBody(r *http.Request, v any) error {
+	defer r.Body.Close()
+	c, ok := h.Codecs.Lookup(r.Header.Get("Content-Type"))
+	if !ok {
+		return errUnsupportedMediaType
+	}
+	return c.Decode(r.Body, v)
+}
+
+// writeBodyTooLarge answers 413 if err is from reading past the request
+// body limit set by withRequestDecoding, and reports whether it did.
+func writeBodyTooLarge(w http.ResponseWriter, r *http.Request, err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	if !errors.As(err, &maxBytesErr) {
+		return false
+	}
+	writeProblem(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body must not exceed %d bytes", maxBytesErr.Limit))
+	return true
+}
+
+// writeUnsupportedMediaType answers a request body that decodeBody cannot
+// decode, listing the supported media types in the Accept header.
+func (h *Handler) writeUnsupportedMediaType(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Accept", mediaTypeList(h.Codecs))
+	writeProblem(w, r, http.StatusUnsupportedMediaType, "Supported media types are "+mediaTypeList(h.Codecs))
+}
+
+func mediaTypeList(codecs *codec.Registry) string {
+	var types []string
+	for _, c := range codecs.Codecs() {
+		types = append(types, c.MediaType())
+	}
+	return strings.Join(types, ", ")
+}
@@ -0,0 +1,105 @@
+// internal/api/respond_test.go
+package api
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+	"gopkg.in/yaml.v3"
+
+	"your-module-name/internal/codec"
+	"your-module-name/internal/config"
+	"your-module-name/internal/models"
+	"your-module-name/internal/store"
+)
+
+func TestContentNegotiation(t *testing.T) {
+	deps := newTestDeps(t, config.Config{ServiceName: "TestService", RuntimeMode: config.RuntimeModeLocal})
+	router := SetupRoutes(deps.handler)
+	do := func(method, target, body string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		for i := 0; i < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Responses", func(t *testing.T) {
+		for mediaType, decode := range map[string]func([]byte, any) error{
+			codec.XMLType:     xml.Unmarshal,
+			codec.YAMLType:    yaml.Unmarshal,
+			codec.MsgPackType: msgpack.Unmarshal,
+			codec.CBORType:    cbor.Unmarshal,
+		} {
+			rr := do(http.MethodGet, "/hello", "", "Accept", mediaType)
+			require.Equal(t, http.StatusOK, rr.Code, mediaType)
+			assert.Equal(t, mediaType, rr.Header().Get("Content-Type"))
+			assert.Contains(t, rr.Header().Values("Vary"), "Accept")
+
+			var resp models.HelloWorldResponse
+			require.NoError(t, decode(rr.Body.Bytes(), &resp), mediaType)
+			assert.Contains(t, resp.Message, "Hello, World from TestService!", mediaType)
+		}
+
+		rr := do(http.MethodGet, "/hello", "", "Accept", "application/json;q=0.5, application/xml")
+		assert.Equal(t, codec.XMLType, rr.Header().Get("Content-Type"), "q-values are honoured")
+	})
+
+	t.Run("Not acceptable", func(t *testing.T) {
+		rr := do(http.MethodPost, "/echo", `{"text_to_echo": "never stored"}`, "Accept", "image/png")
+		assert.Equal(t, http.StatusNotAcceptable, rr.Code)
+		p := decodeProblem(t, rr)
+		assert.Contains(t, p.Detail, codec.CBORType)
+
+		page, err := deps.messages.List(t.Context(), store.ListOptions{})
+		require.NoError(t, err)
+		assert.Empty(t, page.Messages, "the echo is rejected before it is stored")
+	})
+
+	t.Run("Request bodies", func(t *testing.T) {
+		body, err := cbor.Marshal(models.EchoRequest{TextToEcho: "from a device"})
+		require.NoError(t, err)
+		rr := do(http.MethodPost, "/echo", string(body), "Content-Type", codec.CBORType, "Accept", codec.CBORType)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var resp models.EchoResponse
+		require.NoError(t, cbor.Unmarshal(rr.Body.Bytes(), &resp))
+		assert.Equal(t, "from a device", resp.ReceivedText)
+
+		rr = do(http.MethodPost, "/echo", "<echo_request><text_to_echo>partner</text_to_echo></echo_request>",
+			"Content-Type", "text/xml; charset=utf-8", "Accept", "application/xml")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.Contains(t, rr.Body.String(), "<received_text>partner</received_text>")
+
+		rr = do(http.MethodPost, "/echo", "text_to_echo: yaml", "Content-Type", "application/yaml")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.Equal(t, codec.JSONType, rr.Header().Get("Content-Type"), "responses default to JSON")
+
+		rr = do(http.MethodPost, "/echo", "text_to_echo=form", "Content-Type", "application/x-www-form-urlencoded")
+		assert.Equal(t, http.StatusUnsupportedMediaType, rr.Code)
+		assert.Contains(t, rr.Header().Get("Accept"), codec.JSONType)
+		decodeProblem(t, rr)
+	})
+
+	t.Run("Problems", func(t *testing.T) {
+		rr := do(http.MethodGet, "/messages/missing", "", "Accept", "application/xml")
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+		assert.Equal(t, "application/problem+xml", rr.Header().Get("Content-Type"))
+		var p models.Problem
+		require.NoError(t, xml.NewDecoder(bytes.NewReader(rr.Body.Bytes())).Decode(&p))
+		assert.Equal(t, http.StatusNotFound, p.Status)
+
+		rr = do(http.MethodGet, "/messages/missing", "", "Accept", "application/yaml")
+		assert.Equal(t, codec.YAMLType, rr.Header().Get("Content-Type"))
+		assert.Contains(t, rr.Body.String(), "status: 404")
+	})
+}
@@ -0,0 +1,58 @@
+// internal/api/security.go
+package api
+
+import (
+	"net/http"
+	"slices"
+)
+
+// SecurityHeaders are the response headers added by withSecurityHeaders and
+// withRouteHeaders.
+type SecurityHeaders struct {
+	// Default are added to every response. Strict-Transport-Security is
+	// only sent to clients that used HTTPS, as browsers ignore it otherwise.
+	Default http.Header
+	// Routes holds overrides by rule name, the route's permission. Each
+	// header replaces the default one, and an empty value removes it.
+	Routes map[string]http.Header
+}
+
+// withSecurityHeaders adds h.SecurityHeaders.Default to every response,
+// including the mux's own 404 and 405 responses.
+func (h *Handler) withSecurityHeaders(next http.Handler) http.Handler {
+	if len(h.SecurityHeaders.Default) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.setHeaders(w, r, h.SecurityHeaders.Default)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withRouteHeaders applies the header overrides of the route with the given
+// rule name on top of withSecurityHeaders.
+func (h *Handler) withRouteHeaders(rule string, next http.Handler) http.Handler {
+	overrides, ok := h.SecurityHeaders.Routes[rule]
+	if !ok {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.setHeaders(w, r, overrides)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// setHeaders sets headers on w, deleting those with an empty value.
+func (h *Handler) setHeaders(w http.ResponseWriter, r *http.Request, headers http.Header) {
+	https := h.client(r).Scheme == "https"
+	for name, values := range headers {
+		name = http.CanonicalHeaderKey(name)
+		switch {
+		case len(values) == 0 || values[0] == "":
+			w.Header().Del(name)
+		case name == "Strict-Transport-Security" && !https:
+		default:
+			w.Header()[name] = slices.Clone(values)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// internal/api/security_test.go
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"your-module-name/internal/authz"
+	"your-module-name/internal/config"
+	"your-module-name/internal/proxy"
+	"your-module-name/internal/store"
+)
+
+func TestWithSecurityHeaders(t *testing.T) {
+	deps := newTestDeps(t, config.Config{ServiceName: "TestService", RuntimeMode: config.RuntimeModeLocal})
+	deps.handler.TrustedProxies = proxy.Trusted{Prefixes: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}}
+	deps.handler.SecurityHeaders = SecurityHeaders{
+		Default: http.Header{
+			"X-Content-Type-Options":    {"nosniff"},
+			"Strict-Transport-Security": {"max-age=31536000"},
+			"Content-Security-Policy":   {"default-src 'none'"},
+			"Referrer-Policy":           {"no-referrer"},
+		},
+		Routes: map[string]http.Header{
+			authz.PermHelloRead: {
+				"Content-Security-Policy": {""},
+				"Referrer-Policy":         {"same-origin"},
+			},
+		},
+	}
+	router := SetupRoutes(deps.handler)
+
+	t.Run("Every response", func(t *testing.T) {
+		for _, path := range []string{"/healthz", "/messages", "/version", "/missing"} {
+			rr := serve(router, httptest.NewRequest(http.MethodGet, path, nil))
+			assert.Equal(t, "nosniff", rr.Header().Get("X-Content-Type-Options"), path)
+			assert.Equal(t, "default-src 'none'", rr.Header().Get("Content-Security-Policy"), path)
+			assert.Equal(t, "no-referrer", rr.Header().Get("Referrer-Policy"), path)
+			assert.Empty(t, rr.Header().Get("Strict-Transport-Security"), "%s: HSTS is only sent over HTTPS", path)
+		}
+	})
+
+	t.Run("Route overrides", func(t *testing.T) {
+		rr := serve(router, httptest.NewRequest(http.MethodGet, "/hello", nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "nosniff", rr.Header().Get("X-Content-Type-Options"))
+		assert.NotContains(t, rr.Header(), "Content-Security-Policy")
+		assert.Equal(t, "same-origin", rr.Header().Get("Referrer-Policy"))
+	})
+
+	t.Run("HTTPS through a trusted proxy", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("X-Forwarded-Proto", "https")
+		assert.Equal(t, "max-age=31536000", serve(router, req).Header().Get("Strict-Transport-Security"))
+
+		req.RemoteAddr = "203.0.113.7:1234"
+		assert.Empty(t, serve(router, req).Header().Get("Strict-Transport-Security"), "untrusted peers cannot claim HTTPS")
+	})
+
+	t.Run("Client address", func(t *testing.T) {
+		echo := func(remoteAddr, forwardedFor string) {
+			req := httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(`{"text_to_echo":"hi"}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+			req.RemoteAddr = remoteAddr
+			require.Equal(t, http.StatusOK, serve(router, req).Code)
+		}
+		echo("10.0.0.1:1234", "198.51.100.1")
+		echo("203.0.113.7:1234", "198.51.100.2")
+
+		res, err := deps.messages.List(context.Background(), store.ListOptions{})
+		require.NoError(t, err)
+		var callers []string
+		for _, m := range res.Messages {
+			callers = append(callers, m.Caller)
+		}
+		assert.ElementsMatch(t, []string{"198.51.100.1", "203.0.113.7"}, callers)
+	})
+}
@@ -4,16 +4,58 @@ package api
 import (
 	"fmt"
 	"net/http"
+	"time"
 
-	// Old import: "your-module-name/internal/cloudlogging"
-	"github.com/duizendstra/dui-go/logging/cloudlogging" // New import
+	"your-module-name/internal/authz"
+	"your-module-name/internal/flags"
+	"your-module-name/internal/loadshed"
+	"your-module-name/internal/logging"
+)
+
+// Cache-Control policies of the GET routes. Hello varies with per-caller
+// feature flags and messages with the store, so neither may be shared.
+var (
+	helloCachePolicy    = CachePolicy{Private: true, MaxAge: time.Minute}
+	versionCachePolicy  = CachePolicy{MaxAge: 5 * time.Minute}
+	messagesCachePolicy = CachePolicy{Private: true, NoCache: true}
 )
 
 // SetupRoutes configures the HTTP routes and returns the handler.
 func SetupRoutes(handler *Handler) http.Handler {
 	mux := http.NewServeMux()
 
-	// Health check
+	// Feature flags are evaluated inside the trace middleware so their debug
+	// logs are correlated with the request's trace.
+	withFlags := flags.Middleware(handler.Flags, handler.Logger, callerIdentity)
+
+	// One trace middleware, for the configured project, is shared by every
+	// route. Cloud Trace correlation is skipped in local mode, where there is
+	// no X-Cloud-Trace-Context header and the project ID is optional.
+	withTrace := logging.WithCloudTraceContext(handler.AppConfig.ProjectID)
+	if handler.AppConfig.IsLocal() {
+		withTrace = func(h http.Handler) http.Handler { return h }
+	}
+
+	// API routes apply their header overrides and CORS policy first, so that
+	// preflights are answered before anything else and every response
+	// carries the right headers. They negotiate the response media type
+	// before doing any work, then authenticate the caller so that flags and
+	// idempotency keys see the principal, limit its request rate under the
+	// rule named after the route's permission, wait for a slot under the
+	// concurrency limit, start the route's deadline, and check that it holds
+	// that permission; see withRouteHeaders, withCORS, withAuth,
+	// withRateLimit, withLoadShedding, withTimeout and withPermission.
+	negotiate := func(h http.Handler) http.Handler { return withNegotiation(handler.Codecs, h) }
+	classRoute := func(class loadshed.Class, permission string, h http.Handler) http.Handler {
+		h = handler.withTimeout(permission, handler.withPermission(permission, withFlags(h)))
+		return handler.withRouteHeaders(permission, handler.withCORS(permission, withTrace(negotiate(handler.withAuth(handler.withRateLimit(permission, handler.withLoadShedding(class, h)))))))
+	}
+	route := func(permission string, h http.Handler) http.Handler {
+		return classRoute(loadshed.Normal, permission, h)
+	}
+
+	// Health check. Probes are not authenticated, rate limited or shed, and
+	// not meant for browsers.
 	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
@@ -24,27 +66,54 @@ func SetupRoutes(handler *Handler) http.Handler {
 		fmt.Fprintln(w, "ok")
 	})
 
+	// Readiness check: pings the message store when it is backed by a database.
+	mux.HandleFunc("/readyz", handler.HandleReady)
+
+	// Build version, for any authenticated caller: no permission is checked,
+	// and the default rate limit rule applies.
+	mux.Handle("GET /version", route("", withConditional(versionCachePolicy, http.HandlerFunc(handler.HandleVersion))))
+
 	// Hello World GET handler
 	helloHandlerFunc := http.HandlerFunc(handler.HandleHelloWorld)
-	// Use WithCloudTraceContext from the new package
-	handlerWithTraceHello := cloudlogging.WithCloudTraceContext(helloHandlerFunc)
-	mux.Handle("/hello", handlerWithTraceHello)
+	mux.Handle("/hello", route(authz.PermHelloRead, withConditional(helloCachePolicy, helloHandlerFunc)))
 
-	// Echo POST handler
+	// Echo POST handler. Mutating endpoints honour the Idempotency-Key header,
+	// which fingerprints the decompressed body.
 	echoHandlerFunc := http.HandlerFunc(handler.HandleEcho)
-	// Use WithCloudTraceContext from the new package
-	handlerWithTraceEcho := cloudlogging.WithCloudTraceContext(echoHandlerFunc)
-	mux.Handle("/echo", handlerWithTraceEcho)
+	mux.Handle("/echo", route(authz.PermEchoWrite, handler.withRequestDecoding(handler.withIdempotency(echoHandlerFunc))))
 
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path != "/" {
-			http.NotFound(w, r)
-			return
-		}
+	// Messages resource over stored echoes. Method-qualified patterns make the
+	// mux answer other methods with 405 and an Allow header.
+	readMessages := func(h http.HandlerFunc) http.Handler {
+		return route(authz.PermMessagesRead, withConditional(messagesCachePolicy, h))
+	}
+	mux.Handle("GET /messages", readMessages(handler.HandleListMessages))
+	// Search is the most expensive read, so it is shed first under load.
+	mux.Handle("GET /messages:search", classRoute(loadshed.Sheddable, authz.PermMessagesRead, withConditional(messagesCachePolicy, http.HandlerFunc(handler.HandleSearchMessages))))
+	mux.Handle("GET /messages/{id}", readMessages(handler.HandleGetMessage))
+	mux.Handle("DELETE /messages/{id}", route(authz.PermMessagesWrite, handler.withIdempotency(http.HandlerFunc(handler.HandleDeleteMessage))))
+
+	// CORS preflights to the method-qualified routes; /hello and /echo check
+	// their methods themselves, after withCORS.
+	mux.Handle("OPTIONS /version", handler.handlePreflight(map[string]string{http.MethodGet: ""}))
+	mux.Handle("OPTIONS /messages", handler.handlePreflight(map[string]string{http.MethodGet: authz.PermMessagesRead}))
+	mux.Handle("OPTIONS /messages:search", handler.handlePreflight(map[string]string{http.MethodGet: authz.PermMessagesRead}))
+	mux.Handle("OPTIONS /messages/{id}", handler.handlePreflight(map[string]string{
+		http.MethodGet:    authz.PermMessagesRead,
+		http.MethodDelete: authz.PermMessagesWrite,
+	}))
+
+	// Only the root itself: a catch-all "/" would also match other methods on
+	// /messages and hide the mux's 405 responses.
+	mux.Handle("GET /{$}", route("", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/plain")
 		fmt.Fprintln(w, "Welcome to the Go Hello World API!")
-		fmt.Fprintln(w, "Try /hello (GET) or /echo (POST)")
-	})
+		fmt.Fprintln(w, "Try /hello (GET), /echo (POST), /messages (GET) or /version (GET)")
+	})))
 
-	return mux
+	// Compression wraps every route so that idempotent replays and 304s are
+	// handled on unencoded responses. Outside it, every response gets the
+	// security headers, and the client's address and scheme are resolved
+	// first, for the rate limiter, audit events and Strict-Transport-Security.
+	return handler.TrustedProxies.Middleware(handler.withSecurityHeaders(handler.withCompression(mux)))
 }
@@ -11,10 +11,15 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	// "github.com/stretchr/testify/mock" // Removed
 
 	"your-module-name/internal/config"
+	"your-module-name/internal/flags"
+	"your-module-name/internal/logging"
 	"your-module-name/internal/models"
+	"your-module-name/internal/search"
+	"your-module-name/internal/store"
 )
 
 func TestSetupRoutes(t *testing.T) {
@@ -22,7 +27,9 @@ func TestSetupRoutes(t *testing.T) {
 	// Ensure ProjectID is set for config loading, as it's checked by Load()
 	// For server tests, the specific value isn't critical unless a handler uses it directly.
 	cfg := config.Config{ProjectID: "test-project-server", ServiceName: "TestServer"}
-	handler := NewHandler(logger, cfg)
+	indexed := search.NewIndexedRepository(store.NewMemoryRepository())
+	handler := NewHandler(logger, cfg, indexed)
+	handler.Search = indexed
 	router := SetupRoutes(handler)
 
 	t.Run("HealthCheck Endpoint", func(t *testing.T) {
@@ -96,4 +103,101 @@ func TestSetupRoutes(t *testing.T) {
 		router.ServeHTTP(rr, req)
 		assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
 	})
+
+	t.Run("Messages Resource", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/echo", bytes.NewBufferString(`{"text_to_echo": "kept"}`)))
+		var echoed models.EchoResponse
+		assert.NoError(t, json.NewDecoder(rr.Body).Decode(&echoed))
+
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/messages/"+echoed.MessageID, nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var msg models.Message
+		assert.NoError(t, json.NewDecoder(rr.Body).Decode(&msg))
+		assert.Equal(t, "kept", msg.Text)
+
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/messages", nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), echoed.MessageID)
+
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/messages:search?q=kept", nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), echoed.MessageID)
+
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/messages", nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/messages/"+echoed.MessageID, nil))
+		assert.Equal(t, http.StatusNoContent, rr.Code)
+
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/messages/"+echoed.MessageID, nil))
+		assert.Equal(t, http.StatusGone, rr.Code)
+	})
+}
+
+func TestSetupRoutes_FeatureFlags(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := config.Config{ProjectID: "test-project-server", ServiceName: "TestServer"}
+	handler := NewHandler(logger, cfg, store.NewMemoryRepository())
+	provider := flags.NewMemoryProvider()
+	handler.Flags = provider
+	router := SetupRoutes(handler)
+
+	echo := func() models.EchoResponse {
+		req := httptest.NewRequest(http.MethodPost, "/echo", bytes.NewBufferString(`{"text_to_echo": "shout"}`))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var resp models.EchoResponse
+		assert.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+		return resp
+	}
+	hello := func() models.HelloWorldResponse {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/hello", nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var resp models.HelloWorldResponse
+		assert.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+		return resp
+	}
+
+	assert.Contains(t, echo().Reply, "received your message: 'shout'")
+	assert.NotContains(t, hello().Message, "Great to see you")
+
+	provider.Set(flags.Flag{Name: FlagEchoUppercase, Enabled: true})
+	provider.Set(flags.Flag{Name: FlagHelloFriendlyGreeting, Enabled: true})
+
+	resp := echo()
+	assert.Equal(t, "shout", resp.ReceivedText)
+	assert.Contains(t, resp.Reply, "received your message: 'SHOUT'")
+	assert.Contains(t, hello().Message, "Great to see you")
+}
+
+func TestSetupRoutes_TraceEveryAPIRoute(t *testing.T) {
+	var buf bytes.Buffer
+	cfg := config.Config{ProjectID: "test-project-server", ServiceName: "TestServer", LogFormat: logging.FormatCloud}
+	logHandler, _, err := logging.NewHandler(cfg, logging.Options{Writer: &buf})
+	require.NoError(t, err)
+	handler := NewHandler(slog.New(logHandler), cfg, store.NewMemoryRepository())
+	handler.Auth = principalAuthenticator{}
+	router := SetupRoutes(handler)
+
+	for _, path := range []string{"/", "/version", "/hello"} {
+		buf.Reset()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-Cloud-Trace-Context", "105445aa7843bc8bf206b12000100000/1;o=1")
+		req.Header.Set("X-Test-Principal", "stranger")
+		assert.Equal(t, http.StatusUnauthorized, serve(router, req).Code, path)
+
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &entry), path)
+		assert.Equal(t, "Authentication failed", entry["message"], path)
+		assert.Equal(t, "projects/test-project-server/traces/105445aa7843bc8bf206b12000100000", entry["logging.googleapis.com/trace"], path)
+	}
 }
@@ -0,0 +1,220 @@
+// internal/api/timeout.go
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Timeouts are the request deadlines applied by withTimeout.
+type Timeouts struct {
+	// Default applies to routes without a deadline in Routes. Zero disables
+	// deadlines.
+	Default time.Duration
+	// Routes holds deadlines by rule name, the route's permission.
+	Routes map[string]time.Duration
+	// Max bounds the deadline a client may ask for.
+	Max time.Duration
+}
+
+// forRequest returns the deadline of a request to the route with the given
+// rule name: the one the client asked for with X-Request-Timeout or
+// grpc-timeout, capped at Max, or else the route's.
+func (t Timeouts) forRequest(rule string, r *http.Request) (time.Duration, error) {
+	requested, ok, err := t.requested(r)
+	if err != nil {
+		return 0, err
+	}
+	if ok {
+		return min(requested, t.Max), nil
+	}
+	if d, ok := t.Routes[rule]; ok {
+		return d, nil
+	}
+	return t.Default, nil
+}
+
+// requested parses the deadline a client asked for: X-Request-Timeout as a
+// duration ("2.5s", "500ms") or a number of seconds, or else grpc-timeout as
+// gRPC encodes it, up to 8 digits and a unit of H, M, S, m, u or n ("500m"
+// is 500 milliseconds). A grpc-timeout beyond Max is Max, as 99999999H does
+// not fit in a time.Duration.
+func (t Timeouts) requested(r *http.Request) (time.Duration, bool, error) {
+	if v := r.Header.Get("X-Request-Timeout"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			seconds, ferr := strconv.ParseFloat(v, 64)
+			if ferr != nil || seconds > float64(time.Duration(1<<63-1)/time.Second) {
+				return 0, false, fmt.Errorf("X-Request-Timeout %q is not a duration such as 2s or a number of seconds", v)
+			}
+			d = time.Duration(seconds * float64(time.Second))
+		}
+		if d <= 0 {
+			return 0, false, fmt.Errorf("X-Request-Timeout %q is not positive", v)
+		}
+		return d, true, nil
+	}
+	if v := r.Header.Get("grpc-timeout"); v != "" {
+		units := map[byte]time.Duration{'H': time.Hour, 'M': time.Minute, 'S': time.Second, 'm': time.Millisecond, 'u': time.Microsecond, 'n': time.Nanosecond}
+		unit, ok := units[v[len(v)-1]]
+		n, err := strconv.Atoi(v[:len(v)-1])
+		if !ok || err != nil || len(v) > 9 || n <= 0 {
+			return 0, false, fmt.Errorf("grpc-timeout %q is not a positive gRPC timeout such as 500m", v)
+		}
+		if time.Duration(n) > t.Max/unit {
+			return t.Max, true, nil
+		}
+		return time.Duration(n) * unit, true, nil
+	}
+	return 0, false, nil
+}
+
+// withTimeout gives the request to the route with the given rule name a
+// deadline from h.Timeouts, visible to the handler through r.Context(). If
+// the deadline passes before the handler has started its response, the
+// client gets 504 at once; if it had started, the response is cut short.
+// Either way the handler keeps running in its own goroutine until it
+// returns, and whatever it writes after the deadline is discarded; it keeps
+// the request's load-shedding slot until then.
+func (h *Handler) withTimeout(rule string, next http.Handler) http.Handler {
+	if h.Timeouts.Default <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout, err := h.Timeouts.forRequest(rule, r)
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		tw := &timeoutWriter{w: w, header: w.Header().Clone()}
+		done := make(chan struct{})
+		panicked := make(chan any, 1)
+		go func() {
+			defer tw.handlerReturned()
+			defer func() {
+				if p := recover(); p != nil {
+					if tw.discarding() {
+						h.Logger.ErrorContext(ctx, "Handler panicked after its request timed out", "panic", p, "path", r.URL.Path)
+						return
+					}
+					panicked <- p
+					return
+				}
+				close(done)
+			}()
+			next.ServeHTTP(tw, r.WithContext(ctx))
+		}()
+
+		select {
+		case <-done:
+			if ctx.Err() == nil {
+				return
+			}
+			// The handler returned at its deadline, possibly without a
+			// response.
+		case p := <-panicked:
+			panic(p)
+		case <-ctx.Done():
+		}
+		tw.mu.Lock()
+		defer tw.mu.Unlock()
+		tw.timedOut = true
+		if !tw.returned {
+			tw.onReturn = detachSlot(r.Context())
+		}
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return // The client went away.
+		}
+		h.Logger.WarnContext(ctx, "Request timed out", "method", r.Method, "path", r.URL.Path, "timeout", timeout.String(), "response_started", tw.wroteHeader)
+		if !tw.wroteHeader {
+			writeProblem(w, r, http.StatusGatewayTimeout, "The request did not complete within its "+timeout.String()+" deadline")
+		}
+	})
+}
+
+// timeoutWriter passes a handler's response through to w until the request
+// times out, after which writes fail with http.ErrHandlerTimeout. The
+// handler gets its own header map, so that it cannot race with the timeout
+// response on w's headers.
+type timeoutWriter struct {
+	w      http.ResponseWriter
+	header http.Header
+
+	mu          sync.Mutex
+	wroteHeader bool
+	timedOut    bool
+	returned    bool   // The handler returned.
+	onReturn    func() // Called when the handler returns after timing out.
+}
+
+func (tw *timeoutWriter) Header() http.Header { return tw.header }
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.writeHeaderLocked(status)
+}
+
+// writeHeaderLocked copies the handler's headers to w and sends them. The
+// caller must hold tw.mu.
+func (tw *timeoutWriter) writeHeaderLocked(status int) {
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+	tw.wroteHeader = true
+	dst := tw.w.Header()
+	for k := range dst {
+		delete(dst, k)
+	}
+	for k, v := range tw.header {
+		dst[k] = v
+	}
+	tw.w.WriteHeader(status)
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.writeHeaderLocked(http.StatusOK)
+	return tw.w.Write(b)
+}
+
+// Flush sends what has been written so far, unless the request timed out.
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return
+	}
+	tw.writeHeaderLocked(http.StatusOK)
+	_ = http.NewResponseController(tw.w).Flush()
+}
+
+// handlerReturned records that the handler returned and calls onReturn.
+func (tw *timeoutWriter) handlerReturned() {
+	tw.mu.Lock()
+	tw.returned = true
+	onReturn := tw.onReturn
+	tw.mu.Unlock()
+	if onReturn != nil {
+		onReturn()
+	}
+}
+
+// discarding reports whether the request has timed out.
+func (tw *timeoutWriter) discarding() bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	return tw.timedOut
+}
@@ -0,0 +1,164 @@
+// internal/api/timeout_test.go
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"your-module-name/internal/config"
+)
+
+func TestTimeouts_ForRequest(t *testing.T) {
+	timeouts := Timeouts{
+		Default: 10 * time.Second,
+		Routes:  map[string]time.Duration{"echo:write": 2 * time.Second},
+		Max:     30 * time.Second,
+	}
+	for _, tc := range []struct {
+		name    string
+		rule    string
+		header  string
+		value   string
+		want    time.Duration
+		wantErr string
+	}{
+		{name: "Default", rule: "hello:read", want: 10 * time.Second},
+		{name: "Route", rule: "echo:write", want: 2 * time.Second},
+		{name: "Duration", rule: "echo:write", header: "X-Request-Timeout", value: "500ms", want: 500 * time.Millisecond},
+		{name: "Seconds", rule: "echo:write", header: "X-Request-Timeout", value: "2.5", want: 2500 * time.Millisecond},
+		{name: "Longer than the route", rule: "echo:write", header: "X-Request-Timeout", value: "20s", want: 20 * time.Second},
+		{name: "Capped at max", rule: "echo:write", header: "X-Request-Timeout", value: "1h", want: 30 * time.Second},
+		{name: "gRPC milliseconds", rule: "hello:read", header: "grpc-timeout", value: "250m", want: 250 * time.Millisecond},
+		{name: "gRPC hours", rule: "hello:read", header: "grpc-timeout", value: "1H", want: 30 * time.Second},
+		{name: "gRPC overflow", rule: "hello:read", header: "grpc-timeout", value: "99999999H", want: 30 * time.Second},
+		{name: "gRPC just over max", rule: "hello:read", header: "grpc-timeout", value: "30001m", want: 30 * time.Second},
+		{name: "gRPC at max", rule: "hello:read", header: "grpc-timeout", value: "30S", want: 30 * time.Second},
+		{name: "Malformed", header: "X-Request-Timeout", value: "soon", wantErr: `X-Request-Timeout "soon" is not a duration`},
+		{name: "Not positive", header: "X-Request-Timeout", value: "-1s", wantErr: "is not positive"},
+		{name: "Huge", header: "X-Request-Timeout", value: "1e300", wantErr: "is not a duration"},
+		{name: "gRPC unit", header: "grpc-timeout", value: "5x", wantErr: "grpc-timeout"},
+		{name: "gRPC too long", header: "grpc-timeout", value: "123456789S", wantErr: "grpc-timeout"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.header != "" {
+				req.Header.Set(tc.header, tc.value)
+			}
+			got, err := timeouts.forRequest(tc.rule, req)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	deps := newTestDeps(t, config.Config{})
+	deps.handler.Timeouts = Timeouts{Default: 20 * time.Millisecond, Max: time.Second}
+
+	t.Run("Completes in time", func(t *testing.T) {
+		h := deps.handler.withTimeout("", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			deadline, ok := r.Context().Deadline()
+			require.True(t, ok, "the handler sees the deadline")
+			assert.WithinDuration(t, time.Now().Add(time.Second), deadline, 100*time.Millisecond)
+			w.Header().Set("X-Handler", "yes")
+			w.WriteHeader(http.StatusCreated)
+			io.WriteString(w, "done")
+		}))
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Request-Timeout", "1s")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		assert.Equal(t, "yes", rr.Header().Get("X-Handler"))
+		assert.Equal(t, "done", rr.Body.String())
+	})
+
+	t.Run("Deadline exceeded", func(t *testing.T) {
+		release := make(chan struct{})
+		lateWrite := make(chan error, 1)
+		h := deps.handler.withTimeout("", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release // Ignores the context, like a stuck dependency.
+			w.Header().Set("X-Handler", "late")
+			_, err := io.WriteString(w, "too late")
+			lateWrite <- err
+		}))
+		rr := httptest.NewRecorder()
+		start := time.Now()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/slow", nil))
+		assert.Less(t, time.Since(start), 500*time.Millisecond, "the client is answered at the deadline")
+
+		require.Equal(t, http.StatusGatewayTimeout, rr.Code)
+		p := decodeProblem(t, rr)
+		assert.Equal(t, "Gateway Timeout", p.Title)
+		assert.Equal(t, "The request did not complete within its 20ms deadline", p.Detail)
+		assert.Equal(t, "/slow", p.Instance)
+
+		close(release)
+		assert.ErrorIs(t, <-lateWrite, http.ErrHandlerTimeout)
+		assert.Empty(t, rr.Header().Get("X-Handler"), "late headers are discarded")
+		assert.NotContains(t, rr.Body.String(), "too late")
+	})
+
+	t.Run("Deadline exceeded after the response started", func(t *testing.T) {
+		h := deps.handler.withTimeout("", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "partial")
+			<-r.Context().Done()
+			time.Sleep(5 * time.Millisecond)
+			io.WriteString(w, " rest")
+		}))
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "partial", rr.Body.String(), "the response is cut short")
+	})
+
+	t.Run("Client timeout", func(t *testing.T) {
+		h := deps.handler.withTimeout("", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		}))
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Request-Timeout", "5ms")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusGatewayTimeout, rr.Code)
+		assert.Contains(t, decodeProblem(t, rr).Detail, "5ms deadline")
+
+		req.Header.Set("X-Request-Timeout", "soon")
+		rr = httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("Panics propagate", func(t *testing.T) {
+		h := deps.handler.withTimeout("", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		}))
+		assert.PanicsWithValue(t, "boom", func() {
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		})
+	})
+
+	t.Run("Routes", func(t *testing.T) {
+		deps := newTestDeps(t, config.Config{ServiceName: "TestService", RuntimeMode: config.RuntimeModeLocal})
+		deps.handler.Timeouts = Timeouts{Default: time.Second, Max: time.Second}
+		router := SetupRoutes(deps.handler)
+		for _, path := range []string{"/hello", "/version", "/messages"} {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("X-Request-Timeout", "soon")
+			assert.Equal(t, http.StatusBadRequest, serve(router, req).Code, path)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+		req.Header.Set("X-Request-Timeout", "soon")
+		assert.Equal(t, http.StatusOK, serve(router, req).Code, "probes have no deadline")
+	})
+}
@@ -0,0 +1,143 @@
+// internal/audit/audit.go
+
+// Package audit records security-relevant events: who did what to which
+// resource, and whether it succeeded. Events are written to one or more
+// sinks, typically the application's log stream under a dedicated log name
+// and, optionally, a local file protected by a hash chain.
+package audit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// LogName labels audit records in the application's log stream. On Cloud
+// Run it becomes the "log" label of the entry, so a log router sink can
+// route audit records to their own bucket.
+const LogName = "audit"
+
+// Actions recorded by the service.
+const (
+	ActionAuthenticate  = "auth.authenticate"
+	ActionAuthorize     = "auth.authorize"
+	ActionAdminRequest  = "admin.request"
+	ActionMessageDelete = "messages.delete"
+	ActionConfigLoad    = "config.load"
+	ActionConfigReload  = "config.reload"
+)
+
+// Outcomes of an audited action.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomeDenied  = "denied"
+)
+
+// Event is an audited action.
+type Event struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+	// Outcome is OutcomeSuccess, OutcomeFailure or OutcomeDenied.
+	Outcome string `json:"outcome"`
+	// Principal is the ID of the authenticated caller, if any.
+	Principal string `json:"principal,omitempty"`
+	// Resource is what the action applied to, e.g. "messages/123" or a
+	// configuration file.
+	Resource string `json:"resource,omitempty"`
+	// Reason explains the outcome.
+	Reason    string `json:"reason,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	SourceIP  string `json:"source_ip,omitempty"`
+}
+
+// Sink stores audit events.
+// Implementations must be safe for concurrent use.
+type Sink interface {
+	Write(ctx context.Context, e Event) error
+}
+
+// Logger records events to its sinks. A nil *Logger records nothing, so
+// auditing can be left unconfigured in tests.
+type Logger struct {
+	sinks  []Sink
+	errors *slog.Logger
+	now    func() time.Time
+}
+
+// New returns a logger writing to sinks. Failures to write an event are
+// reported to errorLog; they never fail the audited action.
+func New(errorLog *slog.Logger, sinks ...Sink) *Logger {
+	return &Logger{sinks: sinks, errors: errorLog, now: time.Now}
+}
+
+// Record writes e to every sink, stamping it with the current time if it
+// has none.
+func (l *Logger) Record(ctx context.Context, e Event) {
+	if l == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = l.now()
+	}
+	e.Time = e.Time.UTC()
+	for _, sink := range l.sinks {
+		if err := sink.Write(ctx, e); err != nil {
+			l.errors.ErrorContext(ctx, "Failed to write audit event", "action", e.Action, "error", err)
+		}
+	}
+}
+
+// Close closes the sinks that hold resources, such as files.
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+	var errs []error
+	for _, sink := range l.sinks {
+		if c, ok := sink.(io.Closer); ok {
+			errs = append(errs, c.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SlogSink writes events as log records under LogName.
+type SlogSink struct {
+	handler slog.Handler
+}
+
+// NewSlogSink returns a sink writing to handler, typically the application's
+// log handler.
+func NewSlogSink(handler slog.Handler) *SlogSink {
+	return &SlogSink{handler: handler}
+}
+
+// Write implements Sink. Denied and failed actions are logged as warnings.
+func (s *SlogSink) Write(ctx context.Context, e Event) error {
+	level := slog.LevelInfo
+	if e.Outcome != OutcomeSuccess {
+		level = slog.LevelWarn
+	}
+	rec := slog.NewRecord(e.Time, level, "Audit: "+e.Action+" "+e.Outcome, 0)
+	rec.AddAttrs(
+		slog.String("log_name", LogName),
+		slog.Group("logging.googleapis.com/labels", slog.String("log", LogName)),
+		slog.String("action", e.Action),
+		slog.String("outcome", e.Outcome),
+	)
+	for _, a := range []slog.Attr{
+		slog.String("principal", e.Principal),
+		slog.String("resource", e.Resource),
+		slog.String("reason", e.Reason),
+		slog.String("request_id", e.RequestID),
+		slog.String("source_ip", e.SourceIP),
+	} {
+		if a.Value.String() != "" {
+			rec.AddAttrs(a)
+		}
+	}
+	return s.handler.Handle(ctx, rec)
+}
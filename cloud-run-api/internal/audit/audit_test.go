@@ -0,0 +1,67 @@
+// internal/audit/audit_test.go
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingSink fails every write.
+type failingSink struct{}
+
+func (failingSink) Write(context.Context, Event) error { return errors.New("disk full") }
+
+func TestSlogSink(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(slog.New(slog.DiscardHandler), NewSlogSink(slog.NewJSONHandler(&buf, nil)))
+	l.now = func() time.Time { return time.Date(2025, 6, 1, 14, 0, 0, 0, time.FixedZone("CEST", 2*60*60)) }
+
+	l.Record(context.Background(), Event{
+		Action:    ActionAuthorize,
+		Outcome:   OutcomeDenied,
+		Principal: "apikey:ci",
+		Resource:  "POST /echo",
+		RequestID: "trace-1",
+		SourceIP:  "203.0.113.7",
+	})
+
+	var rec map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &rec))
+	assert.Equal(t, "2025-06-01T12:00:00Z", rec["time"], "events are stamped in UTC")
+	assert.Equal(t, "WARN", rec["level"])
+	assert.Equal(t, "Audit: auth.authorize denied", rec["msg"])
+	assert.Equal(t, LogName, rec["log_name"])
+	assert.Equal(t, map[string]any{"log": LogName}, rec["logging.googleapis.com/labels"])
+	assert.Equal(t, "apikey:ci", rec["principal"])
+	assert.Equal(t, "POST /echo", rec["resource"])
+	assert.Equal(t, "trace-1", rec["request_id"])
+	assert.Equal(t, "203.0.113.7", rec["source_ip"])
+	assert.NotContains(t, rec, "reason", "empty fields are omitted")
+
+	buf.Reset()
+	l.Record(context.Background(), Event{Action: ActionConfigLoad, Outcome: OutcomeSuccess})
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &rec))
+	assert.Equal(t, "INFO", rec["level"])
+}
+
+func TestLogger(t *testing.T) {
+	var nilLogger *Logger
+	nilLogger.Record(context.Background(), Event{Action: ActionConfigLoad})
+	assert.NoError(t, nilLogger.Close())
+
+	var errs, out bytes.Buffer
+	l := New(slog.New(slog.NewTextHandler(&errs, nil)), failingSink{}, NewSlogSink(slog.NewTextHandler(&out, nil)))
+	l.Record(context.Background(), Event{Action: ActionMessageDelete, Outcome: OutcomeSuccess})
+	assert.Contains(t, errs.String(), "Failed to write audit event")
+	assert.Contains(t, errs.String(), "disk full")
+	assert.Contains(t, out.String(), "messages.delete", "a failing sink does not stop the others")
+	assert.NoError(t, l.Close())
+}
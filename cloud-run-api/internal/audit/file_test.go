@@ -0,0 +1,121 @@
+// internal/audit/file_test.go
+package audit
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeEvents appends an event per action to the file at path.
+func writeEvents(t *testing.T, path string, chain bool, actions ...string) {
+	t.Helper()
+	sink, err := NewFileSink(path, chain)
+	require.NoError(t, err)
+	defer sink.Close()
+	for i, action := range actions {
+		e := Event{Time: time.Date(2025, 6, 1, 12, 0, i, 0, time.UTC), Action: action, Outcome: OutcomeSuccess, Principal: "apikey:ci"}
+		require.NoError(t, sink.Write(context.Background(), e))
+	}
+}
+
+// readLines returns the lines of the file at path.
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+// verifyLines runs Verify on lines.
+func verifyLines(lines []string) (int, string, error) {
+	return Verify(strings.NewReader(strings.Join(lines, "\n") + "\n"))
+}
+
+func TestFileSink_HashChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	writeEvents(t, path, true, ActionConfigLoad, ActionAuthenticate, ActionMessageDelete)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	lines := readLines(t, path)
+	require.Len(t, lines, 3)
+	records, last, err := verifyLines(lines)
+	require.NoError(t, err)
+	assert.Equal(t, 3, records)
+	assert.Len(t, last, 64)
+
+	// Reopening the file continues the chain.
+	writeEvents(t, path, true, ActionConfigReload)
+	lines = readLines(t, path)
+	records, _, err = verifyLines(lines)
+	require.NoError(t, err)
+	assert.Equal(t, 4, records)
+
+	t.Run("Edited record", func(t *testing.T) {
+		edited := append([]string(nil), lines...)
+		edited[1] = strings.Replace(edited[1], "apikey:ci", "apikey:other", 1)
+		records, _, err := verifyLines(edited)
+		assert.ErrorContains(t, err, "line 2: hash mismatch")
+		assert.Equal(t, 1, records)
+	})
+
+	t.Run("Removed record", func(t *testing.T) {
+		removed := append(append([]string(nil), lines[:1]...), lines[2:]...)
+		_, _, err := verifyLines(removed)
+		assert.ErrorContains(t, err, "line 2: previous hash does not match line 1")
+	})
+
+	t.Run("Reordered records", func(t *testing.T) {
+		reordered := []string{lines[0], lines[2], lines[1], lines[3]}
+		_, _, err := verifyLines(reordered)
+		assert.ErrorContains(t, err, "line 2")
+	})
+
+	t.Run("Removed first record", func(t *testing.T) {
+		_, _, err := verifyLines(lines[1:])
+		assert.ErrorContains(t, err, "earlier records were removed")
+	})
+
+	t.Run("Tampered file is not extended", func(t *testing.T) {
+		tampered := filepath.Join(t.TempDir(), "audit.jsonl")
+		require.NoError(t, os.WriteFile(tampered, []byte(strings.Join(lines[1:], "\n")+"\n"), 0o600))
+		_, err := NewFileSink(tampered, true)
+		assert.ErrorContains(t, err, "earlier records were removed")
+	})
+}
+
+func TestFileSink_WithoutChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	writeEvents(t, path, false, ActionConfigLoad, ActionAuthenticate)
+
+	lines := readLines(t, path)
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"action":"config.load"`)
+	assert.NotContains(t, lines[0], "hash")
+
+	_, _, err := Verify(bytes.NewReader([]byte(lines[0] + "\n")))
+	assert.ErrorContains(t, err, "line 1: record is not hash-chained")
+
+	_, err = NewFileSink(path, true)
+	assert.Error(t, err, "an unchained file cannot be continued as a chain")
+}
+
+func TestVerify_Malformed(t *testing.T) {
+	_, _, err := Verify(strings.NewReader("not json\n"))
+	assert.ErrorContains(t, err, "line 1: malformed record")
+
+	records, last, err := Verify(strings.NewReader(""))
+	require.NoError(t, err)
+	assert.Zero(t, records)
+	assert.Empty(t, last)
+}
@@ -0,0 +1,272 @@
+// internal/auth/apikey.go
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"your-module-name/internal/filewatch"
+)
+
+// APIKeyHeader carries an API key. Keys are also accepted as
+// "Authorization: ApiKey <key>".
+const APIKeyHeader = "X-API-Key"
+
+// apiKeyScheme is the Authorization scheme for API keys.
+const apiKeyScheme = "ApiKey"
+
+// apiKeyPrefix starts every generated key, so leaked keys are easy to spot.
+const apiKeyPrefix = "ak_"
+
+// ErrKeyNotFound is returned by a KeyStore for an unknown key hash.
+var ErrKeyNotFound = errors.New("auth: API key not found")
+
+// APIKey is a stored API key. Only the hash of the key is kept.
+type APIKey struct {
+	// ID names the key in logs and principals; it is not secret.
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	// Hash is HashAPIKey of the key.
+	Hash   string   `json:"hash"`
+	Scopes []string `json:"scopes"`
+	// ExpiresAt is when the key stops working; zero means never.
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+	Disabled  bool      `json:"disabled,omitempty"`
+}
+
+// HashAPIKey returns the hex SHA-256 hash of key. Generated keys carry 256
+// bits of randomness, so an unsalted fast hash does not make them guessable.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateAPIKey returns a new random API key.
+func GenerateAPIKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("auth: failed to generate API key: %w", err)
+	}
+	return apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// KeyStore looks up API keys by hash.
+// Implementations must be safe for concurrent use.
+type KeyStore interface {
+	// LookupKey returns the key with the given HashAPIKey hash, or
+	// ErrKeyNotFound.
+	LookupKey(ctx context.Context, hash string) (APIKey, error)
+}
+
+// APIKeyAuthenticator authenticates requests by API key.
+type APIKeyAuthenticator struct {
+	Keys KeyStore
+	now  func() time.Time
+}
+
+// NewAPIKeyAuthenticator returns an authenticator for the keys in keys.
+func NewAPIKeyAuthenticator(keys KeyStore) *APIKeyAuthenticator {
+	return &APIKeyAuthenticator{Keys: keys, now: time.Now}
+}
+
+// Authenticate implements Authenticator.
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	presented := r.Header.Get(APIKeyHeader)
+	if presented == "" {
+		scheme, credentials, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !strings.EqualFold(scheme, apiKeyScheme) {
+			return Principal{}, ErrNoCredentials
+		}
+		presented = strings.TrimSpace(credentials)
+	}
+	if presented == "" {
+		return Principal{}, ErrNoCredentials
+	}
+
+	key, err := a.Keys.LookupKey(r.Context(), HashAPIKey(presented))
+	switch {
+	case errors.Is(err, ErrKeyNotFound):
+		return Principal{}, fmt.Errorf("%w: unknown API key", ErrInvalidCredentials)
+	case err != nil:
+		return Principal{}, err
+	case key.Disabled:
+		return Principal{}, fmt.Errorf("%w: API key %q is disabled", ErrInvalidCredentials, key.ID)
+	case !key.ExpiresAt.IsZero() && !a.now().Before(key.ExpiresAt):
+		return Principal{}, fmt.Errorf("%w: API key %q expired at %s", ErrInvalidCredentials, key.ID, key.ExpiresAt.Format(time.RFC3339))
+	}
+	return Principal{ID: "apikey:" + key.ID, Name: key.Name, Scopes: key.Scopes}, nil
+}
+
+// Challenge implements Authenticator.
+func (a *APIKeyAuthenticator) Challenge(realm string) string {
+	return apiKeyScheme + ` realm="` + realm + `"`
+}
+
+// MemoryKeyStore is a mutable KeyStore, mainly intended for tests.
+type MemoryKeyStore struct {
+	mu     sync.RWMutex
+	byHash map[string]APIKey
+}
+
+// NewMemoryKeyStore returns a store holding the given keys.
+func NewMemoryKeyStore(keys ...APIKey) *MemoryKeyStore {
+	s := &MemoryKeyStore{byHash: make(map[string]APIKey, len(keys))}
+	for _, k := range keys {
+		s.byHash[k.Hash] = k
+	}
+	return s
+}
+
+// Set adds or replaces the key with k's hash.
+func (s *MemoryKeyStore) Set(k APIKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.byHash[k.Hash] = k
+}
+
+// LookupKey implements KeyStore.
+func (s *MemoryKeyStore) LookupKey(_ context.Context, hash string) (APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	k, ok := s.byHash[hash]
+	if !ok {
+		return APIKey{}, ErrKeyNotFound
+	}
+	return k, nil
+}
+
+// keyFileFormat is the JSON document read by FileKeyStore.
+type keyFileFormat struct {
+	Keys []APIKey `json:"keys"`
+}
+
+// FileKeyStore serves API keys from a JSON file of the form {"keys": [...]}
+// and reloads it when the file changes, so keys can be added, disabled and
+// rotated without a restart. If a reload fails, the last valid set of keys
+// remains in effect.
+type FileKeyStore struct {
+	file   *filewatch.File
+	logger *slog.Logger
+
+	// OnReload, if set, is called by Watch after each reload that loaded
+	// changes (err is nil) or failed. Set it before starting Watch.
+	OnReload func(ctx context.Context, err error)
+
+	mu     sync.RWMutex
+	byHash map[string]APIKey
+}
+
+// NewFileKeyStore loads the key file at path. It returns an error if the
+// initial load fails, so a broken file is caught at startup.
+func NewFileKeyStore(path string, logger *slog.Logger) (*FileKeyStore, error) {
+	s := &FileKeyStore{logger: logger}
+	s.file = filewatch.New(path, s.load)
+	if _, err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// LookupKey implements KeyStore.
+func (s *FileKeyStore) LookupKey(_ context.Context, hash string) (APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	k, ok := s.byHash[hash]
+	if !ok {
+		return APIKey{}, ErrKeyNotFound
+	}
+	return k, nil
+}
+
+// Len returns the number of loaded keys.
+func (s *FileKeyStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.byHash)
+}
+
+// Reload re-reads the file if its modification time or size changed since
+// the last successful load. It reports whether new keys were loaded.
+func (s *FileKeyStore) Reload() (bool, error) {
+	reloaded, err := s.file.Reload()
+	if err != nil {
+		return false, fmt.Errorf("auth: %w", err)
+	}
+	return reloaded, nil
+}
+
+// load parses and validates a key file and puts its keys in effect.
+func (s *FileKeyStore) load(data []byte) error {
+	var doc keyFileFormat
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", s.file.Path(), err)
+	}
+	if err := validateKeys(doc.Keys); err != nil {
+		return fmt.Errorf("invalid %s: %w", s.file.Path(), err)
+	}
+	byHash := make(map[string]APIKey, len(doc.Keys))
+	for _, k := range doc.Keys {
+		byHash[k.Hash] = k
+	}
+	s.mu.Lock()
+	s.byHash = byHash
+	s.mu.Unlock()
+	return nil
+}
+
+// Watch polls the file every interval and reloads it on change until ctx is
+// cancelled. Reload failures are logged and the previous keys are kept.
+func (s *FileKeyStore) Watch(ctx context.Context, interval time.Duration) {
+	s.file.Watch(ctx, interval, func(ctx context.Context, err error) {
+		if err != nil {
+			err = fmt.Errorf("auth: %w", err)
+		}
+		if s.OnReload != nil {
+			s.OnReload(ctx, err)
+		}
+		if err != nil {
+			s.logger.WarnContext(ctx, "Failed to reload API keys; keeping previous keys", "path", s.file.Path(), "error", err)
+			return
+		}
+		s.logger.InfoContext(ctx, "API keys reloaded", "path", s.file.Path(), "count", s.Len())
+	})
+}
+
+func validateKeys(keys []APIKey) error {
+	ids := make(map[string]bool, len(keys))
+	hashes := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		if k.ID == "" {
+			return fmt.Errorf("key with empty id")
+		}
+		if ids[k.ID] {
+			return fmt.Errorf("duplicate key id %q", k.ID)
+		}
+		ids[k.ID] = true
+		if b, err := hex.DecodeString(k.Hash); err != nil || len(b) != sha256.Size || k.Hash != strings.ToLower(k.Hash) {
+			return fmt.Errorf("key %q: hash must be a lower-case hex SHA-256 digest", k.ID)
+		}
+		if hashes[k.Hash] {
+			return fmt.Errorf("key %q: duplicate hash", k.ID)
+		}
+		hashes[k.Hash] = true
+		for _, scope := range k.Scopes {
+			if !slices.Contains(KnownScopes, scope) {
+				return fmt.Errorf("key %q: unknown scope %q", k.ID, scope)
+			}
+		}
+	}
+	return nil
+}
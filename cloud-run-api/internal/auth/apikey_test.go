@@ -0,0 +1,156 @@
+// internal/auth/apikey_test.go
+package auth
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateAPIKey(t *testing.T) {
+	a, err := GenerateAPIKey()
+	require.NoError(t, err)
+	b, err := GenerateAPIKey()
+	require.NoError(t, err)
+	assert.NotEqual(t, a, b)
+	assert.True(t, strings.HasPrefix(a, "ak_"), a)
+	assert.Len(t, HashAPIKey(a), 64)
+}
+
+func TestAPIKeyAuthenticator(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	keys := NewMemoryKeyStore(
+		APIKey{ID: "ci", Name: "CI pipeline", Hash: HashAPIKey("ci-key"), Scopes: []string{ScopeEchoWrite}},
+		APIKey{ID: "old", Hash: HashAPIKey("old-key"), ExpiresAt: now},
+		APIKey{ID: "off", Hash: HashAPIKey("off-key"), Disabled: true},
+	)
+	a := NewAPIKeyAuthenticator(keys)
+	a.now = func() time.Time { return now }
+	authenticate := func(header, value string) (Principal, error) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		return a.Authenticate(req)
+	}
+
+	for _, tc := range []struct{ header, value string }{
+		{APIKeyHeader, "ci-key"},
+		{"Authorization", "ApiKey ci-key"},
+		{"Authorization", "apikey  ci-key"},
+	} {
+		p, err := authenticate(tc.header, tc.value)
+		require.NoError(t, err, tc)
+		assert.Equal(t, Principal{ID: "apikey:ci", Name: "CI pipeline", Scopes: []string{ScopeEchoWrite}}, p)
+		assert.True(t, p.HasScope(ScopeEchoWrite))
+		assert.False(t, p.HasScope(ScopeHelloRead))
+	}
+
+	for _, tc := range []struct{ header, value string }{
+		{"", ""},
+		{"Authorization", "Bearer some.jwt.token"},
+		{"Authorization", "ApiKey "},
+	} {
+		_, err := authenticate(tc.header, tc.value)
+		assert.ErrorIs(t, err, ErrNoCredentials, tc)
+	}
+
+	for value, reason := range map[string]string{
+		"wrong-key": "unknown",
+		"old-key":   "expired",
+		"off-key":   "disabled",
+	} {
+		_, err := authenticate(APIKeyHeader, value)
+		assert.ErrorIs(t, err, ErrInvalidCredentials, value)
+		assert.ErrorContains(t, err, reason)
+	}
+}
+
+func TestContext(t *testing.T) {
+	_, ok := FromContext(context.Background())
+	assert.False(t, ok)
+
+	ctx := NewContext(context.Background(), Principal{ID: "apikey:ci"})
+	p, ok := FromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "apikey:ci", p.ID)
+}
+
+func writeKeyFile(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestFileKeyStore(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	path := filepath.Join(t.TempDir(), "keys.json")
+	base := time.Now().Add(-time.Hour)
+
+	writeKeyFile(t, path, `{"keys": [{"id": "ci", "hash": "`+HashAPIKey("ci-key")+`", "scopes": ["echo:write"]}]}`, base)
+	s, err := NewFileKeyStore(path, logger)
+	require.NoError(t, err)
+	assert.Equal(t, 1, s.Len())
+	k, err := s.LookupKey(ctx, HashAPIKey("ci-key"))
+	require.NoError(t, err)
+	assert.Equal(t, "ci", k.ID)
+	_, err = s.LookupKey(ctx, HashAPIKey("other"))
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+
+	reloaded, err := s.Reload()
+	require.NoError(t, err)
+	assert.False(t, reloaded, "unchanged file is not reloaded")
+
+	// Disabling the key takes effect on reload.
+	writeKeyFile(t, path, `{"keys": [{"id": "ci", "hash": "`+HashAPIKey("ci-key")+`", "scopes": ["echo:write"], "disabled": true}]}`, base.Add(time.Minute))
+	reloaded, err = s.Reload()
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+	k, err = s.LookupKey(ctx, HashAPIKey("ci-key"))
+	require.NoError(t, err)
+	assert.True(t, k.Disabled)
+
+	// An invalid file keeps the previous keys.
+	writeKeyFile(t, path, `{"keys": [{"id": "ci", "hash": "not-a-hash"}]}`, base.Add(2*time.Minute))
+	_, err = s.Reload()
+	assert.ErrorContains(t, err, "hash")
+	assert.Equal(t, 1, s.Len())
+}
+
+func TestValidateKeys(t *testing.T) {
+	hash := HashAPIKey("k")
+	tests := map[string][]APIKey{
+		"empty id":      {{Hash: hash}},
+		"duplicate id":  {{ID: "a", Hash: hash}, {ID: "a", Hash: HashAPIKey("j")}},
+		"upper hash":    {{ID: "a", Hash: strings.ToUpper(hash)}},
+		"short hash":    {{ID: "a", Hash: hash[:10]}},
+		"shared hash":   {{ID: "a", Hash: hash}, {ID: "b", Hash: hash}},
+		"unknown scope": {{ID: "a", Hash: hash, Scopes: []string{"admin"}}},
+	}
+	for name, keys := range tests {
+		assert.Error(t, validateKeys(keys), name)
+	}
+	assert.NoError(t, validateKeys([]APIKey{{ID: "a", Hash: hash, Scopes: KnownScopes}}))
+}
+
+func TestNewFileKeyStore_Invalid(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	_, err := NewFileKeyStore(filepath.Join(t.TempDir(), "missing.json"), logger)
+	assert.Error(t, err)
+
+	path := filepath.Join(t.TempDir(), "keys.json")
+	writeKeyFile(t, path, `{"keys": [`, time.Now())
+	_, err = NewFileKeyStore(path, logger)
+	assert.ErrorContains(t, err, "parse")
+}
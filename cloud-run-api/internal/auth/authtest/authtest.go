@@ -0,0 +1,152 @@
+// internal/auth/authtest/authtest.go
+
+// Package authtest provides a local OpenID Connect issuer for testing token
+// authentication without network access.
+package authtest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Issuer signs tokens with RS256 and serves its discovery document at
+// /.well-known/openid-configuration and its key set at /jwks.json. Its URL
+// is the issuer identifier.
+type Issuer struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	keys    []*rsa.PrivateKey // The last one signs.
+	fetches int
+}
+
+// NewIssuer starts an issuer that is shut down when the test ends.
+func NewIssuer(t testing.TB) *Issuer {
+	t.Helper()
+	iss := &Issuer{}
+	iss.Rotate(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"issuer": iss.URL, "jwks_uri": iss.URL + "/jwks.json"})
+	})
+	mux.HandleFunc("GET /jwks.json", func(w http.ResponseWriter, r *http.Request) {
+		iss.mu.Lock()
+		iss.fetches++
+		iss.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		w.Write(iss.JWKS())
+	})
+	iss.Server = httptest.NewServer(mux)
+	t.Cleanup(iss.Close)
+	return iss
+}
+
+// Rotate adds a new signing key. Tokens signed with earlier keys stay valid
+// as long as the key set still lists them.
+func (iss *Issuer) Rotate(t testing.TB) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("authtest: failed to generate key: %v", err)
+	}
+	iss.mu.Lock()
+	defer iss.mu.Unlock()
+	iss.keys = append(iss.keys, key)
+}
+
+// Fetches returns how often the key set was served.
+func (iss *Issuer) Fetches() int {
+	iss.mu.Lock()
+	defer iss.mu.Unlock()
+	return iss.fetches
+}
+
+// JWKS returns the issuer's key set document.
+func (iss *Issuer) JWKS() []byte {
+	iss.mu.Lock()
+	defer iss.mu.Unlock()
+	type jwk struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		Alg string `json:"alg"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	for i, key := range iss.keys {
+		set.Keys = append(set.Keys, jwk{
+			Kty: "RSA",
+			Kid: strconv.Itoa(i),
+			Use: "sig",
+			Alg: "RS256",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+	b, _ := json.Marshal(set)
+	return b
+}
+
+// WriteJWKS writes the key set to a file in a temporary directory and
+// returns its file:// URL.
+func (iss *Issuer) WriteJWKS(t testing.TB) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, iss.JWKS(), 0o600); err != nil {
+		t.Fatalf("authtest: failed to write key set: %v", err)
+	}
+	return "file://" + path
+}
+
+// Claims returns valid claims for a token from the issuer to audience,
+// expiring in an hour.
+func (iss *Issuer) Claims(subject, audience string) map[string]any {
+	now := time.Now()
+	return map[string]any{
+		"iss": iss.URL,
+		"sub": subject,
+		"aud": audience,
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+}
+
+// Sign returns a compact JWS of claims signed with the current key.
+func (iss *Issuer) Sign(t testing.TB, claims map[string]any) string {
+	t.Helper()
+	iss.mu.Lock()
+	kid := len(iss.keys) - 1
+	key := iss.keys[kid]
+	iss.mu.Unlock()
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": strconv.Itoa(kid)})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("authtest: failed to encode claims: %v", err)
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("authtest: failed to sign token: %v", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
@@ -0,0 +1,71 @@
+// internal/auth/cert.go
+package auth
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+// CertificateAuthenticator authenticates requests by the client certificate
+// verified during the TLS handshake (mutual TLS). The listener verifies the
+// certificate against its client CAs; see internal/tlsconfig.
+type CertificateAuthenticator struct {
+	// Roles are granted to every principal it authenticates. Client CAs
+	// are typically dedicated to internal services, so this defaults to
+	// RoleService.
+	Roles []string
+}
+
+// NewCertificateAuthenticator returns an authenticator for verified client
+// certificates whose principals hold RoleService.
+func NewCertificateAuthenticator() *CertificateAuthenticator {
+	return &CertificateAuthenticator{Roles: []string{RoleService}}
+}
+
+// Authenticate implements Authenticator. The principal ID is "cert:"
+// followed by the certificate's SPIFFE ID or, failing that, its first URI,
+// DNS or email SAN or its common name.
+func (a *CertificateAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return Principal{}, ErrNoCredentials
+	}
+	// Certificates the handshake did not verify, e.g. with a listener that
+	// only requests them, prove nothing.
+	if len(r.TLS.VerifiedChains) == 0 {
+		return Principal{}, fmt.Errorf("%w: client certificate was not verified", ErrInvalidCredentials)
+	}
+	leaf := r.TLS.VerifiedChains[0][0]
+	id := CertificateID(leaf)
+	if id == "" {
+		return Principal{}, fmt.Errorf("%w: client certificate %s has no identity", ErrInvalidCredentials, leaf.SerialNumber)
+	}
+	return Principal{ID: "cert:" + id, Name: leaf.Subject.CommonName, Roles: slices.Clone(a.Roles)}, nil
+}
+
+// Challenge implements Authenticator. Client certificates are requested by
+// the TLS handshake, not by an HTTP challenge.
+func (a *CertificateAuthenticator) Challenge(string) string {
+	return ""
+}
+
+// CertificateID returns the identity of a client certificate: its SPIFFE ID
+// if it has one, otherwise its first URI, DNS or email SAN, otherwise its
+// common name.
+func CertificateID(cert *x509.Certificate) string {
+	for _, u := range cert.URIs {
+		if u.Scheme == "spiffe" {
+			return u.String()
+		}
+	}
+	switch {
+	case len(cert.URIs) > 0:
+		return cert.URIs[0].String()
+	case len(cert.DNSNames) > 0:
+		return cert.DNSNames[0]
+	case len(cert.EmailAddresses) > 0:
+		return cert.EmailAddresses[0]
+	}
+	return cert.Subject.CommonName
+}
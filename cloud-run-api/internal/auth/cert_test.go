@@ -0,0 +1,63 @@
+// internal/auth/cert_test.go
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"your-module-name/internal/tlsconfig/tlstest"
+)
+
+func TestCertificateID(t *testing.T) {
+	spiffe, _ := url.Parse("spiffe://example.org/ns/prod/sa/billing")
+	other, _ := url.Parse("https://billing.example.org")
+
+	for _, tc := range []struct {
+		name string
+		cert x509.Certificate
+		want string
+	}{
+		{"SPIFFE ID", x509.Certificate{URIs: []*url.URL{other, spiffe}, DNSNames: []string{"billing"}}, "spiffe://example.org/ns/prod/sa/billing"},
+		{"URI", x509.Certificate{URIs: []*url.URL{other}, DNSNames: []string{"billing"}}, "https://billing.example.org"},
+		{"DNS name", x509.Certificate{DNSNames: []string{"billing.internal"}, EmailAddresses: []string{"ops@example.org"}}, "billing.internal"},
+		{"email", x509.Certificate{EmailAddresses: []string{"ops@example.org"}, Subject: pkix.Name{CommonName: "ops"}}, "ops@example.org"},
+		{"common name", x509.Certificate{Subject: pkix.Name{CommonName: "billing"}}, "billing"},
+	} {
+		assert.Equal(t, tc.want, CertificateID(&tc.cert), tc.name)
+	}
+}
+
+func TestCertificateAuthenticator(t *testing.T) {
+	a := NewCertificateAuthenticator()
+	cert := tlstest.NewCA(t, "client CA").ClientCert(t, "billing", "spiffe://example.org/ns/prod/sa/billing")
+	request := func(state *tls.ConnectionState) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.TLS = state
+		return req
+	}
+
+	p, err := a.Authenticate(request(&tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{cert.Leaf},
+		VerifiedChains:   [][]*x509.Certificate{{cert.Leaf}},
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, Principal{ID: "cert:spiffe://example.org/ns/prod/sa/billing", Name: "billing", Roles: []string{RoleService}}, p)
+
+	_, err = a.Authenticate(request(nil))
+	assert.ErrorIs(t, err, ErrNoCredentials, "plain HTTP")
+	_, err = a.Authenticate(request(&tls.ConnectionState{}))
+	assert.ErrorIs(t, err, ErrNoCredentials, "no client certificate")
+	_, err = a.Authenticate(request(&tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert.Leaf}}))
+	assert.ErrorIs(t, err, ErrInvalidCredentials, "unverified client certificate")
+
+	assert.Empty(t, a.Challenge("svc"))
+	assert.Equal(t, `ApiKey realm="svc"`, Chain{a, NewAPIKeyAuthenticator(NewMemoryKeyStore())}.Challenge("svc"))
+}
@@ -0,0 +1,298 @@
+// internal/auth/jwks.go
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultJWKSTTL applies when the JWKS response has no max-age.
+	defaultJWKSTTL = time.Hour
+	// minJWKSRefresh bounds how often a token with an unknown key ID can
+	// make the set be fetched again, so forged key IDs cannot flood the
+	// provider.
+	minJWKSRefresh = time.Minute
+	// jwksRetryBackoff bounds how often a failed fetch is retried.
+	jwksRetryBackoff = 5 * time.Second
+	// jwksFetchTimeout bounds a fetch of the key set, including discovery.
+	jwksFetchTimeout = 10 * time.Second
+	// maxJWKSBytes bounds the key set and discovery documents.
+	maxJWKSBytes = 1 << 20
+)
+
+// defaultJWKSClient fetches key sets when NewJWKS is given no client.
+var defaultJWKSClient = &http.Client{Timeout: jwksFetchTimeout}
+
+// errUnknownKey is returned by JWKS.Key for a key ID that is not in the set.
+var errUnknownKey = errors.New("auth: unknown signing key")
+
+// jwk is a JSON Web Key (RFC 7517) as found in a key set.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// signingKey is a public key from a key set.
+type signingKey struct {
+	key crypto.PublicKey
+	alg string // Empty if the key does not restrict its algorithm.
+}
+
+// JWKS is a cached JSON Web Key Set fetched from an https:// or file:// URL.
+// The set is refetched when its Cache-Control max-age (default one hour)
+// has passed, and at most once a minute when a token names an unknown key,
+// so rotated keys are picked up. If a refetch fails, the cached keys remain
+// in use and it is retried after five seconds.
+type JWKS struct {
+	url       string
+	discovery string // OpenID configuration URL to read url from, if url is empty.
+	client    *http.Client
+	now       func() time.Time
+
+	fetchMu     sync.Mutex // Serialises fetches.
+	mu          sync.RWMutex
+	keys        map[string]signingKey
+	expires     time.Time
+	lastFetch   time.Time // Of the last successful fetch.
+	lastFailure time.Time // Of the last failed fetch.
+}
+
+// NewJWKS returns a key set fetched from url, an https:// or file:// URL.
+// A nil client means one with a 10 second timeout.
+func NewJWKS(url string, client *http.Client) *JWKS {
+	if client == nil {
+		client = defaultJWKSClient
+	}
+	return &JWKS{url: url, client: client, now: time.Now}
+}
+
+// NewOIDCJWKS returns the key set of an OpenID Connect issuer, located
+// through the issuer's discovery document on first use.
+func NewOIDCJWKS(issuer string, client *http.Client) *JWKS {
+	j := NewJWKS("", client)
+	j.discovery = strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	return j
+}
+
+// Key returns the key with the given ID, fetching the set if needed.
+func (j *JWKS) Key(ctx context.Context, kid string) (signingKey, error) {
+	key, found, fresh, recent := j.cached(kid)
+	switch {
+	case found && fresh:
+		return key, nil
+	case recent:
+		// Fetched, or failed to, too recently to try again: serve what we have.
+		if found {
+			return key, nil
+		}
+		return signingKey{}, fmt.Errorf("%w %q", errUnknownKey, kid)
+	}
+
+	if err := j.refresh(ctx); err != nil && !found {
+		return signingKey{}, err
+	}
+	key, found, _, _ = j.cached(kid)
+	if !found {
+		return signingKey{}, fmt.Errorf("%w %q", errUnknownKey, kid)
+	}
+	return key, nil
+}
+
+// cached looks kid up in the cached set and reports whether the set is
+// still fresh and whether it was fetched within minJWKSRefresh or failed to
+// be within jwksRetryBackoff.
+func (j *JWKS) cached(kid string) (key signingKey, found, fresh, recent bool) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	now := j.now()
+	key, found = j.keys[kid]
+	recent = !j.lastFetch.IsZero() && now.Sub(j.lastFetch) < minJWKSRefresh ||
+		!j.lastFailure.IsZero() && now.Sub(j.lastFailure) < jwksRetryBackoff
+	return key, found, now.Before(j.expires), recent
+}
+
+// refresh fetches the key set unless a caller waiting on the same fetch
+// lock already did. The fetch serves every caller waiting on it, so it is
+// not cancelled with ctx, the request of whichever caller started it, but
+// bounded by jwksFetchTimeout.
+func (j *JWKS) refresh(ctx context.Context) error {
+	j.fetchMu.Lock()
+	defer j.fetchMu.Unlock()
+	if _, _, _, recent := j.cached(""); recent {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), jwksFetchTimeout)
+	defer cancel()
+	keys, ttl, err := j.fetch(ctx)
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err != nil {
+		j.lastFailure = j.now()
+		return err
+	}
+	j.lastFetch = j.now()
+	j.keys = keys
+	j.expires = j.lastFetch.Add(ttl)
+	return nil
+}
+
+func (j *JWKS) fetch(ctx context.Context) (map[string]signingKey, time.Duration, error) {
+	if j.url == "" {
+		var doc struct {
+			JWKSURI string `json:"jwks_uri"`
+		}
+		body, _, err := j.get(ctx, j.discovery)
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := json.Unmarshal(body, &doc); err != nil || doc.JWKSURI == "" {
+			return nil, 0, fmt.Errorf("auth: no jwks_uri in %s", j.discovery)
+		}
+		j.url = doc.JWKSURI
+	}
+
+	body, ttl, err := j.get(ctx, j.url)
+	if err != nil {
+		return nil, 0, err
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(body, &set); err != nil {
+		return nil, 0, fmt.Errorf("auth: failed to parse key set %s: %w", j.url, err)
+	}
+	keys := make(map[string]signingKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			// Skip keys of types we do not verify rather than failing the set.
+			continue
+		}
+		keys[k.Kid] = signingKey{key: pub, alg: k.Alg}
+	}
+	return keys, ttl, nil
+}
+
+// get reads a document from an https:// or file:// URL and returns it with
+// its cache lifetime.
+func (j *JWKS) get(ctx context.Context, url string) ([]byte, time.Duration, error) {
+	if path, ok := strings.CutPrefix(url, "file://"); ok {
+		body, err := os.ReadFile(path)
+		if err != nil {
+			return nil, 0, fmt.Errorf("auth: failed to read %s: %w", path, err)
+		}
+		return body, defaultJWKSTTL, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("auth: invalid key set URL %s: %w", url, err)
+	}
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("auth: failed to fetch %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("auth: failed to fetch %s: %s", url, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxJWKSBytes))
+	if err != nil {
+		return nil, 0, fmt.Errorf("auth: failed to read %s: %w", url, err)
+	}
+	return body, maxAge(resp.Header.Get("Cache-Control")), nil
+}
+
+// maxAge returns the max-age of a Cache-Control header, or defaultJWKSTTL.
+func maxAge(cacheControl string) time.Duration {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		if strings.EqualFold(name, "max-age") {
+			if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+				return time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return defaultJWKSTTL
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("auth: invalid RSA exponent")
+		}
+		exponent := 0
+		for _, b := range e {
+			exponent = exponent<<8 | int(b)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exponent}, nil
+	case "EC":
+		var (
+			curve    elliptic.Curve
+			ecdhPart ecdh.Curve
+		)
+		switch k.Crv {
+		case "P-256":
+			curve, ecdhPart = elliptic.P256(), ecdh.P256()
+		case "P-384":
+			curve, ecdhPart = elliptic.P384(), ecdh.P384()
+		default:
+			return nil, fmt.Errorf("auth: unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) != size || len(y) != size {
+			return nil, fmt.Errorf("auth: invalid EC point")
+		}
+		// crypto/ecdh rejects points that are not on the curve.
+		if _, err := ecdhPart.NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	default:
+		return nil, fmt.Errorf("auth: unsupported key type %q", k.Kty)
+	}
+}
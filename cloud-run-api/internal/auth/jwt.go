@@ -0,0 +1,365 @@
+// internal/auth/jwt.go
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Google ID tokens, as minted for service-to-service calls on Cloud Run.
+const (
+	GoogleIssuer   = "https://accounts.google.com"
+	GoogleJWKSURL  = "https://www.googleapis.com/oauth2/v3/certs"
+	googleIssuerV1 = "accounts.google.com"
+)
+
+// maxTokenBytes bounds the bearer tokens parsed.
+const maxTokenBytes = 16 << 10
+
+// JWTVerifier verifies JSON Web Tokens from one issuer: the signature with
+// the issuer's key set, then the issuer, audience and validity period.
+type JWTVerifier struct {
+	// Name prefixes the IDs of principals it verifies, e.g. "google".
+	Name string
+	// Issuers are the accepted iss claims.
+	Issuers []string
+	// Audiences are the accepted aud claims; a token must name at least one.
+	Audiences []string
+	Keys      *JWKS
+	// ClockSkew is tolerated on exp, nbf and iat.
+	ClockSkew time.Duration
+	// RolesClaim names the claim holding the principal's roles, if any.
+	RolesClaim string
+	// Roles are granted to every principal the verifier accepts.
+	Roles []string
+	// AllowedEmails, if set, limits principals to these verified emails.
+	AllowedEmails []string
+
+	now func() time.Time
+}
+
+// NewGoogleIDTokenVerifier returns a verifier for Google-signed ID tokens
+// with one of audiences, typically the service's URL, from the service
+// accounts in allowedEmails. Anyone can mint a Google ID token for any
+// audience, so the email allowlist is what restricts callers.
+func NewGoogleIDTokenVerifier(audiences, allowedEmails []string, client *http.Client) *JWTVerifier {
+	return &JWTVerifier{
+		Name:          "google",
+		Issuers:       []string{GoogleIssuer, googleIssuerV1},
+		Audiences:     audiences,
+		Keys:          NewJWKS(GoogleJWKSURL, client),
+		AllowedEmails: allowedEmails,
+		Roles:         []string{RoleService},
+	}
+}
+
+// jwtHeader is the JOSE header of a JWS.
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// jwtClaims are the claims a verifier inspects. Roles are read separately
+// because their claim name is configurable.
+type jwtClaims struct {
+	Issuer        string          `json:"iss"`
+	Subject       string          `json:"sub"`
+	Audience      stringList      `json:"aud"`
+	ExpiresAt     *float64        `json:"exp"`
+	NotBefore     *float64        `json:"nbf"`
+	IssuedAt      *float64        `json:"iat"`
+	Email         string          `json:"email"`
+	EmailVerified json.RawMessage `json:"email_verified"`
+	Name          string          `json:"name"`
+	Scope         string          `json:"scope"`
+	Scp           stringList      `json:"scp"`
+}
+
+// stringList is a claim that may be a string or an array of strings.
+type stringList []string
+
+func (l *stringList) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*l = strings.Fields(s)
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// parsedJWT is a compact JWS split into its parts.
+type parsedJWT struct {
+	header       jwtHeader
+	claims       jwtClaims
+	rawClaims    map[string]json.RawMessage
+	signingInput string
+	signature    []byte
+}
+
+func parseJWT(token string) (parsedJWT, error) {
+	var p parsedJWT
+	if len(token) > maxTokenBytes {
+		return p, errors.New("token too large")
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return p, errors.New("malformed token")
+	}
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || json.Unmarshal(header, &p.header) != nil {
+		return p, errors.New("malformed token header")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || json.Unmarshal(payload, &p.claims) != nil || json.Unmarshal(payload, &p.rawClaims) != nil {
+		return p, errors.New("malformed token claims")
+	}
+	if p.signature, err = base64.RawURLEncoding.DecodeString(parts[2]); err != nil {
+		return p, errors.New("malformed token signature")
+	}
+	p.signingInput = parts[0] + "." + parts[1]
+	return p, nil
+}
+
+// Verify verifies token and returns its principal. Errors wrap
+// ErrInvalidCredentials, except failures to fetch the key set.
+func (v *JWTVerifier) Verify(ctx context.Context, token string) (Principal, error) {
+	p, err := parseJWT(token)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	return v.verify(ctx, p)
+}
+
+func (v *JWTVerifier) verify(ctx context.Context, p parsedJWT) (Principal, error) {
+	invalid := func(format string, args ...any) (Principal, error) {
+		return Principal{}, fmt.Errorf("%w: %s", ErrInvalidCredentials, fmt.Sprintf(format, args...))
+	}
+
+	key, err := v.Keys.Key(ctx, p.header.Kid)
+	if errors.Is(err, errUnknownKey) {
+		return invalid("%v", err)
+	}
+	if err != nil {
+		return Principal{}, err
+	}
+	if key.alg != "" && key.alg != p.header.Alg {
+		return invalid("key %q is for %s, not %s", p.header.Kid, key.alg, p.header.Alg)
+	}
+	if err := verifySignature(p.header.Alg, key.key, p.signingInput, p.signature); err != nil {
+		return invalid("%v", err)
+	}
+
+	c := p.claims
+	if !slices.Contains(v.Issuers, c.Issuer) {
+		return invalid("issuer %q is not trusted", c.Issuer)
+	}
+	if !slices.ContainsFunc(c.Audience, func(aud string) bool { return slices.Contains(v.Audiences, aud) }) {
+		return invalid("audience %q is not accepted", c.Audience)
+	}
+	now := time.Now()
+	if v.now != nil {
+		now = v.now()
+	}
+	switch {
+	case c.ExpiresAt == nil:
+		return invalid("token has no expiry")
+	case !now.Before(numericDate(*c.ExpiresAt).Add(v.ClockSkew)):
+		return invalid("token expired at %s", numericDate(*c.ExpiresAt).Format(time.RFC3339))
+	case c.NotBefore != nil && now.Add(v.ClockSkew).Before(numericDate(*c.NotBefore)):
+		return invalid("token is not valid before %s", numericDate(*c.NotBefore).Format(time.RFC3339))
+	case c.IssuedAt != nil && now.Add(v.ClockSkew).Before(numericDate(*c.IssuedAt)):
+		return invalid("token was issued in the future")
+	case c.Subject == "":
+		return invalid("token has no subject")
+	}
+
+	verifiedEmail := ""
+	if c.Email != "" && emailVerified(c.EmailVerified) {
+		verifiedEmail = c.Email
+	}
+	if len(v.AllowedEmails) > 0 && !slices.Contains(v.AllowedEmails, verifiedEmail) {
+		return invalid("email %q is not allowed", c.Email)
+	}
+
+	principal := Principal{
+		ID:     v.Name + ":" + c.Subject,
+		Name:   c.Name,
+		Scopes: knownScopes(append(strings.Fields(c.Scope), c.Scp...)),
+		Roles:  slices.Clone(v.Roles),
+	}
+	if verifiedEmail != "" {
+		principal.ID = v.Name + ":" + verifiedEmail
+		if principal.Name == "" {
+			principal.Name = verifiedEmail
+		}
+	}
+	if raw, ok := p.rawClaims[v.RolesClaim]; ok && v.RolesClaim != "" {
+		var roles stringList
+		if err := json.Unmarshal(raw, &roles); err != nil {
+			return invalid("claim %q is not a list of roles", v.RolesClaim)
+		}
+		principal.Roles = append(principal.Roles, roles...)
+	}
+	return principal, nil
+}
+
+// knownScopes keeps the scopes that are in KnownScopes. Token scopes are
+// issued by the identity provider, not this service, so others such as
+// "openid" or a "*" are not taken to grant anything.
+func knownScopes(scopes []string) []string {
+	return slices.DeleteFunc(scopes, func(scope string) bool { return !slices.Contains(KnownScopes, scope) })
+}
+
+// numericDate converts a JWT NumericDate (seconds since the epoch).
+func numericDate(seconds float64) time.Time {
+	return time.Unix(0, int64(seconds*float64(time.Second)))
+}
+
+// emailVerified reads an email_verified claim, which some providers send as
+// a string.
+func emailVerified(raw json.RawMessage) bool {
+	var b bool
+	if json.Unmarshal(raw, &b) == nil {
+		return b
+	}
+	var s string
+	return json.Unmarshal(raw, &s) == nil && s == "true"
+}
+
+// verifySignature checks a JWS signature. Only asymmetric algorithms are
+// accepted: "none" and HMAC would let anyone holding the public key, or
+// no key at all, forge tokens.
+func verifySignature(alg string, key crypto.PublicKey, signingInput string, sig []byte) error {
+	var h hash.Hash
+	var hashID crypto.Hash
+	switch alg {
+	case "RS256", "ES256", "PS256":
+		h, hashID = sha256.New(), crypto.SHA256
+	case "RS384", "ES384", "PS384":
+		h, hashID = sha512.New384(), crypto.SHA384
+	case "RS512", "PS512":
+		h, hashID = sha512.New(), crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	h.Write([]byte(signingInput))
+	digest := h.Sum(nil)
+
+	switch alg[:2] {
+	case "RS", "PS":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("algorithm %s needs an RSA key", alg)
+		}
+		var err error
+		if alg[0] == 'R' {
+			err = rsa.VerifyPKCS1v15(pub, hashID, digest, sig)
+		} else {
+			err = rsa.VerifyPSS(pub, hashID, digest, sig, nil)
+		}
+		if err != nil {
+			return errors.New("invalid signature")
+		}
+	case "ES":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("algorithm %s needs an EC key", alg)
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size || (alg == "ES256") != (size == 32) {
+			return errors.New("invalid signature")
+		}
+		r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errors.New("invalid signature")
+		}
+	}
+	return nil
+}
+
+// BearerAuthenticator authenticates requests with a JWT in an
+// "Authorization: Bearer" header, using the verifier for the token's issuer.
+type BearerAuthenticator struct {
+	Verifiers []*JWTVerifier
+}
+
+// NewBearerAuthenticator returns an authenticator accepting tokens from any
+// of verifiers.
+func NewBearerAuthenticator(verifiers ...*JWTVerifier) *BearerAuthenticator {
+	return &BearerAuthenticator{Verifiers: verifiers}
+}
+
+// Authenticate implements Authenticator.
+func (a *BearerAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return Principal{}, ErrNoCredentials
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return Principal{}, ErrNoCredentials
+	}
+
+	p, err := parseJWT(token)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	// The issuer is only trusted once the signature has been verified with
+	// the key set of the verifier it selects.
+	for _, v := range a.Verifiers {
+		if slices.Contains(v.Issuers, p.claims.Issuer) {
+			return v.verify(r.Context(), p)
+		}
+	}
+	return Principal{}, fmt.Errorf("%w: issuer %q is not trusted", ErrInvalidCredentials, p.claims.Issuer)
+}
+
+// Challenge implements Authenticator.
+func (a *BearerAuthenticator) Challenge(realm string) string {
+	return `Bearer realm="` + realm + `"`
+}
+
+// Chain authenticates requests with the first authenticator that finds
+// credentials it understands.
+type Chain []Authenticator
+
+// Authenticate implements Authenticator.
+func (c Chain) Authenticate(r *http.Request) (Principal, error) {
+	for _, a := range c {
+		p, err := a.Authenticate(r)
+		if !errors.Is(err, ErrNoCredentials) {
+			return p, err
+		}
+	}
+	return Principal{}, ErrNoCredentials
+}
+
+// Challenge implements Authenticator.
+func (c Chain) Challenge(realm string) string {
+	var challenges []string
+	for _, a := range c {
+		if challenge := a.Challenge(realm); challenge != "" {
+			challenges = append(challenges, challenge)
+		}
+	}
+	return strings.Join(challenges, ", ")
+}
@@ -0,0 +1,328 @@
+// internal/auth/jwt_test.go
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"your-module-name/internal/auth/authtest"
+)
+
+// testVerifier returns a verifier for iss's tokens to "api" with a clock
+// that the test can move.
+func testVerifier(iss *authtest.Issuer, keys *JWKS, now *time.Time) *JWTVerifier {
+	keys.now = func() time.Time { return *now }
+	return &JWTVerifier{
+		Name:       "oidc",
+		Issuers:    []string{iss.URL},
+		Audiences:  []string{"api"},
+		Keys:       keys,
+		ClockSkew:  time.Minute,
+		RolesClaim: "roles",
+		now:        func() time.Time { return *now },
+	}
+}
+
+func TestJWTVerifier(t *testing.T) {
+	iss := authtest.NewIssuer(t)
+	now := time.Now()
+	v := testVerifier(iss, NewJWKS(iss.URL+"/jwks.json", nil), &now)
+	ctx := context.Background()
+
+	t.Run("valid", func(t *testing.T) {
+		claims := iss.Claims("user-1", "api")
+		claims["name"] = "Ada"
+		claims["scope"] = "openid hello:read echo:write * messages:*"
+		claims["roles"] = []string{"admin"}
+		p, err := v.Verify(ctx, iss.Sign(t, claims))
+		require.NoError(t, err)
+		assert.Equal(t, Principal{
+			ID:     "oidc:user-1",
+			Name:   "Ada",
+			Scopes: []string{ScopeHelloRead, ScopeEchoWrite},
+			Roles:  []string{RoleAdmin},
+		}, p)
+	})
+
+	t.Run("audience list and verified email", func(t *testing.T) {
+		claims := iss.Claims("user-2", "")
+		claims["aud"] = []string{"other", "api"}
+		claims["email"] = "ada@example.com"
+		claims["email_verified"] = "true"
+		p, err := v.Verify(ctx, iss.Sign(t, claims))
+		require.NoError(t, err)
+		assert.Equal(t, "oidc:ada@example.com", p.ID)
+		assert.Equal(t, "ada@example.com", p.Name)
+	})
+
+	invalid := map[string]func(map[string]any){
+		"wrong audience":   func(c map[string]any) { c["aud"] = "other" },
+		"wrong issuer":     func(c map[string]any) { c["iss"] = "https://evil.example.com" },
+		"expired":          func(c map[string]any) { c["exp"] = now.Add(-2 * time.Minute).Unix() },
+		"no expiry":        func(c map[string]any) { delete(c, "exp") },
+		"not yet valid":    func(c map[string]any) { c["nbf"] = now.Add(2 * time.Minute).Unix() },
+		"issued in future": func(c map[string]any) { c["iat"] = now.Add(2 * time.Minute).Unix() },
+		"no subject":       func(c map[string]any) { delete(c, "sub") },
+		"malformed roles":  func(c map[string]any) { c["roles"] = map[string]string{"a": "b"} },
+	}
+	for name, mutate := range invalid {
+		t.Run(name, func(t *testing.T) {
+			claims := iss.Claims("user-1", "api")
+			mutate(claims)
+			_, err := v.Verify(ctx, iss.Sign(t, claims))
+			assert.ErrorIs(t, err, ErrInvalidCredentials)
+		})
+	}
+
+	t.Run("clock skew", func(t *testing.T) {
+		claims := iss.Claims("user-1", "api")
+		claims["exp"] = now.Add(-30 * time.Second).Unix()
+		claims["nbf"] = now.Add(30 * time.Second).Unix()
+		_, err := v.Verify(ctx, iss.Sign(t, claims))
+		assert.NoError(t, err)
+	})
+
+	t.Run("bad signature", func(t *testing.T) {
+		token := iss.Sign(t, iss.Claims("user-1", "api"))
+		other := iss.Sign(t, iss.Claims("user-2", "api"))
+		forged := token[:len(token)-len(signaturePart(other))] + signaturePart(other)
+		_, err := v.Verify(ctx, forged)
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	})
+
+	t.Run("unsigned", func(t *testing.T) {
+		for _, alg := range []string{"none", "HS256"} {
+			header, _ := json.Marshal(map[string]string{"alg": alg, "kid": "0"})
+			payload, _ := json.Marshal(iss.Claims("user-1", "api"))
+			token := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload) + "."
+			_, err := v.Verify(ctx, token)
+			assert.ErrorIs(t, err, ErrInvalidCredentials, alg)
+		}
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		for _, token := range []string{"", "a.b", "a.b.c", "!!.!!.!!"} {
+			_, err := v.Verify(ctx, token)
+			assert.ErrorIs(t, err, ErrInvalidCredentials, token)
+		}
+	})
+}
+
+func signaturePart(token string) string {
+	return token[strings.LastIndex(token, ".")+1:]
+}
+
+// longLivedClaims outlive the key set's max-age, for tests that wait it out.
+func longLivedClaims(iss *authtest.Issuer, now time.Time) map[string]any {
+	claims := iss.Claims("user-1", "api")
+	claims["exp"] = now.Add(24 * time.Hour).Unix()
+	return claims
+}
+
+func TestJWKS_Rotation(t *testing.T) {
+	iss := authtest.NewIssuer(t)
+	now := time.Now()
+	v := testVerifier(iss, NewJWKS(iss.URL+"/jwks.json", nil), &now)
+	ctx := context.Background()
+
+	_, err := v.Verify(ctx, iss.Sign(t, iss.Claims("user-1", "api")))
+	require.NoError(t, err)
+	_, err = v.Verify(ctx, iss.Sign(t, iss.Claims("user-1", "api")))
+	require.NoError(t, err)
+	assert.Equal(t, 1, iss.Fetches(), "cached key set is reused")
+
+	// A token signed with a new key is rejected until the set may be
+	// fetched again, so unknown key IDs cannot flood the issuer.
+	iss.Rotate(t)
+	rotated := iss.Sign(t, longLivedClaims(iss, now))
+	_, err = v.Verify(ctx, rotated)
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	assert.Equal(t, 1, iss.Fetches())
+
+	now = now.Add(minJWKSRefresh)
+	_, err = v.Verify(ctx, rotated)
+	require.NoError(t, err)
+	assert.Equal(t, 2, iss.Fetches())
+
+	// Once the max-age has passed the set is fetched again.
+	now = now.Add(2 * time.Hour)
+	_, err = v.Verify(ctx, rotated)
+	require.NoError(t, err)
+	assert.Equal(t, 3, iss.Fetches())
+}
+
+func TestJWKS_KeepsKeysWhenFetchFails(t *testing.T) {
+	iss := authtest.NewIssuer(t)
+	now := time.Now()
+	v := testVerifier(iss, NewJWKS(iss.URL+"/jwks.json", nil), &now)
+	token := iss.Sign(t, longLivedClaims(iss, now))
+	_, err := v.Verify(context.Background(), token)
+	require.NoError(t, err)
+
+	iss.Close()
+	now = now.Add(2 * time.Hour)
+	_, err = v.Verify(context.Background(), token)
+	assert.NoError(t, err)
+}
+
+func TestJWKS_FetchOutlivesTheRequest(t *testing.T) {
+	iss := authtest.NewIssuer(t)
+	now := time.Now()
+	v := testVerifier(iss, NewJWKS(iss.URL+"/jwks.json", nil), &now)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := v.Verify(ctx, iss.Sign(t, iss.Claims("user-1", "api")))
+	require.NoError(t, err, "a cancelled request does not fail the fetch it started")
+	assert.Equal(t, 1, iss.Fetches())
+}
+
+func TestJWKS_RetriesFailedFetches(t *testing.T) {
+	iss := authtest.NewIssuer(t)
+	now := time.Now()
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	v := testVerifier(iss, NewJWKS("file://"+path, nil), &now)
+	token := iss.Sign(t, iss.Claims("user-1", "api"))
+
+	_, err := v.Verify(context.Background(), token)
+	require.Error(t, err)
+	require.NoError(t, os.WriteFile(path, iss.JWKS(), 0o600))
+	_, err = v.Verify(context.Background(), token)
+	assert.Error(t, err, "failures are not retried at once")
+
+	now = now.Add(jwksRetryBackoff)
+	_, err = v.Verify(context.Background(), token)
+	assert.NoError(t, err, "a failure does not count as a fetch for minJWKSRefresh")
+}
+
+func TestJWKS_Sources(t *testing.T) {
+	iss := authtest.NewIssuer(t)
+	now := time.Now()
+
+	t.Run("file", func(t *testing.T) {
+		v := testVerifier(iss, NewJWKS(iss.WriteJWKS(t), nil), &now)
+		_, err := v.Verify(context.Background(), iss.Sign(t, iss.Claims("user-1", "api")))
+		assert.NoError(t, err)
+	})
+
+	t.Run("discovery", func(t *testing.T) {
+		v := testVerifier(iss, NewOIDCJWKS(iss.URL+"/", nil), &now)
+		_, err := v.Verify(context.Background(), iss.Sign(t, iss.Claims("user-1", "api")))
+		assert.NoError(t, err)
+	})
+
+	t.Run("unreachable", func(t *testing.T) {
+		v := testVerifier(iss, NewJWKS("file://"+filepath.Join(t.TempDir(), "missing.json"), nil), &now)
+		_, err := v.Verify(context.Background(), iss.Sign(t, iss.Claims("user-1", "api")))
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, ErrInvalidCredentials, "a key set outage is not the caller's fault")
+	})
+}
+
+func TestJWTVerifier_ES256(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	set, _ := json.Marshal(map[string]any{"keys": []map[string]string{{
+		"kty": "EC", "kid": "ec", "crv": "P-256",
+		"x": base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+		"y": base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+	}}})
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, set, 0o600))
+
+	header, _ := json.Marshal(map[string]string{"alg": "ES256", "kid": "ec"})
+	payload, _ := json.Marshal(map[string]any{"iss": "https://issuer.example.com", "aud": "api", "sub": "svc", "exp": time.Now().Add(time.Hour).Unix()})
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	require.NoError(t, err)
+	sig := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+
+	v := &JWTVerifier{Name: "ec", Issuers: []string{"https://issuer.example.com"}, Audiences: []string{"api"}, Keys: NewJWKS("file://"+path, nil)}
+	p, err := v.Verify(context.Background(), signingInput+"."+base64.RawURLEncoding.EncodeToString(sig))
+	require.NoError(t, err)
+	assert.Equal(t, "ec:svc", p.ID)
+
+	_, err = v.Verify(context.Background(), signingInput+"."+base64.RawURLEncoding.EncodeToString(sig[1:]))
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestGoogleIDTokenVerifier_AllowedEmails(t *testing.T) {
+	iss := authtest.NewIssuer(t)
+	now := time.Now()
+	v := NewGoogleIDTokenVerifier([]string{"https://api.example.com"}, []string{"caller@project.iam.gserviceaccount.com"}, nil)
+	// Trust the test issuer in place of Google.
+	v.Issuers = []string{iss.URL}
+	v.Keys = NewJWKS(iss.URL+"/jwks.json", nil)
+	v.now = func() time.Time { return now }
+
+	token := func(email string, verified bool) string {
+		claims := iss.Claims("1234567890", "https://api.example.com")
+		claims["email"] = email
+		claims["email_verified"] = verified
+		return iss.Sign(t, claims)
+	}
+
+	p, err := v.Verify(context.Background(), token("caller@project.iam.gserviceaccount.com", true))
+	require.NoError(t, err)
+	assert.Equal(t, "google:caller@project.iam.gserviceaccount.com", p.ID)
+	assert.True(t, p.HasRole(RoleService))
+
+	_, err = v.Verify(context.Background(), token("someone@gmail.com", true))
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = v.Verify(context.Background(), token("caller@project.iam.gserviceaccount.com", false))
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestBearerAuthenticator(t *testing.T) {
+	iss := authtest.NewIssuer(t)
+	now := time.Now()
+	bearer := NewBearerAuthenticator(testVerifier(iss, NewJWKS(iss.URL+"/jwks.json", nil), &now))
+	apiKeys := NewAPIKeyAuthenticator(NewMemoryKeyStore(APIKey{ID: "ci", Hash: HashAPIKey("ci-key"), Scopes: []string{ScopeEchoWrite}}))
+	chain := Chain{apiKeys, bearer}
+
+	authenticate := func(a Authenticator, header, value string) (Principal, error) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		return a.Authenticate(req)
+	}
+
+	p, err := authenticate(chain, "Authorization", "Bearer "+iss.Sign(t, iss.Claims("user-1", "api")))
+	require.NoError(t, err)
+	assert.Equal(t, "oidc:user-1", p.ID)
+
+	p, err = authenticate(chain, APIKeyHeader, "ci-key")
+	require.NoError(t, err)
+	assert.Equal(t, "apikey:ci", p.ID)
+
+	_, err = authenticate(chain, "", "")
+	assert.ErrorIs(t, err, ErrNoCredentials)
+	_, err = authenticate(bearer, "Authorization", "Bearer ")
+	assert.ErrorIs(t, err, ErrNoCredentials)
+	_, err = authenticate(chain, "Authorization", "Bearer not-a-jwt")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	other := authtest.NewIssuer(t)
+	_, err = authenticate(chain, "Authorization", "Bearer "+other.Sign(t, other.Claims("user-1", "api")))
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	assert.Zero(t, other.Fetches(), "untrusted issuers are never contacted")
+
+	assert.Equal(t, `ApiKey realm="svc", Bearer realm="svc"`, chain.Challenge("svc"))
+}
@@ -0,0 +1,128 @@
+// internal/authz/authz.go
+
+// Package authz decides what an authenticated principal may do. Routes
+// declare the permission they need; a Policy maps roles to permissions, and
+// a principal is granted the permissions of its roles plus its scopes, which
+// name permissions directly. Only policy grants may be patterns; a scope
+// grants exactly the permission of the same name.
+package authz
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"your-module-name/internal/auth"
+)
+
+// Permissions checked by the API routes. They share their names with the
+// scopes of internal/auth, so a scope grants the permission of that name.
+const (
+	PermHelloRead     = auth.ScopeHelloRead
+	PermEchoWrite     = auth.ScopeEchoWrite
+	PermMessagesRead  = auth.ScopeMessagesRead
+	PermMessagesWrite = auth.ScopeMessagesWrite
+)
+
+// KnownPermissions lists every permission a route may require.
+var KnownPermissions = auth.KnownScopes
+
+// Decision reasons reported in a Decision.
+const (
+	ReasonNoPermission = "no_permission" // The route only requires authentication.
+	ReasonScope        = "scope"
+	ReasonRole         = "role"
+	ReasonDenied       = "denied"
+)
+
+// Decision is the outcome of evaluating a permission for a principal.
+type Decision struct {
+	Allowed bool
+	Reason  string
+	// Grant is the scope or "role=grant" that allowed the permission.
+	Grant string
+}
+
+// Policy maps role names to the permissions they grant. A grant is a
+// permission name, a "resource:*" pattern matching every permission on the
+// resource, or "*" matching every permission.
+type Policy struct {
+	Roles map[string][]string `json:"roles"`
+}
+
+// DefaultPolicy is in effect when no policy file is configured: admins and
+// other services may call every route, and anonymous callers none that
+// require a permission.
+func DefaultPolicy() *Policy {
+	return &Policy{Roles: map[string][]string{
+		auth.RoleAdmin:     {"*"},
+		auth.RoleService:   {"*"},
+		auth.RoleAnonymous: {},
+	}}
+}
+
+// Policy implements Source.
+func (p *Policy) Policy() *Policy { return p }
+
+// Evaluate decides whether principal holds permission. An empty permission
+// only requires authentication and is always allowed.
+func (p *Policy) Evaluate(principal auth.Principal, permission string) Decision {
+	if permission == "" {
+		return Decision{Allowed: true, Reason: ReasonNoPermission}
+	}
+	if principal.HasScope(permission) {
+		return Decision{Allowed: true, Reason: ReasonScope, Grant: permission}
+	}
+	for _, role := range principal.Roles {
+		for _, grant := range p.Roles[role] {
+			if matches(grant, permission) {
+				return Decision{Allowed: true, Reason: ReasonRole, Grant: role + "=" + grant}
+			}
+		}
+	}
+	return Decision{Reason: ReasonDenied}
+}
+
+// Permissions returns the permissions role grants, sorted, with patterns
+// expanded against KnownPermissions.
+func (p *Policy) Permissions(role string) []string {
+	var perms []string
+	for _, perm := range KnownPermissions {
+		if slices.ContainsFunc(p.Roles[role], func(grant string) bool { return matches(grant, perm) }) {
+			perms = append(perms, perm)
+		}
+	}
+	sort.Strings(perms)
+	return perms
+}
+
+// Validate reports grants that match no known permission, which are
+// usually typos.
+func (p *Policy) Validate() error {
+	roles := make([]string, 0, len(p.Roles))
+	for role := range p.Roles {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	for _, role := range roles {
+		if role == "" {
+			return fmt.Errorf("role with empty name")
+		}
+		for _, grant := range p.Roles[role] {
+			if !slices.ContainsFunc(KnownPermissions, func(perm string) bool { return matches(grant, perm) }) {
+				return fmt.Errorf("role %q: grant %q matches no known permission", role, grant)
+			}
+		}
+	}
+	return nil
+}
+
+// matches reports whether grant grants permission.
+func matches(grant, permission string) bool {
+	if grant == "*" || grant == permission {
+		return true
+	}
+	resource, ok := strings.CutSuffix(grant, ":*")
+	return ok && strings.HasPrefix(permission, resource+":")
+}
@@ -0,0 +1,66 @@
+// internal/authz/authz_test.go
+package authz
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"your-module-name/internal/auth"
+)
+
+func TestPolicy_Evaluate(t *testing.T) {
+	policy := &Policy{Roles: map[string][]string{
+		"viewer":   {PermHelloRead, PermMessagesRead},
+		"operator": {"messages:*"},
+		"admin":    {"*"},
+	}}
+
+	for _, tc := range []struct {
+		name       string
+		principal  auth.Principal
+		permission string
+		want       Decision
+	}{
+		{"authentication only", auth.Principal{}, "", Decision{Allowed: true, Reason: ReasonNoPermission}},
+		{"scope", auth.Principal{Scopes: []string{PermEchoWrite}}, PermEchoWrite, Decision{Allowed: true, Reason: ReasonScope, Grant: PermEchoWrite}},
+		{"scopes are not patterns", auth.Principal{Scopes: []string{"messages:*", "*"}}, PermMessagesWrite, Decision{Reason: ReasonDenied}},
+		{"role", auth.Principal{Roles: []string{"viewer"}}, PermMessagesRead, Decision{Allowed: true, Reason: ReasonRole, Grant: "viewer=messages:read"}},
+		{"role pattern", auth.Principal{Roles: []string{"operator"}}, PermMessagesWrite, Decision{Allowed: true, Reason: ReasonRole, Grant: "operator=messages:*"}},
+		{"wildcard", auth.Principal{Roles: []string{"admin"}}, PermEchoWrite, Decision{Allowed: true, Reason: ReasonRole, Grant: "admin=*"}},
+		{"second role", auth.Principal{Roles: []string{"viewer", "operator"}}, PermMessagesWrite, Decision{Allowed: true, Reason: ReasonRole, Grant: "operator=messages:*"}},
+		{"role lacks permission", auth.Principal{Roles: []string{"viewer"}}, PermEchoWrite, Decision{Reason: ReasonDenied}},
+		{"pattern is per resource", auth.Principal{Roles: []string{"operator"}}, PermHelloRead, Decision{Reason: ReasonDenied}},
+		{"unknown role", auth.Principal{Roles: []string{"intern"}}, PermHelloRead, Decision{Reason: ReasonDenied}},
+		{"other scope", auth.Principal{Scopes: []string{PermHelloRead}}, PermEchoWrite, Decision{Reason: ReasonDenied}},
+		{"no grants", auth.Principal{ID: "apikey:empty"}, PermHelloRead, Decision{Reason: ReasonDenied}},
+	} {
+		assert.Equal(t, tc.want, policy.Evaluate(tc.principal, tc.permission), tc.name)
+	}
+}
+
+func TestDefaultPolicy(t *testing.T) {
+	policy := DefaultPolicy()
+	for _, perm := range KnownPermissions {
+		assert.True(t, policy.Evaluate(auth.Principal{Roles: []string{auth.RoleAdmin}}, perm).Allowed, perm)
+		assert.True(t, policy.Evaluate(auth.Principal{Roles: []string{auth.RoleService}}, perm).Allowed, perm)
+		assert.False(t, policy.Evaluate(auth.Principal{Roles: []string{"viewer"}}, perm).Allowed, perm)
+	}
+	assert.NoError(t, policy.Validate())
+}
+
+func TestPolicy_Permissions(t *testing.T) {
+	policy := &Policy{Roles: map[string][]string{"operator": {"messages:*", PermHelloRead}}}
+	assert.Equal(t, []string{PermHelloRead, PermMessagesRead, PermMessagesWrite}, policy.Permissions("operator"))
+	assert.Empty(t, policy.Permissions("unknown"))
+}
+
+func TestPolicy_Validate(t *testing.T) {
+	assert.NoError(t, (&Policy{}).Validate())
+	assert.NoError(t, (&Policy{Roles: map[string][]string{"operator": {"messages:*", PermEchoWrite, "*"}}}).Validate())
+
+	err := (&Policy{Roles: map[string][]string{"viewer": {"mesages:read"}}}).Validate()
+	assert.ErrorContains(t, err, `role "viewer": grant "mesages:read"`)
+	assert.Error(t, (&Policy{Roles: map[string][]string{"viewer": {"billing:*"}}}).Validate())
+	assert.Error(t, (&Policy{Roles: map[string][]string{"": {PermHelloRead}}}).Validate())
+}
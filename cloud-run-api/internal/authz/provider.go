@@ -0,0 +1,96 @@
+// internal/authz/provider.go
+package authz
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"your-module-name/internal/filewatch"
+)
+
+// Source supplies the policy in effect.
+// Implementations must be safe for concurrent use.
+type Source interface {
+	Policy() *Policy
+}
+
+// FileSource serves the policy in a JSON file of the form
+// {"roles": {"role": ["permission", ...]}} and reloads it when the file
+// changes, so roles can be changed without a restart. If a reload fails,
+// the last valid policy remains in effect.
+type FileSource struct {
+	file   *filewatch.File
+	logger *slog.Logger
+
+	// OnReload, if set, is called by Watch after each reload that loaded
+	// changes (err is nil) or failed. Set it before starting Watch.
+	OnReload func(ctx context.Context, err error)
+
+	mu     sync.RWMutex
+	policy *Policy
+}
+
+// NewFileSource loads the policy file at path. It returns an error if the
+// initial load fails, so a broken file is caught at startup.
+func NewFileSource(path string, logger *slog.Logger) (*FileSource, error) {
+	s := &FileSource{logger: logger}
+	s.file = filewatch.New(path, s.load)
+	if _, err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Policy implements Source.
+func (s *FileSource) Policy() *Policy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.policy
+}
+
+// Reload re-reads the file if its modification time or size changed since
+// the last successful load. It reports whether a new policy was loaded.
+func (s *FileSource) Reload() (bool, error) {
+	reloaded, err := s.file.Reload()
+	if err != nil {
+		return false, fmt.Errorf("authz: %w", err)
+	}
+	return reloaded, nil
+}
+
+// load parses and validates a policy file and puts it in effect.
+func (s *FileSource) load(data []byte) error {
+	var policy Policy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", s.file.Path(), err)
+	}
+	if err := policy.Validate(); err != nil {
+		return fmt.Errorf("invalid %s: %w", s.file.Path(), err)
+	}
+	s.mu.Lock()
+	s.policy = &policy
+	s.mu.Unlock()
+	return nil
+}
+
+// Watch polls the file every interval and reloads it on change until ctx is
+// cancelled. Reload failures are logged and the previous policy is kept.
+func (s *FileSource) Watch(ctx context.Context, interval time.Duration) {
+	s.file.Watch(ctx, interval, func(ctx context.Context, err error) {
+		if err != nil {
+			err = fmt.Errorf("authz: %w", err)
+		}
+		if s.OnReload != nil {
+			s.OnReload(ctx, err)
+		}
+		if err != nil {
+			s.logger.WarnContext(ctx, "Failed to reload authorization policy; keeping previous policy", "path", s.file.Path(), "error", err)
+			return
+		}
+		s.logger.InfoContext(ctx, "Authorization policy reloaded", "path", s.file.Path(), "roles", len(s.Policy().Roles))
+	})
+}
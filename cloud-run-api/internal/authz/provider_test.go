@@ -0,0 +1,59 @@
+// internal/authz/provider_test.go
+package authz
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePolicy(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestFileSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	start := time.Now().Add(-time.Hour)
+	writePolicy(t, path, `{"roles": {"viewer": ["hello:read"]}}`, start)
+
+	source, err := NewFileSource(path, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+	assert.Equal(t, []string{PermHelloRead}, source.Policy().Permissions("viewer"))
+
+	reloaded, err := source.Reload()
+	require.NoError(t, err)
+	assert.False(t, reloaded, "unchanged file is not reloaded")
+
+	writePolicy(t, path, `{"roles": {"viewer": ["hello:read", "messages:read"]}}`, start.Add(time.Minute))
+	reloaded, err = source.Reload()
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+	assert.Equal(t, []string{PermHelloRead, PermMessagesRead}, source.Policy().Permissions("viewer"))
+
+	// An invalid policy keeps the previous one in effect.
+	writePolicy(t, path, `{"roles": {"viewer": ["helo:read"]}}`, start.Add(2*time.Minute))
+	_, err = source.Reload()
+	require.Error(t, err)
+	assert.Equal(t, []string{PermHelloRead, PermMessagesRead}, source.Policy().Permissions("viewer"))
+}
+
+func TestNewFileSource_Errors(t *testing.T) {
+	dir := t.TempDir()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	_, err := NewFileSource(filepath.Join(dir, "missing.json"), logger)
+	assert.Error(t, err)
+
+	path := filepath.Join(dir, "broken.json")
+	writePolicy(t, path, `{"roles": [`, time.Now())
+	_, err = NewFileSource(path, logger)
+	assert.ErrorContains(t, err, "failed to parse")
+}
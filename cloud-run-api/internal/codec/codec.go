@@ -0,0 +1,236 @@
+// internal/codec/codec.go
+//
+// Package codec encodes and decodes API payloads in several media types and
+// negotiates which one to use from Accept and Content-Type headers.
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// Codec encodes and decodes values in one media type.
+type Codec interface {
+	// MediaType is the canonical media type, e.g. "application/json".
+	MediaType() string
+	// Aliases are other media types accepted for the same format.
+	Aliases() []string
+	Encode(w io.Writer, v any) error
+	Decode(r io.Reader, v any) error
+}
+
+// Media types of the built-in codecs.
+const (
+	JSONType    = "application/json"
+	XMLType     = "application/xml"
+	MsgPackType = "application/msgpack"
+	CBORType    = "application/cbor"
+	YAMLType    = "application/yaml"
+)
+
+// JSON encodes values with encoding/json.
+type JSON struct{}
+
+func (JSON) MediaType() string { return JSONType }
+func (JSON) Aliases() []string { return nil }
+
+func (JSON) Encode(w io.Writer, v any) error { return json.NewEncoder(w).Encode(v) }
+func (JSON) Decode(r io.Reader, v any) error { return json.NewDecoder(r).Decode(v) }
+
+// XML encodes values with encoding/xml.
+type XML struct{}
+
+func (XML) MediaType() string { return XMLType }
+func (XML) Aliases() []string { return []string{"text/xml"} }
+
+func (XML) Encode(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(v)
+}
+func (XML) Decode(r io.Reader, v any) error { return xml.NewDecoder(r).Decode(v) }
+
+// MsgPack encodes values as MessagePack, using `msgpack` struct tags.
+type MsgPack struct{}
+
+func (MsgPack) MediaType() string { return MsgPackType }
+func (MsgPack) Aliases() []string {
+	return []string{"application/x-msgpack", "application/vnd.msgpack"}
+}
+
+func (MsgPack) Encode(w io.Writer, v any) error { return msgpack.NewEncoder(w).Encode(v) }
+func (MsgPack) Decode(r io.Reader, v any) error { return msgpack.NewDecoder(r).Decode(v) }
+
+// CBOR encodes values as CBOR (RFC 8949), using `cbor` struct tags.
+type CBOR struct{}
+
+func (CBOR) MediaType() string { return CBORType }
+func (CBOR) Aliases() []string { return nil }
+
+func (CBOR) Encode(w io.Writer, v any) error { return cbor.NewEncoder(w).Encode(v) }
+func (CBOR) Decode(r io.Reader, v any) error { return cbor.NewDecoder(r).Decode(v) }
+
+// YAML encodes values as YAML, using `yaml` struct tags.
+type YAML struct{}
+
+func (YAML) MediaType() string { return YAMLType }
+func (YAML) Aliases() []string { return []string{"application/x-yaml", "text/yaml"} }
+
+func (YAML) Encode(w io.Writer, v any) error {
+	enc := yaml.NewEncoder(w)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func (YAML) Decode(r io.Reader, v any) error {
+	// yaml.v3 reports an empty document as io.EOF, like encoding/json.
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(r); err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(buf.Bytes())) == 0 {
+		return io.EOF
+	}
+	return yaml.Unmarshal(buf.Bytes(), v)
+}
+
+// Registry holds codecs keyed by media type. The first codec registered is
+// the default and wins ties in negotiation.
+type Registry struct {
+	codecs []Codec
+	byType map[string]Codec
+}
+
+// NewRegistry returns a registry of codecs, in order of preference.
+func NewRegistry(codecs ...Codec) *Registry {
+	r := &Registry{byType: make(map[string]Codec)}
+	for _, c := range codecs {
+		r.codecs = append(r.codecs, c)
+		r.byType[c.MediaType()] = c
+		for _, alias := range c.Aliases() {
+			r.byType[alias] = c
+		}
+	}
+	return r
+}
+
+// Default returns a registry of the built-in codecs, JSON first.
+func Default() *Registry {
+	return NewRegistry(JSON{}, XML{}, MsgPack{}, CBOR{}, YAML{})
+}
+
+// Codecs returns the registered codecs in order of preference.
+func (r *Registry) Codecs() []Codec { return r.codecs }
+
+// Default returns the preferred codec.
+func (r *Registry) Default() Codec { return r.codecs[0] }
+
+// Lookup returns the codec for a media type, ignoring parameters such as
+// charset. An empty media type selects the default codec.
+func (r *Registry) Lookup(mediaType string) (Codec, bool) {
+	if strings.TrimSpace(mediaType) == "" {
+		return r.Default(), true
+	}
+	mt, _, err := mime.ParseMediaType(mediaType)
+	if err != nil {
+		return nil, false
+	}
+	c, ok := r.byType[mt]
+	return c, ok
+}
+
+// Negotiate picks the codec for a response from an Accept header (RFC 9110
+// section 12.5.1). The most specific media range matching a codec sets its
+// quality; the codec with the highest quality wins, ties going to the
+// preferred one. It returns false if no codec is acceptable. An empty header
+// accepts the default codec.
+func (r *Registry) Negotiate(accept string) (Codec, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return r.Default(), true
+	}
+	ranges := parseAccept(accept)
+
+	var (
+		best  Codec
+		bestQ float64
+	)
+	for _, c := range r.codecs {
+		q := quality(ranges, c)
+		if q > bestQ {
+			best, bestQ = c, q
+		}
+	}
+	return best, best != nil
+}
+
+// mediaRange is one element of an Accept header.
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+// specificity ranks how precisely a range names a media type.
+func (m mediaRange) specificity() int {
+	switch {
+	case m.typ == "*":
+		return 0
+	case m.subtype == "*":
+		return 1
+	default:
+		return 2
+	}
+}
+
+func (m mediaRange) matches(mediaType string) bool {
+	typ, subtype, _ := strings.Cut(mediaType, "/")
+	return (m.typ == "*" || m.typ == typ) && (m.subtype == "*" || m.subtype == subtype)
+}
+
+// parseAccept parses an Accept header, skipping malformed elements.
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		typ, subtype, ok := strings.Cut(mt, "/")
+		if !ok || (typ == "*" && subtype != "*") {
+			continue
+		}
+		q := 1.0
+		if s, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(s, 64); err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+		ranges = append(ranges, mediaRange{typ: typ, subtype: subtype, q: q})
+	}
+	return ranges
+}
+
+// quality returns the quality the ranges assign to c: that of the most
+// specific range matching any of its media types, or 0.
+func quality(ranges []mediaRange, c Codec) float64 {
+	q, specificity := 0.0, -1
+	for _, mt := range append([]string{c.MediaType()}, c.Aliases()...) {
+		for _, m := range ranges {
+			if m.matches(mt) && m.specificity() > specificity {
+				q, specificity = m.q, m.specificity()
+			}
+		}
+	}
+	return q
+}
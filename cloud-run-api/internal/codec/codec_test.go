@@ -0,0 +1,157 @@
+// internal/codec/codec_test.go
+package codec
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"your-module-name/internal/models"
+)
+
+func TestNegotiate(t *testing.T) {
+	r := Default()
+	tests := []struct {
+		accept string
+		want   string // "" means not acceptable.
+	}{
+		{"", JSONType},
+		{"*/*", JSONType},
+		{"application/xml", XMLType},
+		{"text/xml", XMLType},
+		{"application/x-msgpack", MsgPackType},
+		{"application/cbor, application/json;q=0.9", CBORType},
+		{"application/json;q=0.5, application/yaml", YAMLType},
+		{"application/*;q=0.2, application/xml;q=0.8", XMLType},
+		{"*/*;q=0.1, application/json;q=0", XMLType}, // q=0 excludes JSON; XML is the next preference.
+		{"application/*, application/json;q=0, application/xml;q=0", MsgPackType},
+		{"text/*", XMLType},
+		{"image/png", ""},
+		{"application/json;q=0", ""},
+		{"garbage;;, application/yaml", YAMLType},
+		{"application/json;q=2", ""},
+	}
+	for _, tt := range tests {
+		c, ok := r.Negotiate(tt.accept)
+		if tt.want == "" {
+			assert.False(t, ok, "%q should not be acceptable", tt.accept)
+			continue
+		}
+		require.True(t, ok, tt.accept)
+		assert.Equal(t, tt.want, c.MediaType(), tt.accept)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	r := Default()
+	for mediaType, want := range map[string]string{
+		"":                                JSONType,
+		"application/json; charset=utf-8": JSONType,
+		"Application/YAML":                YAMLType,
+		"application/vnd.msgpack":         MsgPackType,
+		"text/xml; charset=utf-8":         XMLType,
+	} {
+		c, ok := r.Lookup(mediaType)
+		require.True(t, ok, mediaType)
+		assert.Equal(t, want, c.MediaType(), mediaType)
+	}
+	for _, mediaType := range []string{"text/plain", "application/", ";"} {
+		_, ok := r.Lookup(mediaType)
+		assert.False(t, ok, mediaType)
+	}
+}
+
+// TestRoundTrip encodes and decodes API models with every codec.
+func TestRoundTrip(t *testing.T) {
+	msg := models.Message{ID: "m1", Text: "héllo <world> & \"friends\"", Caller: "10.0.0.1", CreateTime: "2025-06-01T12:00:00Z"}
+	values := []any{
+		&models.EchoRequest{TextToEcho: "echo me"},
+		&models.EchoResponse{MessageID: "m1", ReceivedText: "x", Reply: "y", Timestamp: "t"},
+		&models.ListMessagesResponse{Messages: []models.Message{msg, {ID: "m2", Text: "two", CreateTime: "t"}}, NextPageToken: "next"},
+		&models.SearchMessagesResponse{
+			Results:   []models.SearchResult{{Message: msg, Score: 1.25, Highlights: []string{"<em>héllo</em>"}}},
+			TotalSize: 1,
+		},
+		&models.Problem{Type: "about:blank", Title: "Gone", Status: 410, Detail: "deleted", Instance: "/messages/m1"},
+		&models.VersionResponse{Service: "svc", Version: "v1", GoVersion: "go1.24", Modified: true},
+	}
+	for _, c := range Default().Codecs() {
+		for _, v := range values {
+			var buf bytes.Buffer
+			require.NoError(t, c.Encode(&buf, v), "%s %T", c.MediaType(), v)
+
+			decoded := newOf(v)
+			require.NoError(t, c.Decode(&buf, decoded), "%s %T:\n%s", c.MediaType(), v, buf.String())
+			clearXMLName(decoded)
+			assert.Equal(t, v, decoded, "%s %T", c.MediaType(), v)
+		}
+	}
+}
+
+func TestEncodedFieldNames(t *testing.T) {
+	v := models.EchoResponse{MessageID: "m1"}
+	for _, c := range []Codec{JSON{}, XML{}, YAML{}} {
+		var buf bytes.Buffer
+		require.NoError(t, c.Encode(&buf, v))
+		assert.Contains(t, buf.String(), "message_id", c.MediaType())
+		assert.NotContains(t, buf.String(), "XMLName", c.MediaType())
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, XML{}.Encode(&buf, models.Problem{Status: 404}))
+	assert.Contains(t, buf.String(), `<problem xmlns="urn:ietf:rfc:7807">`)
+}
+
+func TestDecodeEmptyBody(t *testing.T) {
+	for _, c := range Default().Codecs() {
+		var v models.EchoRequest
+		assert.ErrorIs(t, c.Decode(bytes.NewReader(nil), &v), io.EOF, c.MediaType())
+	}
+}
+
+// newOf returns a pointer to a new zero value of the type v points to.
+func newOf(v any) any {
+	switch v.(type) {
+	case *models.EchoRequest:
+		return new(models.EchoRequest)
+	case *models.EchoResponse:
+		return new(models.EchoResponse)
+	case *models.ListMessagesResponse:
+		return new(models.ListMessagesResponse)
+	case *models.SearchMessagesResponse:
+		return new(models.SearchMessagesResponse)
+	case *models.Problem:
+		return new(models.Problem)
+	case *models.VersionResponse:
+		return new(models.VersionResponse)
+	}
+	panic("unexpected type")
+}
+
+// clearXMLName zeroes the XMLName fields filled in by XML decoding.
+func clearXMLName(v any) {
+	switch v := v.(type) {
+	case *models.EchoRequest:
+		v.XMLName.Local, v.XMLName.Space = "", ""
+	case *models.EchoResponse:
+		v.XMLName.Local = ""
+	case *models.ListMessagesResponse:
+		v.XMLName.Local = ""
+		for i := range v.Messages {
+			v.Messages[i].XMLName.Local = ""
+		}
+	case *models.SearchMessagesResponse:
+		v.XMLName.Local = ""
+		for i := range v.Results {
+			v.Results[i].XMLName.Local = ""
+			v.Results[i].Message.XMLName.Local = ""
+		}
+	case *models.Problem:
+		v.XMLName.Local, v.XMLName.Space = "", ""
+	case *models.VersionResponse:
+		v.XMLName.Local = ""
+	}
+}
@@ -1,8 +1,15 @@
 // internal/config/config.go
 package config
 
+//go:generate go run ../../cmd config docs -readme ../../README.md -env ../../.env.example
+
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"net/netip"
+	"strings"
+	"time"
 	// "os" // No longer directly needed for Getenv
 
 	"github.com/duizendstra/dui-go/env" // Use your library
@@ -10,21 +17,291 @@ import (
 
 // Config holds application configuration values loaded from the environment.
 // Struct tags define the corresponding environment variables, defaults, and requirements.
+//
+// Besides the tags understood by env.Process, each field carries documentation tags
+// used to generate the configuration reference (see docs.go):
+//   - `envDescription:"..."`: Human-readable description of the variable.
+//   - `envExample:"..."`: Placeholder value written to .env.example when there is no default.
+//   - `envSecret:"true"`: Marks the value as sensitive; it is never written to generated files.
 type Config struct {
-	ServiceName string `env:"API_SERVICE_NAME" envDefault:"go-hello-world-api"`
-	Port        string `env:"PORT" envDefault:"8080"`
-	// GOOGLE_CLOUD_PROJECT is needed by the cloudlogging library internally,
-	// but env.Process doesn't strictly need to load it into *this* struct
-	// unless other parts of *your* application code need it directly.
-	// If only the logger needs it, we might not need it here.
-	// Let's assume for now your app *might* need it elsewhere, or for clarity.
-	ProjectID string `env:"GOOGLE_CLOUD_PROJECT" envRequired:"true"`
+	ServiceName string `env:"API_SERVICE_NAME" envDefault:"go-hello-world-api" envDescription:"Service name used in responses and as the logging component."`
+	Port        string `env:"PORT" envDefault:"8080" envDescription:"Port the HTTP server listens on. Set automatically by Cloud Run."`
+	// RuntimeMode selects between running on Google Cloud ("cloud") and local
+	// development ("local"). In local mode the project ID is optional, logs are
+	// human-readable text and Cloud Trace correlation is skipped.
+	RuntimeMode string `env:"RUNTIME_MODE" envDefault:"cloud" envDescription:"Runtime mode: 'cloud' (Cloud Run, GKE, GCE) or 'local' for development."`
+	// GOOGLE_CLOUD_PROJECT is used for Cloud Logging trace correlation. When it is
+	// not set, Load discovers the project (see ProjectDiscoverer); discovery must
+	// succeed in cloud mode.
+	ProjectID string `env:"GOOGLE_CLOUD_PROJECT" envExample:"your-gcp-project-id" envDescription:"Google Cloud project ID. Discovered from gcloud, credentials or the metadata server when unset; optional in local mode."`
+	// Logging. See internal/logging for how these select and configure handlers.
+	LogLevel         string `env:"LOG_LEVEL" envDefault:"INFO" envDescription:"Minimum log severity: DEBUG, INFO, WARNING or ERROR."`
+	LogFormat        string `env:"LOG_FORMAT" envDescription:"Comma-separated log outputs: cloud, json, text, otlp. Defaults to cloud, or text in local mode."`
+	OTLPLogsEndpoint string `env:"OTEL_EXPORTER_OTLP_LOGS_ENDPOINT" envDefault:"http://localhost:4318/v1/logs" envDescription:"OTLP/HTTP logs endpoint used by the otlp log format."`
+	Revision         string `env:"K_REVISION" envDescription:"Revision name, set automatically by Cloud Run; added to every log entry."`
+
+	// Log redaction, applied to every log output. See logging.NewRedactingHandler.
+	LogRedactKeys      string `env:"LOG_REDACT_KEYS" envDescription:"Extra comma-separated attribute key patterns to redact, added to the built-in list (e.g. '*email*,x-user-*')."`
+	LogRedactDetectors string `env:"LOG_REDACT_DETECTORS" envDefault:"email,credit_card,bearer" envDescription:"Value detectors applied to every logged string: email, credit_card, bearer."`
+	LogRedactStrategy  string `env:"LOG_REDACT_STRATEGY" envDefault:"replace" envDescription:"How redacted values are masked: replace, remove, hash or partial."`
+	LogRedactHashKey   string `env:"LOG_REDACT_HASH_KEY" envSecret:"true" envDescription:"Optional HMAC key for the hash redaction strategy."`
+
+	// Feature flags. FEATURE_FLAGS_FILE takes precedence over FEATURE_FLAGS when set.
+	FeatureFlags              string `env:"FEATURE_FLAGS" envDescription:"Static feature flags, e.g. 'echo-uppercase=on,hello-friendly-greeting=25%'."`
+	FeatureFlagsFile          string `env:"FEATURE_FLAGS_FILE" envDescription:"Path to a JSON feature flag file, reloaded when it changes."`
+	FeatureFlagsReloadSeconds int    `env:"FEATURE_FLAGS_RELOAD_SECONDS" envDefault:"10" envDescription:"How often FEATURE_FLAGS_FILE is checked for changes, in seconds."`
+
+	// Message storage. See Store.
+	Store
+
+	// Idempotency-Key handling on mutating endpoints. See api.withIdempotency.
+	IdempotencyTTLSeconds int `env:"IDEMPOTENCY_TTL_SECONDS" envDefault:"86400" envDescription:"How long responses to requests with an Idempotency-Key are kept for replay, in seconds."`
+	IdempotencyWaitMillis int `env:"IDEMPOTENCY_WAIT_MS" envDefault:"2000" envDescription:"How long a duplicate of an in-progress idempotent request waits for it before getting 409, in milliseconds."`
+	IdempotencyMaxKeys    int `env:"IDEMPOTENCY_MAX_KEYS" envDefault:"10000" envDescription:"Maximum number of Idempotency-Keys remembered per instance; the oldest completed ones are evicted, and requests get 503 while keys in progress fill the store. 0 means no limit."`
+	IdempotencyMaxBytes   int `env:"IDEMPOTENCY_MAX_BYTES" envDefault:"67108864" envDescription:"Maximum total size in bytes of the responses remembered for Idempotency-Keys per instance; the oldest are evicted first. 0 means no limit."`
+
+	// Authentication. See internal/auth and api.withAuth. Probes are never
+	// authenticated.
+	AuthRequired         bool   `env:"AUTH_REQUIRED" envDefault:"true" envDescription:"Reject requests without credentials. Requires API_KEYS_FILE, OIDC_ISSUER, GOOGLE_ID_TOKEN_AUDIENCE or TLS_CLIENT_CA_FILE in cloud mode; when false, such requests are served with the permissions the authorization policy grants the anonymous role."`
+	APIKeysFile          string `env:"API_KEYS_FILE" envDescription:"Path to a JSON file of hashed API keys, reloaded when it changes. Create entries with the 'apikey create' command."`
+	APIKeysReloadSeconds int    `env:"API_KEYS_RELOAD_SECONDS" envDefault:"10" envDescription:"How often API_KEYS_FILE is checked for changes, in seconds."`
+
+	// Bearer tokens (JWT): Google ID tokens for service-to-service calls and
+	// an OpenID Connect provider for users. Comma-separated lists.
+	GoogleIDTokenAudience string `env:"GOOGLE_ID_TOKEN_AUDIENCE" envExample:"https://your-service-abc123-ew.a.run.app" envDescription:"Accepted audiences of Google-signed ID tokens, usually the service URL. Enables Google ID token authentication."`
+	GoogleIDTokenEmails   string `env:"GOOGLE_ID_TOKEN_EMAILS" envExample:"caller@your-gcp-project-id.iam.gserviceaccount.com" envDescription:"Service accounts allowed to call with Google ID tokens; required with GOOGLE_ID_TOKEN_AUDIENCE."`
+	OIDCIssuer            string `env:"OIDC_ISSUER" envDescription:"Issuer URL of the OpenID Connect provider whose tokens are accepted."`
+	OIDCAudience          string `env:"OIDC_AUDIENCE" envDescription:"Accepted audiences of OIDC_ISSUER tokens; required with OIDC_ISSUER."`
+	OIDCJWKSURL           string `env:"OIDC_JWKS_URL" envDescription:"Key set URL of OIDC_ISSUER (https:// or file://). Discovered from the issuer when unset."`
+	OIDCRolesClaim        string `env:"OIDC_ROLES_CLAIM" envDefault:"roles" envDescription:"Claim of OIDC_ISSUER tokens holding the user's roles."`
+	JWTClockSkewSeconds   int    `env:"JWT_CLOCK_SKEW_SECONDS" envDefault:"60" envDescription:"Clock skew tolerated when checking token expiry and validity, in seconds."`
+
+	// Authorization of authenticated callers. See internal/authz and
+	// api.withPermission.
+	AuthzPolicyFile          string `env:"AUTHZ_POLICY_FILE" envDescription:"Path to a JSON file mapping roles to permissions, reloaded when it changes. Without it, the admin and service roles are granted every permission."`
+	AuthzPolicyReloadSeconds int    `env:"AUTHZ_POLICY_RELOAD_SECONDS" envDefault:"10" envDescription:"How often AUTHZ_POLICY_FILE is checked for changes, in seconds."`
+	AuthzMode                string `env:"AUTHZ_MODE" envDefault:"enforce" envDescription:"'enforce' answers requests lacking a permission with 403; 'audit' only logs them, for trying out a policy."`
+
+	// TLS listener, for running outside Cloud Run. See internal/tlsconfig.
+	// With TLS_CLIENT_CA_FILE, verified client certificates authenticate
+	// callers (mutual TLS).
+	TLSCertFile      string `env:"TLS_CERT_FILE" envDescription:"PEM certificate chain to serve HTTPS with; requires TLS_KEY_FILE. Reloaded when it changes."`
+	TLSKeyFile       string `env:"TLS_KEY_FILE" envSecret:"true" envDescription:"PEM private key of TLS_CERT_FILE."`
+	TLSClientCAFile  string `env:"TLS_CLIENT_CA_FILE" envDescription:"PEM bundle of CAs that sign client certificates; enables mutual TLS. Requires TLS_CERT_FILE."`
+	TLSClientAuth    string `env:"TLS_CLIENT_AUTH" envDefault:"require" envDescription:"With TLS_CLIENT_CA_FILE: 'require' rejects connections without a valid client certificate, 'verify-if-given' also accepts callers with other credentials."`
+	TLSReloadSeconds int    `env:"TLS_RELOAD_SECONDS" envDefault:"30" envDescription:"How often the TLS certificate, key and client CA files are checked for changes, in seconds."`
+
+	// Audit events. They are always written to the log stream under the
+	// "audit" log name; see internal/audit.
+	AuditLogFile   string `env:"AUDIT_LOG_FILE" envDescription:"Path of a local file that audit events are also appended to, as JSON lines."`
+	AuditHashChain bool   `env:"AUDIT_HASH_CHAIN" envDefault:"false" envDescription:"Chain the records of AUDIT_LOG_FILE by hash so edits are detectable with the 'audit verify' command."`
+
+	// Rate limiting. See internal/ratelimit and api.withRateLimit.
+	RateLimits string `env:"RATE_LIMITS" envExample:"*=600/m,echo:write=60/m:120" envDescription:"Per-client rate limits as name=count/period[:burst] (period s, m or h), where name is a route's permission or * for every other route. Empty disables rate limiting."`
+	// The default trusts the Google Front End, which proxies requests to
+	// Cloud Load Balancing backends. See internal/proxy.
+	TrustedProxies     string `env:"TRUSTED_PROXIES" envDefault:"35.191.0.0/16,130.211.0.0/22" envDescription:"Comma-separated IPs or CIDRs of proxies whose TRUSTED_PROXY_HEADER is trusted to name the client's IP and scheme. Empty trusts no proxy."`
+	TrustedProxyHeader string `env:"TRUSTED_PROXY_HEADER" envDefault:"X-Forwarded-For" envDescription:"Header the trusted proxies add the client to: X-Forwarded-For (with X-Forwarded-Proto), as the Google Front End does, or Forwarded. The other header is ignored, since proxies pass it through from the client."`
+
+	// Adaptive concurrency limiting and load shedding. See internal/loadshed
+	// and api.withLoadShedding.
+	ConcurrencyMaxLimit           int `env:"CONCURRENCY_MAX_LIMIT" envDefault:"80" envDescription:"Upper bound of the adaptive limit on requests served at once; match the Cloud Run concurrency setting. 0 disables concurrency limiting."`
+	ConcurrencyMinLimit           int `env:"CONCURRENCY_MIN_LIMIT" envDefault:"4" envDescription:"Lower bound of the adaptive concurrency limit."`
+	ConcurrencyLatencyThresholdMS int `env:"CONCURRENCY_LATENCY_THRESHOLD_MS" envDefault:"1000" envDescription:"Request latency above which the concurrency limit backs off, in milliseconds."`
+	ConcurrencyQueueSize          int `env:"CONCURRENCY_QUEUE_SIZE" envDefault:"50" envDescription:"Requests that may wait for a slot when the concurrency limit is reached; further requests get 503."`
+	ConcurrencyQueueTimeoutMS     int `env:"CONCURRENCY_QUEUE_TIMEOUT_MS" envDefault:"500" envDescription:"How long a request may wait for a slot before getting 503, in milliseconds."`
+
+	// Request deadlines. See api.withTimeout.
+	RequestTimeoutMillis    int    `env:"REQUEST_TIMEOUT_MS" envDefault:"10000" envDescription:"Deadline of requests to routes without their own in REQUEST_TIMEOUTS, in milliseconds; slower requests get 504."`
+	RequestTimeouts         string `env:"REQUEST_TIMEOUTS" envExample:"echo:write=2s,messages:read=5s" envDescription:"Per-route deadlines as name=duration, where name is a route's permission."`
+	RequestTimeoutMaxMillis int    `env:"REQUEST_TIMEOUT_MAX_MS" envDefault:"30000" envDescription:"Longest deadline a client may ask for with X-Request-Timeout or grpc-timeout, in milliseconds."`
+
+	// Cross-origin requests from browser frontends. See internal/cors and
+	// api.withCORS.
+	CORSAllowedOrigins   string `env:"CORS_ALLOWED_ORIGINS" envExample:"https://app.example.com,https://*.example.com" envDescription:"Comma-separated origins allowed to call the API from a browser: exact, https://*.example.com for any subdomain, ~regexp matched against the whole origin, or * for any. Empty disables CORS."`
+	CORSRouteOrigins     string `env:"CORS_ROUTE_ORIGINS" envExample:"messages:write=https://admin.example.com" envDescription:"Per-route allowed origins as name=origin origin..., where name is a route's permission; replaces CORS_ALLOWED_ORIGINS on that route, and an empty list disables CORS on it."`
+	CORSAllowedMethods   string `env:"CORS_ALLOWED_METHODS" envDefault:"GET,HEAD,POST,DELETE" envDescription:"Comma-separated methods allowed in cross-origin requests."`
+	CORSAllowedHeaders   string `env:"CORS_ALLOWED_HEADERS" envDefault:"Authorization,Content-Type,Content-Encoding,Idempotency-Key,If-Match,If-None-Match,X-API-Key,X-Request-Timeout" envDescription:"Comma-separated request headers cross-origin requests may send."`
+	CORSExposedHeaders   string `env:"CORS_EXPOSED_HEADERS" envDefault:"ETag,Retry-After,RateLimit-Limit,RateLimit-Remaining,RateLimit-Reset,RateLimit-Policy,Idempotent-Replayed" envDescription:"Comma-separated response headers browsers let cross-origin scripts read."`
+	CORSAllowCredentials bool   `env:"CORS_ALLOW_CREDENTIALS" envDefault:"false" envDescription:"Let browsers send cookies and HTTP authentication with cross-origin requests. Cannot be combined with the origin *."`
+	CORSMaxAgeSeconds    int    `env:"CORS_MAX_AGE_SECONDS" envDefault:"600" envDescription:"How long browsers may cache a preflight response, in seconds."`
+
+	// Security headers added to every response. See api.withSecurityHeaders.
+	StrictTransportSecurity string `env:"STRICT_TRANSPORT_SECURITY" envDefault:"max-age=31536000; includeSubDomains" envDescription:"Strict-Transport-Security header of responses to HTTPS requests. Empty omits it."`
+	ContentSecurityPolicy   string `env:"CONTENT_SECURITY_POLICY" envDefault:"default-src 'none'; frame-ancestors 'none'" envDescription:"Content-Security-Policy header of responses. Empty omits it."`
+	ReferrerPolicy          string `env:"REFERRER_POLICY" envDefault:"no-referrer" envDescription:"Referrer-Policy header of responses. Empty omits it."`
+	SecurityHeadersRoutes   string `env:"SECURITY_HEADERS_ROUTES" envExample:"{\"hello:read\":{\"Content-Security-Policy\":\"\"}}" envDescription:"Per-route response headers as a JSON object of route permissions to header names and values; each replaces the default header, and an empty value omits it."`
+
+	// MetricsPort serves /metrics on a separate listener, so the metrics
+	// are not exposed on the service URL. See internal/metrics.
+	MetricsPort string `env:"METRICS_PORT" envExample:"9090" envDescription:"Port serving Prometheus metrics at /metrics, e.g. for the Managed Service for Prometheus sidecar. Empty disables the metrics listener."`
+
+	// Request and response bodies. See api.withCompression and api.withRequestDecoding.
+	MaxRequestBodyBytes int `env:"MAX_REQUEST_BODY_BYTES" envDefault:"1048576" envDescription:"Maximum size of a request body in bytes, both as sent and after decompressing its Content-Encoding."`
+	CompressionMinBytes int `env:"COMPRESSION_MIN_BYTES" envDefault:"1024" envDescription:"Smallest response body compressed with the client's Accept-Encoding (zstd, br or gzip), in bytes."`
+}
+
+// Store configures the message store. It is embedded in Config, and loaded on
+// its own by LoadStore for commands that only use the store, such as migrate.
+// See internal/store and its backend subpackages.
+type Store struct {
+	StoreBackend     string `env:"STORE_BACKEND" envDefault:"memory" envDescription:"Message store backend: 'memory', 'sqlite' or 'postgres'."`
+	SQLitePath       string `env:"SQLITE_PATH" envDefault:"/tmp/messages.db" envDescription:"SQLite database file used by the sqlite store backend."`
+	DBMaxOpenConns   int    `env:"DB_MAX_OPEN_CONNS" envDefault:"4" envDescription:"Maximum number of open database connections."`
+	DBMigrateOnStart bool   `env:"DB_MIGRATE_ON_START" envDefault:"true" envDescription:"Apply pending schema migrations at startup. When false, run the 'migrate' command instead."`
+
+	// In-memory store (STORE_BACKEND=memory). The oldest messages are evicted
+	// beyond either limit.
+	MemoryStoreMaxMessages int `env:"MEMORY_STORE_MAX_MESSAGES" envDefault:"10000" envDescription:"Maximum number of messages, including deleted ones, kept by the memory store before the oldest are evicted. 0 means no limit."`
+	MemoryStoreMaxBytes    int `env:"MEMORY_STORE_MAX_BYTES" envDefault:"67108864" envDescription:"Maximum total size in bytes of the messages kept by the memory store before the oldest are evicted. 0 means no limit."`
+
+	// PostgreSQL (STORE_BACKEND=postgres). POSTGRES_DSN takes precedence over
+	// the Cloud SQL settings, which connect through the /cloudsql Unix socket.
+	PostgresDSN              string `env:"POSTGRES_DSN" envSecret:"true" envDescription:"PostgreSQL connection string or postgres:// URL."`
+	CloudSQLInstance         string `env:"CLOUD_SQL_INSTANCE" envExample:"your-gcp-project-id:europe-west1:your-instance" envDescription:"Cloud SQL instance connection name; connects through the Unix socket mounted by Cloud Run."`
+	PostgresUser             string `env:"POSTGRES_USER" envDescription:"Database user for CLOUD_SQL_INSTANCE."`
+	PostgresPassword         string `env:"POSTGRES_PASSWORD" envSecret:"true" envDescription:"Database password for CLOUD_SQL_INSTANCE."`
+	PostgresDatabase         string `env:"POSTGRES_DB" envDefault:"postgres" envDescription:"Database name for CLOUD_SQL_INSTANCE."`
+	DBMaxIdleConns           int    `env:"DB_MAX_IDLE_CONNS" envDefault:"2" envDescription:"Maximum number of idle PostgreSQL connections kept in the pool."`
+	DBConnMaxLifetimeSeconds int    `env:"DB_CONN_MAX_LIFETIME_SECONDS" envDefault:"1800" envDescription:"Maximum lifetime of a PostgreSQL connection, in seconds."`
+	DBStatementTimeoutMillis int    `env:"DB_STATEMENT_TIMEOUT_MS" envDefault:"5000" envDescription:"PostgreSQL statement timeout in milliseconds; a shorter request deadline takes precedence."`
+	DBMaxRetries             int    `env:"DB_MAX_RETRIES" envDefault:"3" envDescription:"Retries of PostgreSQL transactions aborted by serialization failures or deadlocks. 0 disables retries."`
+}
+
+// Authorization modes accepted in AUTHZ_MODE.
+const (
+	AuthzModeEnforce = "enforce"
+	AuthzModeAudit   = "audit"
+)
+
+// Client certificate policies accepted in TLS_CLIENT_AUTH.
+const (
+	TLSClientAuthRequire       = "require"
+	TLSClientAuthVerifyIfGiven = "verify-if-given"
+)
+
+// Runtime modes accepted in RUNTIME_MODE.
+const (
+	RuntimeModeCloud = "cloud"
+	RuntimeModeLocal = "local"
+)
+
+// Store backends accepted in STORE_BACKEND.
+const (
+	StoreBackendMemory   = "memory"
+	StoreBackendSQLite   = "sqlite"
+	StoreBackendPostgres = "postgres"
+)
+
+// IsLocal reports whether the service runs in local development mode.
+func (c Config) IsLocal() bool {
+	return c.RuntimeMode == RuntimeModeLocal
+}
+
+// HasAuthentication reports whether any kind of credentials is configured.
+func (c Config) HasAuthentication() bool {
+	return c.APIKeysFile != "" || c.OIDCIssuer != "" || c.GoogleIDTokenAudience != "" || c.TLSClientCAFile != ""
+}
+
+// TrustedProxyPrefixes parses TRUSTED_PROXIES. A bare IP address is a
+// prefix of its full length.
+func (c Config) TrustedProxyPrefixes() ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, item := range strings.Split(c.TrustedProxies, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if addr, err := netip.ParseAddr(item); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, fmt.Errorf("TRUSTED_PROXIES: %q is not an IP address or CIDR", item)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// RouteTimeouts parses REQUEST_TIMEOUTS into deadlines by route name. Each
+// must be positive and at most REQUEST_TIMEOUT_MAX_MS.
+func (c Config) RouteTimeouts() (map[string]time.Duration, error) {
+	timeouts := map[string]time.Duration{}
+	for _, item := range strings.Split(c.RequestTimeouts, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(item, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("REQUEST_TIMEOUTS: expected name=duration, got %q", item)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("REQUEST_TIMEOUTS: %s: expected a positive duration such as 5s, got %q", name, value)
+		}
+		if limit := time.Duration(c.RequestTimeoutMaxMillis) * time.Millisecond; d > limit {
+			return nil, fmt.Errorf("REQUEST_TIMEOUTS: %s: %s exceeds REQUEST_TIMEOUT_MAX_MS", name, d)
+		}
+		timeouts[name] = d
+	}
+	return timeouts, nil
+}
+
+// RouteCORSOrigins parses CORS_ROUTE_ORIGINS into allowed origins by route
+// name. A route may have an empty list.
+func (c Config) RouteCORSOrigins() (map[string][]string, error) {
+	origins := map[string][]string{}
+	for _, item := range strings.Split(c.CORSRouteOrigins, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(item, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("CORS_ROUTE_ORIGINS: expected name=origin origin..., got %q", item)
+		}
+		if _, dup := origins[name]; dup {
+			return nil, fmt.Errorf("CORS_ROUTE_ORIGINS: %s is listed twice", name)
+		}
+		origins[name] = strings.Fields(value)
+	}
+	return origins, nil
+}
+
+// RouteSecurityHeaders parses SECURITY_HEADERS_ROUTES into response
+// headers by route name.
+func (c Config) RouteSecurityHeaders() (map[string]map[string]string, error) {
+	routes := map[string]map[string]string{}
+	if strings.TrimSpace(c.SecurityHeadersRoutes) == "" {
+		return routes, nil
+	}
+	if err := json.Unmarshal([]byte(c.SecurityHeadersRoutes), &routes); err != nil {
+		return nil, fmt.Errorf("SECURITY_HEADERS_ROUTES: expected a JSON object such as {\"hello:read\":{\"Referrer-Policy\":\"same-origin\"}}: %w", err)
+	}
+	for name, headers := range routes {
+		for header, value := range headers {
+			if header == "" || strings.ContainsAny(header, " \t\r\n:") || strings.ContainsAny(value, "\r\n") {
+				return nil, fmt.Errorf("SECURITY_HEADERS_ROUTES: %s: invalid header %q", name, header)
+			}
+		}
+	}
+	return routes, nil
 }
 
 // Load configuration from environment variables using the dui-go/env library.
+// If GOOGLE_CLOUD_PROJECT is unset, the project ID is discovered; in local mode
+// the metadata server is not consulted and a missing project is not an error.
 func Load() (Config, error) {
 	var cfg Config
 	err := env.Process(&cfg) // Use the new Process function
+	if err == nil {
+		err = env.Process(&cfg.Store)
+	}
 	if err != nil {
 		// Wrap the error for more context, preserving the original error type if possible
 		return Config{}, fmt.Errorf("failed to load config from environment: %w", err)
@@ -32,6 +309,167 @@ func Load() (Config, error) {
 
 	// Add any custom cross-field validation here if needed after loading
 	// e.g., if cfg.Port had to be within a certain range (though it's a string here).
+	if cfg.RuntimeMode != RuntimeModeCloud && cfg.RuntimeMode != RuntimeModeLocal {
+		return Config{}, fmt.Errorf("RUNTIME_MODE must be %q or %q, got %q", RuntimeModeCloud, RuntimeModeLocal, cfg.RuntimeMode)
+	}
+	if cfg.FeatureFlagsReloadSeconds <= 0 {
+		return Config{}, fmt.Errorf("FEATURE_FLAGS_RELOAD_SECONDS must be positive, got %d", cfg.FeatureFlagsReloadSeconds)
+	}
+	if err := cfg.Store.validate(); err != nil {
+		return Config{}, err
+	}
+	if cfg.IdempotencyTTLSeconds <= 0 {
+		return Config{}, fmt.Errorf("IDEMPOTENCY_TTL_SECONDS must be positive, got %d", cfg.IdempotencyTTLSeconds)
+	}
+	if cfg.IdempotencyWaitMillis < 0 {
+		return Config{}, fmt.Errorf("IDEMPOTENCY_WAIT_MS must not be negative, got %d", cfg.IdempotencyWaitMillis)
+	}
+	if cfg.IdempotencyMaxKeys < 0 {
+		return Config{}, fmt.Errorf("IDEMPOTENCY_MAX_KEYS must not be negative, got %d", cfg.IdempotencyMaxKeys)
+	}
+	if cfg.IdempotencyMaxBytes < 0 {
+		return Config{}, fmt.Errorf("IDEMPOTENCY_MAX_BYTES must not be negative, got %d", cfg.IdempotencyMaxBytes)
+	}
+
+	if cfg.APIKeysReloadSeconds <= 0 {
+		return Config{}, fmt.Errorf("API_KEYS_RELOAD_SECONDS must be positive, got %d", cfg.APIKeysReloadSeconds)
+	}
+	if cfg.AuthzPolicyReloadSeconds <= 0 {
+		return Config{}, fmt.Errorf("AUTHZ_POLICY_RELOAD_SECONDS must be positive, got %d", cfg.AuthzPolicyReloadSeconds)
+	}
+	if cfg.AuthzMode != AuthzModeEnforce && cfg.AuthzMode != AuthzModeAudit {
+		return Config{}, fmt.Errorf("AUTHZ_MODE must be %q or %q, got %q", AuthzModeEnforce, AuthzModeAudit, cfg.AuthzMode)
+	}
+	if cfg.GoogleIDTokenAudience != "" && cfg.GoogleIDTokenEmails == "" {
+		return Config{}, fmt.Errorf("GOOGLE_ID_TOKEN_AUDIENCE requires GOOGLE_ID_TOKEN_EMAILS")
+	}
+	if cfg.OIDCIssuer != "" && cfg.OIDCAudience == "" {
+		return Config{}, fmt.Errorf("OIDC_ISSUER requires OIDC_AUDIENCE")
+	}
+	if cfg.JWTClockSkewSeconds < 0 {
+		return Config{}, fmt.Errorf("JWT_CLOCK_SKEW_SECONDS must not be negative, got %d", cfg.JWTClockSkewSeconds)
+	}
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return Config{}, fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if cfg.TLSClientCAFile != "" && cfg.TLSCertFile == "" {
+		return Config{}, fmt.Errorf("TLS_CLIENT_CA_FILE requires TLS_CERT_FILE and TLS_KEY_FILE")
+	}
+	if cfg.TLSClientAuth != TLSClientAuthRequire && cfg.TLSClientAuth != TLSClientAuthVerifyIfGiven {
+		return Config{}, fmt.Errorf("TLS_CLIENT_AUTH must be %q or %q, got %q", TLSClientAuthRequire, TLSClientAuthVerifyIfGiven, cfg.TLSClientAuth)
+	}
+	if cfg.TLSReloadSeconds <= 0 {
+		return Config{}, fmt.Errorf("TLS_RELOAD_SECONDS must be positive, got %d", cfg.TLSReloadSeconds)
+	}
+	if cfg.AuditHashChain && cfg.AuditLogFile == "" {
+		return Config{}, fmt.Errorf("AUDIT_HASH_CHAIN requires AUDIT_LOG_FILE")
+	}
+	if _, err := cfg.TrustedProxyPrefixes(); err != nil {
+		return Config{}, err
+	}
+	if cfg.ConcurrencyMaxLimit < 0 {
+		return Config{}, fmt.Errorf("CONCURRENCY_MAX_LIMIT must not be negative, got %d", cfg.ConcurrencyMaxLimit)
+	}
+	if cfg.ConcurrencyMaxLimit > 0 && (cfg.ConcurrencyMinLimit <= 0 || cfg.ConcurrencyMinLimit > cfg.ConcurrencyMaxLimit) {
+		return Config{}, fmt.Errorf("CONCURRENCY_MIN_LIMIT must be between 1 and CONCURRENCY_MAX_LIMIT (%d), got %d", cfg.ConcurrencyMaxLimit, cfg.ConcurrencyMinLimit)
+	}
+	if cfg.ConcurrencyLatencyThresholdMS <= 0 {
+		return Config{}, fmt.Errorf("CONCURRENCY_LATENCY_THRESHOLD_MS must be positive, got %d", cfg.ConcurrencyLatencyThresholdMS)
+	}
+	if cfg.ConcurrencyQueueSize < 0 {
+		return Config{}, fmt.Errorf("CONCURRENCY_QUEUE_SIZE must not be negative, got %d", cfg.ConcurrencyQueueSize)
+	}
+	if cfg.ConcurrencyQueueTimeoutMS <= 0 {
+		return Config{}, fmt.Errorf("CONCURRENCY_QUEUE_TIMEOUT_MS must be positive, got %d", cfg.ConcurrencyQueueTimeoutMS)
+	}
+	if cfg.RequestTimeoutMillis <= 0 {
+		return Config{}, fmt.Errorf("REQUEST_TIMEOUT_MS must be positive, got %d", cfg.RequestTimeoutMillis)
+	}
+	if cfg.RequestTimeoutMaxMillis < cfg.RequestTimeoutMillis {
+		return Config{}, fmt.Errorf("REQUEST_TIMEOUT_MAX_MS (%d) must not be less than REQUEST_TIMEOUT_MS (%d)", cfg.RequestTimeoutMaxMillis, cfg.RequestTimeoutMillis)
+	}
+	if _, err := cfg.RouteTimeouts(); err != nil {
+		return Config{}, err
+	}
+	if cfg.CORSMaxAgeSeconds < 0 {
+		return Config{}, fmt.Errorf("CORS_MAX_AGE_SECONDS must not be negative, got %d", cfg.CORSMaxAgeSeconds)
+	}
+	if _, err := cfg.RouteCORSOrigins(); err != nil {
+		return Config{}, err
+	}
+	if _, err := cfg.RouteSecurityHeaders(); err != nil {
+		return Config{}, err
+	}
+	if cfg.MetricsPort != "" && cfg.MetricsPort == cfg.Port {
+		return Config{}, fmt.Errorf("METRICS_PORT must differ from PORT (%s)", cfg.Port)
+	}
+	if cfg.MaxRequestBodyBytes <= 0 {
+		return Config{}, fmt.Errorf("MAX_REQUEST_BODY_BYTES must be positive, got %d", cfg.MaxRequestBodyBytes)
+	}
+	if cfg.CompressionMinBytes <= 0 {
+		return Config{}, fmt.Errorf("COMPRESSION_MIN_BYTES must be positive, got %d", cfg.CompressionMinBytes)
+	}
+
+	if cfg.ProjectID == "" {
+		discoverer := NewProjectDiscoverer()
+		discoverer.SkipMetadata = cfg.IsLocal()
+		projectID, _, err := discoverer.Discover(context.Background())
+		if err != nil && !cfg.IsLocal() {
+			return Config{}, fmt.Errorf("GOOGLE_CLOUD_PROJECT is not set and discovery failed: %w", err)
+		}
+		cfg.ProjectID = projectID
+	}
+
+	// Refuse to expose every endpoint by accident.
+	if cfg.AuthRequired && !cfg.HasAuthentication() && !cfg.IsLocal() {
+		return Config{}, fmt.Errorf("AUTH_REQUIRED is true but none of API_KEYS_FILE, OIDC_ISSUER, GOOGLE_ID_TOKEN_AUDIENCE or TLS_CLIENT_CA_FILE is set; set AUTH_REQUIRED=false to serve unauthenticated requests")
+	}
 
 	return cfg, nil
 }
+
+// LoadStore reads and validates only the Store settings from environment
+// variables, so that commands using the store do not need the rest of the
+// server's configuration.
+func LoadStore() (Store, error) {
+	var s Store
+	if err := env.Process(&s); err != nil {
+		return Store{}, fmt.Errorf("failed to load config from environment: %w", err)
+	}
+	if err := s.validate(); err != nil {
+		return Store{}, err
+	}
+	return s, nil
+}
+
+// validate checks the Store settings.
+func (s Store) validate() error {
+	switch s.StoreBackend {
+	case StoreBackendMemory, StoreBackendSQLite:
+	case StoreBackendPostgres:
+		if s.PostgresDSN == "" && s.CloudSQLInstance == "" {
+			return fmt.Errorf("STORE_BACKEND=postgres requires POSTGRES_DSN or CLOUD_SQL_INSTANCE")
+		}
+	default:
+		return fmt.Errorf("STORE_BACKEND must be %q, %q or %q, got %q", StoreBackendMemory, StoreBackendSQLite, StoreBackendPostgres, s.StoreBackend)
+	}
+	if s.MemoryStoreMaxMessages < 0 {
+		return fmt.Errorf("MEMORY_STORE_MAX_MESSAGES must not be negative, got %d", s.MemoryStoreMaxMessages)
+	}
+	if s.MemoryStoreMaxBytes < 0 {
+		return fmt.Errorf("MEMORY_STORE_MAX_BYTES must not be negative, got %d", s.MemoryStoreMaxBytes)
+	}
+	if s.DBMaxOpenConns <= 0 {
+		return fmt.Errorf("DB_MAX_OPEN_CONNS must be positive, got %d", s.DBMaxOpenConns)
+	}
+	if s.DBMaxIdleConns < 0 {
+		return fmt.Errorf("DB_MAX_IDLE_CONNS must not be negative, got %d", s.DBMaxIdleConns)
+	}
+	if s.DBConnMaxLifetimeSeconds < 0 {
+		return fmt.Errorf("DB_CONN_MAX_LIFETIME_SECONDS must not be negative, got %d", s.DBConnMaxLifetimeSeconds)
+	}
+	if s.DBMaxRetries < 0 {
+		return fmt.Errorf("DB_MAX_RETRIES must not be negative, got %d", s.DBMaxRetries)
+	}
+	return nil
+}
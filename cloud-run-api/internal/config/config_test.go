@@ -2,9 +2,10 @@
 package config
 
 import (
+	"net/netip"
 	"os"
-	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -33,13 +34,26 @@ func setEnvForTest(t *testing.T, key, value string) {
 	})
 }
 
+// isolateProjectDiscovery points every project discovery source at an empty
+// location so tests do not depend on the developer's gcloud setup.
+func isolateProjectDiscovery(t *testing.T) {
+	t.Helper()
+	setEnvForTest(t, "CLOUDSDK_CORE_PROJECT", "")
+	setEnvForTest(t, "CLOUDSDK_CONFIG", t.TempDir())
+	setEnvForTest(t, "GOOGLE_APPLICATION_CREDENTIALS", "")
+	setEnvForTest(t, "GCE_METADATA_HOST", "127.0.0.1:1") // Nothing listens here
+}
+
 func TestLoadConfig(t *testing.T) {
+	// Cloud mode requires API keys unless AUTH_REQUIRED=false.
+	setEnvForTest(t, "API_KEYS_FILE", "/etc/api-keys.json")
 
 	t.Run("Defaults", func(t *testing.T) {
 		// Set required var, unset others to test defaults via env.Process
 		setEnvForTest(t, "GOOGLE_CLOUD_PROJECT", "test-project-defaults")
 		os.Unsetenv("API_SERVICE_NAME")
 		os.Unsetenv("PORT")
+		os.Unsetenv("RUNTIME_MODE")
 
 		cfg, err := Load() // Load calls env.Process internally
 		require.NoError(t, err, "Load() with defaults failed unexpectedly")
@@ -47,6 +61,11 @@ func TestLoadConfig(t *testing.T) {
 		assert.Equal(t, "go-hello-world-api", cfg.ServiceName, "Default ServiceName mismatch")
 		assert.Equal(t, "8080", cfg.Port, "Default Port mismatch")
 		assert.Equal(t, "test-project-defaults", cfg.ProjectID, "ProjectID mismatch")
+		assert.Equal(t, RuntimeModeCloud, cfg.RuntimeMode, "Default RuntimeMode mismatch")
+		assert.Equal(t, StoreBackendMemory, cfg.StoreBackend, "Default StoreBackend mismatch")
+		assert.True(t, cfg.DBMigrateOnStart, "Default DBMigrateOnStart mismatch")
+		assert.Equal(t, 86400, cfg.IdempotencyTTLSeconds, "Default IdempotencyTTLSeconds mismatch")
+		assert.Equal(t, AuthzModeEnforce, cfg.AuthzMode, "Default AuthzMode mismatch")
 	})
 
 	t.Run("Overrides", func(t *testing.T) {
@@ -63,23 +82,412 @@ func TestLoadConfig(t *testing.T) {
 		assert.Equal(t, "overridden-project-id", cfg.ProjectID)
 	})
 
-	t.Run("Missing Required ProjectID", func(t *testing.T) {
-		// Ensure the required variable is unset
-		originalProjectID, projectIDSet := os.LookupEnv("GOOGLE_CLOUD_PROJECT")
-		os.Unsetenv("GOOGLE_CLOUD_PROJECT")
-		t.Cleanup(func() {
-			if projectIDSet {
-				os.Setenv("GOOGLE_CLOUD_PROJECT", originalProjectID)
-			} else {
-				os.Unsetenv("GOOGLE_CLOUD_PROJECT")
-			}
-		})
+	t.Run("Missing ProjectID In Cloud Mode", func(t *testing.T) {
+		// Ensure the project is unset and every discovery source comes up empty.
+		isolateProjectDiscovery(t)
+		setEnvForTest(t, "GOOGLE_CLOUD_PROJECT", "")
+		setEnvForTest(t, "RUNTIME_MODE", "cloud")
+
+		_, err := Load()
+		require.Error(t, err, "Load() succeeded when the project could not be determined")
+		assert.ErrorIs(t, err, ErrProjectNotFound)
+		assert.Contains(t, err.Error(), "GOOGLE_CLOUD_PROJECT is not set", "Error message mismatch")
+	})
+
+	t.Run("Discovered ProjectID", func(t *testing.T) {
+		isolateProjectDiscovery(t)
+		setEnvForTest(t, "GOOGLE_CLOUD_PROJECT", "")
+		setEnvForTest(t, "RUNTIME_MODE", "cloud")
+		setEnvForTest(t, "CLOUDSDK_CORE_PROJECT", "discovered-project")
+
+		cfg, err := Load()
+		require.NoError(t, err)
+		assert.Equal(t, "discovered-project", cfg.ProjectID)
+	})
+
+	t.Run("Local Mode Without ProjectID", func(t *testing.T) {
+		isolateProjectDiscovery(t)
+		setEnvForTest(t, "GOOGLE_CLOUD_PROJECT", "")
+		setEnvForTest(t, "RUNTIME_MODE", "local")
+
+		cfg, err := Load()
+		require.NoError(t, err, "Load() in local mode should not require a project")
+		assert.True(t, cfg.IsLocal())
+		assert.Empty(t, cfg.ProjectID)
+	})
+
+	t.Run("Invalid RuntimeMode", func(t *testing.T) {
+		setEnvForTest(t, "GOOGLE_CLOUD_PROJECT", "test-project")
+		setEnvForTest(t, "RUNTIME_MODE", "staging")
 
 		_, err := Load()
-		require.Error(t, err, "Load() succeeded when GOOGLE_CLOUD_PROJECT was missing")
-		// Check that the error message originates from the env library's required check
-		assert.Contains(t, err.Error(), "required environment variable GOOGLE_CLOUD_PROJECT is not set or is empty", "Error message mismatch")
-		// Check for the wrapping text added in our Load function
-		assert.True(t, strings.HasPrefix(err.Error(), "failed to load config from environment: "), "Error should be wrapped")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "RUNTIME_MODE")
 	})
+
+	t.Run("Invalid StoreBackend", func(t *testing.T) {
+		setEnvForTest(t, "GOOGLE_CLOUD_PROJECT", "test-project")
+		setEnvForTest(t, "STORE_BACKEND", "mysql")
+
+		_, err := Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "STORE_BACKEND")
+	})
+
+	t.Run("Postgres Requires Connection Settings", func(t *testing.T) {
+		setEnvForTest(t, "GOOGLE_CLOUD_PROJECT", "test-project")
+		setEnvForTest(t, "STORE_BACKEND", "postgres")
+		setEnvForTest(t, "POSTGRES_DSN", "")
+		setEnvForTest(t, "CLOUD_SQL_INSTANCE", "")
+
+		_, err := Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "POSTGRES_DSN")
+
+		setEnvForTest(t, "CLOUD_SQL_INSTANCE", "test-project:europe-west1:main")
+		cfg, err := Load()
+		require.NoError(t, err)
+		assert.Equal(t, StoreBackendPostgres, cfg.StoreBackend)
+	})
+
+	t.Run("Invalid Memory Store Limits", func(t *testing.T) {
+		setEnvForTest(t, "GOOGLE_CLOUD_PROJECT", "test-project")
+		for _, name := range []string{"MEMORY_STORE_MAX_MESSAGES", "MEMORY_STORE_MAX_BYTES"} {
+			setEnvForTest(t, name, "-1")
+			_, err := Load()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), name)
+			setEnvForTest(t, name, "0")
+		}
+		cfg, err := Load()
+		require.NoError(t, err)
+		assert.Zero(t, cfg.MemoryStoreMaxMessages, "0 means no limit")
+	})
+
+	t.Run("Invalid Database Settings", func(t *testing.T) {
+		setEnvForTest(t, "GOOGLE_CLOUD_PROJECT", "test-project")
+		for _, name := range []string{"DB_MAX_IDLE_CONNS", "DB_CONN_MAX_LIFETIME_SECONDS", "DB_MAX_RETRIES"} {
+			setEnvForTest(t, name, "-1")
+			_, err := Load()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), name)
+			setEnvForTest(t, name, "0")
+		}
+		cfg, err := Load()
+		require.NoError(t, err)
+		assert.Zero(t, cfg.DBMaxRetries, "0 disables retries")
+	})
+
+	t.Run("Invalid Idempotency Settings", func(t *testing.T) {
+		setEnvForTest(t, "GOOGLE_CLOUD_PROJECT", "test-project")
+		setEnvForTest(t, "IDEMPOTENCY_TTL_SECONDS", "0")
+
+		_, err := Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "IDEMPOTENCY_TTL_SECONDS")
+
+		setEnvForTest(t, "IDEMPOTENCY_TTL_SECONDS", "60")
+		setEnvForTest(t, "IDEMPOTENCY_WAIT_MS", "-1")
+		_, err = Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "IDEMPOTENCY_WAIT_MS")
+
+		setEnvForTest(t, "IDEMPOTENCY_WAIT_MS", "0")
+		for _, name := range []string{"IDEMPOTENCY_MAX_KEYS", "IDEMPOTENCY_MAX_BYTES"} {
+			setEnvForTest(t, name, "-1")
+			_, err = Load()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), name)
+			setEnvForTest(t, name, "0")
+		}
+	})
+
+	t.Run("Auth Requires API Keys In Cloud Mode", func(t *testing.T) {
+		setEnvForTest(t, "GOOGLE_CLOUD_PROJECT", "test-project")
+		setEnvForTest(t, "RUNTIME_MODE", "cloud")
+		setEnvForTest(t, "API_KEYS_FILE", "")
+
+		_, err := Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "API_KEYS_FILE")
+
+		setEnvForTest(t, "AUTH_REQUIRED", "false")
+		cfg, err := Load()
+		require.NoError(t, err)
+		assert.False(t, cfg.AuthRequired)
+
+		setEnvForTest(t, "AUTH_REQUIRED", "true")
+		setEnvForTest(t, "RUNTIME_MODE", "local")
+		_, err = Load()
+		assert.NoError(t, err, "local mode may run without keys")
+	})
+
+	t.Run("Token Settings", func(t *testing.T) {
+		setEnvForTest(t, "GOOGLE_CLOUD_PROJECT", "test-project")
+		setEnvForTest(t, "RUNTIME_MODE", "cloud")
+		setEnvForTest(t, "API_KEYS_FILE", "")
+		setEnvForTest(t, "GOOGLE_ID_TOKEN_AUDIENCE", "https://api.example.com")
+
+		_, err := Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "GOOGLE_ID_TOKEN_EMAILS")
+
+		setEnvForTest(t, "GOOGLE_ID_TOKEN_EMAILS", "caller@test-project.iam.gserviceaccount.com")
+		cfg, err := Load()
+		require.NoError(t, err, "Google ID tokens satisfy AUTH_REQUIRED")
+		assert.True(t, cfg.HasAuthentication())
+		assert.Equal(t, 60, cfg.JWTClockSkewSeconds)
+
+		setEnvForTest(t, "OIDC_ISSUER", "https://login.example.com")
+		_, err = Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "OIDC_AUDIENCE")
+	})
+
+	t.Run("Authorization Settings", func(t *testing.T) {
+		setEnvForTest(t, "GOOGLE_CLOUD_PROJECT", "test-project")
+		setEnvForTest(t, "AUTHZ_MODE", "permissive")
+
+		_, err := Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "AUTHZ_MODE")
+
+		setEnvForTest(t, "AUTHZ_MODE", AuthzModeAudit)
+		setEnvForTest(t, "AUTHZ_POLICY_FILE", "/etc/authz/policy.json")
+		cfg, err := Load()
+		require.NoError(t, err)
+		assert.Equal(t, AuthzModeAudit, cfg.AuthzMode)
+		assert.Equal(t, "/etc/authz/policy.json", cfg.AuthzPolicyFile)
+		assert.Equal(t, 10, cfg.AuthzPolicyReloadSeconds)
+
+		setEnvForTest(t, "AUTHZ_POLICY_RELOAD_SECONDS", "0")
+		_, err = Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "AUTHZ_POLICY_RELOAD_SECONDS")
+	})
+
+	t.Run("TLS Settings", func(t *testing.T) {
+		setEnvForTest(t, "GOOGLE_CLOUD_PROJECT", "test-project")
+		setEnvForTest(t, "RUNTIME_MODE", "cloud")
+		setEnvForTest(t, "API_KEYS_FILE", "")
+		setEnvForTest(t, "TLS_CERT_FILE", "/etc/tls/tls.crt")
+
+		_, err := Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "TLS_KEY_FILE")
+
+		setEnvForTest(t, "TLS_KEY_FILE", "/etc/tls/tls.key")
+		setEnvForTest(t, "TLS_CLIENT_CA_FILE", "/etc/tls/clients.pem")
+		setEnvForTest(t, "TLS_CLIENT_AUTH", "optional")
+		_, err = Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "TLS_CLIENT_AUTH")
+
+		setEnvForTest(t, "TLS_CLIENT_AUTH", TLSClientAuthVerifyIfGiven)
+		cfg, err := Load()
+		require.NoError(t, err, "client certificates satisfy AUTH_REQUIRED")
+		assert.Equal(t, TLSClientAuthVerifyIfGiven, cfg.TLSClientAuth)
+		assert.Equal(t, 30, cfg.TLSReloadSeconds)
+
+		setEnvForTest(t, "TLS_CERT_FILE", "")
+		setEnvForTest(t, "TLS_KEY_FILE", "")
+		_, err = Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "TLS_CLIENT_CA_FILE requires")
+	})
+
+	t.Run("Audit Settings", func(t *testing.T) {
+		setEnvForTest(t, "GOOGLE_CLOUD_PROJECT", "test-project")
+		setEnvForTest(t, "AUDIT_HASH_CHAIN", "true")
+
+		_, err := Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "AUDIT_HASH_CHAIN requires AUDIT_LOG_FILE")
+
+		setEnvForTest(t, "AUDIT_LOG_FILE", "/var/log/audit.jsonl")
+		cfg, err := Load()
+		require.NoError(t, err)
+		assert.Equal(t, "/var/log/audit.jsonl", cfg.AuditLogFile)
+		assert.True(t, cfg.AuditHashChain)
+	})
+
+	t.Run("Trusted Proxies", func(t *testing.T) {
+		setEnvForTest(t, "GOOGLE_CLOUD_PROJECT", "test-project")
+
+		cfg, err := Load()
+		require.NoError(t, err)
+		prefixes, err := cfg.TrustedProxyPrefixes()
+		require.NoError(t, err)
+		assert.Equal(t, []netip.Prefix{
+			netip.MustParsePrefix("35.191.0.0/16"),
+			netip.MustParsePrefix("130.211.0.0/22"),
+		}, prefixes, "the Google Front End is trusted by default")
+		assert.Equal(t, "X-Forwarded-For", cfg.TrustedProxyHeader)
+
+		setEnvForTest(t, "TRUSTED_PROXIES", "10.0.0.0/8, 192.168.1.7,2001:db8::/32")
+		cfg, err = Load()
+		require.NoError(t, err)
+		prefixes, err = cfg.TrustedProxyPrefixes()
+		require.NoError(t, err)
+		assert.Equal(t, []netip.Prefix{
+			netip.MustParsePrefix("10.0.0.0/8"),
+			netip.MustParsePrefix("192.168.1.7/32"),
+			netip.MustParsePrefix("2001:db8::/32"),
+		}, prefixes)
+
+		setEnvForTest(t, "TRUSTED_PROXIES", "10.0.0.0/8,proxy.internal")
+		_, err = Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "proxy.internal")
+	})
+
+	t.Run("Concurrency Settings", func(t *testing.T) {
+		setEnvForTest(t, "GOOGLE_CLOUD_PROJECT", "test-project")
+
+		cfg, err := Load()
+		require.NoError(t, err)
+		assert.Equal(t, 80, cfg.ConcurrencyMaxLimit)
+		assert.Equal(t, 4, cfg.ConcurrencyMinLimit)
+		assert.Equal(t, 500, cfg.ConcurrencyQueueTimeoutMS)
+
+		setEnvForTest(t, "CONCURRENCY_MIN_LIMIT", "100")
+		_, err = Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "CONCURRENCY_MIN_LIMIT")
+
+		setEnvForTest(t, "CONCURRENCY_MAX_LIMIT", "0")
+		_, err = Load()
+		require.NoError(t, err, "the minimum is not checked when limiting is disabled")
+
+		setEnvForTest(t, "CONCURRENCY_QUEUE_SIZE", "-1")
+		_, err = Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "CONCURRENCY_QUEUE_SIZE")
+
+		setEnvForTest(t, "CONCURRENCY_QUEUE_SIZE", "0")
+		setEnvForTest(t, "METRICS_PORT", "8080")
+		setEnvForTest(t, "PORT", "8080")
+		_, err = Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "METRICS_PORT")
+	})
+
+	t.Run("Request Timeouts", func(t *testing.T) {
+		setEnvForTest(t, "GOOGLE_CLOUD_PROJECT", "test-project")
+		setEnvForTest(t, "REQUEST_TIMEOUTS", "echo:write=2s, messages:read=1500ms")
+
+		cfg, err := Load()
+		require.NoError(t, err)
+		assert.Equal(t, 10000, cfg.RequestTimeoutMillis)
+		assert.Equal(t, 30000, cfg.RequestTimeoutMaxMillis)
+		timeouts, err := cfg.RouteTimeouts()
+		require.NoError(t, err)
+		assert.Equal(t, map[string]time.Duration{"echo:write": 2 * time.Second, "messages:read": 1500 * time.Millisecond}, timeouts)
+
+		for _, spec := range []string{"echo:write", "echo:write=soon", "echo:write=0s", "echo:write=1m"} {
+			setEnvForTest(t, "REQUEST_TIMEOUTS", spec)
+			_, err = Load()
+			require.Error(t, err, spec)
+			assert.Contains(t, err.Error(), "REQUEST_TIMEOUTS")
+		}
+
+		setEnvForTest(t, "REQUEST_TIMEOUTS", "")
+		setEnvForTest(t, "REQUEST_TIMEOUT_MAX_MS", "5000")
+		_, err = Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "REQUEST_TIMEOUT_MAX_MS")
+	})
+
+	t.Run("CORS Settings", func(t *testing.T) {
+		setEnvForTest(t, "GOOGLE_CLOUD_PROJECT", "test-project")
+		setEnvForTest(t, "CORS_ROUTE_ORIGINS", "messages:write=https://admin.example.com https://*.example.com, echo:write=")
+
+		cfg, err := Load()
+		require.NoError(t, err)
+		assert.Empty(t, cfg.CORSAllowedOrigins)
+		assert.Equal(t, "GET,HEAD,POST,DELETE", cfg.CORSAllowedMethods)
+		assert.False(t, cfg.CORSAllowCredentials)
+		assert.Equal(t, 600, cfg.CORSMaxAgeSeconds)
+		origins, err := cfg.RouteCORSOrigins()
+		require.NoError(t, err)
+		assert.Equal(t, map[string][]string{
+			"messages:write": {"https://admin.example.com", "https://*.example.com"},
+			"echo:write":     {},
+		}, origins)
+
+		for _, spec := range []string{"https://app.example.com", "=https://app.example.com", "echo:write=*,echo:write=*"} {
+			setEnvForTest(t, "CORS_ROUTE_ORIGINS", spec)
+			_, err = Load()
+			require.Error(t, err, spec)
+			assert.Contains(t, err.Error(), "CORS_ROUTE_ORIGINS")
+		}
+
+		setEnvForTest(t, "CORS_ROUTE_ORIGINS", "")
+		setEnvForTest(t, "CORS_MAX_AGE_SECONDS", "-1")
+		_, err = Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "CORS_MAX_AGE_SECONDS")
+	})
+
+	t.Run("Security Headers", func(t *testing.T) {
+		setEnvForTest(t, "GOOGLE_CLOUD_PROJECT", "test-project")
+		setEnvForTest(t, "SECURITY_HEADERS_ROUTES", `{"hello:read": {"Content-Security-Policy": "", "Referrer-Policy": "same-origin"}}`)
+
+		cfg, err := Load()
+		require.NoError(t, err)
+		assert.Equal(t, "max-age=31536000; includeSubDomains", cfg.StrictTransportSecurity)
+		assert.Equal(t, "default-src 'none'; frame-ancestors 'none'", cfg.ContentSecurityPolicy)
+		assert.Equal(t, "no-referrer", cfg.ReferrerPolicy)
+		routes, err := cfg.RouteSecurityHeaders()
+		require.NoError(t, err)
+		assert.Equal(t, map[string]map[string]string{
+			"hello:read": {"Content-Security-Policy": "", "Referrer-Policy": "same-origin"},
+		}, routes)
+
+		for _, spec := range []string{`hello:read=x`, `{"hello:read": "x"}`, `{"hello:read": {"Bad Header": "x"}}`, `{"hello:read": {"X-Test": "a\r\nSet-Cookie: b"}}`} {
+			setEnvForTest(t, "SECURITY_HEADERS_ROUTES", spec)
+			_, err = Load()
+			require.Error(t, err, spec)
+			assert.Contains(t, err.Error(), "SECURITY_HEADERS_ROUTES")
+		}
+	})
+
+	t.Run("Invalid Body Size Settings", func(t *testing.T) {
+		setEnvForTest(t, "GOOGLE_CLOUD_PROJECT", "test-project")
+		setEnvForTest(t, "MAX_REQUEST_BODY_BYTES", "0")
+
+		_, err := Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "MAX_REQUEST_BODY_BYTES")
+
+		setEnvForTest(t, "MAX_REQUEST_BODY_BYTES", "4096")
+		setEnvForTest(t, "COMPRESSION_MIN_BYTES", "-1")
+		_, err = Load()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "COMPRESSION_MIN_BYTES")
+
+		setEnvForTest(t, "COMPRESSION_MIN_BYTES", "256")
+		cfg, err := Load()
+		require.NoError(t, err)
+		assert.Equal(t, 4096, cfg.MaxRequestBodyBytes)
+		assert.Equal(t, 256, cfg.CompressionMinBytes)
+	})
+}
+
+func TestLoadStore(t *testing.T) {
+	isolateProjectDiscovery(t)
+	setEnvForTest(t, "GOOGLE_CLOUD_PROJECT", "")
+	setEnvForTest(t, "RUNTIME_MODE", "cloud")
+	setEnvForTest(t, "STORE_BACKEND", "sqlite")
+	setEnvForTest(t, "SQLITE_PATH", "/tmp/messages.db")
+
+	cfg, err := LoadStore()
+	require.NoError(t, err, "the store settings do not need the server's")
+	assert.Equal(t, "sqlite", cfg.StoreBackend)
+	assert.Equal(t, "/tmp/messages.db", cfg.SQLitePath)
+
+	setEnvForTest(t, "DB_MAX_RETRIES", "-1")
+	_, err = LoadStore()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "DB_MAX_RETRIES")
 }
@@ -0,0 +1,134 @@
+// internal/config/discovery.go
+package config
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"your-module-name/internal/metadata"
+)
+
+// ErrProjectNotFound is returned when no source yields a project ID.
+var ErrProjectNotFound = errors.New("google cloud project ID could not be determined")
+
+// ProjectDiscoverer determines the Google Cloud project ID when
+// GOOGLE_CLOUD_PROJECT is not set. Sources are tried in order:
+//  1. The CLOUDSDK_CORE_PROJECT environment variable.
+//  2. The credentials JSON named by GOOGLE_APPLICATION_CREDENTIALS.
+//  3. The active gcloud CLI configuration.
+//  4. The metadata server, unless SkipMetadata is set.
+type ProjectDiscoverer struct {
+	CredentialsFile string
+	GcloudConfigDir string
+	Metadata        *metadata.Client
+	SkipMetadata    bool
+}
+
+// NewProjectDiscoverer returns a discoverer configured from the standard
+// Google Cloud environment variables.
+func NewProjectDiscoverer() *ProjectDiscoverer {
+	configDir := os.Getenv("CLOUDSDK_CONFIG")
+	if configDir == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			configDir = filepath.Join(home, ".config", "gcloud")
+		}
+	}
+	return &ProjectDiscoverer{
+		CredentialsFile: os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"),
+		GcloudConfigDir: configDir,
+		Metadata:        metadata.NewClient(),
+	}
+}
+
+// Discover returns the project ID and a short description of where it was found.
+func (d *ProjectDiscoverer) Discover(ctx context.Context) (projectID, source string, err error) {
+	if p := os.Getenv("CLOUDSDK_CORE_PROJECT"); p != "" {
+		return p, "CLOUDSDK_CORE_PROJECT", nil
+	}
+	if p := d.fromCredentialsFile(); p != "" {
+		return p, "credentials file " + d.CredentialsFile, nil
+	}
+	if p := d.fromGcloudConfig(); p != "" {
+		return p, "gcloud configuration", nil
+	}
+	if !d.SkipMetadata && d.Metadata != nil {
+		p, err := d.Metadata.ProjectID(ctx)
+		if err == nil && p != "" {
+			return p, "metadata server", nil
+		}
+		if err != nil {
+			return "", "", fmt.Errorf("%w: metadata server: %v", ErrProjectNotFound, err)
+		}
+	}
+	return "", "", ErrProjectNotFound
+}
+
+// fromCredentialsFile reads project_id (service account keys) or
+// quota_project_id (user credentials) from the credentials JSON.
+func (d *ProjectDiscoverer) fromCredentialsFile() string {
+	if d.CredentialsFile == "" {
+		return ""
+	}
+	data, err := os.ReadFile(d.CredentialsFile)
+	if err != nil {
+		return ""
+	}
+	var creds struct {
+		ProjectID      string `json:"project_id"`
+		QuotaProjectID string `json:"quota_project_id"`
+	}
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return ""
+	}
+	if creds.ProjectID != "" {
+		return creds.ProjectID
+	}
+	return creds.QuotaProjectID
+}
+
+// fromGcloudConfig reads core/project from the active gcloud configuration,
+// e.g. ~/.config/gcloud/configurations/config_default.
+func (d *ProjectDiscoverer) fromGcloudConfig() string {
+	if d.GcloudConfigDir == "" {
+		return ""
+	}
+	active := "default"
+	if data, err := os.ReadFile(filepath.Join(d.GcloudConfigDir, "active_config")); err == nil {
+		if name := strings.TrimSpace(string(data)); name != "" {
+			active = name
+		}
+	}
+
+	f, err := os.Open(filepath.Join(d.GcloudConfigDir, "configurations", "config_"+active))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "", strings.HasPrefix(line, "#"), strings.HasPrefix(line, ";"):
+			continue
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		if section != "core" {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if ok && strings.TrimSpace(key) == "project" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,88 @@
+// internal/config/discovery_test.go
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"your-module-name/internal/metadata"
+	"your-module-name/internal/metadata/metadatatest"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func TestProjectDiscoverer(t *testing.T) {
+	setEnvForTest(t, "CLOUDSDK_CORE_PROJECT", "")
+	unreachable := &metadata.Client{Host: "127.0.0.1:1", HTTPClient: metadata.NewClient().HTTPClient}
+	ctx := context.Background()
+
+	t.Run("Credentials File", func(t *testing.T) {
+		dir := t.TempDir()
+		creds := filepath.Join(dir, "sa.json")
+		writeFile(t, creds, `{"type":"service_account","project_id":"from-credentials"}`)
+
+		d := &ProjectDiscoverer{CredentialsFile: creds, Metadata: unreachable}
+		project, source, err := d.Discover(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "from-credentials", project)
+		assert.Contains(t, source, "credentials file")
+	})
+
+	t.Run("Quota Project From User Credentials", func(t *testing.T) {
+		creds := filepath.Join(t.TempDir(), "adc.json")
+		writeFile(t, creds, `{"type":"authorized_user","quota_project_id":"from-quota"}`)
+
+		project, _, err := (&ProjectDiscoverer{CredentialsFile: creds, SkipMetadata: true}).Discover(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "from-quota", project)
+	})
+
+	t.Run("Gcloud Active Configuration", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "active_config"), "work\n")
+		writeFile(t, filepath.Join(dir, "configurations", "config_default"), "[core]\nproject = not-active\n")
+		writeFile(t, filepath.Join(dir, "configurations", "config_work"),
+			"[compute]\nproject = wrong-section\n\n[core]\naccount = dev@example.com\nproject = from-gcloud\n")
+
+		project, source, err := (&ProjectDiscoverer{GcloudConfigDir: dir, SkipMetadata: true}).Discover(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "from-gcloud", project)
+		assert.Equal(t, "gcloud configuration", source)
+	})
+
+	t.Run("Metadata Server", func(t *testing.T) {
+		srv := metadatatest.NewServer(t, map[string]string{"project/project-id": "from-metadata"})
+		d := &ProjectDiscoverer{
+			GcloudConfigDir: t.TempDir(),
+			Metadata:        &metadata.Client{Host: srv.Host(), HTTPClient: srv.Client()},
+		}
+		project, source, err := d.Discover(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "from-metadata", project)
+		assert.Equal(t, "metadata server", source)
+	})
+
+	t.Run("Environment Override Wins", func(t *testing.T) {
+		setEnvForTest(t, "CLOUDSDK_CORE_PROJECT", "from-env")
+		project, _, err := (&ProjectDiscoverer{SkipMetadata: true}).Discover(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "from-env", project)
+	})
+
+	t.Run("Nothing Found", func(t *testing.T) {
+		_, _, err := (&ProjectDiscoverer{GcloudConfigDir: t.TempDir(), SkipMetadata: true}).Discover(ctx)
+		assert.ErrorIs(t, err, ErrProjectNotFound)
+
+		_, _, err = (&ProjectDiscoverer{GcloudConfigDir: t.TempDir(), Metadata: unreachable}).Discover(ctx)
+		assert.ErrorIs(t, err, ErrProjectNotFound)
+	})
+}
@@ -0,0 +1,149 @@
+// internal/config/docs.go
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// Markers delimiting the generated configuration table in a Markdown document.
+const (
+	DocsBeginMarker = "<!-- config-docs:begin -->"
+	DocsEndMarker   = "<!-- config-docs:end -->"
+)
+
+// Variable describes a single environment variable backing a Config field,
+// as declared by the field's struct tags.
+type Variable struct {
+	Name        string
+	Field       string
+	Default     string
+	Example     string
+	Description string
+	Required    bool
+	Secret      bool
+}
+
+// Variables reflects over Config and returns the documented environment
+// variables in field declaration order, including those of embedded structs
+// such as Store.
+func Variables() []Variable {
+	return variables(reflect.TypeOf(Config{}))
+}
+
+func variables(typ reflect.Type) []Variable {
+	vars := make([]Variable, 0, typ.NumField())
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			vars = append(vars, variables(field.Type)...)
+			continue
+		}
+
+		// Mirror env.Process: an absent or empty tag defaults to the uppercase field name.
+		name := strings.ToUpper(field.Name)
+		if tagName, ok := field.Tag.Lookup("env"); ok && tagName != "" {
+			name = tagName
+		}
+
+		vars = append(vars, Variable{
+			Name:        name,
+			Field:       field.Name,
+			Default:     field.Tag.Get("envDefault"),
+			Example:     field.Tag.Get("envExample"),
+			Description: field.Tag.Get("envDescription"),
+			Required:    field.Tag.Get("envRequired") == "true",
+			Secret:      field.Tag.Get("envSecret") == "true",
+		})
+	}
+	return vars
+}
+
+// WriteMarkdown writes the configuration reference as a Markdown table.
+func WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("| Variable | Description | Default | Required | Secret |\n")
+	b.WriteString("|---|---|---|---|---|\n")
+	for _, v := range Variables() {
+		def := "-"
+		if v.Default != "" && !v.Secret {
+			def = "`" + v.Default + "`"
+		}
+		fmt.Fprintf(&b, "| `%s` | %s | %s | %s | %s |\n",
+			v.Name, escapeTableCell(v.Description), def, yesNo(v.Required), yesNo(v.Secret))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteEnvExample writes a .env.example file listing every variable with its
+// description. Secret values are always left empty.
+func WriteEnvExample(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("# Generated from internal/config.Config by `go generate ./...`. DO NOT EDIT.\n")
+	b.WriteString("# Copy this file to .env and adjust the values for your environment.\n")
+	for _, v := range Variables() {
+		b.WriteString("\n")
+		if v.Description != "" {
+			fmt.Fprintf(&b, "# %s\n", v.Description)
+		}
+		switch {
+		case v.Required && v.Secret:
+			b.WriteString("# Required. Secret: never commit a real value.\n")
+		case v.Required:
+			b.WriteString("# Required.\n")
+		case v.Secret:
+			b.WriteString("# Secret: never commit a real value.\n")
+		}
+
+		value := v.Default
+		if v.Example != "" {
+			value = v.Example
+		}
+		if v.Secret {
+			value = ""
+		}
+		fmt.Fprintf(&b, "%s=%q\n", v.Name, value)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ReplaceMarkdownSection returns doc with the content between DocsBeginMarker
+// and DocsEndMarker replaced by a freshly generated configuration table.
+func ReplaceMarkdownSection(doc []byte) ([]byte, error) {
+	begin := bytes.Index(doc, []byte(DocsBeginMarker))
+	end := bytes.Index(doc, []byte(DocsEndMarker))
+	if begin < 0 || end < 0 || end < begin {
+		return nil, fmt.Errorf("config docs markers %q and %q not found in document", DocsBeginMarker, DocsEndMarker)
+	}
+
+	var table bytes.Buffer
+	if err := WriteMarkdown(&table); err != nil {
+		return nil, fmt.Errorf("failed to render configuration table: %w", err)
+	}
+
+	var out bytes.Buffer
+	out.Write(doc[:begin+len(DocsBeginMarker)])
+	out.WriteString("\n")
+	out.Write(table.Bytes())
+	out.Write(doc[end:])
+	return out.Bytes(), nil
+}
+
+func escapeTableCell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "Yes"
+	}
+	return "No"
+}
@@ -0,0 +1,61 @@
+// internal/config/docs_test.go
+package config
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVariables(t *testing.T) {
+	vars := Variables()
+	require.NotEmpty(t, vars)
+
+	byName := make(map[string]Variable, len(vars))
+	for _, v := range vars {
+		assert.NotEmpty(t, v.Description, "%s is missing an envDescription tag", v.Field)
+		byName[v.Name] = v
+	}
+
+	project, ok := byName["GOOGLE_CLOUD_PROJECT"]
+	require.True(t, ok)
+	assert.False(t, project.Required)
+	assert.Equal(t, "your-gcp-project-id", project.Example)
+	assert.Equal(t, "ProjectID", project.Field)
+
+	port, ok := byName["PORT"]
+	require.True(t, ok)
+	assert.Equal(t, "8080", port.Default)
+	assert.False(t, port.Required)
+}
+
+// TestGeneratedDocsUpToDate fails when README.md or .env.example no longer
+// match the Config struct. Run `go generate ./...` to refresh them.
+func TestGeneratedDocsUpToDate(t *testing.T) {
+	t.Run("README.md", func(t *testing.T) {
+		readme, err := os.ReadFile("../../README.md")
+		require.NoError(t, err)
+
+		want, err := ReplaceMarkdownSection(readme)
+		require.NoError(t, err)
+		assert.Equal(t, string(want), string(readme), "README.md configuration section is stale; run `go generate ./...`")
+	})
+
+	t.Run(".env.example", func(t *testing.T) {
+		got, err := os.ReadFile("../../.env.example")
+		require.NoError(t, err)
+
+		var want bytes.Buffer
+		require.NoError(t, WriteEnvExample(&want))
+		assert.Equal(t, want.String(), string(got), ".env.example is stale; run `go generate ./...`")
+	})
+}
+
+func TestReplaceMarkdownSection_MissingMarkers(t *testing.T) {
+	_, err := ReplaceMarkdownSection([]byte("# No markers here\n"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "markers")
+}
@@ -0,0 +1,245 @@
+// internal/cors/cors.go
+//
+// Package cors implements Cross-Origin Resource Sharing, which lets browser
+// frontends served from other origins call the API. A Policy decides which
+// origins may do so and answers their preflight requests; the per-route
+// middleware that applies policies lives in internal/api.
+package cors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Options configure a Policy.
+type Options struct {
+	// AllowedOrigins are the origins allowed to make cross-origin requests.
+	// Each is an exact origin ("https://app.example.com"), a wildcard
+	// matching any subdomain ("https://*.example.com"), a regular expression
+	// matched against the whole origin after "~" ("~https://pr-[0-9]+\.example\.dev"),
+	// or "*" for any origin.
+	AllowedOrigins []string
+	// AllowedMethods may be used in cross-origin requests. Default GET,
+	// HEAD and POST, the methods browsers send without a preflight.
+	AllowedMethods []string
+	// AllowedHeaders are the request headers, besides the CORS-safelisted
+	// ones, that cross-origin requests may send.
+	AllowedHeaders []string
+	// ExposedHeaders are the response headers, besides the CORS-safelisted
+	// ones, that browsers let scripts read.
+	ExposedHeaders []string
+	// AllowCredentials lets browsers send cookies and HTTP authentication
+	// with cross-origin requests. It cannot be combined with "*".
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response. Zero
+	// leaves it to the browser, usually 5 seconds.
+	MaxAge time.Duration
+}
+
+// Policy is a parsed CORS policy. The zero value is not usable; create one
+// with New.
+type Policy struct {
+	anyOrigin   bool
+	exact       []string
+	wildcards   []wildcard
+	patterns    []*regexp.Regexp
+	methods     []string
+	headers     []string // Lower case.
+	allowMethod string   // Access-Control-Allow-Methods value.
+	allowHeader string   // Access-Control-Allow-Headers value.
+	expose      string   // Access-Control-Expose-Headers value.
+	credentials bool
+	maxAge      string // Access-Control-Max-Age value, or "".
+}
+
+// wildcard matches the origins with the given scheme and a host that is a
+// subdomain of suffix, e.g. "https://" and ".example.com".
+type wildcard struct {
+	prefix, suffix string
+}
+
+// New parses opts into a policy. It fails if an origin is malformed or if
+// AllowCredentials is combined with "*", which browsers reject.
+func New(opts Options) (*Policy, error) {
+	p := &Policy{credentials: opts.AllowCredentials}
+	for _, origin := range opts.AllowedOrigins {
+		if err := p.addOrigin(origin); err != nil {
+			return nil, err
+		}
+	}
+	if p.anyOrigin && p.credentials {
+		return nil, errors.New("cors: the origin * cannot be combined with credentials; list the origins instead")
+	}
+
+	for _, m := range opts.AllowedMethods {
+		p.methods = append(p.methods, strings.ToUpper(m))
+	}
+	if len(p.methods) == 0 {
+		p.methods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+	}
+	p.allowMethod = strings.Join(p.methods, ", ")
+
+	for _, h := range opts.AllowedHeaders {
+		p.headers = append(p.headers, strings.ToLower(h))
+	}
+	p.allowHeader = strings.Join(opts.AllowedHeaders, ", ")
+	p.expose = strings.Join(opts.ExposedHeaders, ", ")
+	if opts.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(int(opts.MaxAge / time.Second))
+	}
+	return p, nil
+}
+
+// addOrigin parses one entry of Options.AllowedOrigins.
+func (p *Policy) addOrigin(origin string) error {
+	switch {
+	case origin == "*":
+		p.anyOrigin = true
+		return nil
+	case strings.HasPrefix(origin, "~"):
+		re, err := regexp.Compile(`^(?:` + origin[1:] + `)$`)
+		if err != nil {
+			return fmt.Errorf("cors: origin pattern %q: %w", origin, err)
+		}
+		p.patterns = append(p.patterns, re)
+		return nil
+	}
+
+	origin = strings.ToLower(origin)
+	scheme, host, ok := strings.Cut(origin, "://")
+	if !ok || scheme == "" || host == "" || strings.ContainsAny(host, "/?#@") {
+		return fmt.Errorf("cors: origin %q is not of the form scheme://host[:port]", origin)
+	}
+	if rest, ok := strings.CutPrefix(host, "*."); ok {
+		if rest == "" || strings.Contains(rest, "*") {
+			return fmt.Errorf("cors: origin %q: a wildcard must be followed by a domain, as in https://*.example.com", origin)
+		}
+		p.wildcards = append(p.wildcards, wildcard{prefix: scheme + "://", suffix: "." + rest})
+		return nil
+	}
+	if _, err := url.Parse(origin); err != nil || strings.Contains(host, "*") {
+		return fmt.Errorf("cors: origin %q is not of the form scheme://host[:port]", origin)
+	}
+	p.exact = append(p.exact, origin)
+	return nil
+}
+
+// AllowsOrigin reports whether origin may make cross-origin requests.
+func (p *Policy) AllowsOrigin(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	if p.anyOrigin {
+		return true
+	}
+	lower := strings.ToLower(origin)
+	if slices.Contains(p.exact, lower) {
+		return true
+	}
+	for _, w := range p.wildcards {
+		sub, ok := strings.CutPrefix(lower, w.prefix)
+		if !ok {
+			continue
+		}
+		sub, ok = strings.CutSuffix(sub, w.suffix)
+		if ok && sub != "" && !strings.HasPrefix(sub, ".") && !strings.ContainsAny(sub, "/:@") {
+			return true
+		}
+	}
+	for _, re := range p.patterns {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPreflight reports whether r is a CORS preflight request: an OPTIONS
+// request with Origin and Access-Control-Request-Method headers.
+func IsPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Origin") != "" && r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// Preflight answers a preflight request with 204. The Access-Control-Allow-*
+// headers are only set if the origin, method and headers asked for are all
+// allowed; otherwise the browser does not send the actual request.
+func (p *Policy) Preflight(w http.ResponseWriter, r *http.Request) {
+	header := w.Header()
+	header.Add("Vary", "Origin")
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+	origin := r.Header.Get("Origin")
+	if p.AllowsOrigin(origin) && p.allowsMethod(r.Header.Get("Access-Control-Request-Method")) && p.allowsHeaders(r.Header.Values("Access-Control-Request-Headers")) {
+		p.setOrigin(header, origin)
+		header.Set("Access-Control-Allow-Methods", p.allowMethod)
+		if p.allowHeader != "" {
+			header.Set("Access-Control-Allow-Headers", p.allowHeader)
+		}
+		if p.maxAge != "" {
+			header.Set("Access-Control-Max-Age", p.maxAge)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Apply adds the CORS headers of the response to an actual request from
+// origin, which may be empty for same-origin and non-browser requests.
+// Vary: Origin is always added, so that caches keep the responses to
+// different origins apart.
+func (p *Policy) Apply(header http.Header, origin string) {
+	header.Add("Vary", "Origin")
+	if !p.AllowsOrigin(origin) {
+		return
+	}
+	p.setOrigin(header, origin)
+	if p.expose != "" {
+		header.Set("Access-Control-Expose-Headers", p.expose)
+	}
+}
+
+// setOrigin sets Access-Control-Allow-Origin, and -Credentials if allowed.
+func (p *Policy) setOrigin(header http.Header, origin string) {
+	if p.anyOrigin {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.credentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (p *Policy) allowsMethod(method string) bool {
+	return slices.Contains(p.methods, method)
+}
+
+// allowsHeaders reports whether every header in the comma-separated
+// Access-Control-Request-Headers values is allowed or CORS-safelisted.
+func (p *Policy) allowsHeaders(values []string) bool {
+	for _, v := range values {
+		for _, name := range strings.Split(v, ",") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" || slices.Contains(p.headers, name) || safelisted[name] {
+				continue
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// safelisted are the CORS-safelisted request headers, which browsers send
+// without asking. Content-Type is only safelisted for form and plain-text
+// bodies, so browsers ask for it with JSON bodies and it is not listed here.
+var safelisted = map[string]bool{
+	"accept":           true,
+	"accept-language":  true,
+	"content-language": true,
+}
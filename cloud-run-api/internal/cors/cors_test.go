@@ -0,0 +1,139 @@
+// internal/cors/cors_test.go
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy_AllowsOrigin(t *testing.T) {
+	p, err := New(Options{AllowedOrigins: []string{
+		"https://app.example.com",
+		"http://localhost:3000",
+		"https://*.example.org",
+		`~https://pr-[0-9]+\.preview\.example\.dev`,
+	}})
+	require.NoError(t, err)
+
+	for origin, want := range map[string]bool{
+		"https://app.example.com":              true,
+		"HTTPS://APP.EXAMPLE.COM":              true,
+		"http://app.example.com":               false,
+		"https://app.example.com:8443":         false,
+		"https://evil-app.example.com":         false,
+		"http://localhost:3000":                true,
+		"http://localhost:3001":                false,
+		"https://www.example.org":              true,
+		"https://a.b.example.org":              true,
+		"https://example.org":                  false,
+		"https://.example.org":                 false,
+		"https://evil.com/.example.org":        false,
+		"https://example.org.evil.com":         false,
+		"https://evilexample.org":              false,
+		"https://pr-42.preview.example.dev":    true,
+		"https://pr-42.preview.example.dev.io": false,
+		"https://pr-x.preview.example.dev":     false,
+		"null":                                 false,
+		"":                                     false,
+	} {
+		assert.Equal(t, want, p.AllowsOrigin(origin), origin)
+	}
+
+	anyOrigin, err := New(Options{AllowedOrigins: []string{"*"}})
+	require.NoError(t, err)
+	assert.True(t, anyOrigin.AllowsOrigin("https://anything.test"))
+	assert.False(t, anyOrigin.AllowsOrigin(""), "requests without Origin are not cross-origin")
+}
+
+func TestNew_Invalid(t *testing.T) {
+	for _, opts := range []Options{
+		{AllowedOrigins: []string{"app.example.com"}},
+		{AllowedOrigins: []string{"https://app.example.com/path"}},
+		{AllowedOrigins: []string{"https://*"}},
+		{AllowedOrigins: []string{"https://*.*.example.com"}},
+		{AllowedOrigins: []string{"https://app.*.com"}},
+		{AllowedOrigins: []string{"~https://(unclosed"}},
+		{AllowedOrigins: []string{"*"}, AllowCredentials: true},
+	} {
+		_, err := New(opts)
+		assert.Error(t, err, opts.AllowedOrigins)
+	}
+}
+
+func TestPolicy_Preflight(t *testing.T) {
+	p, err := New(Options{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowedMethods:   []string{"get", "post", "delete"},
+		AllowedHeaders:   []string{"authorization", "Content-Type", "Idempotency-Key"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	})
+	require.NoError(t, err)
+
+	preflight := func(origin, method, headers string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, "/echo", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", method)
+		if headers != "" {
+			req.Header.Set("Access-Control-Request-Headers", headers)
+		}
+		require.True(t, IsPreflight(req))
+		rr := httptest.NewRecorder()
+		p.Preflight(rr, req)
+		return rr
+	}
+
+	rr := preflight("https://app.example.com", http.MethodDelete, "authorization, content-type,idempotency-key, accept")
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.Equal(t, "https://app.example.com", rr.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", rr.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "GET, POST, DELETE", rr.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "authorization, Content-Type, Idempotency-Key", rr.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "600", rr.Header().Get("Access-Control-Max-Age"))
+	assert.Equal(t, []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"}, rr.Header().Values("Vary"))
+
+	for name, rr := range map[string]*httptest.ResponseRecorder{
+		"Origin":  preflight("https://evil.example", http.MethodPost, ""),
+		"Method":  preflight("https://app.example.com", http.MethodPut, ""),
+		"Headers": preflight("https://app.example.com", http.MethodPost, "X-Custom"),
+	} {
+		assert.Equal(t, http.StatusNoContent, rr.Code, name)
+		assert.Empty(t, rr.Header().Get("Access-Control-Allow-Origin"), name)
+		assert.Empty(t, rr.Header().Get("Access-Control-Allow-Methods"), name)
+	}
+
+	req := httptest.NewRequest(http.MethodOptions, "/echo", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	assert.False(t, IsPreflight(req), "a preflight names the method it asks for")
+}
+
+func TestPolicy_Apply(t *testing.T) {
+	p, err := New(Options{
+		AllowedOrigins: []string{"https://*.example.com"},
+		ExposedHeaders: []string{"ETag", "RateLimit-Remaining"},
+	})
+	require.NoError(t, err)
+
+	header := http.Header{}
+	p.Apply(header, "https://app.example.com")
+	assert.Equal(t, "https://app.example.com", header.Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "ETag, RateLimit-Remaining", header.Get("Access-Control-Expose-Headers"))
+	assert.Empty(t, header.Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "Origin", header.Get("Vary"))
+
+	header = http.Header{}
+	p.Apply(header, "https://evil.test")
+	assert.Empty(t, header.Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "Origin", header.Get("Vary"), "responses vary by origin even when it is refused")
+
+	anyOrigin, err := New(Options{AllowedOrigins: []string{"*"}})
+	require.NoError(t, err)
+	header = http.Header{}
+	anyOrigin.Apply(header, "https://anything.test")
+	assert.Equal(t, "*", header.Get("Access-Control-Allow-Origin"))
+}
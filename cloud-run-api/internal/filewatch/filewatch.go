@@ -0,0 +1,80 @@
+// internal/filewatch/filewatch.go
+
+// Package filewatch reloads configuration files that change on disk. A
+// File polls the modification time and size of its path and hands the
+// contents to a load function when either changes; the load function
+// parses them and swaps them in, or returns an error to keep what it
+// loaded before.
+package filewatch
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// File is a file that is loaded again whenever it changes.
+type File struct {
+	path string
+	load func(data []byte) error
+
+	mu      sync.Mutex
+	loaded  bool
+	modTime time.Time
+	size    int64
+}
+
+// New returns a File that passes the contents of the file at path to load.
+// The file is not read until the first Reload.
+func New(path string, load func(data []byte) error) *File {
+	return &File{path: path, load: load}
+}
+
+// Path returns the path of the file.
+func (f *File) Path() string { return f.path }
+
+// Reload reads the file and calls load if its modification time or size
+// changed since the last successful load, or it was never loaded. It
+// reports whether load succeeded; errors from load are returned as they are.
+func (f *File) Reload() (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to stat %s: %w", f.path, err)
+	}
+	if f.loaded && info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return false, nil
+	}
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", f.path, err)
+	}
+	if err := f.load(data); err != nil {
+		return false, err
+	}
+	f.loaded = true
+	f.modTime = info.ModTime()
+	f.size = info.Size()
+	return true, nil
+}
+
+// Watch calls Reload every interval until ctx is cancelled, and reports
+// each reload that loaded changes (err is nil) or failed to onReload.
+func (f *File) Watch(ctx context.Context, interval time.Duration, onReload func(ctx context.Context, err error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if reloaded, err := f.Reload(); reloaded || err != nil {
+				onReload(ctx, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// internal/filewatch/filewatch_test.go
+package filewatch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.txt")
+	start := time.Now().Add(-time.Hour)
+
+	var mu sync.Mutex
+	var current string
+	loaded := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return current
+	}
+	f := New(path, func(data []byte) error {
+		if string(data) == "bad" {
+			return errors.New("bad contents")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		current = string(data)
+		return nil
+	})
+	assert.Equal(t, path, f.Path())
+
+	_, err := f.Reload()
+	assert.ErrorContains(t, err, "failed to stat", "a missing file is an error")
+
+	writeFile(t, path, "one", start)
+	reloaded, err := f.Reload()
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+	assert.Equal(t, "one", loaded())
+
+	reloaded, err = f.Reload()
+	require.NoError(t, err)
+	assert.False(t, reloaded, "an unchanged file is not loaded again")
+
+	writeFile(t, path, "two", start)
+	reloaded, err = f.Reload()
+	require.NoError(t, err)
+	assert.False(t, reloaded, "changes with the same modification time and size are not noticed")
+
+	writeFile(t, path, "bad", start.Add(time.Minute))
+	_, err = f.Reload()
+	require.EqualError(t, err, "bad contents", "load errors are returned as they are")
+	assert.Equal(t, "one", loaded())
+
+	writeFile(t, path, "three", start.Add(time.Minute))
+	reloaded, err = f.Reload()
+	require.NoError(t, err)
+	assert.True(t, reloaded, "a failed load is retried even if the file has the same modification time")
+	assert.Equal(t, "three", loaded())
+
+	t.Run("Watch", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		reports := make(chan error)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			f.Watch(ctx, 10*time.Millisecond, func(_ context.Context, err error) { reports <- err })
+		}()
+
+		writeFile(t, path, "bad", start.Add(2*time.Minute))
+		select {
+		case err := <-reports:
+			assert.EqualError(t, err, "bad contents")
+		case <-time.After(time.Second):
+			t.Fatal("the failed reload was not reported")
+		}
+
+		// The bad file is reported on every poll until it is fixed.
+		writeFile(t, path, "four", start.Add(3*time.Minute))
+		timeout := time.After(time.Second)
+		for reloaded := false; !reloaded; {
+			select {
+			case err := <-reports:
+				reloaded = err == nil
+			case <-timeout:
+				t.Fatal("the reload was not reported")
+			}
+		}
+		assert.Equal(t, "four", loaded())
+
+		cancel()
+		<-done
+	})
+}
@@ -0,0 +1,183 @@
+// internal/flags/flags.go
+//
+// Package flags provides feature flags evaluated per request. Flags are
+// supplied by a Provider (static configuration, a hot-reloaded JSON file, or
+// an in-memory store for tests), evaluated against an EvalContext built from
+// the request, and exposed to handlers through the request context.
+package flags
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Evaluation reasons reported in an Evaluation.
+const (
+	ReasonDisabled = "disabled" // The flag is switched off.
+	ReasonUnknown  = "unknown"  // No flag with this name is defined.
+	ReasonCaller   = "caller"   // The caller is explicitly targeted.
+	ReasonHeader   = "header"   // A request header matched a targeting rule.
+	ReasonRollout  = "rollout"  // The caller fell inside (or outside) the rollout percentage.
+)
+
+// Flag defines a feature flag and the rules used to evaluate it.
+//
+// A disabled flag is always off. An enabled flag is on for callers listed in
+// Callers and for requests carrying any of the Headers values; otherwise the
+// caller is bucketed by a stable hash and the flag is on when the bucket falls
+// below Percentage. A nil Percentage means 100%.
+type Flag struct {
+	Name       string            `json:"name"`
+	Enabled    bool              `json:"enabled"`
+	Percentage *int              `json:"percentage,omitempty"`
+	Callers    []string          `json:"callers,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+}
+
+// EvalContext holds the per-request attributes flags are evaluated against.
+type EvalContext struct {
+	Caller  string
+	Headers http.Header
+}
+
+// Evaluation is the outcome of evaluating a single flag.
+type Evaluation struct {
+	Name    string
+	Enabled bool
+	Reason  string
+}
+
+// Evaluate evaluates f for the given context.
+func (f Flag) Evaluate(ec EvalContext) Evaluation {
+	eval := Evaluation{Name: f.Name}
+	if !f.Enabled {
+		eval.Reason = ReasonDisabled
+		return eval
+	}
+
+	if ec.Caller != "" {
+		for _, c := range f.Callers {
+			if c == ec.Caller {
+				eval.Enabled, eval.Reason = true, ReasonCaller
+				return eval
+			}
+		}
+	}
+
+	for name, want := range f.Headers {
+		if ec.Headers != nil && ec.Headers.Get(name) == want {
+			eval.Enabled, eval.Reason = true, ReasonHeader
+			return eval
+		}
+	}
+
+	eval.Reason = ReasonRollout
+	eval.Enabled = inRollout(f.Name, ec.Caller, f.Percentage)
+	return eval
+}
+
+// inRollout reports whether caller falls within percentage for the named flag.
+// The bucket is derived from a hash of flag name and caller, so a caller keeps
+// the same outcome across requests and instances while the percentage is unchanged.
+func inRollout(name, caller string, percentage *int) bool {
+	if percentage == nil || *percentage >= 100 {
+		return true
+	}
+	if *percentage <= 0 || caller == "" {
+		return false
+	}
+	return Bucket(name, caller) < *percentage
+}
+
+// Bucket returns the stable rollout bucket (0-99) for caller on the named flag.
+func Bucket(name, caller string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(caller))
+	return int(h.Sum32() % 100)
+}
+
+// ParseStatic parses a comma-separated flag specification such as
+// "echo-uppercase=on,hello-friendly-greeting=25%". Each entry is a flag name
+// followed by "on"/"true", "off"/"false" or a rollout percentage. A bare name
+// enables the flag.
+func ParseStatic(spec string) ([]Flag, error) {
+	var out []Flag
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, hasValue := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, fmt.Errorf("flags: empty flag name in %q", entry)
+		}
+
+		flag := Flag{Name: name, Enabled: true}
+		value = strings.ToLower(strings.TrimSpace(value))
+		switch {
+		case !hasValue, value == "on", value == "true":
+		case value == "off", value == "false":
+			flag.Enabled = false
+		case strings.HasSuffix(value, "%"):
+			pct, err := strconv.Atoi(strings.TrimSuffix(value, "%"))
+			if err != nil || pct < 0 || pct > 100 {
+				return nil, fmt.Errorf("flags: invalid percentage for %s: %q", name, value)
+			}
+			flag.Percentage = &pct
+		default:
+			return nil, fmt.Errorf("flags: invalid value for %s: %q", name, value)
+		}
+		out = append(out, flag)
+	}
+	return out, nil
+}
+
+// Set is the collection of flag evaluations for a single request.
+type Set struct {
+	evals map[string]Evaluation
+}
+
+// Evaluate evaluates every flag for the given context.
+func Evaluate(defs []Flag, ec EvalContext) *Set {
+	s := &Set{evals: make(map[string]Evaluation, len(defs))}
+	for _, f := range defs {
+		s.evals[f.Name] = f.Evaluate(ec)
+	}
+	return s
+}
+
+// Enabled reports whether the named flag is on. Unknown flags are off.
+// It is safe to call on a nil Set.
+func (s *Set) Enabled(name string) bool {
+	return s.Lookup(name).Enabled
+}
+
+// Lookup returns the evaluation for the named flag.
+func (s *Set) Lookup(name string) Evaluation {
+	if s != nil {
+		if e, ok := s.evals[name]; ok {
+			return e
+		}
+	}
+	return Evaluation{Name: name, Reason: ReasonUnknown}
+}
+
+// All returns every evaluation sorted by flag name.
+func (s *Set) All() []Evaluation {
+	if s == nil {
+		return nil
+	}
+	out := make([]Evaluation, 0, len(s.evals))
+	for _, e := range s.evals {
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
@@ -0,0 +1,135 @@
+// internal/flags/flags_test.go
+package flags
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func intPtr(i int) *int { return &i }
+
+func TestFlagEvaluate(t *testing.T) {
+	tests := []struct {
+		name       string
+		flag       Flag
+		ec         EvalContext
+		wantOn     bool
+		wantReason string
+	}{
+		{
+			name:       "Disabled",
+			flag:       Flag{Name: "f", Enabled: false, Callers: []string{"alice"}},
+			ec:         EvalContext{Caller: "alice"},
+			wantReason: ReasonDisabled,
+		},
+		{
+			name:       "Enabled without rollout",
+			flag:       Flag{Name: "f", Enabled: true},
+			ec:         EvalContext{Caller: "anyone"},
+			wantOn:     true,
+			wantReason: ReasonRollout,
+		},
+		{
+			name:       "Targeted caller",
+			flag:       Flag{Name: "f", Enabled: true, Percentage: intPtr(0), Callers: []string{"alice"}},
+			ec:         EvalContext{Caller: "alice"},
+			wantOn:     true,
+			wantReason: ReasonCaller,
+		},
+		{
+			name:       "Header match",
+			flag:       Flag{Name: "f", Enabled: true, Percentage: intPtr(0), Headers: map[string]string{"X-Beta": "1"}},
+			ec:         EvalContext{Caller: "bob", Headers: http.Header{"X-Beta": []string{"1"}}},
+			wantOn:     true,
+			wantReason: ReasonHeader,
+		},
+		{
+			name:       "Header mismatch falls through to rollout",
+			flag:       Flag{Name: "f", Enabled: true, Percentage: intPtr(0), Headers: map[string]string{"X-Beta": "1"}},
+			ec:         EvalContext{Caller: "bob", Headers: http.Header{"X-Beta": []string{"0"}}},
+			wantReason: ReasonRollout,
+		},
+		{
+			name:       "Partial rollout without caller is off",
+			flag:       Flag{Name: "f", Enabled: true, Percentage: intPtr(99)},
+			ec:         EvalContext{},
+			wantReason: ReasonRollout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.flag.Evaluate(tt.ec)
+			assert.Equal(t, tt.wantOn, got.Enabled)
+			assert.Equal(t, tt.wantReason, got.Reason)
+			assert.Equal(t, tt.flag.Name, got.Name)
+		})
+	}
+}
+
+func TestRolloutIsStableAndProportional(t *testing.T) {
+	flag := Flag{Name: "gradual", Enabled: true, Percentage: intPtr(30)}
+
+	on := 0
+	const callers = 10000
+	for i := 0; i < callers; i++ {
+		ec := EvalContext{Caller: fmt.Sprintf("caller-%d", i)}
+		first := flag.Evaluate(ec).Enabled
+		assert.Equal(t, first, flag.Evaluate(ec).Enabled, "evaluation must be stable for a caller")
+		if first {
+			on++
+		}
+	}
+	assert.InDelta(t, 0.30, float64(on)/callers, 0.03)
+
+	// Raising the percentage never turns a caller off.
+	wider := Flag{Name: "gradual", Enabled: true, Percentage: intPtr(60)}
+	for i := 0; i < 1000; i++ {
+		ec := EvalContext{Caller: fmt.Sprintf("caller-%d", i)}
+		if flag.Evaluate(ec).Enabled {
+			assert.True(t, wider.Evaluate(ec).Enabled)
+		}
+	}
+}
+
+func TestParseStatic(t *testing.T) {
+	defs, err := ParseStatic(" a , b=off, c=25%, d=true ")
+	require.NoError(t, err)
+	require.Len(t, defs, 4)
+
+	assert.Equal(t, Flag{Name: "a", Enabled: true}, defs[0])
+	assert.Equal(t, Flag{Name: "b", Enabled: false}, defs[1])
+	assert.Equal(t, "c", defs[2].Name)
+	require.NotNil(t, defs[2].Percentage)
+	assert.Equal(t, 25, *defs[2].Percentage)
+	assert.True(t, defs[3].Enabled)
+
+	empty, err := ParseStatic("")
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+
+	for _, bad := range []string{"=on", "x=maybe", "x=150%", "x=abc%"} {
+		_, err := ParseStatic(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestSet(t *testing.T) {
+	set := Evaluate([]Flag{{Name: "b", Enabled: true}, {Name: "a", Enabled: false}}, EvalContext{})
+	assert.True(t, set.Enabled("b"))
+	assert.False(t, set.Enabled("a"))
+	assert.False(t, set.Enabled("missing"))
+	assert.Equal(t, ReasonUnknown, set.Lookup("missing").Reason)
+
+	all := set.All()
+	require.Len(t, all, 2)
+	assert.Equal(t, "a", all[0].Name)
+
+	var nilSet *Set
+	assert.False(t, nilSet.Enabled("b"))
+	assert.Nil(t, nilSet.All())
+}
@@ -0,0 +1,49 @@
+// internal/flags/middleware.go
+package flags
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+)
+
+type setKey struct{}
+
+// NewContext returns a copy of ctx carrying the evaluated flag set.
+func NewContext(ctx context.Context, s *Set) context.Context {
+	return context.WithValue(ctx, setKey{}, s)
+}
+
+// FromContext returns the flag set stored in ctx, or nil if there is none.
+// A nil Set reports every flag as off.
+func FromContext(ctx context.Context) *Set {
+	s, _ := ctx.Value(setKey{}).(*Set)
+	return s
+}
+
+// Enabled reports whether the named flag is on for the request carried by ctx.
+func Enabled(ctx context.Context, name string) bool {
+	return FromContext(ctx).Enabled(name)
+}
+
+// Middleware evaluates every flag from p for each request and stores the
+// result on the request context. caller derives the caller identity used for
+// targeting and percentage rollouts. Evaluations are logged at debug level
+// with the request context, so they carry the request's trace when the
+// middleware runs inside the trace middleware.
+func Middleware(p Provider, logger *slog.Logger, caller func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			set := Evaluate(p.Flags(), EvalContext{Caller: caller(r), Headers: r.Header})
+
+			if logger.Enabled(ctx, slog.LevelDebug) {
+				for _, e := range set.All() {
+					logger.DebugContext(ctx, "Feature flag evaluated", "flag", e.Name, "enabled", e.Enabled, "reason", e.Reason)
+				}
+			}
+
+			next.ServeHTTP(w, r.WithContext(NewContext(ctx, set)))
+		})
+	}
+}
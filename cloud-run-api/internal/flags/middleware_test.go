@@ -0,0 +1,48 @@
+// internal/flags/middleware_test.go
+package flags
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	provider := NewMemoryProvider(
+		Flag{Name: "beta", Enabled: true, Percentage: intPtr(0), Callers: []string{"alice"}},
+		Flag{Name: "off", Enabled: false},
+	)
+
+	var gotBeta, gotOff bool
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBeta = Enabled(r.Context(), "beta")
+		gotOff = Enabled(r.Context(), "off")
+	})
+	caller := func(r *http.Request) string { return r.Header.Get("X-Test-Caller") }
+	h := Middleware(provider, logger, caller)(next)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Test-Caller", "alice")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	assert.True(t, gotBeta)
+	assert.False(t, gotOff)
+	assert.Contains(t, logs.String(), "Feature flag evaluated")
+	assert.Contains(t, logs.String(), "flag=beta enabled=true reason=caller")
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Test-Caller", "bob")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	assert.False(t, gotBeta)
+}
+
+func TestFromContext_NoMiddleware(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	assert.Nil(t, FromContext(req.Context()))
+	assert.False(t, Enabled(req.Context(), "anything"))
+}
@@ -0,0 +1,175 @@
+// internal/flags/provider.go
+package flags
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"your-module-name/internal/filewatch"
+)
+
+// Provider supplies the current set of flag definitions.
+// Implementations must be safe for concurrent use.
+type Provider interface {
+	Flags() []Flag
+}
+
+// StaticProvider serves a fixed set of flags, typically parsed from configuration.
+type StaticProvider struct {
+	flags []Flag
+}
+
+// NewStaticProvider returns a provider that always serves the given flags.
+func NewStaticProvider(flags ...Flag) *StaticProvider {
+	return &StaticProvider{flags: flags}
+}
+
+// Flags implements Provider.
+func (p *StaticProvider) Flags() []Flag {
+	return p.flags
+}
+
+// MemoryProvider is a mutable provider, mainly intended for tests.
+type MemoryProvider struct {
+	mu    sync.RWMutex
+	flags map[string]Flag
+}
+
+// NewMemoryProvider returns a provider initialised with the given flags.
+func NewMemoryProvider(flags ...Flag) *MemoryProvider {
+	p := &MemoryProvider{flags: make(map[string]Flag, len(flags))}
+	for _, f := range flags {
+		p.flags[f.Name] = f
+	}
+	return p
+}
+
+// Set adds or replaces a flag.
+func (p *MemoryProvider) Set(f Flag) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.flags[f.Name] = f
+}
+
+// Delete removes the named flag.
+func (p *MemoryProvider) Delete(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.flags, name)
+}
+
+// Flags implements Provider.
+func (p *MemoryProvider) Flags() []Flag {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	out := make([]Flag, 0, len(p.flags))
+	for _, f := range p.flags {
+		out = append(out, f)
+	}
+	return out
+}
+
+// fileFormat is the JSON document read by FileProvider.
+type fileFormat struct {
+	Flags []Flag `json:"flags"`
+}
+
+// FileProvider serves flags from a JSON file of the form {"flags": [...]}
+// and reloads it when the file changes. If a reload fails, the last valid
+// set of flags remains in effect.
+type FileProvider struct {
+	file   *filewatch.File
+	logger *slog.Logger
+
+	// OnReload, if set, is called by Watch after each reload that loaded
+	// changes (err is nil) or failed. Set it before starting Watch.
+	OnReload func(ctx context.Context, err error)
+
+	mu    sync.RWMutex
+	flags []Flag
+}
+
+// NewFileProvider loads the flags file at path. It returns an error if the
+// initial load fails, so a broken file is caught at startup.
+func NewFileProvider(path string, logger *slog.Logger) (*FileProvider, error) {
+	p := &FileProvider{logger: logger}
+	p.file = filewatch.New(path, p.load)
+	if _, err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Flags implements Provider.
+func (p *FileProvider) Flags() []Flag {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.flags
+}
+
+// Reload re-reads the file if its modification time or size changed since the
+// last successful load. It reports whether new flags were loaded.
+func (p *FileProvider) Reload() (bool, error) {
+	reloaded, err := p.file.Reload()
+	if err != nil {
+		return false, fmt.Errorf("flags: %w", err)
+	}
+	return reloaded, nil
+}
+
+// load parses and validates a flags file and puts its flags in effect.
+func (p *FileProvider) load(data []byte) error {
+	var doc fileFormat
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", p.file.Path(), err)
+	}
+	if err := validate(doc.Flags); err != nil {
+		return fmt.Errorf("invalid %s: %w", p.file.Path(), err)
+	}
+	if doc.Flags == nil {
+		doc.Flags = []Flag{}
+	}
+	p.mu.Lock()
+	p.flags = doc.Flags
+	p.mu.Unlock()
+	return nil
+}
+
+// Watch polls the file every interval and reloads it on change until ctx is
+// cancelled. Reload failures are logged and the previous flags are kept.
+func (p *FileProvider) Watch(ctx context.Context, interval time.Duration) {
+	p.file.Watch(ctx, interval, func(ctx context.Context, err error) {
+		if err != nil {
+			err = fmt.Errorf("flags: %w", err)
+		}
+		if p.OnReload != nil {
+			p.OnReload(ctx, err)
+		}
+		if err != nil {
+			p.logger.WarnContext(ctx, "Failed to reload feature flags; keeping previous flags", "path", p.file.Path(), "error", err)
+			return
+		}
+		p.logger.InfoContext(ctx, "Feature flags reloaded", "path", p.file.Path(), "count", len(p.Flags()))
+	})
+}
+
+func validate(flags []Flag) error {
+	seen := make(map[string]bool, len(flags))
+	for _, f := range flags {
+		if f.Name == "" {
+			return fmt.Errorf("flag with empty name")
+		}
+		if seen[f.Name] {
+			return fmt.Errorf("duplicate flag %q", f.Name)
+		}
+		seen[f.Name] = true
+		if f.Percentage != nil && (*f.Percentage < 0 || *f.Percentage > 100) {
+			return fmt.Errorf("flag %q: percentage must be between 0 and 100", f.Name)
+		}
+	}
+	return nil
+}
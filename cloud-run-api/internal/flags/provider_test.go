@@ -0,0 +1,103 @@
+// internal/flags/provider_test.go
+package flags
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFlagsFile(t *testing.T, path, content string, mtime time.Time) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	// Force a distinct modification time; some filesystems have coarse timestamps.
+	require.NoError(t, os.Chtimes(path, mtime, mtime))
+}
+
+func TestMemoryProvider(t *testing.T) {
+	p := NewMemoryProvider(Flag{Name: "a", Enabled: true})
+	assert.Len(t, p.Flags(), 1)
+
+	p.Set(Flag{Name: "b", Enabled: true})
+	assert.Len(t, p.Flags(), 2)
+
+	p.Delete("a")
+	require.Len(t, p.Flags(), 1)
+	assert.Equal(t, "b", p.Flags()[0].Name)
+}
+
+func TestFileProvider(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	path := filepath.Join(t.TempDir(), "flags.json")
+	base := time.Now().Add(-time.Hour)
+	writeFlagsFile(t, path, `{"flags":[{"name":"a","enabled":true}]}`, base)
+
+	p, err := NewFileProvider(path, logger)
+	require.NoError(t, err)
+	require.Len(t, p.Flags(), 1)
+	assert.Equal(t, "a", p.Flags()[0].Name)
+
+	t.Run("Unchanged file is not reloaded", func(t *testing.T) {
+		reloaded, err := p.Reload()
+		require.NoError(t, err)
+		assert.False(t, reloaded)
+	})
+
+	t.Run("Changed file is reloaded", func(t *testing.T) {
+		writeFlagsFile(t, path, `{"flags":[{"name":"b","enabled":true,"percentage":50}]}`, base.Add(time.Minute))
+		reloaded, err := p.Reload()
+		require.NoError(t, err)
+		assert.True(t, reloaded)
+		require.Len(t, p.Flags(), 1)
+		assert.Equal(t, "b", p.Flags()[0].Name)
+	})
+
+	t.Run("Invalid file keeps previous flags", func(t *testing.T) {
+		writeFlagsFile(t, path, `{"flags":[{"name":"c","enabled":true,"percentage":500}]}`, base.Add(2*time.Minute))
+		_, err := p.Reload()
+		require.Error(t, err)
+		require.Len(t, p.Flags(), 1)
+		assert.Equal(t, "b", p.Flags()[0].Name)
+	})
+
+	t.Run("Watch picks up changes", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var reloads atomic.Int32
+		p.OnReload = func(_ context.Context, err error) {
+			if err == nil {
+				reloads.Add(1)
+			}
+		}
+		go p.Watch(ctx, 10*time.Millisecond)
+
+		writeFlagsFile(t, path, `{"flags":[{"name":"d","enabled":false}]}`, base.Add(3*time.Minute))
+		assert.Eventually(t, func() bool {
+			f := p.Flags()
+			return len(f) == 1 && f[0].Name == "d"
+		}, time.Second, 10*time.Millisecond)
+		assert.Eventually(t, func() bool { return reloads.Load() == 1 }, time.Second, 10*time.Millisecond, "OnReload reports the reload")
+	})
+}
+
+func TestNewFileProvider_Errors(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	dir := t.TempDir()
+
+	_, err := NewFileProvider(filepath.Join(dir, "missing.json"), logger)
+	assert.Error(t, err)
+
+	dup := filepath.Join(dir, "dup.json")
+	require.NoError(t, os.WriteFile(dup, []byte(`{"flags":[{"name":"a"},{"name":"a"}]}`), 0o600))
+	_, err = NewFileProvider(dup, logger)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "duplicate")
+}
@@ -0,0 +1,207 @@
+// internal/idempotency/idempotency.go
+//
+// Package idempotency stores the responses of requests made with an
+// Idempotency-Key header, so that retries of a mutating request are answered
+// with the original response instead of being executed again. The HTTP
+// middleware that uses it lives in internal/api.
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Response is a recorded HTTP response.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Record is the state of an idempotency key.
+type Record struct {
+	// Fingerprint identifies the request that claimed the key, so a key
+	// reused for a different request can be rejected.
+	Fingerprint string
+	// Response is nil while the first request is still in progress.
+	Response *Response
+	// ExpiresAt is when the key is forgotten.
+	ExpiresAt time.Time
+}
+
+// Store holds idempotency records. Implementations must be safe for
+// concurrent use; Reserve must be atomic across every instance sharing the
+// store.
+type Store interface {
+	// Reserve claims key for a request with the given fingerprint until ttl
+	// elapses. If the key is already claimed it returns the existing record
+	// and false instead.
+	Reserve(ctx context.Context, key, fingerprint string, ttl time.Duration) (Record, bool, error)
+	// Complete stores the response for a reserved key and keeps it for ttl.
+	Complete(ctx context.Context, key string, resp Response, ttl time.Duration) error
+	// Release forgets a reserved key so that the request can be retried.
+	Release(ctx context.Context, key string) error
+}
+
+// ErrFull is returned by a Store that cannot hold another record.
+var ErrFull = errors.New("idempotency: store is full")
+
+// sweepInterval is how often MemoryStore drops expired records.
+const sweepInterval = time.Minute
+
+// MemoryStore is an in-memory Store. Records are not shared between
+// instances, so duplicates that reach different instances are not detected.
+//
+// MaxKeys and MaxBytes bound the memory it holds. When a record would exceed
+// either, the oldest completed records are evicted, so late retries of their
+// requests are executed again; keys still in progress are never evicted, and
+// when they alone fill the store, Reserve and Complete return ErrFull. Set
+// the limits before first use.
+type MemoryStore struct {
+	// MaxKeys caps the number of records. Zero means no limit.
+	MaxKeys int
+	// MaxBytes caps the total size of the recorded responses' bodies and
+	// headers. Zero means no limit.
+	MaxBytes int
+
+	mu        sync.Mutex
+	records   map[string]entry
+	bytes     int
+	lastSweep time.Time
+	now       func() time.Time // Replaced in tests.
+}
+
+// entry is a record with the bookkeeping of its eviction.
+type entry struct {
+	Record
+	reservedAt time.Time
+	size       int
+}
+
+// NewMemoryStore returns an empty in-memory store without limits.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]entry), now: time.Now}
+}
+
+// Reserve implements Store.
+func (s *MemoryStore) Reserve(ctx context.Context, key, fingerprint string, ttl time.Duration) (Record, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return Record{}, false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.sweep(now, false)
+	if e, ok := s.records[key]; ok && now.Before(e.ExpiresAt) {
+		return e.Record, false, nil
+	}
+	s.remove(key)
+	if s.MaxKeys > 0 && len(s.records) >= s.MaxKeys {
+		s.sweep(now, true)
+		for len(s.records) >= s.MaxKeys {
+			if !s.evictOldest("") {
+				return Record{}, false, ErrFull
+			}
+		}
+	}
+	rec := Record{Fingerprint: fingerprint, ExpiresAt: now.Add(ttl)}
+	s.records[key] = entry{Record: rec, reservedAt: now}
+	return rec, true, nil
+}
+
+// Complete implements Store.
+func (s *MemoryStore) Complete(ctx context.Context, key string, resp Response, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.records[key]
+	if !ok {
+		// Swept or released meanwhile: record it as if just reserved.
+		e.reservedAt = s.now()
+	}
+	s.bytes -= e.size
+	e.size = responseSize(resp)
+	s.bytes += e.size
+	e.Response = &resp
+	e.ExpiresAt = s.now().Add(ttl)
+	s.records[key] = e
+	for s.MaxBytes > 0 && s.bytes > s.MaxBytes {
+		if !s.evictOldest(key) {
+			s.remove(key)
+			return ErrFull
+		}
+	}
+	return nil
+}
+
+// Release implements Store. It succeeds even if ctx is done, so a key is
+// not left reserved after a canceled request.
+func (s *MemoryStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(key)
+	return nil
+}
+
+// Len returns the number of records held, including expired ones not yet
+// swept.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.records)
+}
+
+// sweep drops expired records, unless force is false and it did so less
+// than sweepInterval ago. The caller must hold s.mu.
+func (s *MemoryStore) sweep(now time.Time, force bool) {
+	if !force && now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, e := range s.records {
+		if !now.Before(e.ExpiresAt) {
+			s.remove(key)
+		}
+	}
+}
+
+// evictOldest removes the completed record reserved first, other than
+// keep, and reports whether there was one. The caller must hold s.mu.
+func (s *MemoryStore) evictOldest(keep string) bool {
+	oldest, found := "", false
+	for key, e := range s.records {
+		if e.Response == nil || key == keep {
+			continue
+		}
+		if !found || e.reservedAt.Before(s.records[oldest].reservedAt) {
+			oldest, found = key, true
+		}
+	}
+	if found {
+		s.remove(oldest)
+	}
+	return found
+}
+
+// remove forgets key. The caller must hold s.mu.
+func (s *MemoryStore) remove(key string) {
+	s.bytes -= s.records[key].size
+	delete(s.records, key)
+}
+
+// responseSize approximates the memory held by resp.
+func responseSize(resp Response) int {
+	n := len(resp.Body)
+	for k, v := range resp.Header {
+		n += len(k)
+		for _, s := range v {
+			n += len(s)
+		}
+	}
+	return n
+}
@@ -0,0 +1,135 @@
+// internal/idempotency/idempotency_test.go
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := NewMemoryStore()
+	s.now = func() time.Time { return now }
+
+	rec, reserved, err := s.Reserve(ctx, "k", "fp1", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, reserved)
+	assert.Nil(t, rec.Response)
+
+	rec, reserved, err = s.Reserve(ctx, "k", "fp2", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, reserved, "the key is in progress")
+	assert.Equal(t, "fp1", rec.Fingerprint)
+	assert.Nil(t, rec.Response)
+
+	resp := Response{Status: http.StatusCreated, Header: http.Header{"X-A": {"b"}}, Body: []byte("done")}
+	require.NoError(t, s.Complete(ctx, "k", resp, time.Hour))
+	rec, reserved, err = s.Reserve(ctx, "k", "fp1", time.Minute)
+	require.NoError(t, err)
+	require.False(t, reserved)
+	assert.Equal(t, &resp, rec.Response)
+	assert.Equal(t, now.Add(time.Hour), rec.ExpiresAt, "completion restarts the TTL")
+
+	now = now.Add(time.Hour)
+	_, reserved, _ = s.Reserve(ctx, "k", "fp3", time.Minute)
+	assert.True(t, reserved, "an expired key can be reserved again")
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	require.NoError(t, s.Release(canceled, "k"))
+	_, reserved, _ = s.Reserve(ctx, "k", "fp4", time.Minute)
+	assert.True(t, reserved, "released")
+
+	_, _, _ = s.Reserve(ctx, "old", "fp", time.Second)
+	now = now.Add(2 * sweepInterval)
+	_, _, _ = s.Reserve(ctx, "new", "fp", time.Second)
+	assert.Equal(t, 1, s.Len(), "expired records are swept")
+}
+
+func TestMemoryStore_ReserveIsAtomic(t *testing.T) {
+	s := NewMemoryStore()
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		winners int
+	)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, reserved, err := s.Reserve(context.Background(), "k", "fp", time.Minute)
+			assert.NoError(t, err)
+			if reserved {
+				mu.Lock()
+				winners++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, winners)
+}
+
+func TestMemoryStore_Limits(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	complete := func(s *MemoryStore, key string, body string) error {
+		t.Helper()
+		_, reserved, err := s.Reserve(ctx, key, "fp", time.Hour)
+		require.NoError(t, err)
+		require.True(t, reserved)
+		now = now.Add(time.Second)
+		return s.Complete(ctx, key, Response{Status: http.StatusOK, Body: []byte(body)}, time.Hour)
+	}
+	held := func(s *MemoryStore, key string) bool {
+		_, ok := s.records[key]
+		return ok
+	}
+
+	t.Run("MaxKeys", func(t *testing.T) {
+		s := NewMemoryStore()
+		s.now = func() time.Time { return now }
+		s.MaxKeys = 2
+		require.NoError(t, complete(s, "a", "x"))
+		require.NoError(t, complete(s, "b", "x"))
+		require.NoError(t, complete(s, "c", "x"))
+		assert.Equal(t, 2, s.Len())
+		assert.False(t, held(s, "a"), "the oldest completed key was evicted")
+		assert.True(t, held(s, "b"))
+
+		_, _, err := s.Reserve(ctx, "d", "fp", time.Hour)
+		require.NoError(t, err)
+		_, _, err = s.Reserve(ctx, "e", "fp", time.Hour)
+		require.NoError(t, err)
+		_, _, err = s.Reserve(ctx, "f", "fp", time.Hour)
+		assert.ErrorIs(t, err, ErrFull, "keys in progress are never evicted")
+
+		now = now.Add(2 * time.Hour)
+		_, reserved, err := s.Reserve(ctx, "f", "fp", time.Hour)
+		require.NoError(t, err)
+		assert.True(t, reserved, "expired keys make room")
+	})
+
+	t.Run("MaxBytes", func(t *testing.T) {
+		s := NewMemoryStore()
+		s.now = func() time.Time { return now }
+		s.MaxBytes = 10
+		require.NoError(t, complete(s, "a", "12345"))
+		require.NoError(t, complete(s, "b", "12345"))
+		require.NoError(t, complete(s, "c", "12345"))
+		assert.False(t, held(s, "a"))
+		assert.True(t, held(s, "b"))
+		assert.True(t, held(s, "c"))
+
+		assert.ErrorIs(t, complete(s, "d", "12345678901"), ErrFull, "a response over the limit on its own")
+		assert.False(t, held(s, "d"), "is not kept")
+		assert.Equal(t, 0, s.bytes)
+	})
+}
@@ -0,0 +1,272 @@
+// internal/loadshed/loadshed.go
+//
+// Package loadshed bounds the number of requests served at once with a
+// limit that adapts to observed latency, queues requests over the limit for
+// a bounded time, and sheds those it cannot serve in time, so an overloaded
+// instance answers quickly instead of letting latency grow without bound.
+// The HTTP middleware that uses it lives in internal/api.
+package loadshed
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"your-module-name/internal/metrics"
+)
+
+// Class is the priority of a request. When the limit is reached, queued
+// requests are admitted in class order, and a full queue sheds its lowest
+// class first.
+type Class int
+
+const (
+	// Sheddable requests are expensive and can be retried later.
+	Sheddable Class = iota
+	// Normal is the class of most requests.
+	Normal
+	// Critical requests, such as admin calls, are never queued or shed.
+	Critical
+)
+
+// String returns the class name used in logs and metrics.
+func (c Class) String() string {
+	switch c {
+	case Sheddable:
+		return "sheddable"
+	case Critical:
+		return "critical"
+	}
+	return "normal"
+}
+
+// Errors returned by Acquire when a request is shed.
+var (
+	ErrQueueFull    = errors.New("loadshed: queue full")
+	ErrQueueTimeout = errors.New("loadshed: timed out in queue")
+)
+
+// Options configure a Limiter.
+type Options struct {
+	// MinLimit and MaxLimit bound the concurrency limit. Defaults 1 and 80,
+	// Cloud Run's default concurrency.
+	MinLimit, MaxLimit int
+	// InitialLimit is the limit before any latency is observed. Default
+	// halfway between MinLimit and MaxLimit.
+	InitialLimit int
+	// LatencyThreshold is the latency above which a request counts as a sign
+	// of overload. Default 1s.
+	LatencyThreshold time.Duration
+	// Backoff multiplies the limit on overload. Default 0.9.
+	Backoff float64
+	// QueueSize is how many requests may wait for a slot; zero sheds
+	// requests over the limit at once.
+	QueueSize int
+	// QueueTimeout is how long a request may wait for a slot. Default 500ms.
+	QueueTimeout time.Duration
+}
+
+// Limiter admits requests up to an adaptive concurrency limit. The limit
+// follows AIMD: it grows by one for each request that completes within
+// LatencyThreshold while the limit is at least half used, and shrinks by
+// Backoff for each request that is slower or reports overload. It is safe
+// for concurrent use.
+type Limiter struct {
+	opts Options
+
+	mu       sync.Mutex
+	limit    int
+	inFlight int
+	queue    []*waiter
+	latency  time.Duration // Moving average of request latency.
+
+	requests  *metrics.Counter
+	queueWait *metrics.Histogram
+	now       func() time.Time // Replaced in tests.
+}
+
+// waiter is a queued request. ready receives true when it is admitted and
+// false when it is shed to make room for a higher class.
+type waiter struct {
+	class Class
+	ready chan bool
+}
+
+// New returns a limiter whose state is published to reg under the
+// concurrency_ prefix.
+func New(opts Options, reg *metrics.Registry) *Limiter {
+	if opts.MinLimit <= 0 {
+		opts.MinLimit = 1
+	}
+	if opts.MaxLimit <= 0 {
+		opts.MaxLimit = 80
+	}
+	opts.MaxLimit = max(opts.MaxLimit, opts.MinLimit)
+	if opts.InitialLimit <= 0 {
+		opts.InitialLimit = (opts.MinLimit + opts.MaxLimit) / 2
+	}
+	if opts.LatencyThreshold <= 0 {
+		opts.LatencyThreshold = time.Second
+	}
+	if opts.Backoff <= 0 || opts.Backoff >= 1 {
+		opts.Backoff = 0.9
+	}
+	if opts.QueueTimeout <= 0 {
+		opts.QueueTimeout = 500 * time.Millisecond
+	}
+	l := &Limiter{
+		opts:  opts,
+		limit: min(max(opts.InitialLimit, opts.MinLimit), opts.MaxLimit),
+		now:   time.Now,
+	}
+	l.requests = reg.Counter("concurrency_requests_total", "Requests seen by the concurrency limiter, by class and outcome (admitted, queued, shed).", "class", "outcome")
+	l.queueWait = reg.Histogram("concurrency_queue_wait_seconds", "Time requests spent queued for a slot.", []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5})
+	reg.GaugeFunc("concurrency_limit", "Current adaptive concurrency limit.", func() float64 { return float64(l.Limit()) })
+	reg.GaugeFunc("concurrency_in_flight", "Requests being served.", func() float64 { return float64(l.InFlight()) })
+	reg.GaugeFunc("concurrency_queue_length", "Requests waiting for a slot.", func() float64 { return float64(l.QueueLength()) })
+	return l
+}
+
+// Limit returns the current concurrency limit.
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+// InFlight returns the number of admitted requests not yet released.
+func (l *Limiter) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight
+}
+
+// QueueLength returns the number of queued requests.
+func (l *Limiter) QueueLength() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.queue)
+}
+
+// RetryAfter suggests how long a shed client should wait before retrying:
+// the average request latency, but at least a second.
+func (l *Limiter) RetryAfter() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return max(l.latency, time.Second)
+}
+
+// Acquire admits a request of the given class, waiting in the queue if the
+// limit is reached. It returns ErrQueueFull or ErrQueueTimeout if the
+// request is shed, or ctx's error if ctx is done first. An admitted request
+// must call the returned release function when it completes, reporting
+// whether it failed because of overload, such as a timeout.
+func (l *Limiter) Acquire(ctx context.Context, class Class) (release func(overloaded bool), err error) {
+	l.mu.Lock()
+	if class == Critical || (l.inFlight < l.limit && len(l.queue) == 0) {
+		l.inFlight++
+		l.mu.Unlock()
+		l.requests.Inc(class.String(), "admitted")
+		return l.releaser(), nil
+	}
+	if len(l.queue) >= l.opts.QueueSize && !l.evict(class) {
+		l.mu.Unlock()
+		l.requests.Inc(class.String(), "shed")
+		return nil, ErrQueueFull
+	}
+	w := &waiter{class: class, ready: make(chan bool, 1)}
+	l.queue = append(l.queue, w)
+	l.mu.Unlock()
+
+	start := l.now()
+	timer := time.NewTimer(l.opts.QueueTimeout)
+	defer timer.Stop()
+	select {
+	case admitted := <-w.ready:
+		return l.admitted(w, admitted, start)
+	case <-timer.C:
+		err = ErrQueueTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	l.mu.Lock()
+	if i := slices.Index(l.queue, w); i >= 0 {
+		l.queue = slices.Delete(l.queue, i, i+1)
+		l.mu.Unlock()
+		l.requests.Inc(class.String(), "shed")
+		return nil, err
+	}
+	l.mu.Unlock()
+	// Admitted or evicted while giving up; honour that decision.
+	return l.admitted(w, <-w.ready, start)
+}
+
+// admitted finishes Acquire for a waiter that left the queue.
+func (l *Limiter) admitted(w *waiter, ok bool, start time.Time) (func(bool), error) {
+	l.queueWait.Observe(l.now().Sub(start).Seconds())
+	if !ok {
+		l.requests.Inc(w.class.String(), "shed")
+		return nil, ErrQueueFull
+	}
+	l.requests.Inc(w.class.String(), "queued")
+	return l.releaser(), nil
+}
+
+// evict sheds the newest waiter of the lowest class below class to make
+// room in the queue. It reports whether there was one. The caller must hold
+// l.mu.
+func (l *Limiter) evict(class Class) bool {
+	victim := -1
+	for i, w := range l.queue {
+		if w.class < class && (victim < 0 || w.class <= l.queue[victim].class) {
+			victim = i
+		}
+	}
+	if victim < 0 {
+		return false
+	}
+	l.queue[victim].ready <- false
+	l.queue = slices.Delete(l.queue, victim, victim+1)
+	return true
+}
+
+// releaser returns the release function of a request admitted now.
+func (l *Limiter) releaser() func(bool) {
+	start := l.now()
+	var once sync.Once
+	return func(overloaded bool) {
+		once.Do(func() { l.release(l.now().Sub(start), overloaded) })
+	}
+}
+
+// release adapts the limit to a completed request and admits queued
+// requests into the free slots.
+func (l *Limiter) release(latency time.Duration, overloaded bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.latency == 0 {
+		l.latency = latency
+	} else {
+		l.latency += (latency - l.latency) / 10
+	}
+	switch {
+	case overloaded || latency > l.opts.LatencyThreshold:
+		l.limit = max(int(float64(l.limit)*l.opts.Backoff), l.opts.MinLimit)
+	case l.inFlight*2 >= l.limit:
+		l.limit = min(l.limit+1, l.opts.MaxLimit)
+	}
+	l.inFlight--
+	for l.inFlight < l.limit && len(l.queue) > 0 {
+		next := 0
+		for i, w := range l.queue {
+			if w.class > l.queue[next].class {
+				next = i
+			}
+		}
+		l.queue[next].ready <- true
+		l.queue = slices.Delete(l.queue, next, next+1)
+		l.inFlight++
+	}
+}
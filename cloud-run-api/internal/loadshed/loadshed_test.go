@@ -0,0 +1,203 @@
+// internal/loadshed/loadshed_test.go
+package loadshed
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"your-module-name/internal/metrics"
+)
+
+// testLimiter returns a limiter with opts and a clock that only moves when
+// advanced.
+func testLimiter(t *testing.T, opts Options) (*Limiter, *fakeClock) {
+	t.Helper()
+	clock := &fakeClock{now: time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)}
+	l := New(opts, metrics.NewRegistry())
+	l.now = clock.Now
+	return l, clock
+}
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// result is the outcome of an Acquire run in the background.
+type result struct {
+	release func(bool)
+	err     error
+}
+
+// acquireAsync calls Acquire in the background and waits until the request
+// is queued.
+func acquireAsync(t *testing.T, l *Limiter, ctx context.Context, class Class) <-chan result {
+	t.Helper()
+	queued := l.QueueLength()
+	ch := make(chan result, 1)
+	go func() {
+		release, err := l.Acquire(ctx, class)
+		ch <- result{release, err}
+	}()
+	require.Eventually(t, func() bool { return l.QueueLength() > queued }, time.Second, time.Millisecond)
+	return ch
+}
+
+func receive(t *testing.T, ch <-chan result) result {
+	t.Helper()
+	select {
+	case r := <-ch:
+		return r
+	case <-time.After(time.Second):
+		t.Fatal("Acquire did not return")
+		return result{}
+	}
+}
+
+func TestLimiter_QueueAndPriority(t *testing.T) {
+	l, _ := testLimiter(t, Options{MinLimit: 1, MaxLimit: 1, QueueSize: 3, QueueTimeout: time.Minute})
+	first, err := l.Acquire(context.Background(), Normal)
+	require.NoError(t, err)
+	assert.Equal(t, 1, l.InFlight())
+
+	sheddable := acquireAsync(t, l, context.Background(), Sheddable)
+	normal := acquireAsync(t, l, context.Background(), Normal)
+
+	critical, err := l.Acquire(context.Background(), Critical)
+	require.NoError(t, err, "critical requests skip the queue")
+	assert.Equal(t, 2, l.InFlight())
+	critical(false)
+	assert.Equal(t, 2, l.QueueLength(), "a release over the limit admits no one")
+
+	first(false)
+	r := receive(t, normal)
+	require.NoError(t, r.err, "higher classes are admitted first")
+	assert.Equal(t, 1, l.QueueLength())
+
+	r.release(false)
+	r = receive(t, sheddable)
+	require.NoError(t, r.err)
+	r.release(false)
+	r.release(false) // Releasing twice has no effect.
+	assert.Zero(t, l.InFlight())
+}
+
+func TestLimiter_Shedding(t *testing.T) {
+	t.Run("No queue", func(t *testing.T) {
+		l, _ := testLimiter(t, Options{MinLimit: 1, MaxLimit: 1})
+		_, err := l.Acquire(context.Background(), Normal)
+		require.NoError(t, err)
+		_, err = l.Acquire(context.Background(), Normal)
+		assert.ErrorIs(t, err, ErrQueueFull)
+	})
+
+	t.Run("Queue timeout", func(t *testing.T) {
+		l, _ := testLimiter(t, Options{MinLimit: 1, MaxLimit: 1, QueueSize: 1, QueueTimeout: 10 * time.Millisecond})
+		_, err := l.Acquire(context.Background(), Normal)
+		require.NoError(t, err)
+		_, err = l.Acquire(context.Background(), Normal)
+		assert.ErrorIs(t, err, ErrQueueTimeout)
+		assert.Zero(t, l.QueueLength())
+	})
+
+	t.Run("Canceled context", func(t *testing.T) {
+		l, _ := testLimiter(t, Options{MinLimit: 1, MaxLimit: 1, QueueSize: 1, QueueTimeout: time.Minute})
+		_, err := l.Acquire(context.Background(), Normal)
+		require.NoError(t, err)
+		ctx, cancel := context.WithCancel(context.Background())
+		waiting := acquireAsync(t, l, ctx, Normal)
+		cancel()
+		assert.ErrorIs(t, receive(t, waiting).err, context.Canceled)
+		assert.Zero(t, l.QueueLength())
+	})
+
+	t.Run("Full queue sheds lower classes", func(t *testing.T) {
+		l, _ := testLimiter(t, Options{MinLimit: 1, MaxLimit: 1, QueueSize: 1, QueueTimeout: time.Minute})
+		first, err := l.Acquire(context.Background(), Normal)
+		require.NoError(t, err)
+		sheddable := acquireAsync(t, l, context.Background(), Sheddable)
+
+		normal := make(chan result, 1)
+		go func() {
+			release, err := l.Acquire(context.Background(), Normal)
+			normal <- result{release, err}
+		}()
+		assert.ErrorIs(t, receive(t, sheddable).err, ErrQueueFull, "the sheddable request makes room")
+
+		_, err = l.Acquire(context.Background(), Normal)
+		assert.ErrorIs(t, err, ErrQueueFull, "requests do not evict their own class")
+
+		first(false)
+		require.NoError(t, receive(t, normal).err)
+	})
+}
+
+func TestLimiter_AIMD(t *testing.T) {
+	l, clock := testLimiter(t, Options{MinLimit: 2, MaxLimit: 12, InitialLimit: 10, LatencyThreshold: 100 * time.Millisecond})
+	serve := func(n int, latency time.Duration, overloaded bool) {
+		var releases []func(bool)
+		for range n {
+			release, err := l.Acquire(context.Background(), Normal)
+			require.NoError(t, err)
+			releases = append(releases, release)
+		}
+		clock.Advance(latency)
+		for _, release := range releases {
+			release(overloaded)
+		}
+	}
+
+	serve(1, 10*time.Millisecond, false)
+	assert.Equal(t, 10, l.Limit(), "an idle limiter does not grow")
+
+	serve(8, 10*time.Millisecond, false)
+	assert.Equal(t, 12, l.Limit(), "fast requests grow a busy limiter up to MaxLimit")
+
+	serve(1, 200*time.Millisecond, false)
+	assert.Equal(t, 10, l.Limit(), "a slow request backs off")
+
+	serve(1, 10*time.Millisecond, true)
+	assert.Equal(t, 9, l.Limit(), "overload backs off")
+
+	for range 20 {
+		serve(1, time.Second, false)
+	}
+	assert.Equal(t, 2, l.Limit(), "the limit stays at or above MinLimit")
+	assert.Equal(t, time.Second, l.RetryAfter())
+}
+
+func TestLimiter_Metrics(t *testing.T) {
+	reg := metrics.NewRegistry()
+	l := New(Options{MinLimit: 1, MaxLimit: 1}, reg)
+	release, err := l.Acquire(context.Background(), Normal)
+	require.NoError(t, err)
+	_, err = l.Acquire(context.Background(), Sheddable)
+	require.ErrorIs(t, err, ErrQueueFull)
+
+	var b strings.Builder
+	require.NoError(t, reg.Write(&b))
+	assert.Contains(t, b.String(), "concurrency_limit 1\n")
+	assert.Contains(t, b.String(), "concurrency_in_flight 1\n")
+	assert.Contains(t, b.String(), "concurrency_queue_length 0\n")
+	assert.Contains(t, b.String(), `concurrency_requests_total{class="normal",outcome="admitted"} 1`)
+	assert.Contains(t, b.String(), `concurrency_requests_total{class="sheddable",outcome="shed"} 1`)
+	release(false)
+}
@@ -0,0 +1,137 @@
+// internal/logging/cloud.go
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+
+	"github.com/duizendstra/dui-go/logging/cloudlogging"
+)
+
+// Cloud Logging fields that correlate an entry with its Cloud Trace span.
+const (
+	traceField        = "logging.googleapis.com/trace"
+	spanIDField       = "logging.googleapis.com/spanId"
+	traceSampledField = "logging.googleapis.com/trace_sampled"
+)
+
+// traceContext is the trace of a request, as stored by WithCloudTraceContext.
+type traceContext struct {
+	trace   string // projects/PROJECT_ID/traces/TRACE_ID
+	spanID  string
+	sampled bool
+}
+
+type traceKey struct{}
+
+// cloudTraceContext matches X-Cloud-Trace-Context: TRACE_ID[/SPAN_ID];o=OPTIONS.
+var cloudTraceContext = regexp.MustCompile(`^([a-f\d]+)(?:/([a-f\d]+))?;o=(\d+)$`)
+
+// WithCloudTraceContext returns middleware that reads the trace of each
+// request from X-Cloud-Trace-Context and stores it in the request context,
+// where the cloud format's handler adds it to log entries written with that
+// context. projectID is the project the traces belong to. Build it once and
+// share it between routes.
+func WithCloudTraceContext(projectID string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			m := cloudTraceContext.FindStringSubmatch(r.Header.Get("X-Cloud-Trace-Context"))
+			if m == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			tc := traceContext{trace: "projects/" + projectID + "/traces/" + m[1], spanID: m[2], sampled: m[3] == "1"}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), traceKey{}, tc)))
+		})
+	}
+}
+
+// cloudHandler writes Cloud Logging structured JSON: message, severity and
+// sourceLocation in the fields Cloud Logging reads, the component, and the
+// trace stored by WithCloudTraceContext.
+type cloudHandler struct {
+	slog.Handler
+}
+
+// NewCloudHandler returns the handler of the cloud format, writing entries
+// at or above level to w.
+func NewCloudHandler(w io.Writer, component string, level slog.Leveler) slog.Handler {
+	json := slog.NewJSONHandler(w, &slog.HandlerOptions{
+		AddSource:   true,
+		Level:       level,
+		ReplaceAttr: cloudAttr,
+	})
+	return &cloudHandler{json.WithAttrs([]slog.Attr{slog.String("component", component)})}
+}
+
+// Handle adds the trace of ctx, if any, to the entry.
+func (h *cloudHandler) Handle(ctx context.Context, rec slog.Record) error {
+	if tc, ok := ctx.Value(traceKey{}).(traceContext); ok {
+		rec = rec.Clone()
+		rec.AddAttrs(slog.String(traceField, tc.trace), slog.Bool(traceSampledField, tc.sampled))
+		if tc.spanID != "" {
+			rec.AddAttrs(slog.String(spanIDField, tc.spanID))
+		}
+	}
+	return h.Handler.Handle(ctx, rec)
+}
+
+func (h *cloudHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &cloudHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h *cloudHandler) WithGroup(name string) slog.Handler {
+	return &cloudHandler{h.Handler.WithGroup(name)}
+}
+
+// cloudAttr renames the built-in attributes to the fields Cloud Logging
+// reads.
+func cloudAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) > 0 {
+		return a
+	}
+	switch a.Key {
+	case slog.MessageKey:
+		a.Key = "message"
+	case slog.LevelKey:
+		a.Key = "severity"
+		a.Value = slog.StringValue(severity(a.Value.Any().(slog.Level)))
+	case slog.SourceKey:
+		source, ok := a.Value.Any().(*slog.Source)
+		if !ok || source == nil {
+			return slog.Attr{}
+		}
+		return slog.Group("logging.googleapis.com/sourceLocation",
+			slog.String("file", source.File),
+			slog.Int("line", source.Line),
+			slog.String("function", source.Function),
+		)
+	}
+	return a
+}
+
+// severity returns the Cloud Logging severity of level.
+func severity(level slog.Level) string {
+	switch level {
+	case slog.LevelDebug:
+		return "DEBUG"
+	case slog.LevelInfo:
+		return "INFO"
+	case cloudlogging.LevelNotice:
+		return "NOTICE"
+	case slog.LevelWarn:
+		return "WARNING"
+	case slog.LevelError:
+		return "ERROR"
+	case cloudlogging.LevelCritical:
+		return "CRITICAL"
+	case cloudlogging.LevelAlert:
+		return "ALERT"
+	case cloudlogging.LevelEmergency:
+		return "EMERGENCY"
+	}
+	return "DEFAULT"
+}
@@ -0,0 +1,55 @@
+// internal/logging/cloud_test.go
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/duizendstra/dui-go/logging/cloudlogging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloudHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewCloudHandler(&buf, "svc", slog.LevelDebug))
+	withTrace := WithCloudTraceContext("my-project")
+
+	entry := func(header string) map[string]any {
+		t.Helper()
+		buf.Reset()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if header != "" {
+			req.Header.Set("X-Cloud-Trace-Context", header)
+		}
+		withTrace(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			logger.Log(r.Context(), cloudlogging.LevelCritical, "hello", "k", "v")
+		})).ServeHTTP(httptest.NewRecorder(), req)
+		var e map[string]any
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &e))
+		return e
+	}
+
+	e := entry("105445aa7843bc8bf206b12000100000/1;o=1")
+	assert.Equal(t, "hello", e["message"])
+	assert.Equal(t, "CRITICAL", e["severity"])
+	assert.Equal(t, "svc", e["component"])
+	assert.Equal(t, "v", e["k"])
+	assert.Equal(t, "projects/my-project/traces/105445aa7843bc8bf206b12000100000", e[traceField])
+	assert.Equal(t, "1", e[spanIDField])
+	assert.Equal(t, true, e[traceSampledField])
+	assert.Contains(t, e["logging.googleapis.com/sourceLocation"], "function")
+
+	e = entry("105445aa7843bc8bf206b12000100000;o=0")
+	assert.Equal(t, false, e[traceSampledField])
+	assert.NotContains(t, e, spanIDField)
+
+	for _, header := range []string{"", "not a trace"} {
+		e = entry(header)
+		assert.NotContains(t, e, traceField, header)
+	}
+}
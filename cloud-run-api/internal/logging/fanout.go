@@ -0,0 +1,59 @@
+// internal/logging/fanout.go
+package logging
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+)
+
+// fanoutHandler dispatches each record to every wrapped handler that is
+// enabled for the record's level.
+type fanoutHandler struct {
+	handlers []slog.Handler
+}
+
+// Fanout returns a handler that sends records to all of handlers.
+// With a single handler it is returned unchanged.
+func Fanout(handlers ...slog.Handler) slog.Handler {
+	if len(handlers) == 1 {
+		return handlers[0]
+	}
+	return &fanoutHandler{handlers: handlers}
+}
+
+func (h *fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, hh := range h.handlers {
+		if hh.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *fanoutHandler) Handle(ctx context.Context, rec slog.Record) error {
+	var errs []error
+	for _, hh := range h.handlers {
+		if hh.Enabled(ctx, rec.Level) {
+			// Clone so handlers that add attributes do not affect each other.
+			errs = append(errs, hh.Handle(ctx, rec.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h *fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make([]slog.Handler, len(h.handlers))
+	for i, hh := range h.handlers {
+		out[i] = hh.WithAttrs(attrs)
+	}
+	return &fanoutHandler{handlers: out}
+}
+
+func (h *fanoutHandler) WithGroup(name string) slog.Handler {
+	out := make([]slog.Handler, len(h.handlers))
+	for i, hh := range h.handlers {
+		out[i] = hh.WithGroup(name)
+	}
+	return &fanoutHandler{handlers: out}
+}
@@ -0,0 +1,34 @@
+// internal/logging/fanout_test.go
+package logging
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFanout(t *testing.T) {
+	var debugBuf, warnBuf bytes.Buffer
+	h := Fanout(
+		slog.NewTextHandler(&debugBuf, &slog.HandlerOptions{Level: slog.LevelDebug}),
+		slog.NewTextHandler(&warnBuf, &slog.HandlerOptions{Level: slog.LevelWarn}),
+	)
+	logger := slog.New(h).With("a", 1).WithGroup("g")
+
+	assert.True(t, h.Enabled(context.Background(), slog.LevelDebug))
+	logger.Debug("quiet", "b", 2)
+	logger.Warn("loud", "b", 3)
+
+	assert.Contains(t, debugBuf.String(), "msg=quiet a=1 g.b=2")
+	assert.Contains(t, debugBuf.String(), "msg=loud a=1 g.b=3")
+	assert.NotContains(t, warnBuf.String(), "quiet")
+	assert.Contains(t, warnBuf.String(), "msg=loud a=1 g.b=3")
+}
+
+func TestFanout_Single(t *testing.T) {
+	inner := slog.NewTextHandler(&bytes.Buffer{}, nil)
+	assert.Same(t, inner, Fanout(inner))
+}
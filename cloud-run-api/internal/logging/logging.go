@@ -0,0 +1,155 @@
+// internal/logging/logging.go
+//
+// Package logging builds the application's slog.Handler from configuration.
+// LOG_FORMAT selects one or more outputs (cloud, json, text, otlp); several
+// outputs are combined with Fanout. The result is wrapped in a redacting
+// handler and decorated with common attributes identifying the service,
+// revision and instance.
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/duizendstra/dui-go/logging/cloudlogging"
+
+	"your-module-name/internal/config"
+	"your-module-name/internal/metadata"
+)
+
+// Supported LOG_FORMAT values.
+const (
+	FormatCloud = "cloud" // Cloud Logging structured JSON with trace correlation.
+	FormatJSON  = "json"  // Plain slog JSON.
+	FormatText  = "text"  // Human-readable slog text.
+	FormatOTLP  = "otlp"  // OpenTelemetry logs over OTLP/HTTP.
+)
+
+// Options tune handler construction beyond what Config provides.
+type Options struct {
+	// Writer receives cloud, json and text output. Defaults to os.Stderr,
+	// where Cloud Run collects it.
+	Writer io.Writer
+	// InstanceID identifies the serving instance. See DetectInstanceID.
+	InstanceID string
+}
+
+// ShutdownFunc flushes buffered log records and releases resources.
+type ShutdownFunc func(context.Context) error
+
+// NewHandler returns the handler selected by cfg.LogFormat. The returned
+// ShutdownFunc must be called before exit to flush exporters such as OTLP.
+func NewHandler(cfg config.Config, opts Options) (slog.Handler, ShutdownFunc, error) {
+	if opts.Writer == nil {
+		opts.Writer = os.Stderr
+	}
+	level := ParseLevel(cfg.LogLevel)
+
+	var (
+		handlers  []slog.Handler
+		shutdowns []ShutdownFunc
+	)
+	for _, format := range Formats(cfg) {
+		switch format {
+		case FormatCloud:
+			handlers = append(handlers, NewCloudHandler(opts.Writer, cfg.ServiceName, level))
+		case FormatJSON:
+			handlers = append(handlers, slog.NewJSONHandler(opts.Writer, &slog.HandlerOptions{Level: level}))
+		case FormatText:
+			handlers = append(handlers, slog.NewTextHandler(opts.Writer, &slog.HandlerOptions{Level: level}))
+		case FormatOTLP:
+			otlp := NewOTLPHandler(OTLPOptions{
+				Endpoint: cfg.OTLPLogsEndpoint,
+				Level:    level,
+				Resource: map[string]string{
+					"service.name":        cfg.ServiceName,
+					"service.version":     cfg.Revision,
+					"service.instance.id": opts.InstanceID,
+				},
+			})
+			handlers = append(handlers, otlp)
+			shutdowns = append(shutdowns, otlp.Shutdown)
+		default:
+			return nil, nil, fmt.Errorf("logging: unknown LOG_FORMAT %q (want cloud, json, text or otlp)", format)
+		}
+	}
+
+	redactOpts, err := ParseRedactOptions(cfg.LogRedactKeys, cfg.LogRedactDetectors, cfg.LogRedactStrategy, cfg.LogRedactHashKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	handler := NewRedactingHandler(Fanout(handlers...), redactOpts).WithAttrs(CommonAttrs(cfg, opts.InstanceID))
+	shutdown := func(ctx context.Context) error {
+		var errs []error
+		for _, fn := range shutdowns {
+			errs = append(errs, fn(ctx))
+		}
+		return errors.Join(errs...)
+	}
+	return handler, shutdown, nil
+}
+
+// Formats returns the log formats selected by cfg. An empty LOG_FORMAT means
+// cloud, or text when running in local mode.
+func Formats(cfg config.Config) []string {
+	var formats []string
+	for _, f := range strings.Split(cfg.LogFormat, ",") {
+		if f = strings.ToLower(strings.TrimSpace(f)); f != "" {
+			formats = append(formats, f)
+		}
+	}
+	if len(formats) > 0 {
+		return formats
+	}
+	if cfg.IsLocal() {
+		return []string{FormatText}
+	}
+	return []string{FormatCloud}
+}
+
+// CommonAttrs returns the attributes added to every log record.
+// Empty values are omitted.
+func CommonAttrs(cfg config.Config, instanceID string) []slog.Attr {
+	var attrs []slog.Attr
+	for _, a := range []slog.Attr{
+		slog.String("service", cfg.ServiceName),
+		slog.String("revision", cfg.Revision),
+		slog.String("instance_id", instanceID),
+	} {
+		if a.Value.String() != "" {
+			attrs = append(attrs, a)
+		}
+	}
+	return attrs
+}
+
+// ParseLevel converts a LOG_LEVEL value to an slog.Level, accepting the Cloud
+// Logging severities understood by cloudlogging.StringToLevel plus "WARN".
+// Unknown values default to INFO.
+func ParseLevel(s string) slog.Level {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if s == "WARN" {
+		s = "WARNING"
+	}
+	return cloudlogging.StringToLevel(s)
+}
+
+// DetectInstanceID returns the metadata server's instance ID in cloud mode and
+// the hostname otherwise (or if the metadata server is unavailable).
+func DetectInstanceID(ctx context.Context, cfg config.Config) string {
+	if !cfg.IsLocal() {
+		ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		defer cancel()
+		if id, err := metadata.NewClient().InstanceID(ctx); err == nil && id != "" {
+			return id
+		}
+	}
+	host, _ := os.Hostname()
+	return host
+}
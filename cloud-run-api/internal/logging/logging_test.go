@@ -0,0 +1,119 @@
+// internal/logging/logging_test.go
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/duizendstra/dui-go/logging/cloudlogging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"your-module-name/internal/config"
+)
+
+func TestFormats(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Config
+		want []string
+	}{
+		{"Default cloud", config.Config{}, []string{FormatCloud}},
+		{"Default local", config.Config{RuntimeMode: config.RuntimeModeLocal}, []string{FormatText}},
+		{"Explicit", config.Config{RuntimeMode: config.RuntimeModeLocal, LogFormat: "json"}, []string{FormatJSON}},
+		{"Multiple", config.Config{LogFormat: " Cloud, otlp ,"}, []string{FormatCloud, FormatOTLP}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Formats(tt.cfg))
+		})
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	assert.Equal(t, slog.LevelDebug, ParseLevel("debug"))
+	assert.Equal(t, slog.LevelWarn, ParseLevel("WARN"))
+	assert.Equal(t, slog.LevelWarn, ParseLevel("WARNING"))
+	assert.Equal(t, cloudlogging.LevelCritical, ParseLevel("CRITICAL"))
+	assert.Equal(t, slog.LevelInfo, ParseLevel("bogus"))
+}
+
+func TestNewHandler_JSONWithCommonAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	cfg := config.Config{ServiceName: "svc", Revision: "svc-00001-abc", LogFormat: "json", LogLevel: "DEBUG"}
+	handler, shutdown, err := NewHandler(cfg, Options{Writer: &buf, InstanceID: "inst-1"})
+	require.NoError(t, err)
+	defer shutdown(context.Background())
+
+	slog.New(handler).Debug("hello", "k", "v")
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "hello", entry["msg"])
+	assert.Equal(t, "v", entry["k"])
+	assert.Equal(t, "svc", entry["service"])
+	assert.Equal(t, "svc-00001-abc", entry["revision"])
+	assert.Equal(t, "inst-1", entry["instance_id"])
+}
+
+func TestNewHandler_TextFanout(t *testing.T) {
+	var buf bytes.Buffer
+	cfg := config.Config{ServiceName: "svc", LogFormat: "text,json"}
+	handler, _, err := NewHandler(cfg, Options{Writer: &buf})
+	require.NoError(t, err)
+
+	slog.New(handler).Info("fanned out")
+	out := buf.String()
+	assert.Contains(t, out, "msg=\"fanned out\" service=svc")
+	assert.Contains(t, out, `"msg":"fanned out"`)
+	assert.NotContains(t, out, "revision", "empty common attributes are omitted")
+}
+
+func TestNewHandler_UnknownFormat(t *testing.T) {
+	_, _, err := NewHandler(config.Config{LogFormat: "xml"}, Options{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "xml")
+}
+
+func TestNewHandler_CloudFormat(t *testing.T) {
+	var buf bytes.Buffer
+	cfg := config.Config{ServiceName: "svc", LogFormat: "cloud", LogRedactDetectors: "email,credit_card,bearer"}
+	handler, _, err := NewHandler(cfg, Options{Writer: &buf})
+	require.NoError(t, err)
+	logger := slog.New(handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Cloud-Trace-Context", "105445aa7843bc8bf206b12000100000/1;o=1")
+	WithCloudTraceContext("my-project")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.InfoContext(r.Context(), "signup by jane@example.com")
+	})).ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "signup by "+Redacted, entry["message"])
+	assert.Equal(t, "INFO", entry["severity"])
+	assert.Equal(t, "svc", entry["service"])
+	assert.Equal(t, "projects/my-project/traces/105445aa7843bc8bf206b12000100000", entry[traceField], "the trace survives redaction")
+	assert.Equal(t, "1", entry[spanIDField])
+	assert.Equal(t, true, entry[traceSampledField])
+}
+
+func TestNewHandler_Redacts(t *testing.T) {
+	var buf bytes.Buffer
+	cfg := config.Config{LogFormat: "json", LogRedactDetectors: "email", LogRedactStrategy: "replace"}
+	handler, _, err := NewHandler(cfg, Options{Writer: &buf})
+	require.NoError(t, err)
+
+	slog.New(handler).Info("signup by jane@example.com", "authorization", "Bearer abc")
+	assert.NotContains(t, buf.String(), "jane@example.com")
+	assert.NotContains(t, buf.String(), "abc")
+	assert.Contains(t, buf.String(), Redacted)
+
+	_, _, err = NewHandler(config.Config{LogFormat: "json", LogRedactStrategy: "shred"}, Options{})
+	assert.Error(t, err)
+}
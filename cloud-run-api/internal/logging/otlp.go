@@ -0,0 +1,333 @@
+// internal/logging/otlp.go
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// OTLPOptions configure an OTLPHandler.
+type OTLPOptions struct {
+	// Endpoint is the full OTLP/HTTP logs URL, e.g. http://localhost:4318/v1/logs.
+	Endpoint string
+	Level    slog.Leveler
+	// Resource attributes describing the service; empty values are omitted.
+	Resource map[string]string
+	// BatchSize triggers an export once this many records are buffered. Default 512.
+	BatchSize int
+	// FlushInterval is the maximum time a record stays buffered. Default 2s.
+	FlushInterval time.Duration
+	HTTPClient    *http.Client
+}
+
+// OTLPHandler exports log records to an OpenTelemetry collector using the
+// OTLP/HTTP JSON encoding. Records are buffered and exported in batches by a
+// background goroutine; call Shutdown to flush before exit. Export failures
+// drop the batch rather than blocking the caller.
+type OTLPHandler struct {
+	exp    *otlpExporter
+	level  slog.Leveler
+	attrs  []slog.Attr
+	groups []string
+}
+
+// NewOTLPHandler starts an exporter and returns a handler that feeds it.
+func NewOTLPHandler(opts OTLPOptions) *OTLPHandler {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 512
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = 2 * time.Second
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	if opts.Level == nil {
+		opts.Level = slog.LevelInfo
+	}
+
+	exp := &otlpExporter{
+		endpoint:  opts.Endpoint,
+		client:    opts.HTTPClient,
+		resource:  resourceAttrs(opts.Resource),
+		batchSize: opts.BatchSize,
+		flushNow:  make(chan struct{}, 1),
+		done:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+	go exp.run(opts.FlushInterval)
+	return &OTLPHandler{exp: exp, level: opts.Level}
+}
+
+func (h *OTLPHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *OTLPHandler) Handle(_ context.Context, rec slog.Record) error {
+	attrs := make([]otlpKeyValue, 0, len(h.attrs)+rec.NumAttrs())
+	for _, a := range h.attrs {
+		attrs = appendAttr(attrs, "", a)
+	}
+	prefix := groupPrefix(h.groups)
+	rec.Attrs(func(a slog.Attr) bool {
+		attrs = appendAttr(attrs, prefix, a)
+		return true
+	})
+
+	h.exp.add(otlpLogRecord{
+		TimeUnixNano:   strconv.FormatInt(rec.Time.UnixNano(), 10),
+		SeverityNumber: severityNumber(rec.Level),
+		SeverityText:   rec.Level.String(),
+		Body:           otlpAnyValue{StringValue: ptr(rec.Message)},
+		Attributes:     attrs,
+	})
+	return nil
+}
+
+func (h *OTLPHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	prefix := groupPrefix(h.groups)
+	out := *h
+	out.attrs = append([]slog.Attr{}, h.attrs...)
+	for _, a := range attrs {
+		a.Key = prefix + a.Key
+		out.attrs = append(out.attrs, a)
+	}
+	return &out
+}
+
+func (h *OTLPHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	out := *h
+	out.groups = append(append([]string{}, h.groups...), name)
+	return &out
+}
+
+// Shutdown flushes buffered records and stops the exporter.
+func (h *OTLPHandler) Shutdown(ctx context.Context) error {
+	return h.exp.shutdown(ctx)
+}
+
+func groupPrefix(groups []string) string {
+	prefix := ""
+	for _, g := range groups {
+		prefix += g + "."
+	}
+	return prefix
+}
+
+// severityNumber maps slog levels, including the Cloud Logging levels, onto
+// the OpenTelemetry severity number ranges.
+func severityNumber(l slog.Level) int {
+	switch {
+	case l < slog.LevelInfo:
+		return 5 // DEBUG
+	case l < slog.LevelWarn:
+		return 9 + int(l-slog.LevelInfo) // INFO, NOTICE = INFO2
+	case l < slog.LevelError:
+		return 13 // WARN
+	case l == slog.LevelError:
+		return 17 // ERROR
+	default:
+		return min(21, 18+int(l-slog.LevelError-1)) // CRITICAL, ALERT, EMERGENCY
+	}
+}
+
+type otlpExporter struct {
+	endpoint  string
+	client    *http.Client
+	resource  []otlpKeyValue
+	batchSize int
+
+	mu       sync.Mutex
+	buf      []otlpLogRecord
+	flushNow chan struct{}
+	done     chan struct{}
+	stopped  chan struct{}
+	once     sync.Once
+}
+
+func (e *otlpExporter) add(rec otlpLogRecord) {
+	e.mu.Lock()
+	e.buf = append(e.buf, rec)
+	full := len(e.buf) >= e.batchSize
+	e.mu.Unlock()
+	if full {
+		select {
+		case e.flushNow <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (e *otlpExporter) run(interval time.Duration) {
+	defer close(e.stopped)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-e.flushNow:
+		case <-e.done:
+			return
+		}
+		_ = e.flush(context.Background())
+	}
+}
+
+func (e *otlpExporter) flush(ctx context.Context) error {
+	e.mu.Lock()
+	batch := e.buf
+	e.buf = nil
+	e.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+
+	payload := otlpExportRequest{ResourceLogs: []otlpResourceLogs{{
+		Resource: otlpResource{Attributes: e.resource},
+		ScopeLogs: []otlpScopeLogs{{
+			Scope:      otlpScope{Name: "log/slog"},
+			LogRecords: batch,
+		}},
+	}}}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("logging: failed to encode OTLP logs: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("logging: failed to build OTLP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("logging: OTLP export failed: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("logging: OTLP export returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (e *otlpExporter) shutdown(ctx context.Context) error {
+	e.once.Do(func() { close(e.done) })
+	select {
+	case <-e.stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return e.flush(ctx)
+}
+
+// OTLP/HTTP JSON payload, see opentelemetry-proto logs/v1 and common/v1.
+
+type otlpExportRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpScope       `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano   string         `json:"timeUnixNano"`
+	SeverityNumber int            `json:"severityNumber"`
+	SeverityText   string         `json:"severityText"`
+	Body           otlpAnyValue   `json:"body"`
+	Attributes     []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string        `json:"stringValue,omitempty"`
+	BoolValue   *bool          `json:"boolValue,omitempty"`
+	IntValue    *string        `json:"intValue,omitempty"` // int64 is encoded as a string in OTLP JSON
+	DoubleValue *float64       `json:"doubleValue,omitempty"`
+	KvlistValue *otlpKeyValues `json:"kvlistValue,omitempty"`
+}
+
+type otlpKeyValues struct {
+	Values []otlpKeyValue `json:"values"`
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func resourceAttrs(resource map[string]string) []otlpKeyValue {
+	keys := make([]string, 0, len(resource))
+	for k, v := range resource {
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	out := make([]otlpKeyValue, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, otlpKeyValue{Key: k, Value: otlpAnyValue{StringValue: ptr(resource[k])}})
+	}
+	return out
+}
+
+func appendAttr(kvs []otlpKeyValue, prefix string, a slog.Attr) []otlpKeyValue {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return kvs
+	}
+	return append(kvs, otlpKeyValue{Key: prefix + a.Key, Value: anyValue(a.Value)})
+}
+
+func anyValue(v slog.Value) otlpAnyValue {
+	switch v.Kind() {
+	case slog.KindString:
+		return otlpAnyValue{StringValue: ptr(v.String())}
+	case slog.KindBool:
+		return otlpAnyValue{BoolValue: ptr(v.Bool())}
+	case slog.KindInt64:
+		return otlpAnyValue{IntValue: ptr(strconv.FormatInt(v.Int64(), 10))}
+	case slog.KindUint64:
+		return otlpAnyValue{IntValue: ptr(strconv.FormatUint(v.Uint64(), 10))}
+	case slog.KindFloat64:
+		return otlpAnyValue{DoubleValue: ptr(v.Float64())}
+	case slog.KindDuration:
+		return otlpAnyValue{IntValue: ptr(strconv.FormatInt(int64(v.Duration()), 10))}
+	case slog.KindTime:
+		return otlpAnyValue{StringValue: ptr(v.Time().Format(time.RFC3339Nano))}
+	case slog.KindGroup:
+		var kvs []otlpKeyValue
+		for _, a := range v.Group() {
+			kvs = appendAttr(kvs, "", a)
+		}
+		return otlpAnyValue{KvlistValue: &otlpKeyValues{Values: kvs}}
+	default:
+		return otlpAnyValue{StringValue: ptr(fmt.Sprint(v.Any()))}
+	}
+}
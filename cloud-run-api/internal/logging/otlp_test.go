@@ -0,0 +1,82 @@
+// internal/logging/otlp_test.go
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOTLPHandler(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []otlpExportRequest
+	)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/logs", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var req otlpExportRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		mu.Lock()
+		requests = append(requests, req)
+		mu.Unlock()
+	}))
+	defer collector.Close()
+
+	h := NewOTLPHandler(OTLPOptions{
+		Endpoint:      collector.URL + "/v1/logs",
+		Level:         slog.LevelInfo,
+		Resource:      map[string]string{"service.name": "svc", "service.version": ""},
+		FlushInterval: time.Hour, // Only Shutdown flushes in this test.
+	})
+	logger := slog.New(h).With("request_id", "r1").WithGroup("http")
+	logger.Debug("dropped")
+	logger.Info("served", "status", 200, "ok", true)
+	logger.Error("failed", slog.Group("err", "code", "E1"))
+
+	require.NoError(t, h.Shutdown(context.Background()))
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, requests, 1)
+	rl := requests[0].ResourceLogs[0]
+	require.Len(t, rl.Resource.Attributes, 1, "empty resource attributes are omitted")
+	assert.Equal(t, "service.name", rl.Resource.Attributes[0].Key)
+
+	records := rl.ScopeLogs[0].LogRecords
+	require.Len(t, records, 2)
+	assert.Equal(t, "served", *records[0].Body.StringValue)
+	assert.Equal(t, 9, records[0].SeverityNumber)
+	assert.Equal(t, 17, records[1].SeverityNumber)
+
+	attrs := map[string]otlpAnyValue{}
+	for _, kv := range records[0].Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	assert.Equal(t, "r1", *attrs["request_id"].StringValue)
+	assert.Equal(t, "200", *attrs["http.status"].IntValue)
+	assert.True(t, *attrs["http.ok"].BoolValue)
+
+	errGroup := records[1].Attributes[1]
+	assert.Equal(t, "http.err", errGroup.Key)
+	require.NotNil(t, errGroup.Value.KvlistValue)
+	assert.Equal(t, "code", errGroup.Value.KvlistValue.Values[0].Key)
+}
+
+func TestSeverityNumber(t *testing.T) {
+	assert.Equal(t, 5, severityNumber(slog.LevelDebug))
+	assert.Equal(t, 9, severityNumber(slog.LevelInfo))
+	assert.Equal(t, 10, severityNumber(slog.LevelInfo+1))
+	assert.Equal(t, 13, severityNumber(slog.LevelWarn))
+	assert.Equal(t, 17, severityNumber(slog.LevelError))
+	assert.Equal(t, 18, severityNumber(slog.LevelError+1))
+	assert.Equal(t, 21, severityNumber(slog.LevelError+10))
+}
@@ -0,0 +1,354 @@
+// internal/logging/redact.go
+package logging
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// MaskStrategy selects how a sensitive value is masked.
+type MaskStrategy string
+
+// Supported mask strategies.
+const (
+	MaskReplace MaskStrategy = "replace" // Replace the value with "[REDACTED]".
+	MaskRemove  MaskStrategy = "remove"  // Drop the attribute (or matched text) entirely.
+	MaskHash    MaskStrategy = "hash"    // Replace with a short, stable hash so values can still be correlated.
+	MaskPartial MaskStrategy = "partial" // Keep the last four characters, mask the rest.
+)
+
+// Redacted is the replacement text used by MaskReplace.
+const Redacted = "[REDACTED]"
+
+// DefaultRedactKeys are attribute key patterns that are always redacted.
+// Patterns use path.Match syntax and are matched case-insensitively.
+var DefaultRedactKeys = []string{
+	"authorization", "proxy-authorization", "cookie", "set-cookie",
+	"x-api-key", "api_key", "apikey",
+	"*password*", "*secret*", "*token*",
+	"text_to_echo",
+}
+
+// Detector finds sensitive substrings in string values regardless of key.
+type Detector struct {
+	Name    string
+	Pattern *regexp.Regexp
+	// Validate, if set, must accept a match for it to be redacted.
+	Validate func(match string) bool
+}
+
+// Built-in value detectors.
+var (
+	DetectEmail = Detector{
+		Name:    "email",
+		Pattern: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+	}
+	DetectCreditCard = Detector{
+		Name:     "credit_card",
+		Pattern:  regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`),
+		Validate: luhnValid,
+	}
+	DetectBearerToken = Detector{
+		Name:    "bearer",
+		Pattern: regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9\-._~+/]+=*`),
+	}
+)
+
+// Detectors maps detector names, as used in LOG_REDACT_DETECTORS, to detectors.
+var Detectors = map[string]Detector{
+	DetectEmail.Name:       DetectEmail,
+	DetectCreditCard.Name:  DetectCreditCard,
+	DetectBearerToken.Name: DetectBearerToken,
+}
+
+// RedactOptions configure a redacting handler.
+type RedactOptions struct {
+	// Keys are attribute key patterns (path.Match syntax, case-insensitive)
+	// whose values are masked.
+	Keys []string
+	// Detectors find sensitive substrings in any string value or message.
+	Detectors []Detector
+	// Strategy is applied to matched keys, detected values and struct fields
+	// tagged `redact:""`. Defaults to MaskReplace.
+	Strategy MaskStrategy
+	// HashKey, if set, keys the MaskHash digest (HMAC-SHA256) so hashes of
+	// low-entropy values cannot be reversed by brute force.
+	HashKey []byte
+}
+
+// redactingHandler masks sensitive data before passing records to the next handler.
+type redactingHandler struct {
+	next slog.Handler
+	r    *redactor
+}
+
+// NewRedactingHandler wraps next so that attributes are redacted by key
+// pattern, by `redact` struct tags on logged values, and by value detectors.
+//
+// A struct field tagged `redact:"<strategy>"` (or `redact:""` for the default
+// strategy) is masked whenever the struct is logged as an attribute value;
+// such structs are logged as groups keyed by their JSON field names.
+func NewRedactingHandler(next slog.Handler, opts RedactOptions) slog.Handler {
+	if opts.Strategy == "" {
+		opts.Strategy = MaskReplace
+	}
+	keys := make([]string, len(opts.Keys))
+	for i, k := range opts.Keys {
+		keys[i] = strings.ToLower(k)
+	}
+	return &redactingHandler{next: next, r: &redactor{
+		keys:      keys,
+		detectors: opts.Detectors,
+		strategy:  opts.Strategy,
+		hashKey:   opts.HashKey,
+	}}
+}
+
+func (h *redactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *redactingHandler) Handle(ctx context.Context, rec slog.Record) error {
+	out := slog.NewRecord(rec.Time, rec.Level, h.r.scrub(rec.Message), rec.PC)
+	rec.Attrs(func(a slog.Attr) bool {
+		if a, ok := h.r.attr(a); ok {
+			out.AddAttrs(a)
+		}
+		return true
+	})
+	return h.next.Handle(ctx, out)
+}
+
+func (h *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &redactingHandler{next: h.next.WithAttrs(h.r.attrs(attrs)), r: h.r}
+}
+
+func (h *redactingHandler) WithGroup(name string) slog.Handler {
+	return &redactingHandler{next: h.next.WithGroup(name), r: h.r}
+}
+
+type redactor struct {
+	keys      []string
+	detectors []Detector
+	strategy  MaskStrategy
+	hashKey   []byte
+}
+
+func (r *redactor) attrs(in []slog.Attr) []slog.Attr {
+	out := make([]slog.Attr, 0, len(in))
+	for _, a := range in {
+		if a, ok := r.attr(a); ok {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// attr redacts a single attribute. It returns false if the attribute must be dropped.
+func (r *redactor) attr(a slog.Attr) (slog.Attr, bool) {
+	a.Value = a.Value.Resolve()
+	if r.matchKey(a.Key) {
+		return r.mask(a, r.strategy)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(r.scrub(a.Value.String()))
+	case slog.KindGroup:
+		a.Value = slog.GroupValue(r.attrs(a.Value.Group())...)
+	case slog.KindAny:
+		v := a.Value.Any()
+		if err, ok := v.(error); ok {
+			a.Value = slog.StringValue(r.scrub(err.Error()))
+		} else if group, ok := r.structGroup(v); ok {
+			a.Value = group
+		}
+	}
+	return a, true
+}
+
+func (r *redactor) matchKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, pattern := range r.keys {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *redactor) mask(a slog.Attr, strategy MaskStrategy) (slog.Attr, bool) {
+	if strategy == MaskRemove {
+		return a, false
+	}
+	a.Value = slog.StringValue(r.maskString(a.Value.String(), strategy))
+	return a, true
+}
+
+func (r *redactor) maskString(s string, strategy MaskStrategy) string {
+	switch strategy {
+	case MaskRemove:
+		return ""
+	case MaskHash:
+		var sum []byte
+		if len(r.hashKey) > 0 {
+			mac := hmac.New(sha256.New, r.hashKey)
+			mac.Write([]byte(s))
+			sum = mac.Sum(nil)
+		} else {
+			digest := sha256.Sum256([]byte(s))
+			sum = digest[:]
+		}
+		return "sha256:" + hex.EncodeToString(sum[:6])
+	case MaskPartial:
+		runes := []rune(s)
+		if len(runes) <= 4 {
+			return strings.Repeat("*", len(runes))
+		}
+		return strings.Repeat("*", len(runes)-4) + string(runes[len(runes)-4:])
+	default:
+		return Redacted
+	}
+}
+
+// scrub masks every detector match within s.
+func (r *redactor) scrub(s string) string {
+	for _, d := range r.detectors {
+		s = d.Pattern.ReplaceAllStringFunc(s, func(match string) string {
+			if d.Validate != nil && !d.Validate(match) {
+				return match
+			}
+			return r.maskString(match, r.strategy)
+		})
+	}
+	return s
+}
+
+// structGroup converts a struct (or pointer to struct) with `redact` tags into
+// a group value with tagged fields masked. Structs without tags are left as is.
+func (r *redactor) structGroup(v any) (slog.Value, bool) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return slog.Value{}, false
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct || !hasRedactTags(rv.Type()) {
+		return slog.Value{}, false
+	}
+
+	typ := rv.Type()
+	attrs := make([]slog.Attr, 0, typ.NumField())
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		a := slog.Any(name, rv.Field(i).Interface())
+		if strategy, tagged := field.Tag.Lookup("redact"); tagged {
+			if strategy == "" {
+				strategy = string(r.strategy)
+			}
+			if a, ok := r.mask(a, MaskStrategy(strategy)); ok {
+				attrs = append(attrs, a)
+			}
+			continue
+		}
+		if a, ok := r.attr(a); ok {
+			attrs = append(attrs, a)
+		}
+	}
+	return slog.GroupValue(attrs...), true
+}
+
+var redactTagCache sync.Map // reflect.Type -> bool
+
+func hasRedactTags(typ reflect.Type) bool {
+	if cached, ok := redactTagCache.Load(typ); ok {
+		return cached.(bool)
+	}
+	found := false
+	for i := 0; i < typ.NumField(); i++ {
+		if _, ok := typ.Field(i).Tag.Lookup("redact"); ok {
+			found = true
+			break
+		}
+	}
+	redactTagCache.Store(typ, found)
+	return found
+}
+
+// luhnValid reports whether the digits in s pass the Luhn checksum used by
+// payment card numbers.
+func luhnValid(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c == ' ' || c == '-' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
+
+// ParseRedactOptions builds RedactOptions from comma-separated configuration
+// values. Extra key patterns are added to DefaultRedactKeys.
+func ParseRedactOptions(extraKeys, detectors, strategy, hashKey string) (RedactOptions, error) {
+	opts := RedactOptions{
+		Keys:     append([]string{}, DefaultRedactKeys...),
+		Strategy: MaskStrategy(strings.ToLower(strings.TrimSpace(strategy))),
+		HashKey:  []byte(hashKey),
+	}
+	switch opts.Strategy {
+	case "", MaskReplace, MaskRemove, MaskHash, MaskPartial:
+	default:
+		return RedactOptions{}, fmt.Errorf("logging: unknown redaction strategy %q", strategy)
+	}
+
+	for _, k := range strings.Split(extraKeys, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			if _, err := path.Match(k, ""); err != nil {
+				return RedactOptions{}, fmt.Errorf("logging: invalid redaction key pattern %q: %w", k, err)
+			}
+			opts.Keys = append(opts.Keys, k)
+		}
+	}
+	for _, name := range strings.Split(detectors, ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name == "" {
+			continue
+		}
+		d, ok := Detectors[name]
+		if !ok {
+			return RedactOptions{}, fmt.Errorf("logging: unknown redaction detector %q", name)
+		}
+		opts.Detectors = append(opts.Detectors, d)
+	}
+	return opts, nil
+}
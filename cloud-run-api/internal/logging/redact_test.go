@@ -0,0 +1,167 @@
+// internal/logging/redact_test.go
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"your-module-name/internal/models"
+)
+
+// logOne logs a single record through a redacting JSON handler and returns
+// the decoded entry.
+func logOne(t *testing.T, opts RedactOptions, msg string, args ...any) map[string]any {
+	t.Helper()
+	var buf bytes.Buffer
+	logger := slog.New(NewRedactingHandler(slog.NewJSONHandler(&buf, nil), opts))
+	logger.Info(msg, args...)
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	return entry
+}
+
+func TestRedactByKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy MaskStrategy
+		key      string
+		value    string
+		want     any // nil means the attribute is removed
+	}{
+		{"Replace exact key", MaskReplace, "authorization", "Basic dXNlcjpwYXNz", Redacted},
+		{"Case-insensitive key", MaskReplace, "Authorization", "Basic dXNlcjpwYXNz", Redacted},
+		{"Glob key", MaskReplace, "refresh_token", "r-123", Redacted},
+		{"Remove", MaskRemove, "x-api-key", "k-123", nil},
+		{"Partial", MaskPartial, "text_to_echo", "my secret message", "*************sage"},
+		{"Partial short value", MaskPartial, "password", "abc", "***"},
+		{"Hash", MaskHash, "cookie", "session=1", "sha256:"}, // Checked by pattern below
+		{"Unmatched key untouched", MaskReplace, "path", "/echo", "/echo"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := logOne(t, RedactOptions{Keys: DefaultRedactKeys, Strategy: tt.strategy}, "msg", tt.key, tt.value)
+			got, present := entry[tt.key]
+			if tt.want == nil {
+				assert.False(t, present, "attribute should be removed")
+				return
+			}
+			if tt.strategy == MaskHash {
+				assert.Regexp(t, `^sha256:[0-9a-f]{12}$`, got)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRedactHashIsStableAndKeyed(t *testing.T) {
+	plain := RedactOptions{Keys: []string{"id"}, Strategy: MaskHash}
+	keyed := RedactOptions{Keys: []string{"id"}, Strategy: MaskHash, HashKey: []byte("k")}
+
+	a := logOne(t, plain, "m", "id", "user-1")["id"]
+	b := logOne(t, plain, "m", "id", "user-1")["id"]
+	c := logOne(t, keyed, "m", "id", "user-1")["id"]
+	assert.Equal(t, a, b)
+	assert.NotEqual(t, a, c)
+}
+
+func TestRedactDetectors(t *testing.T) {
+	all := []Detector{DetectEmail, DetectCreditCard, DetectBearerToken}
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"Email", "contact jane.doe@example.com today", "contact [REDACTED] today"},
+		{"Valid card", "card 4111 1111 1111 1111 used", "card [REDACTED] used"},
+		{"Card with dashes", "4111-1111-1111-1111", "[REDACTED]"},
+		{"Luhn failure is kept", "order 1234 5678 9012 3456", "order 1234 5678 9012 3456"},
+		{"Bearer token", "header was Bearer eyJhbGciOi.J9.x-y_z", "header was [REDACTED]"},
+		{"Clean text", "nothing to see", "nothing to see"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := logOne(t, RedactOptions{Detectors: all}, tt.input, "detail", tt.input)
+			assert.Equal(t, tt.want, entry["msg"])
+			assert.Equal(t, tt.want, entry["detail"])
+		})
+	}
+}
+
+func TestRedactStructTags(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		want  map[string]any
+	}{
+		{
+			name:  "Partial tag",
+			value: models.EchoRequest{TextToEcho: "hello world"},
+			want:  map[string]any{"text_to_echo": "*******orld"},
+		},
+		{
+			name:  "Pointer and default strategy",
+			value: &models.EchoResponse{ReceivedText: "abcdef", Reply: "echo abcdef", Timestamp: "t"},
+			want:  map[string]any{"message_id": "", "received_text": "**cdef", "reply": Redacted, "timestamp": "t"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// No key patterns, so only the struct tags apply.
+			entry := logOne(t, RedactOptions{}, "msg", "payload", tt.value)
+			assert.Equal(t, tt.want, entry["payload"])
+		})
+	}
+
+	t.Run("Untagged structs are unchanged", func(t *testing.T) {
+		type plain struct{ Name string }
+		entry := logOne(t, RedactOptions{}, "msg", "p", plain{Name: "x"})
+		assert.Equal(t, map[string]any{"Name": "x"}, entry["p"])
+	})
+}
+
+func TestRedactGroupsErrorsAndWithAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	opts := RedactOptions{Keys: []string{"authorization"}, Detectors: []Detector{DetectEmail}}
+	logger := slog.New(NewRedactingHandler(slog.NewJSONHandler(&buf, nil), opts)).
+		With("authorization", "Bearer x").
+		WithGroup("req")
+	logger.Info("m",
+		slog.Group("headers", "authorization", "secret", "accept", "application/json"),
+		"error", errors.New("user bob@example.com not found"),
+	)
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, Redacted, entry["authorization"])
+	req := entry["req"].(map[string]any)
+	assert.Equal(t, map[string]any{"authorization": Redacted, "accept": "application/json"}, req["headers"])
+	assert.Equal(t, "user [REDACTED] not found", req["error"])
+}
+
+func TestParseRedactOptions(t *testing.T) {
+	opts, err := ParseRedactOptions("*email*, x-user-*", "email,bearer", "HASH", "k")
+	require.NoError(t, err)
+	assert.Contains(t, opts.Keys, "*email*")
+	assert.Contains(t, opts.Keys, "authorization")
+	assert.Len(t, opts.Detectors, 2)
+	assert.Equal(t, MaskHash, opts.Strategy)
+	assert.Equal(t, []byte("k"), opts.HashKey)
+
+	_, err = ParseRedactOptions("", "phone", "", "")
+	assert.Error(t, err)
+	_, err = ParseRedactOptions("", "", "shred", "")
+	assert.Error(t, err)
+	_, err = ParseRedactOptions("[", "", "", "")
+	assert.Error(t, err)
+}
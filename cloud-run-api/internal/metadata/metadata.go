@@ -0,0 +1,83 @@
+// internal/metadata/metadata.go
+//
+// Package metadata is a minimal client for the Google Cloud metadata server
+// available on Cloud Run, GKE and Compute Engine. The server address can be
+// overridden with GCE_METADATA_HOST, which is how tests point it at a fake.
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultHost is the metadata server address used when GCE_METADATA_HOST is unset.
+// The IP is used rather than metadata.google.internal to avoid a DNS lookup.
+const DefaultHost = "169.254.169.254"
+
+// ErrNotFound is returned when the metadata server has no value for a path.
+var ErrNotFound = errors.New("metadata: value not found")
+
+// Client queries the metadata server.
+type Client struct {
+	Host       string
+	HTTPClient *http.Client
+}
+
+// NewClient returns a client for the metadata server named by GCE_METADATA_HOST,
+// or DefaultHost if it is unset.
+func NewClient() *Client {
+	host := os.Getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = DefaultHost
+	}
+	return &Client{
+		Host:       host,
+		HTTPClient: &http.Client{Timeout: 2 * time.Second},
+	}
+}
+
+// Get returns the value at path, relative to /computeMetadata/v1/.
+func (c *Client) Get(ctx context.Context, path string) (string, error) {
+	url := fmt.Sprintf("http://%s/computeMetadata/v1/%s", c.Host, strings.TrimPrefix(path, "/"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("metadata: failed to build request: %w", err)
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("metadata: request for %s failed: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return "", fmt.Errorf("%w: %s", ErrNotFound, path)
+	default:
+		return "", fmt.Errorf("metadata: unexpected status %d for %s", resp.StatusCode, path)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return "", fmt.Errorf("metadata: failed to read %s: %w", path, err)
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// ProjectID returns the ID of the project the workload runs in.
+func (c *Client) ProjectID(ctx context.Context) (string, error) {
+	return c.Get(ctx, "project/project-id")
+}
+
+// InstanceID returns the ID of the instance serving the request.
+func (c *Client) InstanceID(ctx context.Context) (string, error) {
+	return c.Get(ctx, "instance/id")
+}
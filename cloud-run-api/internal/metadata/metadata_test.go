@@ -0,0 +1,39 @@
+// internal/metadata/metadata_test.go
+package metadata
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"your-module-name/internal/metadata/metadatatest"
+)
+
+func TestClient(t *testing.T) {
+	srv := metadatatest.NewServer(t, map[string]string{
+		"project/project-id": "fake-project\n",
+		"instance/id":        "instance-123",
+	})
+	t.Setenv("GCE_METADATA_HOST", srv.Host())
+	c := NewClient()
+	ctx := context.Background()
+
+	project, err := c.ProjectID(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "fake-project", project)
+
+	instance, err := c.InstanceID(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "instance-123", instance)
+
+	_, err = c.Get(ctx, "instance/zone")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestClient_Unreachable(t *testing.T) {
+	t.Setenv("GCE_METADATA_HOST", "127.0.0.1:1")
+	_, err := NewClient().ProjectID(context.Background())
+	assert.Error(t, err)
+}
@@ -0,0 +1,46 @@
+// internal/metadata/metadatatest/metadatatest.go
+//
+// Package metadatatest provides an in-process fake of the Google Cloud
+// metadata server for tests.
+package metadatatest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Server is a fake metadata server serving fixed values.
+type Server struct {
+	*httptest.Server
+}
+
+// NewServer starts a fake metadata server serving values keyed by path
+// relative to /computeMetadata/v1/, e.g. "project/project-id". Like the real
+// server, it rejects requests without the Metadata-Flavor: Google header.
+// The server is closed when the test finishes.
+func NewServer(t testing.TB, values map[string]string) *Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			http.Error(w, "missing Metadata-Flavor header", http.StatusForbidden)
+			return
+		}
+		value, ok := values[strings.TrimPrefix(r.URL.Path, "/computeMetadata/v1/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Metadata-Flavor", "Google")
+		w.Header().Set("Content-Type", "application/text")
+		_, _ = w.Write([]byte(value))
+	}))
+	t.Cleanup(srv.Close)
+	return &Server{Server: srv}
+}
+
+// Host returns the host:port of the server, suitable for GCE_METADATA_HOST.
+func (s *Server) Host() string {
+	return strings.TrimPrefix(s.URL, "http://")
+}
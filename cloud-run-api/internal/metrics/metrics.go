@@ -0,0 +1,278 @@
+// internal/metrics/metrics.go
+//
+// Package metrics keeps counters, gauges and histograms in a Registry and
+// serves them in the Prometheus text exposition format, which Google Cloud
+// Managed Service for Prometheus and most collectors scrape.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// metric is a metric family in a Registry.
+type metric interface {
+	// write writes the family's samples, without its HELP and TYPE lines.
+	write(w io.Writer, name string)
+}
+
+// family is a registered metric with its metadata.
+type family struct {
+	name, help, kind string
+	metric           metric
+}
+
+// Registry holds metrics. It is safe for concurrent use.
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]family
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]family)}
+}
+
+// register adds a family, panicking if the name is taken: metric names are
+// fixed by the code, so a clash is a programming error.
+func (r *Registry) register(name, help, kind string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, dup := r.families[name]; dup {
+		panic(fmt.Sprintf("metrics: %s registered twice", name))
+	}
+	r.families[name] = family{name: name, help: help, kind: kind, metric: m}
+}
+
+// Counter registers a counter partitioned by the named labels.
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	c := &Counter{labels: labels, values: make(map[string]float64)}
+	r.register(name, help, "counter", c)
+	return c
+}
+
+// GaugeFunc registers a gauge whose value is read from f when the registry
+// is written.
+func (r *Registry) GaugeFunc(name, help string, f func() float64) {
+	r.register(name, help, "gauge", gaugeFunc(f))
+}
+
+// Histogram registers a histogram with the given upper bucket bounds, in
+// increasing order, partitioned by the named labels.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{labels: labels, buckets: buckets, series: make(map[string]*histogramSeries)}
+	r.register(name, help, "histogram", h)
+	return h
+}
+
+// Write writes every metric in the Prometheus text format, sorted by name.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	families := make([]family, 0, len(r.families))
+	for _, f := range r.families {
+		families = append(families, f)
+	}
+	r.mu.Unlock()
+	sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+
+	ew := &errWriter{w: w}
+	for _, f := range families {
+		fmt.Fprintf(ew, "# HELP %s %s\n# TYPE %s %s\n", f.name, escapeHelp(f.help), f.name, f.kind)
+		f.metric.write(ew, f.name)
+	}
+	return ew.err
+}
+
+// Handler serves the registry in the Prometheus text format.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = r.Write(w)
+	})
+}
+
+// Counter is a monotonically increasing value per label combination.
+type Counter struct {
+	labels []string
+	mu     sync.Mutex
+	values map[string]float64 // Keyed by encoded label values.
+}
+
+// Inc adds one to the counter for the label values, given in the order the
+// labels were registered.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v, which must not be negative, to the counter for the label
+// values.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	key := labelKey(c.labels, labelValues)
+	c.mu.Lock()
+	c.values[key] += v
+	c.mu.Unlock()
+}
+
+// Value returns the counter for the label values.
+func (c *Counter) Value(labelValues ...string) float64 {
+	key := labelKey(c.labels, labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[key]
+}
+
+func (c *Counter) write(w io.Writer, name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", name, key, formatFloat(c.values[key]))
+	}
+}
+
+type gaugeFunc func() float64
+
+func (f gaugeFunc) write(w io.Writer, name string) {
+	fmt.Fprintf(w, "%s %s\n", name, formatFloat(f()))
+}
+
+// Histogram counts observations in buckets per label combination.
+type Histogram struct {
+	labels  []string
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogramSeries // Keyed by encoded label values.
+}
+
+type histogramSeries struct {
+	counts []uint64 // Per bucket, not cumulative.
+	count  uint64
+	sum    float64
+}
+
+// Observe records v for the label values.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	key := labelKey(h.labels, labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += v
+}
+
+// Count returns the number of observations for the label values.
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	key := labelKey(h.labels, labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.series[key]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *Histogram) write(w io.Writer, name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", name, withLabel(key, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", name, withLabel(key, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", name, key, formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", name, key, s.count)
+	}
+}
+
+// labelKey encodes label values as a Prometheus label set, e.g.
+// `{class="normal",outcome="shed"}`, or "" without labels. It panics if the
+// number of values does not match the labels.
+func labelKey(labels, values []string) string {
+	if len(values) != len(labels) {
+		panic(fmt.Sprintf("metrics: got %d label values for labels %v", len(values), labels))
+	}
+	if len(labels) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, label := range labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(label)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(values[i]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// withLabel adds a label to an encoded label set.
+func withLabel(key, label, value string) string {
+	pair := label + `="` + value + `"`
+	if key == "" {
+		return "{" + pair + "}"
+	}
+	return strings.TrimSuffix(key, "}") + "," + pair + "}"
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+
+// formatFloat formats v as the exposition format expects.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// errWriter remembers the first write error, so the formatting code can
+// ignore errors.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) Write(p []byte) (int, error) {
+	if ew.err != nil {
+		return 0, ew.err
+	}
+	n, err := ew.w.Write(p)
+	ew.err = err
+	return n, err
+}
@@ -0,0 +1,76 @@
+// internal/metrics/metrics_test.go
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_Write(t *testing.T) {
+	r := NewRegistry()
+	requests := r.Counter("test_requests_total", "Requests by outcome.", "class", "outcome")
+	requests.Inc("normal", "admitted")
+	requests.Inc("normal", "admitted")
+	requests.Add(3, "low", `sh"ed`)
+	r.GaugeFunc("test_limit", "Current limit.\nSecond line.", func() float64 { return 12.5 })
+	wait := r.Histogram("test_wait_seconds", "Wait time.", []float64{0.1, 1})
+	wait.Observe(0.05)
+	wait.Observe(0.5)
+	wait.Observe(2)
+
+	var b strings.Builder
+	require.NoError(t, r.Write(&b))
+	assert.Equal(t, `# HELP test_limit Current limit.\nSecond line.
+# TYPE test_limit gauge
+test_limit 12.5
+# HELP test_requests_total Requests by outcome.
+# TYPE test_requests_total counter
+test_requests_total{class="low",outcome="sh\"ed"} 3
+test_requests_total{class="normal",outcome="admitted"} 2
+# HELP test_wait_seconds Wait time.
+# TYPE test_wait_seconds histogram
+test_wait_seconds_bucket{le="0.1"} 1
+test_wait_seconds_bucket{le="1"} 2
+test_wait_seconds_bucket{le="+Inf"} 3
+test_wait_seconds_sum 2.55
+test_wait_seconds_count 3
+`, b.String())
+
+	assert.Equal(t, 2.0, requests.Value("normal", "admitted"))
+	assert.Zero(t, requests.Value("normal", "shed"))
+	assert.Equal(t, uint64(3), wait.Count())
+}
+
+func TestHistogram_Labels(t *testing.T) {
+	r := NewRegistry()
+	h := r.Histogram("test_latency_seconds", "Latency.", []float64{1}, "route")
+	h.Observe(0.5, "echo")
+
+	var b strings.Builder
+	require.NoError(t, r.Write(&b))
+	assert.Contains(t, b.String(), `test_latency_seconds_bucket{route="echo",le="1"} 1`)
+	assert.Contains(t, b.String(), `test_latency_seconds_count{route="echo"} 1`)
+}
+
+func TestRegistry_Misuse(t *testing.T) {
+	r := NewRegistry()
+	c := r.Counter("test_total", "Test.", "outcome")
+	assert.Panics(t, func() { r.Counter("test_total", "Again.") }, "duplicate name")
+	assert.Panics(t, func() { c.Inc() }, "missing label value")
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("test_total", "Test.").Inc()
+
+	rr := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Body.String(), "test_total 1\n")
+}
@@ -1,20 +1,91 @@
 // internal/models/models.go
+//
+// Request and response bodies of the API. Every type is tagged for each
+// codec in internal/codec (JSON, XML, YAML, MessagePack and CBOR) with the
+// same field names; XMLName fields name the XML root element and are omitted
+// from the other formats.
 package models
 
+import "encoding/xml"
+
 // HelloWorldResponse defines the structure for a hello world JSON response.
 type HelloWorldResponse struct {
-	Message   string `json:"message"`
-	Timestamp string `json:"timestamp,omitempty"`
+	XMLName   xml.Name `json:"-" xml:"hello" yaml:"-" msgpack:"-" cbor:"-"`
+	Message   string   `json:"message" xml:"message" yaml:"message" msgpack:"message" cbor:"message"`
+	Timestamp string   `json:"timestamp,omitempty" xml:"timestamp,omitempty" yaml:"timestamp,omitempty" msgpack:"timestamp,omitempty" cbor:"timestamp,omitempty"`
 }
 
 // EchoRequest defines a simple structure for a POST request to be echoed.
+// User-supplied text is tagged `redact` so it is masked when the struct is logged.
 type EchoRequest struct {
-	TextToEcho string `json:"text_to_echo"`
+	XMLName    xml.Name `json:"-" xml:"echo_request" yaml:"-" msgpack:"-" cbor:"-"`
+	TextToEcho string   `json:"text_to_echo" xml:"text_to_echo" yaml:"text_to_echo" msgpack:"text_to_echo" cbor:"text_to_echo" redact:"partial"`
 }
 
 // EchoResponse defines the structure for the echo response.
+// MessageID identifies the stored copy of the echoed message.
 type EchoResponse struct {
-	ReceivedText string `json:"received_text"`
-	Reply        string `json:"reply"`
-	Timestamp    string `json:"timestamp,omitempty"`
+	XMLName      xml.Name `json:"-" xml:"echo_response" yaml:"-" msgpack:"-" cbor:"-"`
+	MessageID    string   `json:"message_id" xml:"message_id" yaml:"message_id" msgpack:"message_id" cbor:"message_id"`
+	ReceivedText string   `json:"received_text" xml:"received_text" yaml:"received_text" msgpack:"received_text" cbor:"received_text" redact:"partial"`
+	Reply        string   `json:"reply" xml:"reply" yaml:"reply" msgpack:"reply" cbor:"reply" redact:""`
+	Timestamp    string   `json:"timestamp,omitempty" xml:"timestamp,omitempty" yaml:"timestamp,omitempty" msgpack:"timestamp,omitempty" cbor:"timestamp,omitempty"`
+}
+
+// VersionResponse describes the running build.
+type VersionResponse struct {
+	XMLName   xml.Name `json:"-" xml:"version" yaml:"-" msgpack:"-" cbor:"-"`
+	Service   string   `json:"service" xml:"service" yaml:"service" msgpack:"service" cbor:"service"`
+	Version   string   `json:"version" xml:"version" yaml:"version" msgpack:"version" cbor:"version"`
+	Commit    string   `json:"commit,omitempty" xml:"commit,omitempty" yaml:"commit,omitempty" msgpack:"commit,omitempty" cbor:"commit,omitempty"`
+	CommitAt  string   `json:"commit_time,omitempty" xml:"commit_time,omitempty" yaml:"commit_time,omitempty" msgpack:"commit_time,omitempty" cbor:"commit_time,omitempty"`
+	Modified  bool     `json:"modified,omitempty" xml:"modified,omitempty" yaml:"modified,omitempty" msgpack:"modified,omitempty" cbor:"modified,omitempty"`
+	GoVersion string   `json:"go_version" xml:"go_version" yaml:"go_version" msgpack:"go_version" cbor:"go_version"`
+	Revision  string   `json:"revision,omitempty" xml:"revision,omitempty" yaml:"revision,omitempty" msgpack:"revision,omitempty" cbor:"revision,omitempty"` // Cloud Run revision.
+}
+
+// Message is a stored echo as returned by the messages resource.
+type Message struct {
+	XMLName    xml.Name `json:"-" xml:"message" yaml:"-" msgpack:"-" cbor:"-"`
+	ID         string   `json:"id" xml:"id" yaml:"id" msgpack:"id" cbor:"id"`
+	Text       string   `json:"text" xml:"text" yaml:"text" msgpack:"text" cbor:"text" redact:"partial"`
+	Caller     string   `json:"caller,omitempty" xml:"caller,omitempty" yaml:"caller,omitempty" msgpack:"caller,omitempty" cbor:"caller,omitempty"`
+	TraceID    string   `json:"trace_id,omitempty" xml:"trace_id,omitempty" yaml:"trace_id,omitempty" msgpack:"trace_id,omitempty" cbor:"trace_id,omitempty"`
+	CreateTime string   `json:"create_time" xml:"create_time" yaml:"create_time" msgpack:"create_time" cbor:"create_time"`
+}
+
+// ListMessagesResponse is a page of messages. NextPageToken is empty on the
+// last page.
+type ListMessagesResponse struct {
+	XMLName       xml.Name  `json:"-" xml:"list_messages_response" yaml:"-" msgpack:"-" cbor:"-"`
+	Messages      []Message `json:"messages" xml:"messages>message" yaml:"messages" msgpack:"messages" cbor:"messages"`
+	NextPageToken string    `json:"next_page_token,omitempty" xml:"next_page_token,omitempty" yaml:"next_page_token,omitempty" msgpack:"next_page_token,omitempty" cbor:"next_page_token,omitempty"`
+}
+
+// SearchResult is a message matching a search query. Highlights are
+// HTML-escaped fragments of the text with matches wrapped in <em></em>.
+type SearchResult struct {
+	XMLName    xml.Name `json:"-" xml:"result" yaml:"-" msgpack:"-" cbor:"-"`
+	Message    Message  `json:"message" xml:"message" yaml:"message" msgpack:"message" cbor:"message"`
+	Score      float64  `json:"score" xml:"score" yaml:"score" msgpack:"score" cbor:"score"`
+	Highlights []string `json:"highlights" xml:"highlights>highlight" yaml:"highlights" msgpack:"highlights" cbor:"highlights" redact:"partial"`
+}
+
+// SearchMessagesResponse is a page of search results, most relevant first.
+type SearchMessagesResponse struct {
+	XMLName       xml.Name       `json:"-" xml:"search_messages_response" yaml:"-" msgpack:"-" cbor:"-"`
+	Results       []SearchResult `json:"results" xml:"results>result" yaml:"results" msgpack:"results" cbor:"results"`
+	TotalSize     int            `json:"total_size" xml:"total_size" yaml:"total_size" msgpack:"total_size" cbor:"total_size"`
+	NextPageToken string         `json:"next_page_token,omitempty" xml:"next_page_token,omitempty" yaml:"next_page_token,omitempty" msgpack:"next_page_token,omitempty" cbor:"next_page_token,omitempty"`
+}
+
+// Problem is an RFC 9457 problem details response body. Its XML form uses
+// the RFC's urn:ietf:rfc:7807 namespace.
+type Problem struct {
+	XMLName  xml.Name `json:"-" xml:"urn:ietf:rfc:7807 problem" yaml:"-" msgpack:"-" cbor:"-"`
+	Type     string   `json:"type" xml:"type" yaml:"type" msgpack:"type" cbor:"type"`
+	Title    string   `json:"title" xml:"title" yaml:"title" msgpack:"title" cbor:"title"`
+	Status   int      `json:"status" xml:"status" yaml:"status" msgpack:"status" cbor:"status"`
+	Detail   string   `json:"detail,omitempty" xml:"detail,omitempty" yaml:"detail,omitempty" msgpack:"detail,omitempty" cbor:"detail,omitempty"`
+	Instance string   `json:"instance,omitempty" xml:"instance,omitempty" yaml:"instance,omitempty" msgpack:"instance,omitempty" cbor:"instance,omitempty"`
 }
@@ -0,0 +1,225 @@
+// internal/proxy/proxy.go
+//
+// Package proxy derives the address and scheme a client used from the
+// headers that reverse proxies add to a request: X-Forwarded-For and
+// X-Forwarded-Proto, or Forwarded (RFC 7239). Clients can send these headers
+// too, so they are only believed when the peer that sent the request is a
+// trusted proxy, only as far back as the chain of trusted proxies goes, and
+// only the one header the proxies are configured to set; a proxy that sets
+// one passes the other through from the client unchanged.
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Client is the origin of a request as seen by the first trusted proxy.
+type Client struct {
+	// IP is the client's IP address, or the peer's address as given in
+	// http.Request.RemoteAddr if it cannot be parsed.
+	IP string
+	// Scheme is "https" or "http".
+	Scheme string
+}
+
+// Header is the header that trusted proxies add the client to.
+type Header int
+
+const (
+	// XForwardedFor proxies append the client to X-Forwarded-For and set
+	// X-Forwarded-Proto, as the Google Front End does.
+	XForwardedFor Header = iota
+	// Forwarded proxies append the client to Forwarded.
+	Forwarded
+)
+
+// ParseHeader parses a header name, case-insensitively, into a Header.
+func ParseHeader(name string) (Header, error) {
+	switch strings.ToLower(name) {
+	case "x-forwarded-for":
+		return XForwardedFor, nil
+	case "forwarded":
+		return Forwarded, nil
+	}
+	return 0, fmt.Errorf("proxy: %q is not X-Forwarded-For or Forwarded", name)
+}
+
+// Trusted describes the trusted proxies. The zero value trusts no proxy.
+type Trusted struct {
+	// Prefixes are the addresses of the trusted proxies.
+	Prefixes []netip.Prefix
+	// Header is the header they add the client to. The other one is
+	// ignored, as it comes from the client.
+	Header Header
+}
+
+// Contains reports whether addr is a trusted proxy.
+func (t Trusted) Contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range t.Prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve returns the client that sent r. When the peer is a trusted proxy,
+// t.Header is read from the right, past any further trusted proxies, to the
+// address that the last of them saw; entries to the left of it may be
+// forged by the client. The scheme is the X-Forwarded-Proto set by the peer,
+// or the proto of that Forwarded entry.
+func (t Trusted) Resolve(r *http.Request) Client {
+	c := Client{IP: remoteIP(r), Scheme: "http"}
+	if r.TLS != nil {
+		c.Scheme = "https"
+	}
+	addr, err := netip.ParseAddr(c.IP)
+	if err != nil || !t.Contains(addr) {
+		return c
+	}
+	if t.Header == Forwarded {
+		return t.resolveForwarded(c, addr, r.Header.Values("Forwarded"))
+	}
+	return t.resolveXForwarded(c, addr, r.Header)
+}
+
+// resolveForwarded walks the elements of Forwarded values from the right.
+func (t Trusted) resolveForwarded(c Client, addr netip.Addr, values []string) Client {
+	elements := parseForwarded(values)
+	for i := len(elements) - 1; i >= 0; i-- {
+		hop, ok := parseNode(elements[i]["for"])
+		if !ok {
+			break
+		}
+		addr = hop
+		if proto, ok := scheme(elements[i]["proto"]); ok {
+			c.Scheme = proto
+		}
+		if !t.Contains(addr) {
+			break
+		}
+	}
+	c.IP = addr.String()
+	return c
+}
+
+// resolveXForwarded walks X-Forwarded-For from the right.
+func (t Trusted) resolveXForwarded(c Client, addr netip.Addr, header http.Header) Client {
+	var hops []string
+	for _, value := range header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		addr = hop.Unmap()
+		if !t.Contains(addr) {
+			break
+		}
+	}
+	c.IP = addr.String()
+
+	// Proxies set X-Forwarded-Proto rather than append to it, so the last
+	// value is the peer's.
+	if values := header.Values("X-Forwarded-Proto"); len(values) > 0 {
+		protos := strings.Split(values[len(values)-1], ",")
+		if proto, ok := scheme(protos[len(protos)-1]); ok {
+			c.Scheme = proto
+		}
+	}
+	return c
+}
+
+// parseForwarded splits Forwarded values into elements, each a map of
+// lower-case parameter names to unquoted values. Commas and semicolons in
+// quoted strings do not split.
+func parseForwarded(values []string) []map[string]string {
+	var elements []map[string]string
+	for _, value := range values {
+		element := map[string]string{}
+		var pair strings.Builder
+		quoted := false
+		flush := func() {
+			name, v, _ := strings.Cut(pair.String(), "=")
+			if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+				element[name] = strings.Trim(strings.TrimSpace(v), `"`)
+			}
+			pair.Reset()
+		}
+		for _, ch := range value {
+			switch {
+			case ch == '"':
+				quoted = !quoted
+				pair.WriteRune(ch)
+			case quoted:
+				pair.WriteRune(ch)
+			case ch == ';':
+				flush()
+			case ch == ',':
+				flush()
+				elements = append(elements, element)
+				element = map[string]string{}
+			default:
+				pair.WriteRune(ch)
+			}
+		}
+		flush()
+		elements = append(elements, element)
+	}
+	return elements
+}
+
+// parseNode parses the address of a Forwarded node: an IPv4 address or a
+// bracketed IPv6 address, either with an optional port. Obfuscated and
+// "unknown" nodes are not addresses.
+func parseNode(node string) (netip.Addr, bool) {
+	if ap, err := netip.ParseAddrPort(node); err == nil {
+		return ap.Addr().Unmap(), true
+	}
+	node = strings.TrimSuffix(strings.TrimPrefix(node, "["), "]")
+	addr, err := netip.ParseAddr(node)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// scheme normalizes a forwarded protocol, accepting only http and https.
+func scheme(proto string) (string, bool) {
+	proto = strings.ToLower(strings.TrimSpace(proto))
+	return proto, proto == "http" || proto == "https"
+}
+
+// remoteIP returns the IP address of the peer that sent r.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+type clientKey struct{}
+
+// Middleware resolves the client of each request with t and stores it in
+// the request context, for FromContext.
+func (t Trusted) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), clientKey{}, t.Resolve(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// FromContext returns the client stored by Middleware.
+func FromContext(ctx context.Context) (Client, bool) {
+	c, ok := ctx.Value(clientKey{}).(Client)
+	return c, ok
+}
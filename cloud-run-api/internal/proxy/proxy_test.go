@@ -0,0 +1,120 @@
+// internal/proxy/proxy_test.go
+package proxy
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrusted_Resolve(t *testing.T) {
+	prefixes := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}
+	for _, tc := range []struct {
+		name       string
+		proxies    Header
+		remoteAddr string
+		header     http.Header
+		tls        bool
+		want       Client
+	}{
+		{"Direct", XForwardedFor, "203.0.113.7:1234", nil, false, Client{"203.0.113.7", "http"}},
+		{"Direct TLS", XForwardedFor, "203.0.113.7:1234", nil, true, Client{"203.0.113.7", "https"}},
+		{"Untrusted peer", XForwardedFor, "203.0.113.7:1234", http.Header{
+			"X-Forwarded-For":   {"198.51.100.1"},
+			"X-Forwarded-Proto": {"https"},
+			"Forwarded":         {"for=198.51.100.1;proto=https"},
+		}, false, Client{"203.0.113.7", "http"}},
+
+		{"X-Forwarded-For", XForwardedFor, "10.0.0.1:1234", http.Header{
+			"X-Forwarded-For":   {"1.1.1.1, 198.51.100.1"},
+			"X-Forwarded-Proto": {"https"},
+		}, false, Client{"198.51.100.1", "https"}},
+		{"X-Forwarded-For chain", XForwardedFor, "10.0.0.1:1234", http.Header{
+			"X-Forwarded-For":   {"198.51.100.1, 10.0.0.2", "10.0.0.3"},
+			"X-Forwarded-Proto": {"http, https"},
+		}, false, Client{"198.51.100.1", "https"}},
+		{"X-Forwarded-Proto not a scheme", XForwardedFor, "10.0.0.1:1234", http.Header{
+			"X-Forwarded-For":   {"198.51.100.1"},
+			"X-Forwarded-Proto": {"gopher"},
+		}, true, Client{"198.51.100.1", "https"}},
+		{"No header", XForwardedFor, "10.0.0.1:1234", nil, false, Client{"10.0.0.1", "http"}},
+		{"Malformed entry", XForwardedFor, "10.0.0.1:1234", http.Header{"X-Forwarded-For": {"198.51.100.1, junk"}}, false, Client{"10.0.0.1", "http"}},
+		{"IPv6 proxy", XForwardedFor, "[2001:db8::1]:1234", http.Header{"X-Forwarded-For": {"2001:db8:ffff::9, 198.51.100.1"}}, false, Client{"198.51.100.1", "http"}},
+
+		{"Forwarded from the client", XForwardedFor, "10.0.0.1:1234", http.Header{
+			"Forwarded":         {"for=1.2.3.4;proto=https"},
+			"X-Forwarded-For":   {"198.51.100.1"},
+			"X-Forwarded-Proto": {"http"},
+		}, false, Client{"198.51.100.1", "http"}},
+		{"Forwarded from the client without X-Forwarded-For", XForwardedFor, "10.0.0.1:1234", http.Header{
+			"Forwarded": {"for=1.2.3.4;proto=https"},
+		}, false, Client{"10.0.0.1", "http"}},
+		{"X-Forwarded-For from the client", Forwarded, "10.0.0.1:1234", http.Header{
+			"Forwarded":         {"for=198.51.100.1"},
+			"X-Forwarded-For":   {"1.2.3.4"},
+			"X-Forwarded-Proto": {"https"},
+		}, false, Client{"198.51.100.1", "http"}},
+		{"Forwarded", Forwarded, "10.0.0.1:1234", http.Header{
+			"Forwarded":       {`for=1.1.1.1;proto=http, for=198.51.100.1;proto=https;by=10.0.0.1`},
+			"X-Forwarded-For": {"192.0.2.1"},
+		}, false, Client{"198.51.100.1", "https"}},
+		{"Forwarded chain", Forwarded, "10.0.0.1:1234", http.Header{
+			"Forwarded": {`for="[2001:db8:cafe::17]:4711";proto=https`, `For=10.0.0.2:8080;Proto=http`},
+		}, false, Client{"2001:db8:cafe::17", "https"}},
+		{"Forwarded quoted separators", Forwarded, "10.0.0.1:1234", http.Header{
+			"Forwarded": {`for=198.51.100.1;host="a,b;c";proto=https`},
+		}, false, Client{"198.51.100.1", "https"}},
+		{"Forwarded obfuscated", Forwarded, "10.0.0.1:1234", http.Header{
+			"Forwarded": {`for=_hidden, for=unknown`},
+		}, false, Client{"10.0.0.1", "http"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tc.remoteAddr
+			for k, v := range tc.header {
+				req.Header[k] = v
+			}
+			if tc.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			trusted := Trusted{Prefixes: prefixes, Header: tc.proxies}
+			assert.Equal(t, tc.want, trusted.Resolve(req))
+		})
+	}
+}
+
+func TestParseHeader(t *testing.T) {
+	h, err := ParseHeader("X-Forwarded-For")
+	require.NoError(t, err)
+	assert.Equal(t, XForwardedFor, h)
+	h, err = ParseHeader("forwarded")
+	require.NoError(t, err)
+	assert.Equal(t, Forwarded, h)
+	_, err = ParseHeader("X-Real-IP")
+	assert.Error(t, err)
+}
+
+func TestTrusted_Middleware(t *testing.T) {
+	trusted := Trusted{Prefixes: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}}
+	var got Client
+	h := trusted.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = FromContext(r.Context())
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	req.Header.Set("X-Forwarded-Proto", "https")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, Client{IP: "198.51.100.1", Scheme: "https"}, got)
+
+	_, ok := FromContext(req.Context())
+	assert.False(t, ok)
+}
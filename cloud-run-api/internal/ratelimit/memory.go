@@ -0,0 +1,67 @@
+// internal/ratelimit/memory.go
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often MemoryStore drops full buckets.
+const sweepInterval = time.Minute
+
+// memoryBucket is a bucket held by MemoryStore.
+type memoryBucket struct {
+	Bucket
+	// full is when the bucket will have refilled, after which it is no
+	// different from a missing one and can be dropped.
+	full time.Time
+}
+
+// MemoryStore is an in-memory Store. Buckets are not shared between
+// instances, so each instance allows a client the full rate.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]memoryBucket
+	lastSweep time.Time
+}
+
+// NewMemoryStore returns an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]memoryBucket)}
+}
+
+// Take implements Store.
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(now)
+	bucket, res := s.buckets[key].Take(limit, now)
+	s.buckets[key] = memoryBucket{Bucket: bucket, full: now.Add(res.Reset)}
+	return res, nil
+}
+
+// Len returns the number of buckets held, including full ones not yet
+// swept.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.buckets)
+}
+
+// sweep drops full buckets at most once per sweepInterval. The caller must
+// hold s.mu.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+// internal/ratelimit/memory_test.go
+package ratelimit_test
+
+import (
+	"testing"
+
+	"your-module-name/internal/ratelimit"
+	"your-module-name/internal/ratelimit/ratelimittest"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ratelimittest.RunConformance(t, func(t *testing.T) ratelimit.Store {
+		return ratelimit.NewMemoryStore()
+	})
+}
+
+func TestFake(t *testing.T) {
+	ratelimittest.RunConformance(t, func(t *testing.T) ratelimit.Store {
+		return ratelimittest.NewFake()
+	})
+}
@@ -0,0 +1,209 @@
+// internal/ratelimit/ratelimit.go
+//
+// Package ratelimit limits how often each client may call a route, using
+// token buckets: a bucket holds up to Burst tokens, refills at Count tokens
+// per Period, and every request takes a token. Buckets live in a Store, so
+// instances can share them. The HTTP middleware that uses it lives in
+// internal/api.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultRule names the rule that applies to routes without one of their own.
+const DefaultRule = "*"
+
+// Limit is the rate and capacity of a token bucket.
+type Limit struct {
+	// Count tokens are added every Period.
+	Count  int
+	Period time.Duration
+	// Burst is the capacity of the bucket: how many requests are allowed
+	// at once after the client has been idle.
+	Burst int
+}
+
+// String formats the limit as ParseRules accepts it, e.g. "60/m:120".
+func (l Limit) String() string {
+	s := strconv.Itoa(l.Count) + "/" + periodUnits[l.Period]
+	if l.Burst != l.Count {
+		s += ":" + strconv.Itoa(l.Burst)
+	}
+	return s
+}
+
+// rate returns the tokens added per second.
+func (l Limit) rate() float64 {
+	return float64(l.Count) / l.Period.Seconds()
+}
+
+// Result is the outcome of taking a token.
+type Result struct {
+	Allowed bool
+	Limit   Limit
+	// Remaining is the number of whole tokens left in the bucket.
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until a token is available; zero if Allowed.
+	RetryAfter time.Duration
+}
+
+// Bucket is the state of a token bucket. Stores keep one per key and update
+// it with Take.
+type Bucket struct {
+	Tokens float64
+	// Updated is when Tokens was computed; a zero Updated is a full bucket.
+	Updated time.Time
+}
+
+// Take refills the bucket for the time elapsed since it was updated and
+// takes a token if one is available. It returns the updated bucket, which
+// the caller stores whether or not the token was taken.
+func (b Bucket) Take(limit Limit, now time.Time) (Bucket, Result) {
+	rate := limit.rate()
+	tokens := float64(limit.Burst)
+	if !b.Updated.IsZero() {
+		// A clock that went backwards refills nothing.
+		elapsed := max(now.Sub(b.Updated).Seconds(), 0)
+		tokens = min(tokens, b.Tokens+elapsed*rate)
+	}
+	res := Result{Limit: limit}
+	if tokens >= 1 {
+		tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - tokens) / rate)
+	}
+	res.Remaining = int(tokens)
+	res.Reset = seconds((float64(limit.Burst) - tokens) / rate)
+	return Bucket{Tokens: tokens, Updated: now}, res
+}
+
+// seconds converts s seconds to a duration, rounding up to the next
+// millisecond so that waiting that long is always enough.
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s*1000)) * time.Millisecond
+}
+
+// Store holds token buckets. Implementations must be safe for concurrent
+// use; Take must be atomic across every instance sharing the store.
+type Store interface {
+	// Take takes a token from the bucket stored under key, creating a full
+	// bucket if there is none. now is the caller's clock, so instances
+	// sharing a store need synchronized clocks.
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// Rules maps rule names to limits. The API names its rules after the
+// permission a route requires, and DefaultRule applies to every other route.
+type Rules map[string]Limit
+
+// periodUnits are the period suffixes ParseRules accepts.
+var periodUnits = map[time.Duration]string{time.Second: "s", time.Minute: "m", time.Hour: "h"}
+
+// ParseRules parses a comma-separated list of rules of the form
+// name=count/period[:burst], where period is s, m or h and burst defaults
+// to count, e.g. "*=600/m,echo:write=60/m:120".
+func ParseRules(s string) (Rules, error) {
+	rules := Rules{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, spec, ok := strings.Cut(item, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("rate limit %q: expected name=count/period[:burst]", item)
+		}
+		if _, dup := rules[name]; dup {
+			return nil, fmt.Errorf("duplicate rate limit for %q", name)
+		}
+		limit, err := parseLimit(strings.TrimSpace(spec))
+		if err != nil {
+			return nil, fmt.Errorf("rate limit %q: %w", name, err)
+		}
+		rules[name] = limit
+	}
+	return rules, nil
+}
+
+// parseLimit parses count/period[:burst].
+func parseLimit(spec string) (Limit, error) {
+	rate, burst, hasBurst := strings.Cut(spec, ":")
+	count, unit, ok := strings.Cut(rate, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("expected count/period[:burst], got %q", spec)
+	}
+	var limit Limit
+	for period, u := range periodUnits {
+		if u == unit {
+			limit.Period = period
+		}
+	}
+	if limit.Period == 0 {
+		return Limit{}, fmt.Errorf("period must be s, m or h, got %q", unit)
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("count must be a positive integer, got %q", count)
+	}
+	limit.Count, limit.Burst = n, n
+	if hasBurst {
+		b, err := strconv.Atoi(burst)
+		if err != nil || b <= 0 {
+			return Limit{}, fmt.Errorf("burst must be a positive integer, got %q", burst)
+		}
+		limit.Burst = b
+	}
+	return limit, nil
+}
+
+// Names returns the rule names, sorted.
+func (r Rules) Names() []string {
+	names := make([]string, 0, len(r))
+	for name := range r {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Limiter takes tokens for clients according to its rules.
+type Limiter struct {
+	rules Rules
+	store Store
+	now   func() time.Time // Replaced in tests.
+}
+
+// NewLimiter returns a limiter applying rules with buckets kept in store.
+func NewLimiter(rules Rules, store Store) *Limiter {
+	return &Limiter{rules: rules, store: store, now: time.Now}
+}
+
+// Take takes a token for client under the rule named rule, or DefaultRule
+// if there is no such rule. Routes that fall back to DefaultRule share a
+// bucket per client. ok is false if neither rule exists, in which case the
+// request is not limited.
+func (l *Limiter) Take(ctx context.Context, rule, client string) (res Result, ok bool, err error) {
+	limit, ok := l.rules[rule]
+	if !ok {
+		rule = DefaultRule
+		if limit, ok = l.rules[rule]; !ok {
+			return Result{}, false, nil
+		}
+	}
+	res, err = l.store.Take(ctx, rule+" "+client, limit, l.now())
+	if err != nil {
+		return Result{}, true, fmt.Errorf("ratelimit: %w", err)
+	}
+	return res, true, nil
+}
@@ -0,0 +1,108 @@
+// internal/ratelimit/ratelimit_test.go
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules(" *=600/m, echo:write=10/s:25 ,hello:read=1000/h,")
+	require.NoError(t, err)
+	assert.Equal(t, Rules{
+		DefaultRule:  {Count: 600, Period: time.Minute, Burst: 600},
+		"echo:write": {Count: 10, Period: time.Second, Burst: 25},
+		"hello:read": {Count: 1000, Period: time.Hour, Burst: 1000},
+	}, rules)
+	assert.Equal(t, []string{DefaultRule, "echo:write", "hello:read"}, rules.Names())
+	assert.Equal(t, "10/s:25", rules["echo:write"].String())
+	assert.Equal(t, "600/m", rules[DefaultRule].String())
+
+	empty, err := ParseRules("")
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+
+	for _, spec := range []string{
+		"echo:write",
+		"=10/s",
+		"echo:write=10",
+		"echo:write=10/d",
+		"echo:write=0/s",
+		"echo:write=ten/s",
+		"echo:write=10/s:0",
+		"echo:write=10/s:x",
+		"*=1/s,*=2/s",
+	} {
+		_, err := ParseRules(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestBucket_ClockGoesBackwards(t *testing.T) {
+	limit := Limit{Count: 1, Period: time.Second, Burst: 1}
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	b, res := Bucket{}.Take(limit, now)
+	require.True(t, res.Allowed)
+	_, res = b.Take(limit, now.Add(-time.Hour))
+	assert.False(t, res.Allowed)
+	assert.Equal(t, time.Second, res.RetryAfter)
+}
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	l := NewLimiter(Rules{
+		DefaultRule:  {Count: 2, Period: time.Minute, Burst: 2},
+		"echo:write": {Count: 1, Period: time.Minute, Burst: 1},
+	}, store)
+	l.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	res, ok, err := l.Take(ctx, "echo:write", "apikey:ci")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.True(t, res.Allowed)
+	res, _, _ = l.Take(ctx, "echo:write", "apikey:ci")
+	assert.False(t, res.Allowed)
+	res, _, _ = l.Take(ctx, "echo:write", "apikey:other")
+	assert.True(t, res.Allowed, "clients have their own buckets")
+
+	// Routes without a rule share the default bucket.
+	res, _, _ = l.Take(ctx, "hello:read", "apikey:ci")
+	assert.True(t, res.Allowed)
+	res, _, _ = l.Take(ctx, "messages:read", "apikey:ci")
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+	res, _, _ = l.Take(ctx, "hello:read", "apikey:ci")
+	assert.False(t, res.Allowed)
+
+	unlimited := NewLimiter(Rules{"echo:write": {Count: 1, Period: time.Second, Burst: 1}}, store)
+	_, ok, err = unlimited.Take(ctx, "hello:read", "apikey:ci")
+	require.NoError(t, err)
+	assert.False(t, ok, "no rule applies")
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, ok, err = l.Take(canceled, "echo:write", "apikey:ci")
+	assert.True(t, ok)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestMemoryStore_SweepsFullBuckets(t *testing.T) {
+	s := NewMemoryStore()
+	limit := Limit{Count: 10, Period: time.Second, Burst: 10}
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	for _, key := range []string{"a", "b", "c"} {
+		_, err := s.Take(context.Background(), key, limit, now)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 3, s.Len())
+
+	_, err := s.Take(context.Background(), "d", limit, now.Add(2*sweepInterval))
+	require.NoError(t, err)
+	assert.Equal(t, 1, s.Len(), "refilled buckets are dropped")
+}
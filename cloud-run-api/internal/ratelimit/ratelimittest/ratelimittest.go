@@ -0,0 +1,178 @@
+// internal/ratelimit/ratelimittest/ratelimittest.go
+//
+// Package ratelimittest provides a conformance suite for ratelimit.Store
+// implementations, so an external store such as Redis is held to the same
+// contract as the in-memory one, and a fake store for tests of code that
+// uses one.
+package ratelimittest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"your-module-name/internal/ratelimit"
+)
+
+// Factory returns a new, empty store. It is called once per subtest and
+// should register any cleanup with t.Cleanup.
+type Factory func(t *testing.T) ratelimit.Store
+
+// RunConformance runs the Store contract tests against stores returned by
+// newStore. The suite passes its own clock to Take, so it does not sleep.
+func RunConformance(t *testing.T, newStore Factory) {
+	t.Helper()
+	for _, tc := range []struct {
+		name string
+		fn   func(*testing.T, ratelimit.Store)
+	}{
+		{"BurstThenDeny", testBurstThenDeny},
+		{"Refill", testRefill},
+		{"RefillCappedAtBurst", testRefillCappedAtBurst},
+		{"KeysAreIndependent", testKeysAreIndependent},
+		{"ConcurrentTakes", testConcurrentTakes},
+		{"CanceledContext", testCanceledContext},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.fn(t, newStore(t))
+		})
+	}
+}
+
+// baseTime is a fixed reference time.
+var baseTime = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// perSecond allows 2 requests per second with bursts of 3.
+var perSecond = ratelimit.Limit{Count: 2, Period: time.Second, Burst: 3}
+
+func take(t *testing.T, s ratelimit.Store, key string, now time.Time) ratelimit.Result {
+	t.Helper()
+	res, err := s.Take(context.Background(), key, perSecond, now)
+	require.NoError(t, err)
+	return res
+}
+
+func testBurstThenDeny(t *testing.T, s ratelimit.Store) {
+	for i := range perSecond.Burst {
+		res := take(t, s, "client", baseTime)
+		require.True(t, res.Allowed, "request %d is within the burst", i+1)
+		assert.Equal(t, perSecond.Burst-i-1, res.Remaining)
+		assert.Equal(t, perSecond, res.Limit)
+		assert.Zero(t, res.RetryAfter)
+	}
+	res := take(t, s, "client", baseTime)
+	assert.False(t, res.Allowed)
+	assert.Zero(t, res.Remaining)
+	assert.Equal(t, 500*time.Millisecond, res.RetryAfter, "a token is added every 500ms")
+	assert.Equal(t, 1500*time.Millisecond, res.Reset, "the bucket refills in 1.5s")
+}
+
+func testRefill(t *testing.T, s ratelimit.Store) {
+	for range perSecond.Burst {
+		take(t, s, "client", baseTime)
+	}
+	res := take(t, s, "client", baseTime.Add(250*time.Millisecond))
+	require.False(t, res.Allowed)
+	assert.Equal(t, 250*time.Millisecond, res.RetryAfter)
+
+	res = take(t, s, "client", baseTime.Add(500*time.Millisecond))
+	assert.True(t, res.Allowed, "a token was added after 500ms")
+	res = take(t, s, "client", baseTime.Add(500*time.Millisecond))
+	assert.False(t, res.Allowed)
+}
+
+func testRefillCappedAtBurst(t *testing.T, s ratelimit.Store) {
+	take(t, s, "client", baseTime)
+	later := baseTime.Add(time.Hour)
+	for range perSecond.Burst {
+		require.True(t, take(t, s, "client", later).Allowed)
+	}
+	assert.False(t, take(t, s, "client", later).Allowed, "idle time does not grow the bucket beyond Burst")
+}
+
+func testKeysAreIndependent(t *testing.T, s ratelimit.Store) {
+	for range perSecond.Burst {
+		take(t, s, "a", baseTime)
+	}
+	assert.False(t, take(t, s, "a", baseTime).Allowed)
+	res := take(t, s, "b", baseTime)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, perSecond.Burst-1, res.Remaining)
+}
+
+func testConcurrentTakes(t *testing.T, s ratelimit.Store) {
+	const workers = 20
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		allowed int
+	)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := s.Take(context.Background(), "client", perSecond, baseTime)
+			assert.NoError(t, err)
+			if res.Allowed {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, perSecond.Burst, allowed, "exactly Burst concurrent requests are allowed")
+}
+
+func testCanceledContext(t *testing.T, s ratelimit.Store) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := s.Take(ctx, "client", perSecond, baseTime)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+// Fake is a Store for tests. It keeps buckets in a ratelimit.MemoryStore,
+// records the keys it is asked for, and fails every Take while Err is set,
+// like an unreachable external store.
+type Fake struct {
+	mu    sync.Mutex
+	err   error
+	keys  []string
+	store *ratelimit.MemoryStore
+}
+
+// NewFake returns an empty fake store.
+func NewFake() *Fake {
+	return &Fake{store: ratelimit.NewMemoryStore()}
+}
+
+// Take implements ratelimit.Store.
+func (f *Fake) Take(ctx context.Context, key string, limit ratelimit.Limit, now time.Time) (ratelimit.Result, error) {
+	f.mu.Lock()
+	f.keys = append(f.keys, key)
+	err := f.err
+	f.mu.Unlock()
+	if err != nil {
+		return ratelimit.Result{}, err
+	}
+	return f.store.Take(ctx, key, limit, now)
+}
+
+// SetErr makes every following Take fail with err, or succeed again if err
+// is nil.
+func (f *Fake) SetErr(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+// Keys returns the keys of every Take so far, in order.
+func (f *Fake) Keys() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.keys...)
+}
@@ -0,0 +1,414 @@
+// internal/search/index.go
+//
+// Package search provides full-text search over stored messages: a query
+// language with phrase and prefix queries, the Searcher interface, and an
+// in-process inverted index with BM25 relevance ranking and highlighted
+// fragments, kept in sync with a store.MessageRepository by
+// IndexedRepository. The index only sees the writes of its own instance, so
+// it serves the memory store; the database stores implement Searcher with
+// their database's full-text index.
+package search
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"hash/fnv"
+	"html"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"your-module-name/internal/store"
+)
+
+// BM25 parameters: term frequency saturation and length normalisation.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Highlight limits.
+const (
+	maxHighlights    = 3  // Fragments returned per hit.
+	highlightContext = 30 // Runes of context on each side of a match.
+)
+
+// document is an indexed message and its tokens.
+type document struct {
+	msg    store.Message
+	tokens []token
+}
+
+// Index is an in-memory inverted index over message text. It is safe for
+// concurrent use.
+type Index struct {
+	mu       sync.RWMutex
+	docs     map[string]*document
+	postings map[string]map[string][]int // term -> message ID -> token positions
+	terms    []string                    // Sorted vocabulary, for prefix queries.
+	totalLen int                         // Tokens across all documents.
+}
+
+// NewIndex returns an empty index.
+func NewIndex() *Index {
+	return &Index{
+		docs:     make(map[string]*document),
+		postings: make(map[string]map[string][]int),
+	}
+}
+
+// Len returns the number of indexed messages.
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.docs)
+}
+
+// Add indexes m, replacing any message with the same ID.
+func (ix *Index) Add(m store.Message) {
+	doc := &document{msg: m, tokens: tokenize(m.Text)}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(m.ID)
+	ix.docs[m.ID] = doc
+	ix.totalLen += len(doc.tokens)
+	for pos, tok := range doc.tokens {
+		postings, ok := ix.postings[tok.term]
+		if !ok {
+			postings = make(map[string][]int)
+			ix.postings[tok.term] = postings
+			i, _ := slices.BinarySearch(ix.terms, tok.term)
+			ix.terms = slices.Insert(ix.terms, i, tok.term)
+		}
+		postings[m.ID] = append(postings[m.ID], pos)
+	}
+}
+
+// Remove drops the message with the given ID from the index, if present.
+func (ix *Index) Remove(id string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(id)
+}
+
+func (ix *Index) remove(id string) {
+	doc, ok := ix.docs[id]
+	if !ok {
+		return
+	}
+	delete(ix.docs, id)
+	ix.totalLen -= len(doc.tokens)
+	for _, tok := range doc.tokens {
+		postings := ix.postings[tok.term]
+		delete(postings, id)
+		if len(postings) == 0 {
+			delete(ix.postings, tok.term)
+			if i, found := slices.BinarySearch(ix.terms, tok.term); found {
+				ix.terms = slices.Delete(ix.terms, i, i+1)
+			}
+		}
+	}
+}
+
+// reset empties the index.
+func (ix *Index) reset() {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.docs = make(map[string]*document)
+	ix.postings = make(map[string]map[string][]int)
+	ix.terms = nil
+	ix.totalLen = 0
+}
+
+// Request is a search request.
+type Request struct {
+	Query Query
+	// PageSize is the maximum number of hits returned. Zero means
+	// store.DefaultPageSize; values above store.MaxPageSize are clamped.
+	PageSize int
+	// PageToken is the NextPageToken of a previous search for the same query.
+	PageToken string
+}
+
+// Hit is a matching message.
+type Hit struct {
+	Message store.Message
+	// Score is the BM25 relevance of the message; higher is better.
+	Score float64
+	// Highlights are HTML-escaped fragments of the text around the matches,
+	// with each match wrapped in <em></em>.
+	Highlights []string
+}
+
+// Response is a page of hits, most relevant first.
+type Response struct {
+	Hits []Hit
+	// TotalSize is the number of messages matching the query.
+	TotalSize     int
+	NextPageToken string
+}
+
+// match is a run of n tokens starting at token position pos.
+type match struct{ pos, n int }
+
+// Search returns the messages matching req.Query. Ties in relevance are
+// broken by recency. It fails with store.ErrInvalidPageToken if the page
+// token was issued for another query.
+func (ix *Index) Search(ctx context.Context, req Request) (Response, error) {
+	if err := ctx.Err(); err != nil {
+		return Response{}, err
+	}
+	offset, err := DecodePageToken(req.PageToken, req.Query)
+	if err != nil {
+		return Response{}, err
+	}
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	// Documents matching every clause, with their matches per clause.
+	var candidates map[string][][]match
+	idfs := make([]float64, len(req.Query.clauses))
+	for i, c := range req.Query.clauses {
+		docs := ix.matchClause(c)
+		idfs[i] = ix.idf(len(docs))
+		if candidates == nil {
+			candidates = make(map[string][][]match, len(docs))
+			for id, ms := range docs {
+				candidates[id] = [][]match{ms}
+			}
+			continue
+		}
+		for id, prev := range candidates {
+			ms, ok := docs[id]
+			if !ok {
+				delete(candidates, id)
+				continue
+			}
+			candidates[id] = append(prev, ms)
+		}
+	}
+
+	avgLen := 1.0
+	if len(ix.docs) > 0 {
+		avgLen = float64(ix.totalLen) / float64(len(ix.docs))
+	}
+	type scored struct {
+		doc     *document
+		score   float64
+		matches [][]match
+	}
+	results := make([]scored, 0, len(candidates))
+	for id, matches := range candidates {
+		doc := ix.docs[id]
+		norm := bm25K1 * (1 - bm25B + bm25B*float64(len(doc.tokens))/avgLen)
+		score := 0.0
+		for i, ms := range matches {
+			tf := float64(len(ms))
+			score += idfs[i] * tf * (bm25K1 + 1) / (tf + norm)
+		}
+		results = append(results, scored{doc: doc, score: score, matches: matches})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].score != results[j].score {
+			return results[i].score > results[j].score
+		}
+		return store.Less(results[i].doc.msg, results[j].doc.msg, store.NewestFirst)
+	})
+
+	resp := Response{TotalSize: len(results)}
+	end := offset + store.PageSize(req.PageSize)
+	if end < len(results) {
+		resp.NextPageToken = EncodePageToken(req.Query, end)
+	} else {
+		end = len(results)
+	}
+	for _, r := range results[min(offset, end):end] {
+		resp.Hits = append(resp.Hits, Hit{
+			Message:    r.doc.msg,
+			Score:      r.score,
+			Highlights: highlight(r.doc, r.matches),
+		})
+	}
+	return resp, nil
+}
+
+// matchClause returns the matches of c per message ID.
+func (ix *Index) matchClause(c Clause) map[string][]match {
+	out := make(map[string][]match)
+	switch c.Kind {
+	case TermClause:
+		for id, positions := range ix.postings[c.Terms[0]] {
+			for _, pos := range positions {
+				out[id] = append(out[id], match{pos, 1})
+			}
+		}
+	case PrefixClause:
+		prefix := c.Terms[0]
+		i, _ := slices.BinarySearch(ix.terms, prefix)
+		for ; i < len(ix.terms) && strings.HasPrefix(ix.terms[i], prefix); i++ {
+			for id, positions := range ix.postings[ix.terms[i]] {
+				for _, pos := range positions {
+					out[id] = append(out[id], match{pos, 1})
+				}
+			}
+		}
+	case PhraseClause:
+		for id, positions := range ix.postings[c.Terms[0]] {
+			for _, pos := range positions {
+				if ix.phraseAt(id, pos, c.Terms) {
+					out[id] = append(out[id], match{pos, len(c.Terms)})
+				}
+			}
+		}
+	}
+	return out
+}
+
+// phraseAt reports whether terms occur in order from position pos.
+func (ix *Index) phraseAt(id string, pos int, terms []string) bool {
+	for i, term := range terms[1:] {
+		if _, ok := slices.BinarySearch(ix.postings[term][id], pos+i+1); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// idf is the BM25 inverse document frequency of a clause matching df messages.
+func (ix *Index) idf(df int) float64 {
+	n := float64(len(ix.docs))
+	return math.Log(1 + (n-float64(df)+0.5)/(float64(df)+0.5))
+}
+
+// highlight returns up to maxHighlights fragments of doc's text around the
+// matches, in text order.
+func highlight(doc *document, matches [][]match) []string {
+	type span struct{ start, end int }
+	var spans []span
+	for _, ms := range matches {
+		for _, m := range ms {
+			spans = append(spans, span{doc.tokens[m.pos].start, doc.tokens[m.pos+m.n-1].end})
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+	// Merge overlapping matches, then group matches whose context overlaps
+	// into fragments.
+	merged := spans[:0]
+	for _, s := range spans {
+		if n := len(merged); n > 0 && s.start <= merged[n-1].end {
+			merged[n-1].end = max(merged[n-1].end, s.end)
+			continue
+		}
+		merged = append(merged, s)
+	}
+
+	text := doc.msg.Text
+	var fragments []string
+	for i := 0; i < len(merged) && len(fragments) < maxHighlights; {
+		start := runeStartBefore(text, merged[i].start, highlightContext)
+		end := runeEndAfter(text, merged[i].end, highlightContext)
+		j := i + 1
+		for j < len(merged) && merged[j].start <= end {
+			end = runeEndAfter(text, merged[j].end, highlightContext)
+			j++
+		}
+
+		start, end = snapToTokens(doc.tokens, start, end)
+		start, end = trimSpaceRange(text, start, end)
+
+		var b strings.Builder
+		if start > 0 {
+			b.WriteString("…")
+		}
+		at := start
+		for _, s := range merged[i:j] {
+			b.WriteString(html.EscapeString(text[at:s.start]))
+			b.WriteString("<em>")
+			b.WriteString(html.EscapeString(text[s.start:s.end]))
+			b.WriteString("</em>")
+			at = s.end
+		}
+		b.WriteString(html.EscapeString(text[at:end]))
+		if end < len(text) {
+			b.WriteString("…")
+		}
+		fragments = append(fragments, b.String())
+		i = j
+	}
+	return fragments
+}
+
+// Highlights returns fragments of m's text around the matches of q, as the
+// Index does for its hits, for searchers that find matches by other means.
+func Highlights(m store.Message, q Query) []string {
+	ix := NewIndex()
+	ix.Add(m)
+	matches := make([][]match, 0, len(q.clauses))
+	for _, c := range q.clauses {
+		matches = append(matches, ix.matchClause(c)[m.ID])
+	}
+	return highlight(ix.docs[m.ID], matches)
+}
+
+// snapToTokens narrows the fragment [start, end) so that it does not cut a
+// word in half at either edge.
+func snapToTokens(tokens []token, start, end int) (int, int) {
+	i := sort.Search(len(tokens), func(i int) bool { return tokens[i].end > start })
+	if i < len(tokens) && tokens[i].start < start {
+		start = tokens[i].end
+	}
+	j := sort.Search(len(tokens), func(j int) bool { return tokens[j].end >= end })
+	if j < len(tokens) && tokens[j].start < end && tokens[j].end > end {
+		end = tokens[j].start
+	}
+	return start, end
+}
+
+// trimSpaceRange narrows [start, end) of s to exclude surrounding white space.
+func trimSpaceRange(s string, start, end int) (int, int) {
+	start += len(s[start:end]) - len(strings.TrimLeftFunc(s[start:end], unicode.IsSpace))
+	end = start + len(strings.TrimRightFunc(s[start:end], unicode.IsSpace))
+	return start, end
+}
+
+// EncodePageToken returns a token for the page of q starting at offset, for
+// searchers that page through ranked hits by offset.
+func EncodePageToken(q Query, offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(queryFingerprint(q) + ":" + strconv.Itoa(offset)))
+}
+
+// DecodePageToken returns the offset encoded in token, or 0 for no token. It
+// fails with store.ErrInvalidPageToken if token was issued for another query.
+func DecodePageToken(token string, q Query) (int, error) {
+	if token == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, store.ErrInvalidPageToken
+	}
+	fingerprint, offset, ok := strings.Cut(string(raw), ":")
+	n, err := strconv.Atoi(offset)
+	if !ok || err != nil || n < 0 || fingerprint != queryFingerprint(q) {
+		return 0, store.ErrInvalidPageToken
+	}
+	return n, nil
+}
+
+// queryFingerprint identifies the clauses of q, so equivalent spellings of a
+// query share page tokens.
+func queryFingerprint(q Query) string {
+	h := fnv.New32a()
+	for _, c := range q.clauses {
+		fmt.Fprintf(h, "%d:%s|", c.Kind, strings.Join(c.Terms, " "))
+	}
+	return strconv.FormatUint(uint64(h.Sum32()), 36)
+}
@@ -0,0 +1,224 @@
+// internal/search/index_test.go
+package search
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"your-module-name/internal/store"
+)
+
+var baseTime = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// newTestIndex indexes texts as messages m0, m1, ... created one second apart.
+func newTestIndex(texts ...string) *Index {
+	ix := NewIndex()
+	for i, text := range texts {
+		ix.Add(store.Message{ID: fmt.Sprintf("m%d", i), Text: text, CreatedAt: baseTime.Add(time.Duration(i) * time.Second)})
+	}
+	return ix
+}
+
+func search(t *testing.T, ix *Index, query string) Response {
+	t.Helper()
+	q, err := ParseQuery(query)
+	require.NoError(t, err)
+	resp, err := ix.Search(context.Background(), Request{Query: q})
+	require.NoError(t, err)
+	return resp
+}
+
+func hitIDs(resp Response) []string {
+	var ids []string
+	for _, h := range resp.Hits {
+		ids = append(ids, h.Message.ID)
+	}
+	return ids
+}
+
+func TestSearch_Matching(t *testing.T) {
+	ix := newTestIndex(
+		"hello world",
+		"world, hello",
+		"say hello to the whole world",
+		"help wanted",
+		"nothing to see",
+	)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"hello", []string{"m0", "m1", "m2"}},
+		{"HELLO world", []string{"m0", "m1", "m2"}},
+		{`"hello world"`, []string{"m0"}},
+		{`"world hello"`, []string{"m1"}},
+		{"hel*", []string{"m0", "m1", "m2", "m3"}},
+		{`wh* "to the"`, []string{"m2"}},
+		{"missing", nil},
+		{"hello missing", nil},
+	}
+	for _, tt := range tests {
+		resp := search(t, ix, tt.query)
+		assert.ElementsMatch(t, tt.want, hitIDs(resp), tt.query)
+		assert.Equal(t, len(tt.want), resp.TotalSize, tt.query)
+	}
+}
+
+func TestSearch_Ranking(t *testing.T) {
+	ix := newTestIndex(
+		"go is fun and go is fast, go go go",
+		"a long message that mentions go once among very many other words",
+		"go",
+		"go",
+	)
+	resp := search(t, ix, "go")
+	require.Len(t, resp.Hits, 4)
+	assert.Equal(t, "m0", resp.Hits[0].Message.ID, "highest term frequency first")
+	assert.Equal(t, []string{"m3", "m2"}, hitIDs(resp)[1:3], "equal scores are ordered newest first")
+	assert.Equal(t, "m1", resp.Hits[3].Message.ID, "long documents rank lower")
+	for i := 1; i < len(resp.Hits); i++ {
+		assert.GreaterOrEqual(t, resp.Hits[i-1].Score, resp.Hits[i].Score)
+	}
+
+	// Rare terms weigh more than common ones.
+	ix = newTestIndex("common rare", "common common", "common")
+	resp = search(t, ix, "common* rare*")
+	assert.Equal(t, []string{"m0"}, hitIDs(resp))
+}
+
+func TestSearch_Highlights(t *testing.T) {
+	long := strings.Repeat("filler ", 20) + "needle in a <haystack> " + strings.Repeat("padding ", 20) + "another needle here"
+	ix := newTestIndex("Hello World, hello <b>!", long)
+
+	resp := search(t, ix, "hello")
+	require.Len(t, resp.Hits, 1)
+	assert.Equal(t, []string{"<em>Hello</em> World, <em>hello</em> &lt;b&gt;!"}, resp.Hits[0].Highlights)
+
+	resp = search(t, ix, `"in a" needle`)
+	require.Len(t, resp.Hits, 1)
+	hl := resp.Hits[0].Highlights
+	require.Len(t, hl, 2, "distant matches get separate fragments")
+	assert.True(t, strings.HasPrefix(hl[0], "…filler "), hl[0])
+	assert.Contains(t, hl[0], " filler <em>needle</em> <em>in a</em> &lt;haystack&gt; padding ")
+	assert.NotContains(t, hl[0], "…r ", "fragments do not start mid-word")
+	assert.True(t, strings.HasSuffix(hl[0], " padding…"), hl[0])
+	assert.Contains(t, hl[1], "another <em>needle</em> here")
+	assert.False(t, strings.HasSuffix(hl[1], "…"), "fragment reaches the end of the text")
+}
+
+func TestSearch_Pagination(t *testing.T) {
+	var texts []string
+	for i := 0; i < 5; i++ {
+		texts = append(texts, "same text")
+	}
+	ix := newTestIndex(texts...)
+	q, err := ParseQuery("same")
+	require.NoError(t, err)
+
+	var ids []string
+	req := Request{Query: q, PageSize: 2}
+	for {
+		resp, err := ix.Search(context.Background(), req)
+		require.NoError(t, err)
+		assert.Equal(t, 5, resp.TotalSize)
+		ids = append(ids, hitIDs(resp)...)
+		if resp.NextPageToken == "" {
+			break
+		}
+		req.PageToken = resp.NextPageToken
+	}
+	assert.Equal(t, []string{"m4", "m3", "m2", "m1", "m0"}, ids)
+
+	first, err := ix.Search(context.Background(), Request{Query: q, PageSize: 2})
+	require.NoError(t, err)
+	same, _ := ParseQuery("  SAME ")
+	_, err = ix.Search(context.Background(), Request{Query: same, PageToken: first.NextPageToken})
+	assert.NoError(t, err, "equivalent queries share tokens")
+	other, _ := ParseQuery("text")
+	_, err = ix.Search(context.Background(), Request{Query: other, PageToken: first.NextPageToken})
+	assert.ErrorIs(t, err, store.ErrInvalidPageToken)
+	_, err = ix.Search(context.Background(), Request{Query: q, PageToken: "garbage!"})
+	assert.ErrorIs(t, err, store.ErrInvalidPageToken)
+}
+
+func TestIndex_AddReplaceRemove(t *testing.T) {
+	ix := newTestIndex("alpha beta", "beta gamma")
+	assert.Equal(t, 2, ix.Len())
+
+	ix.Add(store.Message{ID: "m0", Text: "delta", CreatedAt: baseTime})
+	assert.Equal(t, 2, ix.Len(), "re-adding replaces")
+	assert.Empty(t, search(t, ix, "alpha").Hits)
+	assert.Equal(t, []string{"m0"}, hitIDs(search(t, ix, "delta")))
+
+	ix.Remove("m1")
+	ix.Remove("m1")
+	assert.Empty(t, search(t, ix, "gam*").Hits)
+	assert.NotContains(t, ix.terms, "gamma", "unused terms leave the vocabulary")
+	assert.Equal(t, 1, ix.Len())
+}
+
+func TestIndexedRepository(t *testing.T) {
+	ctx := context.Background()
+	inner := store.NewMemoryRepository()
+	for i := 0; i < store.MaxPageSize+5; i++ {
+		_, err := inner.Create(ctx, store.Message{Text: "existing message"})
+		require.NoError(t, err)
+	}
+
+	repo := NewIndexedRepository(inner)
+	n, err := repo.Rebuild(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, store.MaxPageSize+5, n, "rebuild pages through the whole store")
+
+	msg, err := repo.Create(ctx, store.Message{Text: "a fresh echo"})
+	require.NoError(t, err)
+	q, _ := ParseQuery("fresh")
+	resp, err := repo.Search(ctx, Request{Query: q})
+	require.NoError(t, err)
+	assert.Equal(t, []string{msg.ID}, hitIDs(resp))
+
+	require.NoError(t, repo.Delete(ctx, msg.ID))
+	resp, err = repo.Search(ctx, Request{Query: q})
+	require.NoError(t, err)
+	assert.Empty(t, resp.Hits)
+	assert.ErrorIs(t, repo.Delete(ctx, msg.ID), store.ErrDeleted)
+	assert.NoError(t, repo.Ping(ctx))
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = repo.Search(cancelled, Request{Query: q})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestIndexedRepository_WritesOfOtherInstances(t *testing.T) {
+	ctx := context.Background()
+	shared := store.NewMemoryRepository()
+	repo := NewIndexedRepository(shared)
+	other := NewIndexedRepository(shared)
+	kept, err := repo.Create(ctx, store.Message{Text: "shared echo"})
+	require.NoError(t, err)
+	gone, err := repo.Create(ctx, store.Message{Text: "shared echo again"})
+	require.NoError(t, err)
+	_, err = other.Create(ctx, store.Message{Text: "shared echo elsewhere"})
+	require.NoError(t, err)
+
+	require.NoError(t, other.Delete(ctx, gone.ID))
+	q, _ := ParseQuery("shared")
+	resp, err := repo.Search(ctx, Request{Query: q})
+	require.NoError(t, err)
+	assert.Equal(t, []string{kept.ID}, hitIDs(resp), "deleted messages are dropped; new ones wait for a rebuild")
+	assert.Equal(t, 1, resp.TotalSize)
+
+	_, err = repo.Rebuild(ctx)
+	require.NoError(t, err)
+	resp, err = repo.Search(ctx, Request{Query: q})
+	require.NoError(t, err)
+	assert.Len(t, resp.Hits, 2)
+}
@@ -0,0 +1,113 @@
+// internal/search/query.go
+package search
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ErrInvalidQuery is returned for a query that is empty or cannot be parsed.
+var ErrInvalidQuery = errors.New("search: invalid query")
+
+// ClauseKind is the kind of a query clause.
+type ClauseKind int
+
+const (
+	TermClause   ClauseKind = iota // A single term: hello
+	PrefixClause                   // Terms starting with a prefix: hel*
+	PhraseClause                   // Consecutive terms: "hello world"
+)
+
+// Clause is one required part of a query.
+type Clause struct {
+	Kind  ClauseKind
+	Terms []string // One term, except for phrases.
+}
+
+// Query is a parsed search query. Every clause must match for a message to be
+// returned.
+type Query struct {
+	raw     string
+	clauses []Clause
+}
+
+// String returns the query as it was given to ParseQuery.
+func (q Query) String() string { return q.raw }
+
+// Clauses returns the clauses of q, for searchers that translate it into the
+// query language of a database.
+func (q Query) Clauses() []Clause { return slices.Clone(q.clauses) }
+
+// ParseQuery parses a query string. The syntax is a list of clauses separated
+// by spaces, all of which must match:
+//
+//	word       messages containing the word
+//	wor*       messages containing a word that starts with "wor"
+//	"a b c"    messages containing the words in this order
+//
+// Words are case-insensitive and split on punctuation like indexed text, so
+// hello-world is the phrase "hello world".
+func ParseQuery(s string) (Query, error) {
+	q := Query{raw: s}
+	rest := s
+	for {
+		rest = strings.TrimLeft(rest, " \t\r\n")
+		if rest == "" {
+			break
+		}
+
+		if rest[0] == '"' {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return Query{}, fmt.Errorf("%w: unterminated quote", ErrInvalidQuery)
+			}
+			terms := tokenTerms(rest[1 : end+1])
+			rest = rest[end+2:]
+			switch len(terms) {
+			case 0:
+				continue
+			case 1:
+				q.clauses = append(q.clauses, Clause{Kind: TermClause, Terms: terms})
+			default:
+				q.clauses = append(q.clauses, Clause{Kind: PhraseClause, Terms: terms})
+			}
+			continue
+		}
+
+		word := rest
+		if i := strings.IndexAny(rest, " \t\r\n\""); i >= 0 {
+			word, rest = rest[:i], rest[i:]
+		} else {
+			rest = ""
+		}
+		prefix := strings.HasSuffix(word, "*")
+		terms := tokenTerms(strings.TrimRight(word, "*"))
+		switch {
+		case len(terms) == 0:
+			continue
+		case prefix && len(terms) == 1:
+			q.clauses = append(q.clauses, Clause{Kind: PrefixClause, Terms: terms})
+		case prefix:
+			return Query{}, fmt.Errorf("%w: prefix %q spans several words", ErrInvalidQuery, word)
+		case len(terms) == 1:
+			q.clauses = append(q.clauses, Clause{Kind: TermClause, Terms: terms})
+		default:
+			q.clauses = append(q.clauses, Clause{Kind: PhraseClause, Terms: terms})
+		}
+	}
+	if len(q.clauses) == 0 {
+		return Query{}, fmt.Errorf("%w: no search terms", ErrInvalidQuery)
+	}
+	return q, nil
+}
+
+// tokenTerms returns the terms of s.
+func tokenTerms(s string) []string {
+	var terms []string
+	for _, tok := range tokenize(s) {
+		terms = append(terms, tok.term)
+	}
+	return terms
+}
@@ -0,0 +1,45 @@
+// internal/search/query_test.go
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []Clause
+	}{
+		{"Hello", []Clause{{TermClause, []string{"hello"}}}},
+		{"hel* world", []Clause{{PrefixClause, []string{"hel"}}, {TermClause, []string{"world"}}}},
+		{`"Hello,  World" again`, []Clause{{PhraseClause, []string{"hello", "world"}}, {TermClause, []string{"again"}}}},
+		{`"one"`, []Clause{{TermClause, []string{"one"}}}},
+		{"hello-world", []Clause{{PhraseClause, []string{"hello", "world"}}}},
+		{`x"quoted"`, []Clause{{TermClause, []string{"x"}}, {TermClause, []string{"quoted"}}}},
+		{"Ünïcode", []Clause{{TermClause, []string{"ünïcode"}}}},
+	}
+	for _, tt := range tests {
+		q, err := ParseQuery(tt.in)
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, q.clauses, tt.in)
+		assert.Equal(t, tt.in, q.String())
+	}
+
+	for _, bad := range []string{"", "   ", `"unterminated`, "*", `""`, "!!!", "a-b*"} {
+		_, err := ParseQuery(bad)
+		assert.ErrorIs(t, err, ErrInvalidQuery, "%q", bad)
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	toks := tokenize("It's 9am — café!")
+	var terms []string
+	for _, tok := range toks {
+		terms = append(terms, tok.term)
+	}
+	assert.Equal(t, []string{"it", "s", "9am", "café"}, terms)
+	assert.Equal(t, "café", "It's 9am — café!"[toks[3].start:toks[3].end])
+}
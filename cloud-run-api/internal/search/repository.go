@@ -0,0 +1,104 @@
+// internal/search/repository.go
+package search
+
+import (
+	"context"
+	"errors"
+
+	"your-module-name/internal/store"
+)
+
+// Searcher searches stored messages.
+type Searcher interface {
+	Search(ctx context.Context, req Request) (Response, error)
+}
+
+// IndexedRepository is a store.MessageRepository that keeps an Index in sync
+// with the messages it creates and deletes. The index is per instance: writes
+// that bypass it, such as those of other instances sharing a database, are
+// only picked up by Rebuild, except that Search checks its hits against the
+// repository and drops messages deleted or gone since they were indexed. It
+// suits stores that are per instance too; the database stores implement
+// Searcher themselves.
+type IndexedRepository struct {
+	store.MessageRepository
+	Index *Index
+}
+
+// NewIndexedRepository wraps repo with an empty index. Call Rebuild to index
+// the messages repo already holds.
+func NewIndexedRepository(repo store.MessageRepository) *IndexedRepository {
+	return &IndexedRepository{MessageRepository: repo, Index: NewIndex()}
+}
+
+// Rebuild replaces the index contents with every message in the repository
+// and returns how many were indexed.
+func (r *IndexedRepository) Rebuild(ctx context.Context) (int, error) {
+	r.Index.reset()
+	opts := store.ListOptions{PageSize: store.MaxPageSize, Order: store.OldestFirst}
+	n := 0
+	for {
+		page, err := r.MessageRepository.List(ctx, opts)
+		if err != nil {
+			return n, err
+		}
+		for _, m := range page.Messages {
+			r.Index.Add(m)
+		}
+		n += len(page.Messages)
+		if page.NextPageToken == "" {
+			return n, nil
+		}
+		opts.PageToken = page.NextPageToken
+	}
+}
+
+// Create implements store.MessageRepository.
+func (r *IndexedRepository) Create(ctx context.Context, msg store.Message) (store.Message, error) {
+	msg, err := r.MessageRepository.Create(ctx, msg)
+	if err != nil {
+		return msg, err
+	}
+	r.Index.Add(msg)
+	return msg, nil
+}
+
+// Delete implements store.MessageRepository.
+func (r *IndexedRepository) Delete(ctx context.Context, id string) error {
+	err := r.MessageRepository.Delete(ctx, id)
+	if err == nil || errors.Is(err, store.ErrDeleted) {
+		r.Index.Remove(id)
+	}
+	return err
+}
+
+// Ping implements store.Pinger by pinging the wrapped repository, if it can be.
+func (r *IndexedRepository) Ping(ctx context.Context) error {
+	if pinger, ok := r.MessageRepository.(store.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// Search implements Searcher. Hits whose messages the repository no longer
+// holds are dropped from the page, and from TotalSize, but left in the
+// index, so that page tokens keep their offsets.
+func (r *IndexedRepository) Search(ctx context.Context, req Request) (Response, error) {
+	res, err := r.Index.Search(ctx, req)
+	if err != nil {
+		return res, err
+	}
+	hits := res.Hits[:0]
+	for _, hit := range res.Hits {
+		if _, err := r.MessageRepository.Get(ctx, hit.Message.ID); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				res.TotalSize--
+				continue
+			}
+			return Response{}, err
+		}
+		hits = append(hits, hit)
+	}
+	res.Hits = hits
+	return res, nil
+}
@@ -0,0 +1,17 @@
+// internal/search/repository_test.go
+package search_test
+
+import (
+	"testing"
+
+	"your-module-name/internal/search"
+	"your-module-name/internal/search/searchtest"
+	"your-module-name/internal/store"
+)
+
+func TestIndexedRepository(t *testing.T) {
+	searchtest.RunConformance(t, func(t *testing.T) (store.MessageRepository, search.Searcher) {
+		repo := search.NewIndexedRepository(store.NewMemoryRepository())
+		return repo, repo
+	})
+}
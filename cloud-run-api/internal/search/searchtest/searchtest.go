@@ -0,0 +1,176 @@
+// internal/search/searchtest/searchtest.go
+//
+// Package searchtest provides a conformance suite for search.Searcher
+// implementations, so the in-process index and the database full-text
+// searches answer queries alike.
+package searchtest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"your-module-name/internal/search"
+	"your-module-name/internal/store"
+)
+
+// Factory returns a new, empty repository and a searcher over the messages
+// written to it. It is called once per subtest and should register any
+// cleanup with t.Cleanup.
+type Factory func(t *testing.T) (store.MessageRepository, search.Searcher)
+
+// RunConformance runs the Searcher contract tests against searchers
+// returned by newSearcher.
+func RunConformance(t *testing.T, newSearcher Factory) {
+	t.Helper()
+	for _, tc := range []struct {
+		name string
+		fn   func(*testing.T, store.MessageRepository, search.Searcher)
+	}{
+		{"Matching", testMatching},
+		{"Ranking", testRanking},
+		{"Highlights", testHighlights},
+		{"DeletedMessages", testDeletedMessages},
+		{"Pagination", testPagination},
+		{"InvalidPageToken", testInvalidPageToken},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, searcher := newSearcher(t)
+			tc.fn(t, repo, searcher)
+		})
+	}
+}
+
+// baseTime is a fixed, microsecond-precision reference time.
+var baseTime = time.Date(2025, 6, 1, 12, 0, 0, 123456000, time.UTC)
+
+// seed creates messages m0, m1, ... with the given texts, one second apart.
+func seed(t *testing.T, repo store.MessageRepository, texts ...string) {
+	t.Helper()
+	for i, text := range texts {
+		_, err := repo.Create(context.Background(), store.Message{
+			ID:        fmt.Sprintf("m%d", i),
+			Text:      text,
+			CreatedAt: baseTime.Add(time.Duration(i) * time.Second),
+		})
+		require.NoError(t, err)
+	}
+}
+
+// run searches for query and returns the response.
+func run(t *testing.T, searcher search.Searcher, query string, pageSize int, pageToken string) search.Response {
+	t.Helper()
+	q, err := search.ParseQuery(query)
+	require.NoError(t, err)
+	resp, err := searcher.Search(context.Background(), search.Request{Query: q, PageSize: pageSize, PageToken: pageToken})
+	require.NoError(t, err)
+	return resp
+}
+
+func hitIDs(resp search.Response) []string {
+	ids := []string{}
+	for _, h := range resp.Hits {
+		ids = append(ids, h.Message.ID)
+	}
+	return ids
+}
+
+func testMatching(t *testing.T, repo store.MessageRepository, searcher search.Searcher) {
+	seed(t, repo,
+		"hello world",
+		"world, hello",
+		"say hello to the whole world",
+		"help wanted",
+		"nothing to see",
+	)
+	for _, tc := range []struct {
+		query string
+		want  []string
+	}{
+		{"hello", []string{"m0", "m1", "m2"}},
+		{"HELLO world", []string{"m0", "m1", "m2"}},
+		{`"hello world"`, []string{"m0"}},
+		{"hel*", []string{"m0", "m1", "m2", "m3"}},
+		{"hello wanted", []string{}},
+		{"goodbye", []string{}},
+	} {
+		t.Run(tc.query, func(t *testing.T) {
+			resp := run(t, searcher, tc.query, 0, "")
+			assert.ElementsMatch(t, tc.want, hitIDs(resp))
+			assert.Equal(t, len(tc.want), resp.TotalSize)
+			assert.Empty(t, resp.NextPageToken)
+		})
+	}
+}
+
+func testRanking(t *testing.T, repo store.MessageRepository, searcher search.Searcher) {
+	seed(t, repo,
+		"one apple among many other words in a long sentence",
+		"apple apple apple",
+		"apple pie",
+		"apple pie",
+	)
+	resp := run(t, searcher, "apple", 0, "")
+	ids := hitIDs(resp)
+	require.Len(t, ids, 4)
+	assert.Equal(t, "m1", ids[0], "more occurrences rank higher")
+	assert.Equal(t, []string{"m3", "m2"}, ids[1:3], "ties are broken by recency")
+	for i, h := range resp.Hits {
+		assert.Positive(t, h.Score)
+		if i > 0 {
+			assert.LessOrEqual(t, h.Score, resp.Hits[i-1].Score, "hits are ordered by score")
+		}
+	}
+}
+
+func testHighlights(t *testing.T, repo store.MessageRepository, searcher search.Searcher) {
+	seed(t, repo, "Say <hello> to the world")
+	resp := run(t, searcher, "hello", 0, "")
+	require.Len(t, resp.Hits, 1)
+	assert.Equal(t, []string{"Say &lt;<em>hello</em>&gt; to the world"}, resp.Hits[0].Highlights)
+	assert.Equal(t, "Say <hello> to the world", resp.Hits[0].Message.Text)
+	assert.Equal(t, baseTime, resp.Hits[0].Message.CreatedAt.UTC())
+}
+
+func testDeletedMessages(t *testing.T, repo store.MessageRepository, searcher search.Searcher) {
+	seed(t, repo, "hello one", "hello two")
+	require.NoError(t, repo.Delete(context.Background(), "m0"))
+	resp := run(t, searcher, "hello", 0, "")
+	assert.Equal(t, []string{"m1"}, hitIDs(resp))
+	assert.Equal(t, 1, resp.TotalSize)
+}
+
+func testPagination(t *testing.T, repo store.MessageRepository, searcher search.Searcher) {
+	seed(t, repo, "hello", "hello", "hello", "hello", "hello")
+	var ids []string
+	token := ""
+	for page := 0; ; page++ {
+		require.Less(t, page, 5, "pagination does not end")
+		resp := run(t, searcher, "hello", 2, token)
+		assert.Equal(t, 5, resp.TotalSize)
+		assert.LessOrEqual(t, len(resp.Hits), 2)
+		ids = append(ids, hitIDs(resp)...)
+		if resp.NextPageToken == "" {
+			break
+		}
+		token = resp.NextPageToken
+	}
+	assert.Equal(t, []string{"m4", "m3", "m2", "m1", "m0"}, ids)
+}
+
+func testInvalidPageToken(t *testing.T, repo store.MessageRepository, searcher search.Searcher) {
+	seed(t, repo, "hello", "hello", "world")
+	resp := run(t, searcher, "hello", 1, "")
+	require.NotEmpty(t, resp.NextPageToken)
+
+	other, err := search.ParseQuery("world")
+	require.NoError(t, err)
+	for _, token := range []string{resp.NextPageToken, "not-a-token"} {
+		_, err = searcher.Search(context.Background(), search.Request{Query: other, PageToken: token})
+		assert.ErrorIs(t, err, store.ErrInvalidPageToken)
+	}
+}
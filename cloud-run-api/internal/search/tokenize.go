@@ -0,0 +1,52 @@
+// internal/search/tokenize.go
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// token is a term and its byte offsets in the tokenized text.
+type token struct {
+	term       string
+	start, end int
+}
+
+// tokenize splits s into lower-cased runs of letters and digits.
+func tokenize(s string) []token {
+	var toks []token
+	start := -1
+	for i, r := range s {
+		word := unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+		switch {
+		case word && start < 0:
+			start = i
+		case !word && start >= 0:
+			toks = append(toks, token{term: strings.ToLower(s[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		toks = append(toks, token{term: strings.ToLower(s[start:]), start: start, end: len(s)})
+	}
+	return toks
+}
+
+// runeStartBefore returns the offset of the rune n runes before i in s.
+func runeStartBefore(s string, i, n int) int {
+	for ; n > 0 && i > 0; n-- {
+		_, size := utf8.DecodeLastRuneInString(s[:i])
+		i -= size
+	}
+	return i
+}
+
+// runeEndAfter returns the offset n runes after i in s.
+func runeEndAfter(s string, i, n int) int {
+	for ; n > 0 && i < len(s); n-- {
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+	}
+	return i
+}
@@ -0,0 +1,171 @@
+// internal/store/memory.go
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryRepository is an in-memory MessageRepository. Messages are lost when
+// the process exits, which makes it suitable for tests and single-instance
+// development.
+//
+// MaxMessages and MaxBytes bound the memory it holds: once a new message
+// would exceed either, the oldest messages and tombstones are evicted, and
+// read as not found. Set them, and OnEvict, before first use.
+type MemoryRepository struct {
+	// MaxMessages caps the messages and tombstones kept. Zero means no limit.
+	MaxMessages int
+	// MaxBytes caps the total size of the messages kept, counting their
+	// IDs, text, caller and trace ID. Zero means no limit.
+	MaxBytes int
+	// OnEvict, if set, is called with each evicted message, with the
+	// repository locked.
+	OnEvict func(Message)
+
+	mu       sync.RWMutex
+	messages map[string]Message
+	deleted  map[string]struct{} // Tombstones of deleted messages.
+	order    []string            // IDs of messages and tombstones, oldest first.
+	bytes    int
+}
+
+// NewMemoryRepository returns an empty in-memory repository.
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		messages: make(map[string]Message),
+		deleted:  make(map[string]struct{}),
+	}
+}
+
+// Create implements MessageRepository.
+func (r *MemoryRepository) Create(ctx context.Context, msg Message) (Message, error) {
+	if err := ctx.Err(); err != nil {
+		return Message{}, err
+	}
+	if msg.ID == "" {
+		msg.ID = NewID()
+	}
+	if msg.CreatedAt.IsZero() {
+		msg.CreatedAt = time.Now()
+	}
+	msg.CreatedAt = msg.CreatedAt.UTC()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if old, ok := r.messages[msg.ID]; ok {
+		r.bytes -= messageSize(old)
+	} else if _, ok := r.deleted[msg.ID]; ok {
+		delete(r.deleted, msg.ID)
+	} else {
+		r.order = append(r.order, msg.ID)
+	}
+	r.messages[msg.ID] = msg
+	r.bytes += messageSize(msg)
+	r.evict(msg.ID)
+	return msg, nil
+}
+
+// evict removes the oldest entries other than keep until r is within its
+// limits. A message larger than MaxBytes on its own is kept, alone, until
+// the next one is created. The caller must hold r.mu.
+func (r *MemoryRepository) evict(keep string) {
+	over := func() bool {
+		return r.MaxMessages > 0 && len(r.order) > r.MaxMessages ||
+			r.MaxBytes > 0 && r.bytes > r.MaxBytes
+	}
+	for over() && len(r.order) > 1 {
+		id := r.order[0]
+		if id == keep {
+			// Recreated under an old ID: it is now the newest.
+			r.order = append(r.order[1:], id)
+			continue
+		}
+		r.order = r.order[1:]
+		delete(r.deleted, id)
+		if msg, ok := r.messages[id]; ok {
+			delete(r.messages, id)
+			r.bytes -= messageSize(msg)
+			if r.OnEvict != nil {
+				r.OnEvict(msg)
+			}
+		}
+	}
+}
+
+// messageSize approximates the memory held by msg.
+func messageSize(msg Message) int {
+	return len(msg.ID) + len(msg.Text) + len(msg.Caller) + len(msg.TraceID)
+}
+
+// Get implements MessageRepository.
+func (r *MemoryRepository) Get(ctx context.Context, id string) (Message, error) {
+	if err := ctx.Err(); err != nil {
+		return Message{}, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	msg, ok := r.messages[id]
+	if !ok {
+		return Message{}, r.missing(id)
+	}
+	return msg, nil
+}
+
+// List implements MessageRepository.
+func (r *MemoryRepository) List(ctx context.Context, opts ListOptions) (ListResult, error) {
+	if err := ctx.Err(); err != nil {
+		return ListResult{}, err
+	}
+	cursor, err := opts.Cursor()
+	if err != nil {
+		return ListResult{}, err
+	}
+
+	r.mu.RLock()
+	all := make([]Message, 0, len(r.messages))
+	for _, m := range r.messages {
+		if opts.Matches(m) && (cursor == nil || cursor.After(m, opts.Order)) {
+			all = append(all, m)
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(all, func(i, j int) bool { return Less(all[i], all[j], opts.Order) })
+
+	size := PageSize(opts.PageSize)
+	var result ListResult
+	if len(all) > size {
+		all = all[:size]
+		result.NextPageToken = EncodePageToken(all[size-1], opts)
+	}
+	result.Messages = all
+	return result, nil
+}
+
+// Delete implements MessageRepository.
+func (r *MemoryRepository) Delete(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.messages[id]; !ok {
+		return r.missing(id)
+	}
+	r.bytes -= messageSize(r.messages[id])
+	delete(r.messages, id)
+	r.deleted[id] = struct{}{}
+	return nil
+}
+
+// missing returns the error for an ID that is not in r.messages.
+// The caller must hold r.mu.
+func (r *MemoryRepository) missing(id string) error {
+	if _, ok := r.deleted[id]; ok {
+		return ErrDeleted
+	}
+	return ErrNotFound
+}
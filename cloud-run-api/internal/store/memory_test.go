@@ -0,0 +1,79 @@
+// internal/store/memory_test.go
+package store_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"your-module-name/internal/store"
+	"your-module-name/internal/store/storetest"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	storetest.RunConformance(t, func(t *testing.T) store.MessageRepository {
+		return store.NewMemoryRepository()
+	})
+}
+
+func TestMemoryRepository_Limits(t *testing.T) {
+	ctx := context.Background()
+	create := func(repo *store.MemoryRepository, id, text string) {
+		t.Helper()
+		_, err := repo.Create(ctx, store.Message{ID: id, Text: text})
+		require.NoError(t, err)
+	}
+	ids := func(repo *store.MemoryRepository) []string {
+		t.Helper()
+		page, err := repo.List(ctx, store.ListOptions{Order: store.OldestFirst})
+		require.NoError(t, err)
+		var ids []string
+		for _, m := range page.Messages {
+			ids = append(ids, m.ID)
+		}
+		return ids
+	}
+
+	t.Run("MaxMessages", func(t *testing.T) {
+		repo := store.NewMemoryRepository()
+		repo.MaxMessages = 3
+		var evicted []string
+		repo.OnEvict = func(m store.Message) { evicted = append(evicted, m.ID) }
+
+		create(repo, "a", "x")
+		create(repo, "b", "x")
+		create(repo, "c", "x")
+		require.NoError(t, repo.Delete(ctx, "b"))
+		create(repo, "d", "x")
+		assert.Equal(t, []string{"c", "d"}, ids(repo), "the oldest message went first")
+		assert.Equal(t, []string{"a"}, evicted)
+		_, err := repo.Get(ctx, "a")
+		assert.ErrorIs(t, err, store.ErrNotFound)
+		assert.NotErrorIs(t, err, store.ErrDeleted)
+		_, err = repo.Get(ctx, "b")
+		assert.ErrorIs(t, err, store.ErrDeleted, "tombstones count too")
+
+		create(repo, "e", "x")
+		_, err = repo.Get(ctx, "b")
+		assert.NotErrorIs(t, err, store.ErrDeleted, "the tombstone was evicted")
+		assert.Equal(t, []string{"a"}, evicted, "evicting a tombstone evicts no message")
+	})
+
+	t.Run("MaxBytes", func(t *testing.T) {
+		repo := store.NewMemoryRepository()
+		repo.MaxBytes = 30
+		create(repo, "a", strings.Repeat("x", 10))
+		create(repo, "b", strings.Repeat("x", 10))
+		assert.Equal(t, []string{"a", "b"}, ids(repo))
+		create(repo, "c", strings.Repeat("x", 10))
+		assert.Equal(t, []string{"b", "c"}, ids(repo))
+
+		create(repo, "d", strings.Repeat("x", 100))
+		assert.Equal(t, []string{"d"}, ids(repo), "an oversized message is kept alone")
+		create(repo, "e", "x")
+		assert.Equal(t, []string{"e"}, ids(repo))
+	})
+}
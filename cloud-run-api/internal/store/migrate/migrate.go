@@ -0,0 +1,192 @@
+// internal/store/migrate/migrate.go
+//
+// Package migrate applies versioned SQL schema migrations, typically embedded
+// with embed.FS, to a database/sql database. Migrations are files named
+// NNNN_description.sql, applied in lexical order, each in its own
+// transaction, and recorded in a schema_migrations table. Options.Lock lets
+// several processes migrate the same database at once.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Options configure Run.
+type Options struct {
+	// Placeholder returns the bind parameter for the n-th (1-based) argument,
+	// e.g. "?" for SQLite or "$1" for PostgreSQL. Defaults to "?".
+	Placeholder func(n int) string
+	// Lock is a statement that takes a lock held until the end of its
+	// transaction, such as "SELECT pg_advisory_xact_lock(1)" on PostgreSQL.
+	// Every migration then runs under the lock and is skipped if another run
+	// applied it meanwhile, so that each is applied once when several
+	// instances start at the same time. Empty runs without coordination,
+	// which suits databases used by a single process.
+	Lock string
+}
+
+// Migration is a single schema migration.
+type Migration struct {
+	Version string // File name without the .sql extension, e.g. "0001_create_messages".
+	SQL     string
+}
+
+// Load reads all *.sql files in dir of fsys, sorted by version.
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("migrate: failed to read %s: %w", dir, err)
+	}
+	var migrations []Migration
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".sql") {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("migrate: failed to read %s: %w", e.Name(), err)
+		}
+		migrations = append(migrations, Migration{
+			Version: strings.TrimSuffix(e.Name(), ".sql"),
+			SQL:     string(data),
+		})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Run applies every migration in dir of fsys that has not been applied yet
+// and returns the versions it applied.
+func Run(ctx context.Context, db *sql.DB, fsys fs.FS, dir string, opts Options) ([]string, error) {
+	if opts.Placeholder == nil {
+		opts.Placeholder = func(int) string { return "?" }
+	}
+	migrations, err := Load(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := createTable(ctx, db, opts); err != nil {
+		return nil, err
+	}
+
+	done, err := appliedVersions(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []string
+	for _, m := range migrations {
+		if done[m.Version] {
+			continue
+		}
+		ok, err := apply(ctx, db, m, opts)
+		if err != nil {
+			return applied, err
+		}
+		if ok {
+			applied = append(applied, m.Version)
+		}
+	}
+	return applied, nil
+}
+
+// createTable creates schema_migrations if it does not exist yet. It runs
+// under the lock, as concurrent CREATE TABLE IF NOT EXISTS statements can
+// conflict on PostgreSQL.
+func createTable(ctx context.Context, db *sql.DB, opts Options) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("migrate: failed to begin: %w", err)
+	}
+	defer tx.Rollback() // No-op after Commit.
+
+	if err := lock(ctx, tx, opts); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version TEXT PRIMARY KEY,
+		applied_at TEXT NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("migrate: failed to create schema_migrations: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("migrate: failed to create schema_migrations: %w", err)
+	}
+	return nil
+}
+
+// lock runs opts.Lock, if any, in tx.
+func lock(ctx context.Context, tx *sql.Tx, opts Options) error {
+	if opts.Lock == "" {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, opts.Lock); err != nil {
+		return fmt.Errorf("migrate: failed to take the migration lock: %w", err)
+	}
+	return nil
+}
+
+// querier is implemented by *sql.DB and *sql.Tx.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func appliedVersions(ctx context.Context, db querier) (map[string]bool, error) {
+	rows, err := db.QueryContext(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("migrate: failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+	done := make(map[string]bool)
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, fmt.Errorf("migrate: failed to scan schema_migrations: %w", err)
+		}
+		done[v] = true
+	}
+	return done, rows.Err()
+}
+
+// apply applies m and reports whether it did, which it does not if another
+// run applied it while this one waited for the lock.
+func apply(ctx context.Context, db *sql.DB, m Migration, opts Options) (bool, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("migrate: failed to begin %s: %w", m.Version, err)
+	}
+	defer tx.Rollback() // No-op after Commit.
+
+	if opts.Lock != "" {
+		if err := lock(ctx, tx, opts); err != nil {
+			return false, err
+		}
+		done, err := appliedVersions(ctx, tx)
+		if err != nil {
+			return false, err
+		}
+		if done[m.Version] {
+			return false, nil
+		}
+	}
+	if _, err := tx.ExecContext(ctx, m.SQL); err != nil {
+		return false, fmt.Errorf("migrate: %s failed: %w", m.Version, err)
+	}
+	insert := fmt.Sprintf(`INSERT INTO schema_migrations (version, applied_at) VALUES (%s, %s)`,
+		opts.Placeholder(1), opts.Placeholder(2))
+	if _, err := tx.ExecContext(ctx, insert, m.Version, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		return false, fmt.Errorf("migrate: failed to record %s: %w", m.Version, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("migrate: failed to commit %s: %w", m.Version, err)
+	}
+	return true, nil
+}
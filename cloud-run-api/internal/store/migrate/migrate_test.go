@@ -0,0 +1,63 @@
+// internal/store/migrate/migrate_test.go
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", "file::memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	fsys := fstest.MapFS{
+		"m/0002_add_index.sql": {Data: []byte(`CREATE INDEX t_name ON t (name);`)},
+		"m/0001_create_t.sql":  {Data: []byte(`CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT);`)},
+		"m/README.md":          {Data: []byte(`not a migration`)},
+	}
+
+	applied, err := Run(ctx, db, fsys, "m", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0001_create_t", "0002_add_index"}, applied)
+
+	// Running again is a no-op.
+	applied, err = Run(ctx, db, fsys, "m", Options{})
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+
+	var n int
+	require.NoError(t, db.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_migrations`).Scan(&n))
+	assert.Equal(t, 2, n)
+}
+
+func TestRun_FailedMigrationIsRolledBack(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	fsys := fstest.MapFS{
+		"m/0001_create_t.sql": {Data: []byte(`CREATE TABLE t (id INTEGER PRIMARY KEY);`)},
+		"m/0002_broken.sql":   {Data: []byte(`CREATE TABLE u (id INTEGER); NOT VALID SQL;`)},
+	}
+
+	applied, err := Run(ctx, db, fsys, "m", Options{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "0002_broken")
+	assert.Equal(t, []string{"0001_create_t"}, applied)
+
+	var n int
+	require.NoError(t, db.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_migrations WHERE version = '0002_broken'`).Scan(&n))
+	assert.Zero(t, n)
+}
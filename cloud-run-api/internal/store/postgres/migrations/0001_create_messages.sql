@@ -0,0 +1,10 @@
+-- Echoed messages, listed newest first.
+CREATE TABLE messages (
+    id         TEXT PRIMARY KEY,
+    text       TEXT NOT NULL,
+    caller     TEXT NOT NULL DEFAULT '',
+    trace_id   TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX messages_created_at_id ON messages (created_at DESC, id DESC);
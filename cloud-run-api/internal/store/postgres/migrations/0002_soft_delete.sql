@@ -0,0 +1,4 @@
+-- Deleted messages are kept as tombstones so they can be reported as gone.
+ALTER TABLE messages ADD COLUMN deleted_at TIMESTAMPTZ;
+
+CREATE INDEX messages_caller_created_at_id ON messages (caller, created_at DESC, id DESC);
@@ -0,0 +1,4 @@
+-- Full-text index over message text, searched by Repository.Search. The
+-- simple configuration lower-cases words without stemming them or dropping
+-- stop words, as the in-process index does.
+CREATE INDEX messages_text_search ON messages USING GIN (to_tsvector('simple', text));
@@ -0,0 +1,319 @@
+// internal/store/postgres/postgres.go
+//
+// Package postgres implements store.MessageRepository on PostgreSQL (including
+// Cloud SQL) using the pgx driver through database/sql, which provides the
+// connection pool. Every operation runs in a serializable transaction whose
+// statement timeout is derived from the caller's context, and transactions
+// aborted by serialization failures or deadlocks are retried.
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib" // Registers the "pgx" database/sql driver.
+
+	"your-module-name/internal/store"
+	"your-module-name/internal/store/migrate"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Options configure Open.
+type Options struct {
+	// DSN is a libpq connection string or postgres:// URL. When set, the
+	// connection fields below are ignored.
+	DSN string
+	// CloudSQLInstance ("project:region:instance") connects through the Unix
+	// socket that Cloud Run mounts under /cloudsql.
+	CloudSQLInstance string
+	User             string
+	Password         string
+	Database         string
+
+	// Pool settings. Zero values use the defaults noted.
+	MaxOpenConns    int           // Default 4.
+	MaxIdleConns    int           // Default 2.
+	ConnMaxLifetime time.Duration // Default 30m.
+
+	// StatementTimeout bounds each statement. A shorter context deadline takes
+	// precedence. Default 5s.
+	StatementTimeout time.Duration
+	// MaxRetries is how often a transaction aborted by a serialization failure
+	// or deadlock is retried. Zero, unlike the settings above, disables
+	// retries rather than selecting a default.
+	MaxRetries int
+}
+
+// CloudSQLSocketDir is where Cloud Run mounts Cloud SQL Unix sockets.
+const CloudSQLSocketDir = "/cloudsql"
+
+// ConnString returns the connection string described by o.
+func (o Options) ConnString() (string, error) {
+	if o.DSN != "" {
+		return o.DSN, nil
+	}
+	if o.CloudSQLInstance == "" {
+		return "", errors.New("postgres: a DSN or Cloud SQL instance is required")
+	}
+	params := []string{"host=" + quoteParam(CloudSQLSocketDir+"/"+o.CloudSQLInstance)}
+	for _, p := range [][2]string{{"user", o.User}, {"password", o.Password}, {"dbname", o.Database}} {
+		if p[1] != "" {
+			params = append(params, p[0]+"="+quoteParam(p[1]))
+		}
+	}
+	return strings.Join(params, " "), nil
+}
+
+// quoteParam quotes a keyword/value connection string value.
+func quoteParam(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `'`, `\'`)
+	return "'" + s + "'"
+}
+
+// Repository is a PostgreSQL-backed store.MessageRepository.
+type Repository struct {
+	db               *sql.DB
+	statementTimeout time.Duration
+	maxRetries       int
+}
+
+var _ store.MessageRepository = (*Repository)(nil)
+var _ store.Pinger = (*Repository)(nil)
+
+// Open connects to the database described by opts. It does not apply
+// migrations; call Migrate before use.
+func Open(ctx context.Context, opts Options) (*Repository, error) {
+	dsn, err := opts.ConnString()
+	if err != nil {
+		return nil, err
+	}
+	if opts.MaxOpenConns <= 0 {
+		opts.MaxOpenConns = 4
+	}
+	if opts.MaxIdleConns <= 0 {
+		opts.MaxIdleConns = 2
+	}
+	if opts.ConnMaxLifetime <= 0 {
+		opts.ConnMaxLifetime = 30 * time.Minute
+	}
+	if opts.StatementTimeout <= 0 {
+		opts.StatementTimeout = 5 * time.Second
+	}
+
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: failed to open database: %w", err)
+	}
+	db.SetMaxOpenConns(opts.MaxOpenConns)
+	db.SetMaxIdleConns(min(opts.MaxIdleConns, opts.MaxOpenConns))
+	db.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("postgres: failed to connect: %w", err)
+	}
+	return &Repository{
+		db:               db,
+		statementTimeout: opts.StatementTimeout,
+		maxRetries:       max(opts.MaxRetries, 0),
+	}, nil
+}
+
+// migrationLockKey is the key of the transaction-level advisory lock under
+// which migrations run, an arbitrary constant.
+const migrationLockKey = 7340982168402651697
+
+// Migrate applies pending schema migrations and returns their versions.
+// Concurrent runs against the same database, such as instances starting with
+// DB_MIGRATE_ON_START, wait for each other and apply every migration once.
+func (r *Repository) Migrate(ctx context.Context) ([]string, error) {
+	return migrate.Run(ctx, r.db, migrations, "migrations", migrate.Options{
+		Placeholder: func(n int) string { return "$" + strconv.Itoa(n) },
+		Lock:        fmt.Sprintf("SELECT pg_advisory_xact_lock(%d)", migrationLockKey),
+	})
+}
+
+// Ping implements store.Pinger.
+func (r *Repository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
+
+// Close closes the connection pool.
+func (r *Repository) Close() error {
+	return r.db.Close()
+}
+
+// Create implements store.MessageRepository. PostgreSQL stores timestamps
+// with microsecond precision, so CreatedAt is truncated accordingly.
+func (r *Repository) Create(ctx context.Context, msg store.Message) (store.Message, error) {
+	if msg.ID == "" {
+		msg.ID = store.NewID()
+	}
+	if msg.CreatedAt.IsZero() {
+		msg.CreatedAt = time.Now()
+	}
+	msg.CreatedAt = msg.CreatedAt.UTC().Truncate(time.Microsecond)
+
+	err := r.inTx(ctx, false, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO messages (id, text, caller, trace_id, created_at) VALUES ($1, $2, $3, $4, $5)`,
+			msg.ID, msg.Text, msg.Caller, msg.TraceID, msg.CreatedAt)
+		return err
+	})
+	if err != nil {
+		return store.Message{}, fmt.Errorf("postgres: failed to insert message: %w", err)
+	}
+	return msg, nil
+}
+
+// Get implements store.MessageRepository.
+func (r *Repository) Get(ctx context.Context, id string) (store.Message, error) {
+	var (
+		msg       store.Message
+		deletedAt sql.NullTime
+	)
+	err := r.inTx(ctx, true, func(tx *sql.Tx) error {
+		row := tx.QueryRowContext(ctx,
+			`SELECT id, text, caller, trace_id, created_at, deleted_at FROM messages WHERE id = $1`, id)
+		var err error
+		msg, err = scanMessage(row, &deletedAt)
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return store.Message{}, store.ErrNotFound
+	}
+	if err != nil {
+		return store.Message{}, fmt.Errorf("postgres: failed to get message: %w", err)
+	}
+	if deletedAt.Valid {
+		return store.Message{}, store.ErrDeleted
+	}
+	return msg, nil
+}
+
+// List implements store.MessageRepository.
+func (r *Repository) List(ctx context.Context, opts store.ListOptions) (store.ListResult, error) {
+	cursor, err := opts.Cursor()
+	if err != nil {
+		return store.ListResult{}, err
+	}
+
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	query := `SELECT id, text, caller, trace_id, created_at FROM messages WHERE deleted_at IS NULL`
+	if opts.Caller != "" {
+		query += ` AND caller = ` + arg(opts.Caller)
+	}
+	if !opts.Since.IsZero() {
+		query += ` AND created_at >= ` + arg(opts.Since.UTC())
+	}
+	if !opts.Before.IsZero() {
+		query += ` AND created_at < ` + arg(opts.Before.UTC())
+	}
+	dir, cmp := "DESC", "<"
+	if opts.Order == store.OldestFirst {
+		dir, cmp = "ASC", ">"
+	}
+	if cursor != nil {
+		at := arg(cursor.CreatedAt)
+		query += fmt.Sprintf(` AND (created_at %s %s OR (created_at = %s AND id %s %s))`, cmp, at, at, cmp, arg(cursor.ID))
+	}
+	// Fetch one extra row to know whether there is a next page.
+	size := store.PageSize(opts.PageSize)
+	query += fmt.Sprintf(` ORDER BY created_at %s, id %s LIMIT %s`, dir, dir, arg(size+1))
+
+	var result store.ListResult
+	err = r.inTx(ctx, true, func(tx *sql.Tx) error {
+		result = store.ListResult{}
+		rows, err := tx.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			msg, err := scanMessage(rows, nil)
+			if err != nil {
+				return err
+			}
+			result.Messages = append(result.Messages, msg)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return store.ListResult{}, fmt.Errorf("postgres: failed to list messages: %w", err)
+	}
+	if len(result.Messages) > size {
+		result.Messages = result.Messages[:size]
+		result.NextPageToken = store.EncodePageToken(result.Messages[size-1], opts)
+	}
+	return result, nil
+}
+
+// Delete implements store.MessageRepository. The row is kept as a tombstone.
+func (r *Repository) Delete(ctx context.Context, id string) error {
+	err := r.inTx(ctx, false, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx,
+			`UPDATE messages SET deleted_at = $1 WHERE id = $2 AND deleted_at IS NULL`, time.Now().UTC(), id)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil || n > 0 {
+			return err
+		}
+		// Nothing updated: the message is missing or already deleted.
+		var deletedAt sql.NullTime
+		err = tx.QueryRowContext(ctx, `SELECT deleted_at FROM messages WHERE id = $1`, id).Scan(&deletedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return store.ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		return store.ErrDeleted
+	})
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("postgres: failed to delete message: %w", err)
+	}
+	return err
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanMessage scans a message row. If deletedAt is non-nil, the row has a
+// trailing deleted_at column that is scanned into it.
+func scanMessage(s scanner, deletedAt *sql.NullTime) (store.Message, error) {
+	var msg store.Message
+	dest := []any{&msg.ID, &msg.Text, &msg.Caller, &msg.TraceID, &msg.CreatedAt}
+	if deletedAt != nil {
+		dest = append(dest, deletedAt)
+	}
+	if err := s.Scan(dest...); err != nil {
+		return store.Message{}, err
+	}
+	msg.CreatedAt = msg.CreatedAt.UTC()
+	return msg, nil
+}
+
+// IsRetryable reports whether err aborted a transaction that may succeed when
+// retried: a serialization failure (SQLSTATE 40001) or deadlock (40P01).
+func IsRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == "40001" || pgErr.Code == "40P01"
+}
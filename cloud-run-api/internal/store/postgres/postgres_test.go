@@ -0,0 +1,252 @@
+// internal/store/postgres/postgres_test.go
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"your-module-name/internal/search"
+	"your-module-name/internal/search/searchtest"
+	"your-module-name/internal/store"
+	"your-module-name/internal/store/postgres/postgrestest"
+	"your-module-name/internal/store/storetest"
+)
+
+// openTestRepository returns a migrated repository backed by the in-process
+// postgrestest server, or by the scratch database in POSTGRES_TEST_DSN when
+// set. The real database's messages table is emptied first.
+func openTestRepository(t *testing.T) *Repository {
+	t.Helper()
+	dsn := os.Getenv("POSTGRES_TEST_DSN")
+	if dsn == "" {
+		return openRepository(t, postgrestest.NewServer(t).DSN())
+	}
+	repo := openRepository(t, dsn)
+	_, err := repo.db.ExecContext(context.Background(), `DELETE FROM messages`)
+	require.NoError(t, err)
+	return repo
+}
+
+// openRepository returns a migrated repository connected to dsn.
+func openRepository(t *testing.T, dsn string) *Repository {
+	t.Helper()
+	repo, err := Open(context.Background(), Options{DSN: dsn})
+	require.NoError(t, err)
+	t.Cleanup(func() { repo.Close() })
+	_, err = repo.Migrate(context.Background())
+	require.NoError(t, err)
+	return repo
+}
+
+// openFakeRepository returns a repository backed by a postgrestest server,
+// for tests that inspect or script the server.
+func openFakeRepository(t *testing.T, opts Options) (*Repository, *postgrestest.Server) {
+	t.Helper()
+	srv := postgrestest.NewServer(t)
+	opts.DSN = srv.DSN()
+	repo, err := Open(context.Background(), opts)
+	require.NoError(t, err)
+	t.Cleanup(func() { repo.Close() })
+	_, err = repo.Migrate(context.Background())
+	require.NoError(t, err)
+	return repo, srv
+}
+
+func TestRepository(t *testing.T) {
+	storetest.RunConformance(t, func(t *testing.T) store.MessageRepository {
+		return openTestRepository(t)
+	})
+}
+
+// TestRepository_SimpleProtocol runs the suite with pgx interpolating
+// arguments into query strings, as DSNs for poolers without prepared
+// statement support configure it, rather than binding them to prepared
+// statements.
+func TestRepository_SimpleProtocol(t *testing.T) {
+	storetest.RunConformance(t, func(t *testing.T) store.MessageRepository {
+		return openRepository(t, postgrestest.NewServer(t).SimpleProtocolDSN())
+	})
+}
+
+// TestRepository_Search needs PostgreSQL: postgrestest does not evaluate
+// full-text queries.
+func TestRepository_Search(t *testing.T) {
+	if os.Getenv("POSTGRES_TEST_DSN") == "" {
+		t.Skip("POSTGRES_TEST_DSN is not set")
+	}
+	searchtest.RunConformance(t, func(t *testing.T) (store.MessageRepository, search.Searcher) {
+		repo := openTestRepository(t)
+		return repo, repo
+	})
+}
+
+func TestTSQuery(t *testing.T) {
+	q, err := search.ParseQuery(`hello wor* "big o'brien"`)
+	require.NoError(t, err)
+	assert.Equal(t, `('hello') & ('wor':*) & ('big' <-> 'o' <-> 'brien')`, tsQuery(q))
+}
+
+func TestRepository_MigrateIsIdempotent(t *testing.T) {
+	repo := openTestRepository(t)
+	applied, err := repo.Migrate(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+	assert.NoError(t, repo.Ping(context.Background()))
+}
+
+func TestRepository_ConcurrentMigrations(t *testing.T) {
+	ctx := context.Background()
+	srv := postgrestest.NewServer(t)
+
+	const instances = 4
+	results := make(chan []string, instances)
+	var wg sync.WaitGroup
+	for range instances {
+		repo, err := Open(ctx, Options{DSN: srv.DSN()})
+		require.NoError(t, err)
+		t.Cleanup(func() { repo.Close() })
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			applied, err := repo.Migrate(ctx)
+			assert.NoError(t, err)
+			results <- applied
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	var all []string
+	for applied := range results {
+		all = append(all, applied...)
+	}
+	slices.Sort(all)
+	assert.Equal(t, []string{"0001_create_messages", "0002_soft_delete", "0003_search"}, all, "every migration is applied once")
+}
+
+func TestRepository_RetriesSerializationFailures(t *testing.T) {
+	ctx := context.Background()
+	repo, srv := openFakeRepository(t, Options{MaxRetries: 3})
+
+	srv.FailNext("40001", "40P01")
+	created, err := repo.Create(ctx, store.Message{Text: "retried"})
+	require.NoError(t, err)
+	got, err := repo.Get(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "retried", got.Text)
+
+	inserts := 0
+	for _, s := range srv.Statements() {
+		if strings.HasPrefix(s, "INSERT INTO messages") {
+			inserts++
+		}
+	}
+	assert.Equal(t, 3, inserts, "two failed attempts and one success")
+}
+
+func TestRepository_GivesUpAfterMaxRetries(t *testing.T) {
+	repo, srv := openFakeRepository(t, Options{MaxRetries: 1})
+
+	srv.FailNext("40001", "40001")
+	_, err := repo.Create(context.Background(), store.Message{Text: "x"})
+	require.Error(t, err)
+	assert.True(t, IsRetryable(err))
+}
+
+func TestRepository_ZeroMaxRetriesDisablesRetries(t *testing.T) {
+	repo, srv := openFakeRepository(t, Options{})
+
+	srv.FailNext("40001")
+	_, err := repo.Create(context.Background(), store.Message{Text: "x"})
+	require.Error(t, err)
+	assert.True(t, IsRetryable(err))
+	inserts := 0
+	for _, s := range srv.Statements() {
+		if strings.HasPrefix(s, "INSERT INTO messages") {
+			inserts++
+		}
+	}
+	assert.Equal(t, 1, inserts)
+}
+
+func TestRepository_DoesNotRetryOtherErrors(t *testing.T) {
+	repo, srv := openFakeRepository(t, Options{})
+
+	srv.FailNext("23505")
+	_, err := repo.Create(context.Background(), store.Message{Text: "x"})
+	var pgErr *pgconn.PgError
+	require.ErrorAs(t, err, &pgErr)
+	assert.Equal(t, "23505", pgErr.Code)
+	assert.False(t, IsRetryable(err))
+}
+
+func TestRepository_StatementTimeoutFromContext(t *testing.T) {
+	repo, srv := openFakeRepository(t, Options{StatementTimeout: 5 * time.Second})
+
+	_, err := repo.List(context.Background(), store.ListOptions{})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+	defer cancel()
+	_, err = repo.List(ctx, store.ListOptions{})
+	require.NoError(t, err)
+
+	var timeouts []int
+	for _, s := range srv.Statements() {
+		var ms int
+		if _, err := fmt.Sscanf(s, "SET LOCAL statement_timeout = %d", &ms); err == nil {
+			timeouts = append(timeouts, ms)
+		}
+	}
+	require.Len(t, timeouts, 2)
+	assert.Equal(t, 5000, timeouts[0], "no deadline: configured limit")
+	assert.LessOrEqual(t, timeouts[1], 1500, "deadline: time remaining")
+	assert.Greater(t, timeouts[1], 1000)
+}
+
+func TestStatementTimeout(t *testing.T) {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	_, err := statementTimeout(ctx, time.Second)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestIsRetryable(t *testing.T) {
+	assert.True(t, IsRetryable(fmt.Errorf("wrapped: %w", &pgconn.PgError{Code: "40001"})))
+	assert.True(t, IsRetryable(&pgconn.PgError{Code: "40P01"}))
+	assert.False(t, IsRetryable(&pgconn.PgError{Code: "57014"}))
+	assert.False(t, IsRetryable(errors.New("connection reset")))
+}
+
+func TestOptions_ConnString(t *testing.T) {
+	dsn, err := Options{DSN: "postgres://u@db/app"}.ConnString()
+	require.NoError(t, err)
+	assert.Equal(t, "postgres://u@db/app", dsn)
+
+	dsn, err = Options{
+		CloudSQLInstance: "proj:europe-west1:main",
+		User:             "api",
+		Password:         `p'w\d`,
+		Database:         "messages",
+	}.ConnString()
+	require.NoError(t, err)
+	assert.Equal(t, `host='/cloudsql/proj:europe-west1:main' user='api' password='p\'w\\d' dbname='messages'`, dsn)
+	cfg, err := pgconn.ParseConfig(dsn)
+	require.NoError(t, err)
+	assert.Equal(t, "/cloudsql/proj:europe-west1:main", cfg.Host)
+	assert.Equal(t, `p'w\d`, cfg.Password)
+
+	_, err = Options{}.ConnString()
+	assert.Error(t, err)
+}
@@ -0,0 +1,264 @@
+// internal/store/postgres/postgrestest/extended.go
+package postgrestest
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgproto3"
+)
+
+// prepared is a statement prepared by a Parse message.
+type prepared struct {
+	stmt      statement
+	empty     bool // The query string held no statement.
+	paramOIDs []uint32
+	fields    []pgproto3.FieldDescription // Nil if the statement returns no rows.
+}
+
+// portal is a prepared statement with its parameters bound.
+type portal struct {
+	prepared
+	formats []int16 // Result format codes.
+}
+
+// extended handles a message of the extended query protocol other than Sync
+// and Flush. After an error, the messages up to the next Sync are skipped.
+func (s *Server) extended(backend *pgproto3.Backend, sess *session, msg pgproto3.FrontendMessage) {
+	var errResp *pgproto3.ErrorResponse
+	switch msg := msg.(type) {
+	case *pgproto3.Parse:
+		errResp = s.parseMessage(backend, sess, msg)
+	case *pgproto3.Bind:
+		errResp = bind(backend, sess, msg)
+	case *pgproto3.Describe:
+		errResp = describe(backend, sess, msg)
+	case *pgproto3.Execute:
+		errResp = s.execute(backend, sess, msg)
+	case *pgproto3.Close:
+		if msg.ObjectType == 'S' {
+			delete(sess.prepared, msg.Name)
+		} else {
+			delete(sess.portals, msg.Name)
+		}
+		backend.Send(&pgproto3.CloseComplete{})
+	default:
+		errResp = errorResponse("08P01", fmt.Sprintf("postgrestest: unsupported message %T", msg))
+	}
+	if errResp != nil {
+		backend.Send(errResp)
+		sess.skipping = true
+		if sess.inTx {
+			sess.failed = true
+		}
+	}
+}
+
+// parseMessage prepares a statement, typing its parameters and describing
+// its result from the schema.
+func (s *Server) parseMessage(backend *pgproto3.Backend, sess *session, msg *pgproto3.Parse) *pgproto3.ErrorResponse {
+	if _, ok := sess.prepared[msg.Name]; ok && msg.Name != "" {
+		return errorResponse("42P05", fmt.Sprintf("prepared statement %q already exists", msg.Name))
+	}
+	stmts, err := parse(msg.Query)
+	if err != nil {
+		return errorResponse("42601", err.Error())
+	}
+	if len(stmts) > 1 {
+		return errorResponse("42601", "cannot insert multiple commands into a prepared statement")
+	}
+	if len(stmts) == 0 {
+		sess.prepared[msg.Name] = prepared{empty: true}
+		backend.Send(&pgproto3.ParseComplete{})
+		return nil
+	}
+
+	stmt := stmts[0]
+	p := prepared{stmt: stmt, paramOIDs: make([]uint32, stmt.paramCount())}
+	copy(p.paramOIDs, msg.ParameterOIDs)
+	types, fields, errResp := s.describeStatement(sess, stmt)
+	if errResp != nil {
+		return errResp
+	}
+	for i, n := range stmt.params {
+		if n > 0 && p.paramOIDs[n-1] == 0 {
+			p.paramOIDs[n-1] = types[i]
+		}
+	}
+	for i, oid := range p.paramOIDs {
+		if oid == 0 {
+			// Parameters the statement does not use, or uses where the
+			// server cannot tell their type, are text.
+			p.paramOIDs[i] = oidText
+		}
+	}
+	p.fields = fields
+	sess.prepared[msg.Name] = p
+	backend.Send(&pgproto3.ParseComplete{})
+	return nil
+}
+
+// describeStatement returns the type OID of each of stmt.args and the fields
+// of its result.
+func (s *Server) describeStatement(sess *session, stmt statement) ([]uint32, []pgproto3.FieldDescription, *pgproto3.ErrorResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keyword, _, _ := strings.Cut(stmt.template, " ")
+	switch keyword {
+	case "begin", "start", "commit", "end", "rollback":
+		return make([]uint32, len(stmt.args)), nil, nil
+	}
+	if sess.failed {
+		return nil, nil, errorResponse("25P02", "current transaction is aborted, commands ignored until end of transaction block")
+	}
+	switch {
+	case keyword == "select" && stmt.template != stmtAdvisoryLock,
+		keyword == "insert", keyword == "update", keyword == "delete":
+		res, types, err := s.dml(sess, stmt, true)
+		if err != nil {
+			return nil, nil, toErrorResponse(err, stmt)
+		}
+		return types, res.fields, nil
+	case stmt.paramCount() > 0:
+		// Only data statements take parameters.
+		return nil, nil, unsupported(stmt)
+	case stmt.template == stmtAdvisoryLock:
+		return make([]uint32, len(stmt.args)), voidResult("pg_advisory_xact_lock").fields, nil
+	}
+	return make([]uint32, len(stmt.args)), nil, nil
+}
+
+// bind binds parameters to a prepared statement, creating a portal.
+func bind(backend *pgproto3.Backend, sess *session, msg *pgproto3.Bind) *pgproto3.ErrorResponse {
+	p, ok := sess.prepared[msg.PreparedStatement]
+	if !ok {
+		return errorResponse("26000", fmt.Sprintf("prepared statement %q does not exist", msg.PreparedStatement))
+	}
+	if len(msg.Parameters) != len(p.paramOIDs) {
+		return errorResponse("08P01", fmt.Sprintf("bind message supplies %d parameters, but prepared statement %q requires %d",
+			len(msg.Parameters), msg.PreparedStatement, len(p.paramOIDs)))
+	}
+	if n := len(msg.ResultFormatCodes); n > 1 && n != len(p.fields) {
+		return errorResponse("08P01", fmt.Sprintf("bind message has %d result formats but query has %d columns", n, len(p.fields)))
+	}
+
+	params := make([]string, len(msg.Parameters))
+	for i, raw := range msg.Parameters {
+		v, err := decodeParam(raw, p.paramOIDs[i], formatCode(msg.ParameterFormatCodes, i))
+		if err != nil {
+			return errorResponse("22P03", fmt.Sprintf("postgrestest: parameter $%d: %v", i+1, err))
+		}
+		params[i] = v
+	}
+	// Parameters become literals, so statements run as in the simple protocol.
+	p.stmt.args = slices.Clone(p.stmt.args)
+	for i, n := range p.stmt.params {
+		if n > 0 {
+			p.stmt.args[i] = params[n-1]
+		}
+	}
+	sess.portals[msg.DestinationPortal] = portal{prepared: p, formats: slices.Clone(msg.ResultFormatCodes)}
+	backend.Send(&pgproto3.BindComplete{})
+	return nil
+}
+
+// describe describes a prepared statement or portal.
+func describe(backend *pgproto3.Backend, sess *session, msg *pgproto3.Describe) *pgproto3.ErrorResponse {
+	var fields []pgproto3.FieldDescription
+	if msg.ObjectType == 'S' {
+		p, ok := sess.prepared[msg.Name]
+		if !ok {
+			return errorResponse("26000", fmt.Sprintf("prepared statement %q does not exist", msg.Name))
+		}
+		backend.Send(&pgproto3.ParameterDescription{ParameterOIDs: p.paramOIDs})
+		fields = p.fields
+	} else {
+		p, ok := sess.portals[msg.Name]
+		if !ok {
+			return errorResponse("34000", fmt.Sprintf("portal %q does not exist", msg.Name))
+		}
+		fields = slices.Clone(p.fields)
+		for i := range fields {
+			fields[i].Format = formatCode(p.formats, i)
+		}
+	}
+	if fields == nil {
+		backend.Send(&pgproto3.NoData{})
+	} else {
+		backend.Send(&pgproto3.RowDescription{Fields: fields})
+	}
+	return nil
+}
+
+// execute runs a portal to completion.
+func (s *Server) execute(backend *pgproto3.Backend, sess *session, msg *pgproto3.Execute) *pgproto3.ErrorResponse {
+	p, ok := sess.portals[msg.Portal]
+	if !ok {
+		return errorResponse("34000", fmt.Sprintf("portal %q does not exist", msg.Portal))
+	}
+	if p.empty {
+		backend.Send(&pgproto3.EmptyQueryResponse{})
+		return nil
+	}
+	res, errResp := s.run(sess, p.stmt)
+	if errResp != nil {
+		return errResp
+	}
+	sendRows(backend, res, p.formats)
+	return nil
+}
+
+// formatCode returns the format code of the i-th value given a Bind
+// message's format codes: none means text, one applies to every value.
+func formatCode(codes []int16, i int) int16 {
+	switch len(codes) {
+	case 0:
+		return pgproto3.TextFormat
+	case 1:
+		return codes[0]
+	}
+	return codes[i]
+}
+
+// pgEpoch is the origin of binary timestamps.
+var pgEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// decodeParam decodes a bound parameter into the literal it stands for.
+func decodeParam(raw []byte, oid uint32, format int16) (string, error) {
+	if raw == nil {
+		return "", fmt.Errorf("NULL parameters are not supported")
+	}
+	if format == pgproto3.TextFormat {
+		return string(raw), nil
+	}
+	switch {
+	case oid == oidText:
+		return string(raw), nil
+	case oid == oidInt8 && len(raw) == 8:
+		return strconv.FormatInt(int64(binary.BigEndian.Uint64(raw)), 10), nil
+	case oid == oidTimestamptz && len(raw) == 8:
+		us := int64(binary.BigEndian.Uint64(raw))
+		return time.UnixMicro(pgEpoch.UnixMicro() + us).UTC().Format(time.RFC3339Nano), nil
+	}
+	return "", fmt.Errorf("unsupported binary value of type %d", oid)
+}
+
+// encode encodes v, of the type with the given OID, in a result format.
+func (v value) encode(oid uint32, format int16) []byte {
+	switch {
+	case v.null:
+		return nil
+	case format == pgproto3.BinaryFormat && oid == oidTimestamptz:
+		return binary.BigEndian.AppendUint64(nil, uint64(v.t.UnixMicro()-pgEpoch.UnixMicro()))
+	case format == pgproto3.BinaryFormat && oid == oidInt8:
+		n, _ := strconv.ParseInt(v.s, 10, 64)
+		return binary.BigEndian.AppendUint64(nil, uint64(n))
+	case v.isTime:
+		return []byte(v.t.UTC().Format("2006-01-02 15:04:05.999999-07"))
+	}
+	return []byte(v.s)
+}
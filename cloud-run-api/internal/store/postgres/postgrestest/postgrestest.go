@@ -0,0 +1,558 @@
+// internal/store/postgres/postgrestest/postgrestest.go
+//
+// Package postgrestest provides an in-process stand-in for PostgreSQL so the
+// postgres repository can be tested without a database server or network.
+//
+// The server speaks the PostgreSQL wire protocol, both the extended query
+// protocol that pgx uses by default and the simple query protocol. It models
+// the schema rather than assuming one: CREATE TABLE, ALTER TABLE ... ADD
+// COLUMN and CREATE INDEX statements, such as those of the migrations, define
+// the tables, and statements on tables or columns that do not exist fail as
+// they would on PostgreSQL. It executes INSERT, and SELECT, UPDATE and DELETE
+// with WHERE clauses of column comparisons combined with AND, OR and
+// parentheses, with ORDER BY and LIMIT, on tables of text and timestamptz
+// columns; and it takes transaction-level advisory locks. Transactions are atomic
+// but not isolated from each other. Full-text search indexes can be created
+// but not queried. It is not a SQL engine: CI runs the same tests, and those
+// of full-text search, against a real database by setting POSTGRES_TEST_DSN.
+package postgrestest
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgproto3"
+)
+
+// PostgreSQL type OIDs of the supported column, parameter and result types.
+const (
+	oidInt8        = 20
+	oidText        = 25
+	oidTimestamptz = 1184
+	oidVoid        = 2278
+)
+
+// Server is a fake PostgreSQL server.
+type Server struct {
+	ln net.Listener
+
+	mu         sync.Mutex
+	tables     map[string]*table
+	indexes    map[string]bool
+	statements []string
+	failures   []string // SQLSTATE codes returned by upcoming data statements
+	nextPID    uint32
+
+	locks    map[string]*session // Advisory locks by key, with their holders.
+	unlocked *sync.Cond          // Signalled, with mu, when locks are released.
+}
+
+// NewServer starts a fake server with an empty database on a loopback port.
+// It is closed when the test finishes.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("postgrestest: failed to listen: %v", err)
+	}
+	s := &Server{
+		ln:      ln,
+		tables:  make(map[string]*table),
+		indexes: make(map[string]bool),
+		locks:   make(map[string]*session),
+	}
+	s.unlocked = sync.NewCond(&s.mu)
+	go s.serve()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+// DSN returns a connection URL for the server. Clients use the extended
+// query protocol, pgx's default.
+func (s *Server) DSN() string {
+	return "postgres://test@" + s.ln.Addr().String() + "/test?sslmode=disable"
+}
+
+// SimpleProtocolDSN returns a connection URL for the server that makes pgx
+// use the simple query protocol, with arguments interpolated by the client.
+func (s *Server) SimpleProtocolDSN() string {
+	return s.DSN() + "&default_query_exec_mode=simple_protocol"
+}
+
+// Statements returns every statement executed so far, as sent by the client:
+// with parameter placeholders in the extended protocol, or with literals
+// inlined in the simple protocol.
+func (s *Server) Statements() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.statements...)
+}
+
+// FailNext makes the next len(codes) data statements (anything except
+// transaction control and SET) fail with the given SQLSTATE codes, e.g.
+// "40001" for a serialization failure.
+func (s *Server) FailNext(codes ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, codes...)
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+// session is the per-connection state.
+type session struct {
+	inTx   bool
+	failed bool
+	undo   []func()
+
+	prepared map[string]prepared // Prepared statements by name; "" is unnamed.
+	portals  map[string]portal   // Bound statements by name; "" is unnamed.
+	// skipping is set when an extended-protocol message fails, after which
+	// messages are ignored until the next Sync.
+	skipping bool
+}
+
+func (sess *session) txStatus() byte {
+	switch {
+	case sess.failed:
+		return 'E'
+	case sess.inTx:
+		return 'T'
+	default:
+		return 'I'
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	backend := pgproto3.NewBackend(conn, conn)
+
+startup:
+	for {
+		msg, err := backend.ReceiveStartupMessage()
+		if err != nil {
+			return
+		}
+		switch msg.(type) {
+		case *pgproto3.SSLRequest, *pgproto3.GSSEncRequest:
+			if _, err := conn.Write([]byte{'N'}); err != nil {
+				return
+			}
+		case *pgproto3.StartupMessage:
+			break startup
+		default: // CancelRequest: statements are never long-running.
+			return
+		}
+	}
+
+	s.mu.Lock()
+	s.nextPID++
+	pid := s.nextPID
+	s.mu.Unlock()
+
+	backend.Send(&pgproto3.AuthenticationOk{})
+	for _, p := range [][2]string{
+		{"server_version", "16.0 (postgrestest)"},
+		{"server_encoding", "UTF8"},
+		{"client_encoding", "UTF8"},
+		{"standard_conforming_strings", "on"},
+		{"DateStyle", "ISO, MDY"},
+		{"TimeZone", "UTC"},
+		{"integer_datetimes", "on"},
+	} {
+		backend.Send(&pgproto3.ParameterStatus{Name: p[0], Value: p[1]})
+	}
+	backend.Send(&pgproto3.BackendKeyData{ProcessID: pid, SecretKey: pid})
+	backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+	if err := backend.Flush(); err != nil {
+		return
+	}
+
+	sess := &session{prepared: make(map[string]prepared), portals: make(map[string]portal)}
+	defer func() {
+		// A connection closed mid-transaction rolls it back.
+		s.mu.Lock()
+		sess.rollback()
+		s.releaseLocks(sess)
+		s.mu.Unlock()
+	}()
+	for {
+		msg, err := backend.Receive()
+		if err != nil {
+			return
+		}
+		switch msg := msg.(type) {
+		case *pgproto3.Query:
+			s.query(backend, sess, msg.String)
+		case *pgproto3.Sync:
+			sess.skipping = false
+			backend.Send(&pgproto3.ReadyForQuery{TxStatus: sess.txStatus()})
+		case *pgproto3.Flush:
+		case *pgproto3.Terminate:
+			return
+		default:
+			if !sess.skipping {
+				s.extended(backend, sess, msg)
+			}
+		}
+		if err := backend.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// query executes a simple-protocol query string, which may hold several
+// statements, and sends the results.
+func (s *Server) query(backend *pgproto3.Backend, sess *session, sql string) {
+	defer backend.Send(&pgproto3.ReadyForQuery{TxStatus: sess.txStatus()})
+
+	stmts, err := parse(sql)
+	if err != nil {
+		backend.Send(errorResponse("42601", err.Error()))
+		return
+	}
+	if len(stmts) == 0 {
+		backend.Send(&pgproto3.EmptyQueryResponse{})
+		return
+	}
+
+	for _, stmt := range stmts {
+		if stmt.paramCount() > 0 {
+			backend.Send(errorResponse("42P02", fmt.Sprintf("there is no parameter $%d", stmt.paramCount())))
+			return
+		}
+		res, errResp := s.run(sess, stmt)
+		if errResp != nil {
+			backend.Send(errResp)
+			return
+		}
+		if res.fields != nil {
+			backend.Send(&pgproto3.RowDescription{Fields: res.fields})
+		}
+		sendRows(backend, res, nil)
+	}
+}
+
+// run executes stmt, recording it and marking the transaction failed if it
+// fails.
+func (s *Server) run(sess *session, stmt statement) (result, *pgproto3.ErrorResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statements = append(s.statements, stmt.text)
+	res, errResp := s.exec(sess, stmt)
+	if errResp != nil && sess.inTx {
+		sess.failed = true
+	}
+	return res, errResp
+}
+
+// sendRows sends the rows of res, with the given result format codes, and
+// its command tag.
+func sendRows(backend *pgproto3.Backend, res result, formats []int16) {
+	for _, row := range res.rows {
+		values := make([][]byte, len(row))
+		for i, v := range row {
+			values[i] = v.encode(res.fields[i].DataTypeOID, formatCode(formats, i))
+		}
+		backend.Send(&pgproto3.DataRow{Values: values})
+	}
+	backend.Send(&pgproto3.CommandComplete{CommandTag: []byte(res.tag)})
+}
+
+type result struct {
+	tag    string
+	fields []pgproto3.FieldDescription
+	rows   [][]value
+}
+
+// stmtSetTimeout and stmtAdvisoryLock are statement templates, as normalised
+// by parse, with literals replaced by "?". Statements on tables are handled
+// by dml and schema changes by ddl.
+const (
+	stmtSetTimeout   = "set local statement_timeout=?"
+	stmtAdvisoryLock = "select pg_advisory_xact_lock(?)"
+)
+
+// exec executes stmt. The caller must hold s.mu.
+func (s *Server) exec(sess *session, stmt statement) (result, *pgproto3.ErrorResponse) {
+	keyword, _, _ := strings.Cut(stmt.template, " ")
+	switch keyword {
+	case "begin", "start":
+		sess.inTx, sess.failed, sess.undo = true, false, nil
+		return result{tag: "BEGIN"}, nil
+	case "commit", "end":
+		if sess.failed {
+			sess.rollback()
+			s.releaseLocks(sess)
+			return result{tag: "ROLLBACK"}, nil
+		}
+		sess.inTx, sess.undo = false, nil
+		s.releaseLocks(sess)
+		return result{tag: "COMMIT"}, nil
+	case "rollback":
+		sess.rollback()
+		s.releaseLocks(sess)
+		return result{tag: "ROLLBACK"}, nil
+	}
+
+	if sess.failed {
+		return result{}, errorResponse("25P02", "current transaction is aborted, commands ignored until end of transaction block")
+	}
+	if keyword == "set" {
+		if stmt.template != stmtSetTimeout {
+			return result{}, unsupported(stmt)
+		}
+		if _, err := strconv.Atoi(stmt.args[0]); err != nil {
+			return result{}, errorResponse("22023", "invalid value for parameter \"statement_timeout\": "+stmt.args[0])
+		}
+		return result{tag: "SET"}, nil
+	}
+	if len(s.failures) > 0 {
+		code := s.failures[0]
+		s.failures = s.failures[1:]
+		return result{}, errorResponse(code, "postgrestest: injected failure")
+	}
+
+	var (
+		res result
+		err error
+	)
+	switch {
+	case stmt.template == stmtAdvisoryLock:
+		res = voidResult("pg_advisory_xact_lock")
+		if !sess.inTx {
+			// Outside a transaction block, the lock is released at once.
+			break
+		}
+		key := stmt.args[0]
+		for s.locks[key] != nil && s.locks[key] != sess {
+			s.unlocked.Wait()
+		}
+		s.locks[key] = sess
+	case keyword == "create" || keyword == "alter":
+		res, err = s.ddl(sess, stmt)
+	case keyword == "select" || keyword == "insert" || keyword == "update" || keyword == "delete":
+		res, _, err = s.dml(sess, stmt, false)
+	default:
+		return result{}, unsupported(stmt)
+	}
+	if err != nil {
+		return result{}, toErrorResponse(err, stmt)
+	}
+	return res, nil
+}
+
+// releaseLocks releases the advisory locks held by sess, at the end of its
+// transaction.
+func (s *Server) releaseLocks(sess *session) {
+	for key, holder := range s.locks {
+		if holder == sess {
+			delete(s.locks, key)
+			s.unlocked.Broadcast()
+		}
+	}
+}
+
+// voidResult is the result of a SELECT of a function returning void.
+func voidResult(name string) result {
+	return result{
+		tag:    "SELECT 1",
+		fields: []pgproto3.FieldDescription{field(name, oidVoid)},
+		rows:   [][]value{{{}}},
+	}
+}
+
+func (sess *session) record(undo func()) {
+	if sess.inTx {
+		sess.undo = append(sess.undo, undo)
+	}
+}
+
+func (sess *session) rollback() {
+	for i := len(sess.undo) - 1; i >= 0; i-- {
+		sess.undo[i]()
+	}
+	sess.inTx, sess.failed, sess.undo = false, false, nil
+}
+
+func field(name string, oid uint32) pgproto3.FieldDescription {
+	return pgproto3.FieldDescription{Name: []byte(name), DataTypeOID: oid, DataTypeSize: -1, TypeModifier: -1}
+}
+
+// sqlError is an error with a SQLSTATE code.
+type sqlError struct {
+	code, message string
+}
+
+func (e *sqlError) Error() string { return e.message }
+
+func errorf(code, format string, args ...any) error {
+	return &sqlError{code: code, message: fmt.Sprintf(format, args...)}
+}
+
+// toErrorResponse converts an error from executing stmt. Errors without a
+// SQLSTATE are syntax errors: statements the server does not understand.
+func toErrorResponse(err error, stmt statement) *pgproto3.ErrorResponse {
+	var sqlErr *sqlError
+	if errors.As(err, &sqlErr) {
+		return errorResponse(sqlErr.code, sqlErr.message)
+	}
+	return errorResponse("42601", fmt.Sprintf("postgrestest: %v in: %s", err, stmt.text))
+}
+
+func errorResponse(code, message string) *pgproto3.ErrorResponse {
+	return &pgproto3.ErrorResponse{Severity: "ERROR", SeverityUnlocalized: "ERROR", Code: code, Message: message}
+}
+
+func unsupported(stmt statement) *pgproto3.ErrorResponse {
+	return errorResponse("42601", "postgrestest: unsupported statement: "+stmt.text)
+}
+
+// parseTimestamp parses a timestamptz literal as interpolated by pgx.
+func parseTimestamp(s string) (time.Time, error) {
+	for _, layout := range []string{
+		"2006-01-02 15:04:05.999999999Z07:00:00",
+		"2006-01-02 15:04:05.999999999Z07:00",
+		"2006-01-02 15:04:05.999999999Z07",
+		time.RFC3339Nano,
+	} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, errorf("22007", "invalid input syntax for type timestamp with time zone: %q", s)
+}
+
+// statement is one SQL statement with its literals extracted.
+type statement struct {
+	text     string   // As sent, trimmed.
+	template string   // Lower-cased, whitespace-normalised, literals and parameters replaced by "?".
+	args     []string // Literal values in order, with "" for parameters until bound.
+	params   []int    // For each of args, its parameter number ($1 is 1), or 0 for a literal.
+}
+
+// parse splits sql into statements, dropping comments and extracting string
+// and numeric literals and $n parameters.
+func parse(sql string) ([]statement, error) {
+	var (
+		stmts  []statement
+		text   strings.Builder
+		tmpl   strings.Builder
+		args   []string
+		params []int
+	)
+	flush := func() {
+		if t := normalise(tmpl.String()); t != "" {
+			stmts = append(stmts, statement{text: strings.TrimSpace(text.String()), template: t, args: args, params: params})
+		}
+		text.Reset()
+		tmpl.Reset()
+		args, params = nil, nil
+	}
+
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case c == '-' && i+1 < len(sql) && sql[i+1] == '-':
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+			tmpl.WriteByte(' ')
+		case c == '\'':
+			start := i
+			var lit strings.Builder
+			for i++; ; i++ {
+				if i >= len(sql) {
+					return nil, errors.New("postgrestest: unterminated string literal")
+				}
+				if sql[i] == '\'' {
+					if i+1 < len(sql) && sql[i+1] == '\'' {
+						lit.WriteByte('\'')
+						i++
+						continue
+					}
+					break
+				}
+				lit.WriteByte(sql[i])
+			}
+			text.WriteString(sql[start : i+1])
+			tmpl.WriteByte('?')
+			args = append(args, lit.String())
+			params = append(params, 0)
+		case c == '$' && i+1 < len(sql) && sql[i+1] >= '0' && sql[i+1] <= '9':
+			start := i
+			for i+1 < len(sql) && sql[i+1] >= '0' && sql[i+1] <= '9' {
+				i++
+			}
+			n, err := strconv.Atoi(sql[start+1 : i+1])
+			if err != nil || n == 0 {
+				return nil, fmt.Errorf("postgrestest: invalid parameter %s", sql[start:i+1])
+			}
+			text.WriteString(sql[start : i+1])
+			tmpl.WriteByte('?')
+			args = append(args, "")
+			params = append(params, n)
+		case c >= '0' && c <= '9' && !isIdentByte(prevByte(sql, i)):
+			start := i
+			for i+1 < len(sql) && (isIdentByte(sql[i+1]) || sql[i+1] == '.') {
+				i++
+			}
+			text.WriteString(sql[start : i+1])
+			tmpl.WriteByte('?')
+			args = append(args, sql[start:i+1])
+			params = append(params, 0)
+		case c == ';':
+			flush()
+		default:
+			text.WriteByte(c)
+			tmpl.WriteByte(c)
+		}
+	}
+	flush()
+	return stmts, nil
+}
+
+// paramCount returns the highest parameter number of stmt.
+func (stmt statement) paramCount() int {
+	n := 0
+	for _, p := range stmt.params {
+		n = max(n, p)
+	}
+	return n
+}
+
+func prevByte(s string, i int) byte {
+	if i == 0 {
+		return ' '
+	}
+	return s[i-1]
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// normalise lower-cases a template, collapses whitespace and removes it
+// around punctuation so formatting differences do not matter.
+func normalise(s string) string {
+	s = strings.ToLower(strings.Join(strings.Fields(s), " "))
+	for _, p := range []string{"(", ")", ",", "=", "<", ">"} {
+		s = strings.ReplaceAll(s, " "+p, p)
+		s = strings.ReplaceAll(s, p+" ", p)
+	}
+	return s
+}
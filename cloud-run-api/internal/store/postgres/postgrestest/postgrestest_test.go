@@ -0,0 +1,121 @@
+// internal/store/postgres/postgrestest/postgrestest_test.go
+package postgrestest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sqlState returns the SQLSTATE of err, or "" if it has none.
+func sqlState(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code
+	}
+	return ""
+}
+
+func TestServer(t *testing.T) {
+	for name, dsn := range map[string]func(*Server) string{
+		"extended protocol": (*Server).DSN,
+		"simple protocol":   (*Server).SimpleProtocolDSN,
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			srv := NewServer(t)
+			conn, err := pgx.Connect(ctx, dsn(srv))
+			require.NoError(t, err)
+			t.Cleanup(func() { conn.Close(ctx) })
+
+			_, err = conn.Exec(ctx, `SELECT id FROM notes`)
+			assert.Equal(t, "42P01", sqlState(err), "no table before it is created")
+
+			_, err = conn.Exec(ctx, `
+				CREATE TABLE notes (id TEXT PRIMARY KEY, body TEXT NOT NULL DEFAULT 'empty', at TIMESTAMPTZ NOT NULL);
+				CREATE INDEX notes_at ON notes (at DESC, id);
+				CREATE INDEX notes_body_search ON notes USING GIN (to_tsvector('simple', body))`)
+			require.NoError(t, err)
+			_, err = conn.Exec(ctx, `CREATE INDEX notes_tag_search ON notes USING GIN (to_tsvector('simple', tag))`)
+			assert.Equal(t, "42703", sqlState(err))
+			_, err = conn.Exec(ctx, `CREATE INDEX notes_tag ON notes (tag)`)
+			assert.Equal(t, "42703", sqlState(err))
+			_, err = conn.Exec(ctx, `CREATE TABLE notes (id TEXT)`)
+			assert.Equal(t, "42P07", sqlState(err))
+			_, err = conn.Exec(ctx, `CREATE TABLE tags (id SERIAL)`)
+			assert.Equal(t, "42704", sqlState(err))
+
+			at := time.Date(2024, 5, 6, 7, 8, 9, 123456000, time.UTC)
+			insert := `INSERT INTO notes (id, at) VALUES ($1, $2)`
+			_, err = conn.Exec(ctx, insert, "n1", at)
+			require.NoError(t, err)
+			_, err = conn.Exec(ctx, insert, "n2", at.Add(time.Hour))
+			require.NoError(t, err)
+			_, err = conn.Exec(ctx, insert, "n1", at)
+			assert.Equal(t, "23505", sqlState(err))
+			_, err = conn.Exec(ctx, `INSERT INTO notes (id, body) VALUES ($1, $2)`, "n3", "no time")
+			assert.Equal(t, "23502", sqlState(err))
+			_, err = conn.Exec(ctx, `INSERT INTO notes (id, tag, at) VALUES ($1, $2, $3)`, "n3", "x", at)
+			assert.Equal(t, "42703", sqlState(err))
+
+			rows, err := conn.Query(ctx, `SELECT id, body, at FROM notes WHERE at >= $1 ORDER BY at DESC LIMIT $2`, at, 5)
+			require.NoError(t, err)
+			type note struct {
+				ID, Body string
+				At       time.Time
+			}
+			notes, err := pgx.CollectRows(rows, pgx.RowToStructByPos[note])
+			require.NoError(t, err)
+			for i := range notes {
+				notes[i].At = notes[i].At.UTC()
+			}
+			assert.Equal(t, []note{{"n2", "empty", at.Add(time.Hour)}, {"n1", "empty", at}}, notes)
+
+			tag, err := conn.Exec(ctx, `UPDATE notes SET body = $1 WHERE id = $2`, "edited", "n1")
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), tag.RowsAffected())
+			var body string
+			require.NoError(t, conn.QueryRow(ctx, `SELECT body FROM notes WHERE id = $1`, "n1").Scan(&body))
+			assert.Equal(t, "edited", body)
+		})
+	}
+}
+
+func TestServer_TransactionsUndoSchemaAndData(t *testing.T) {
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, NewServer(t).DSN())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close(ctx) })
+
+	_, err = conn.Exec(ctx, `CREATE TABLE notes (id TEXT PRIMARY KEY)`)
+	require.NoError(t, err)
+
+	tx, err := conn.Begin(ctx)
+	require.NoError(t, err)
+	_, err = tx.Exec(ctx, `ALTER TABLE notes ADD COLUMN at TIMESTAMPTZ`)
+	require.NoError(t, err)
+	_, err = tx.Exec(ctx, `INSERT INTO notes (id, at) VALUES ($1, $2)`, "n1", time.Now())
+	require.NoError(t, err)
+	_, err = tx.Exec(ctx, `SELECT missing FROM notes`)
+	assert.Equal(t, "42703", sqlState(err))
+	_, err = tx.Exec(ctx, `SELECT id FROM notes`)
+	assert.Equal(t, "25P02", sqlState(err), "the transaction is aborted")
+	require.NoError(t, tx.Rollback(ctx))
+
+	_, err = conn.Exec(ctx, `SELECT at FROM notes`)
+	assert.Equal(t, "42703", sqlState(err), "the column is gone")
+	var n int
+	rows, err := conn.Query(ctx, `SELECT id FROM notes`)
+	require.NoError(t, err)
+	for rows.Next() {
+		n++
+	}
+	require.NoError(t, rows.Err())
+	assert.Zero(t, n, "the row is gone")
+}
@@ -0,0 +1,580 @@
+// internal/store/postgres/postgrestest/query.go
+package postgrestest
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// value is a column value or literal: a string, a timestamp, or NULL.
+// Integers, which only appear as LIMIT arguments, are strings.
+type value struct {
+	s      string
+	t      time.Time
+	isTime bool
+	null   bool
+}
+
+// compare orders a and b; ok is false if either is NULL.
+func compare(a, b value) (cmp int, ok bool) {
+	if a.null || b.null {
+		return 0, false
+	}
+	if a.isTime {
+		return a.t.Compare(b.t), true
+	}
+	return strings.Compare(a.s, b.s), true
+}
+
+// convert converts a literal to a value of the type with the given OID.
+func convert(raw string, oid uint32) (value, error) {
+	switch oid {
+	case oidTimestamptz:
+		t, err := parseTimestamp(raw)
+		if err != nil {
+			return value{}, err
+		}
+		return value{t: t, isTime: true}, nil
+	case oidInt8:
+		if _, err := strconv.ParseInt(raw, 10, 64); err != nil {
+			return value{}, errorf("22P02", "invalid input syntax for type bigint: %q", raw)
+		}
+	}
+	return value{s: raw}, nil
+}
+
+// queryParser is a recursive descent parser over a statement template.
+type queryParser struct {
+	toks []string
+	pos  int
+	args []string
+	arg  int // Index in args of the next literal.
+	// types holds the type OID of each literal in args, as they are parsed.
+	types []uint32
+	// describe is set when the statement is only being prepared: literals
+	// are typed but not converted, as parameters are not bound yet.
+	describe bool
+}
+
+func newQueryParser(stmt statement, describe bool) *queryParser {
+	return &queryParser{
+		toks:     tokenize(stmt.template),
+		args:     stmt.args,
+		types:    make([]uint32, len(stmt.args)),
+		describe: describe,
+	}
+}
+
+func (p *queryParser) peek() string {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos]
+	}
+	return ""
+}
+
+// accept consumes toks if they come next.
+func (p *queryParser) accept(toks ...string) bool {
+	if p.pos+len(toks) > len(p.toks) || !slices.Equal(p.toks[p.pos:p.pos+len(toks)], toks) {
+		return false
+	}
+	p.pos += len(toks)
+	return true
+}
+
+func (p *queryParser) expect(toks ...string) error {
+	for _, tok := range toks {
+		if !p.accept(tok) {
+			return fmt.Errorf("expected %q at %q", tok, p.peek())
+		}
+	}
+	return nil
+}
+
+// end fails if any of the statement is left unparsed.
+func (p *queryParser) end() error {
+	if p.pos < len(p.toks) {
+		return fmt.Errorf("unexpected %q", p.peek())
+	}
+	return nil
+}
+
+// ident consumes an identifier.
+func (p *queryParser) ident() (string, error) {
+	tok := p.peek()
+	if tok == "" || !isIdentByte(tok[0]) || tok[0] >= '0' && tok[0] <= '9' {
+		return "", fmt.Errorf("expected an identifier at %q", tok)
+	}
+	p.pos++
+	return tok, nil
+}
+
+// column consumes the name of a column of t.
+func (p *queryParser) column(t *table) (column, error) {
+	name, err := p.ident()
+	if err != nil {
+		return column{}, err
+	}
+	return t.column(name)
+}
+
+// literal consumes a "?" placeholder and converts its argument to the type
+// with the given OID.
+func (p *queryParser) literal(oid uint32) (value, error) {
+	if err := p.expect("?"); err != nil {
+		return value{}, err
+	}
+	if p.arg >= len(p.args) {
+		return value{}, errors.New("missing literal")
+	}
+	i := p.arg
+	p.arg++
+	p.types[i] = oid
+	if p.describe {
+		return value{}, nil
+	}
+	return convert(p.args[i], oid)
+}
+
+type predicate func(row) bool
+
+// expr := and { "or" and }
+func (p *queryParser) expr(t *table) (predicate, error) {
+	left, err := p.and(t)
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("or") {
+		right, err := p.and(t)
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(r row) bool { return l(r) || right(r) }
+	}
+	return left, nil
+}
+
+// and := primary { "and" primary }
+func (p *queryParser) and(t *table) (predicate, error) {
+	left, err := p.primary(t)
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("and") {
+		right, err := p.primary(t)
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(r row) bool { return l(r) && right(r) }
+	}
+	return left, nil
+}
+
+// primary := "(" expr ")" | column "is" ["not"] "null" | column op "?"
+func (p *queryParser) primary(t *table) (predicate, error) {
+	if p.accept("(") {
+		e, err := p.expr(t)
+		if err != nil {
+			return nil, err
+		}
+		return e, p.expect(")")
+	}
+
+	col, err := p.column(t)
+	if err != nil {
+		return nil, err
+	}
+	if p.accept("is") {
+		not := p.accept("not")
+		if err := p.expect("null"); err != nil {
+			return nil, err
+		}
+		return func(r row) bool { return t.get(r, col).null != not }, nil
+	}
+
+	op := p.peek()
+	var test func(int) bool
+	switch op {
+	case "=":
+		test = func(c int) bool { return c == 0 }
+	case "<>", "!=":
+		test = func(c int) bool { return c != 0 }
+	case "<":
+		test = func(c int) bool { return c < 0 }
+	case "<=":
+		test = func(c int) bool { return c <= 0 }
+	case ">":
+		test = func(c int) bool { return c > 0 }
+	case ">=":
+		test = func(c int) bool { return c >= 0 }
+	default:
+		return nil, fmt.Errorf("unsupported operator %q", op)
+	}
+	p.pos++
+	lit, err := p.literal(col.oid)
+	if err != nil {
+		return nil, err
+	}
+	return func(r row) bool {
+		c, ok := compare(t.get(r, col), lit)
+		return ok && test(c)
+	}, nil
+}
+
+// where parses an optional WHERE clause.
+func (p *queryParser) where(t *table) (predicate, error) {
+	if !p.accept("where") {
+		return func(row) bool { return true }, nil
+	}
+	return p.expr(t)
+}
+
+// dml executes, or with describe only prepares, a SELECT, INSERT, UPDATE or
+// DELETE statement. It returns the type OID of each of stmt.args.
+func (s *Server) dml(sess *session, stmt statement, describe bool) (result, []uint32, error) {
+	p := newQueryParser(stmt, describe)
+	var (
+		res result
+		err error
+	)
+	switch {
+	case p.accept("select"):
+		res, err = s.selectRows(p)
+	case p.accept("insert"):
+		res, err = s.insertRow(p, sess)
+	case p.accept("update"):
+		res, err = s.updateRows(p, sess)
+	case p.accept("delete"):
+		res, err = s.deleteRows(p, sess)
+	default:
+		err = fmt.Errorf("unsupported statement")
+	}
+	if err == nil {
+		err = p.end()
+	}
+	return res, p.types, err
+}
+
+// table consumes the name of a table.
+func (s *Server) table(p *queryParser) (*table, error) {
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	t, ok := s.tables[name]
+	if !ok {
+		return nil, errorf("42P01", "relation %q does not exist", name)
+	}
+	return t, nil
+}
+
+func (s *Server) selectRows(p *queryParser) (result, error) {
+	// The select list comes before the table it refers to.
+	var names []string
+	if !p.accept("*") {
+		for {
+			name, err := p.ident()
+			if err != nil {
+				return result{}, err
+			}
+			names = append(names, name)
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+	if err := p.expect("from"); err != nil {
+		return result{}, err
+	}
+	t, err := s.table(p)
+	if err != nil {
+		return result{}, err
+	}
+	cols := t.columns
+	if names != nil {
+		cols = nil
+		for _, name := range names {
+			col, err := t.column(name)
+			if err != nil {
+				return result{}, err
+			}
+			cols = append(cols, col)
+		}
+	}
+	match, err := p.where(t)
+	if err != nil {
+		return result{}, err
+	}
+
+	type orderTerm struct {
+		col  column
+		desc bool
+	}
+	var order []orderTerm
+	if p.accept("order") {
+		if err := p.expect("by"); err != nil {
+			return result{}, err
+		}
+		for {
+			col, err := p.column(t)
+			if err != nil {
+				return result{}, err
+			}
+			desc := p.accept("desc")
+			if !desc {
+				p.accept("asc")
+			}
+			order = append(order, orderTerm{col: col, desc: desc})
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+	limit := int64(-1)
+	if p.accept("limit") {
+		lit, err := p.literal(oidInt8)
+		if err != nil {
+			return result{}, err
+		}
+		if !p.describe {
+			limit, _ = strconv.ParseInt(lit.s, 10, 64)
+			if limit < 0 {
+				return result{}, errorf("2201W", "LIMIT must not be negative")
+			}
+		}
+	}
+
+	res := result{}
+	for _, col := range cols {
+		res.fields = append(res.fields, field(col.name, col.oid))
+	}
+	if p.describe {
+		return res, nil
+	}
+
+	// Without ORDER BY, rows come in insertion order.
+	var rows []row
+	for _, id := range slices.Sorted(maps.Keys(t.rows)) {
+		if r := t.rows[id]; match(r) {
+			rows = append(rows, r)
+		}
+	}
+	slices.SortStableFunc(rows, func(a, b row) int {
+		for _, o := range order {
+			c, _ := compare(t.get(a, o.col), t.get(b, o.col))
+			if c != 0 {
+				if o.desc {
+					return -c
+				}
+				return c
+			}
+		}
+		return 0
+	})
+	if limit >= 0 && int64(len(rows)) > limit {
+		rows = rows[:limit]
+	}
+
+	res.tag = fmt.Sprintf("SELECT %d", len(rows))
+	for _, r := range rows {
+		values := make([]value, len(cols))
+		for i, col := range cols {
+			values[i] = t.get(r, col)
+		}
+		res.rows = append(res.rows, values)
+	}
+	return res, nil
+}
+
+func (s *Server) insertRow(p *queryParser, sess *session) (result, error) {
+	if err := p.expect("into"); err != nil {
+		return result{}, err
+	}
+	t, err := s.table(p)
+	if err != nil {
+		return result{}, err
+	}
+	cols := t.columns
+	if p.accept("(") {
+		cols = nil
+		for {
+			col, err := p.column(t)
+			if err != nil {
+				return result{}, err
+			}
+			cols = append(cols, col)
+			if !p.accept(",") {
+				break
+			}
+		}
+		if err := p.expect(")"); err != nil {
+			return result{}, err
+		}
+	}
+	if err := p.expect("values", "("); err != nil {
+		return result{}, err
+	}
+	r := make(row, len(t.columns))
+	for i, col := range cols {
+		if i > 0 {
+			if err := p.expect(","); err != nil {
+				return result{}, err
+			}
+		}
+		if _, ok := r[col.name]; ok {
+			return result{}, errorf("42701", "column %q specified more than once", col.name)
+		}
+		v, err := p.literal(col.oid)
+		if err != nil {
+			return result{}, err
+		}
+		r[col.name] = v
+	}
+	if err := p.expect(")"); err != nil {
+		return result{}, err
+	}
+	if p.describe {
+		return result{}, nil
+	}
+
+	for _, col := range t.columns {
+		if _, ok := r[col.name]; !ok {
+			r[col.name] = col.defaultValue()
+		}
+		if col.notNull && r[col.name].null {
+			return result{}, errorf("23502", "null value in column %q of relation %q violates not-null constraint", col.name, t.name)
+		}
+	}
+	if err := t.checkKey(r, -1); err != nil {
+		return result{}, err
+	}
+	id := t.insert(r)
+	sess.record(func() { delete(t.rows, id) })
+	return result{tag: "INSERT 0 1"}, nil
+}
+
+func (s *Server) updateRows(p *queryParser, sess *session) (result, error) {
+	t, err := s.table(p)
+	if err != nil {
+		return result{}, err
+	}
+	if err := p.expect("set"); err != nil {
+		return result{}, err
+	}
+	type assignment struct {
+		col column
+		v   value
+	}
+	var sets []assignment
+	for {
+		col, err := p.column(t)
+		if err != nil {
+			return result{}, err
+		}
+		if err := p.expect("="); err != nil {
+			return result{}, err
+		}
+		v, err := p.literal(col.oid)
+		if err != nil {
+			return result{}, err
+		}
+		sets = append(sets, assignment{col: col, v: v})
+		if !p.accept(",") {
+			break
+		}
+	}
+	match, err := p.where(t)
+	if err != nil {
+		return result{}, err
+	}
+	if p.describe {
+		return result{}, nil
+	}
+
+	n := 0
+	for id, old := range t.rows {
+		if !match(old) {
+			continue
+		}
+		r := maps.Clone(old)
+		for _, a := range sets {
+			r[a.col.name] = a.v
+		}
+		if err := t.checkKey(r, id); err != nil {
+			return result{}, err
+		}
+		t.rows[id] = r
+		sess.record(func() { t.rows[id] = old })
+		n++
+	}
+	return result{tag: fmt.Sprintf("UPDATE %d", n)}, nil
+}
+
+func (s *Server) deleteRows(p *queryParser, sess *session) (result, error) {
+	if err := p.expect("from"); err != nil {
+		return result{}, err
+	}
+	t, err := s.table(p)
+	if err != nil {
+		return result{}, err
+	}
+	match, err := p.where(t)
+	if err != nil {
+		return result{}, err
+	}
+	if p.describe {
+		return result{}, nil
+	}
+
+	n := 0
+	for id, old := range t.rows {
+		if !match(old) {
+			continue
+		}
+		delete(t.rows, id)
+		sess.record(func() { t.rows[id] = old })
+		n++
+	}
+	return result{tag: fmt.Sprintf("DELETE %d", n)}, nil
+}
+
+// tokenize splits a normalised statement template into identifiers,
+// placeholders, operators and punctuation.
+func tokenize(template string) []string {
+	var toks []string
+	for i := 0; i < len(template); {
+		c := template[i]
+		switch {
+		case c == ' ':
+			i++
+		case isIdentByte(c):
+			j := i
+			for j < len(template) && isIdentByte(template[j]) {
+				j++
+			}
+			toks = append(toks, template[i:j])
+			i = j
+		case i+1 < len(template) && isTwoCharOperator(template[i:i+2]):
+			toks = append(toks, template[i:i+2])
+			i += 2
+		default:
+			toks = append(toks, string(c))
+			i++
+		}
+	}
+	return toks
+}
+
+func isTwoCharOperator(s string) bool {
+	switch s {
+	case "<=", ">=", "<>", "!=":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,351 @@
+// internal/store/postgres/postgrestest/schema.go
+package postgrestest
+
+import (
+	"fmt"
+	"slices"
+)
+
+// table is a table of the fake database.
+type table struct {
+	name    string
+	columns []column
+	key     string      // Name of the primary key column, if any.
+	rows    map[int]row // By insertion order.
+	nextRow int
+}
+
+// column is a column of a table.
+type column struct {
+	name    string
+	oid     uint32
+	notNull bool
+	def     *value // Default, or nil for NULL.
+}
+
+// row holds the values of a table row by column name. Columns added after
+// the row was inserted are missing and read as their defaults.
+type row map[string]value
+
+func (c column) defaultValue() value {
+	if c.def == nil {
+		return value{null: true}
+	}
+	return *c.def
+}
+
+// column returns the column of t with the given name.
+func (t *table) column(name string) (column, error) {
+	for _, c := range t.columns {
+		if c.name == name {
+			return c, nil
+		}
+	}
+	return column{}, errorf("42703", "column %q of relation %q does not exist", name, t.name)
+}
+
+// get returns the value of col in r.
+func (t *table) get(r row, col column) value {
+	if v, ok := r[col.name]; ok {
+		return v
+	}
+	return col.defaultValue()
+}
+
+// checkKey fails if r has the primary key of a row other than the one with
+// the given id.
+func (t *table) checkKey(r row, id int) error {
+	if t.key == "" {
+		return nil
+	}
+	key := r[t.key]
+	for other, o := range t.rows {
+		if c, ok := compare(key, o[t.key]); ok && c == 0 && other != id {
+			return errorf("23505", "duplicate key value violates unique constraint %q", t.name+"_pkey")
+		}
+	}
+	return nil
+}
+
+// insert adds r to t and returns its id.
+func (t *table) insert(r row) int {
+	id := t.nextRow
+	t.nextRow++
+	t.rows[id] = r
+	return id
+}
+
+// ddl executes a CREATE TABLE, ALTER TABLE ... ADD COLUMN or CREATE INDEX
+// statement. Indexes are checked against the schema but not used.
+func (s *Server) ddl(sess *session, stmt statement) (result, error) {
+	p := newQueryParser(stmt, false)
+	var (
+		res result
+		err error
+	)
+	switch {
+	case p.accept("create", "table"):
+		res, err = s.createTable(p, sess)
+	case p.accept("create", "index"):
+		res, err = s.createIndex(p, sess)
+	case p.accept("alter", "table"):
+		res, err = s.alterTable(p, sess)
+	default:
+		err = fmt.Errorf("unsupported statement")
+	}
+	if err == nil {
+		err = p.end()
+	}
+	return res, err
+}
+
+// ifNotExists parses an optional IF NOT EXISTS.
+func (p *queryParser) ifNotExists() (bool, error) {
+	if !p.accept("if") {
+		return false, nil
+	}
+	return true, p.expect("not", "exists")
+}
+
+// relationExists fails if a table or index has the given name.
+func (s *Server) relationExists(name string) error {
+	if _, ok := s.tables[name]; ok || s.indexes[name] {
+		return errorf("42P07", "relation %q already exists", name)
+	}
+	return nil
+}
+
+func (s *Server) createTable(p *queryParser, sess *session) (result, error) {
+	ifNotExists, err := p.ifNotExists()
+	if err != nil {
+		return result{}, err
+	}
+	name, err := p.ident()
+	if err != nil {
+		return result{}, err
+	}
+	if err := p.expect("("); err != nil {
+		return result{}, err
+	}
+	t := &table{name: name, rows: make(map[int]row)}
+	for {
+		if p.accept("primary") {
+			if err := p.expect("key", "("); err != nil {
+				return result{}, err
+			}
+			key, err := p.ident()
+			if err != nil {
+				return result{}, err
+			}
+			if err := t.setKey(key); err != nil {
+				return result{}, err
+			}
+			if err := p.expect(")"); err != nil {
+				return result{}, err
+			}
+		} else {
+			col, primary, err := p.columnDef()
+			if err != nil {
+				return result{}, err
+			}
+			if _, err := t.column(col.name); err == nil {
+				return result{}, errorf("42701", "column %q specified more than once", col.name)
+			}
+			t.columns = append(t.columns, col)
+			if primary {
+				if err := t.setKey(col.name); err != nil {
+					return result{}, err
+				}
+			}
+		}
+		if !p.accept(",") {
+			break
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return result{}, err
+	}
+
+	if err := s.relationExists(name); err != nil {
+		if ifNotExists {
+			return result{tag: "CREATE TABLE"}, nil
+		}
+		return result{}, err
+	}
+	s.tables[name] = t
+	sess.record(func() { delete(s.tables, name) })
+	return result{tag: "CREATE TABLE"}, nil
+}
+
+// setKey makes the column with the given name the primary key of t.
+func (t *table) setKey(name string) error {
+	if t.key != "" {
+		return errorf("42P16", "multiple primary keys for table %q are not allowed", t.name)
+	}
+	i := slices.IndexFunc(t.columns, func(c column) bool { return c.name == name })
+	if i < 0 {
+		return errorf("42703", "column %q named in key does not exist", name)
+	}
+	t.columns[i].notNull = true
+	t.key = name
+	return nil
+}
+
+// columnDef := name type { "primary" "key" | "not" "null" | "null" | "default" "?" }
+func (p *queryParser) columnDef() (col column, primary bool, err error) {
+	if col.name, err = p.ident(); err != nil {
+		return column{}, false, err
+	}
+	if col.oid, err = p.typeName(); err != nil {
+		return column{}, false, err
+	}
+	for {
+		switch {
+		case p.accept("primary"):
+			if err := p.expect("key"); err != nil {
+				return column{}, false, err
+			}
+			primary = true
+		case p.accept("not"):
+			if err := p.expect("null"); err != nil {
+				return column{}, false, err
+			}
+			col.notNull = true
+		case p.accept("null"):
+		case p.accept("default"):
+			def, err := p.literal(col.oid)
+			if err != nil {
+				return column{}, false, err
+			}
+			col.def = &def
+		default:
+			return col, primary, nil
+		}
+	}
+}
+
+// typeName consumes a column type and returns its OID.
+func (p *queryParser) typeName() (uint32, error) {
+	name, err := p.ident()
+	if err != nil {
+		return 0, err
+	}
+	switch name {
+	case "text":
+		return oidText, nil
+	case "timestamptz":
+		return oidTimestamptz, nil
+	case "timestamp":
+		if p.accept("with") {
+			return oidTimestamptz, p.expect("time", "zone")
+		}
+	}
+	return 0, errorf("42704", "type %q does not exist", name)
+}
+
+func (s *Server) alterTable(p *queryParser, sess *session) (result, error) {
+	t, err := s.table(p)
+	if err != nil {
+		return result{}, err
+	}
+	if err := p.expect("add"); err != nil {
+		return result{}, err
+	}
+	p.accept("column")
+	ifNotExists, err := p.ifNotExists()
+	if err != nil {
+		return result{}, err
+	}
+	col, primary, err := p.columnDef()
+	if err != nil {
+		return result{}, err
+	}
+	if primary {
+		return result{}, fmt.Errorf("unsupported primary key in ADD COLUMN")
+	}
+
+	if _, err := t.column(col.name); err == nil {
+		if ifNotExists {
+			return result{tag: "ALTER TABLE"}, nil
+		}
+		return result{}, errorf("42701", "column %q of relation %q already exists", col.name, t.name)
+	}
+	if col.notNull && col.def == nil && len(t.rows) > 0 {
+		return result{}, errorf("23502", "column %q of relation %q contains null values", col.name, t.name)
+	}
+	columns := t.columns
+	t.columns = append(slices.Clip(columns), col)
+	sess.record(func() { t.columns = columns })
+	return result{tag: "ALTER TABLE"}, nil
+}
+
+func (s *Server) createIndex(p *queryParser, sess *session) (result, error) {
+	ifNotExists, err := p.ifNotExists()
+	if err != nil {
+		return result{}, err
+	}
+	name, err := p.ident()
+	if err != nil {
+		return result{}, err
+	}
+	if err := p.expect("on"); err != nil {
+		return result{}, err
+	}
+	t, err := s.table(p)
+	if err != nil {
+		return result{}, err
+	}
+	if p.accept("using") {
+		if _, err := p.ident(); err != nil {
+			return result{}, err
+		}
+	}
+	if err := p.expect("("); err != nil {
+		return result{}, err
+	}
+	for {
+		if err := p.indexElement(t); err != nil {
+			return result{}, err
+		}
+		if !p.accept("desc") {
+			p.accept("asc")
+		}
+		if !p.accept(",") {
+			break
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return result{}, err
+	}
+
+	if err := s.relationExists(name); err != nil {
+		if ifNotExists {
+			return result{tag: "CREATE INDEX"}, nil
+		}
+		return result{}, err
+	}
+	s.indexes[name] = true
+	sess.record(func() { delete(s.indexes, name) })
+	return result{tag: "CREATE INDEX"}, nil
+}
+
+// indexElement consumes a column of t, or a to_tsvector(config, column)
+// expression as indexed for full-text search. Such indexes are accepted so
+// that the migrations apply, but the server does not evaluate full-text
+// queries.
+func (p *queryParser) indexElement(t *table) error {
+	if p.accept("to_tsvector", "(") {
+		if _, err := p.literal(oidText); err != nil {
+			return err
+		}
+		if err := p.expect(","); err != nil {
+			return err
+		}
+		if _, err := p.column(t); err != nil {
+			return err
+		}
+		return p.expect(")")
+	}
+	_, err := p.column(t)
+	return err
+}
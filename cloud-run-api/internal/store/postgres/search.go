@@ -0,0 +1,90 @@
+// internal/store/postgres/search.go
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"your-module-name/internal/search"
+	"your-module-name/internal/store"
+)
+
+var _ search.Searcher = (*Repository)(nil)
+
+// Search implements search.Searcher with the GIN index of migration 0003,
+// so every instance sharing the database sees the same results. Hits are
+// ranked by ts_rank_cd, most relevant first, and ties are broken by recency.
+func (r *Repository) Search(ctx context.Context, req search.Request) (search.Response, error) {
+	offset, err := search.DecodePageToken(req.PageToken, req.Query)
+	if err != nil {
+		return search.Response{}, err
+	}
+	// The expression must match the index's for the index to be used.
+	const from = ` FROM messages, to_tsquery('simple', $1) AS query
+		WHERE deleted_at IS NULL AND to_tsvector('simple', text) @@ query`
+	size := store.PageSize(req.PageSize)
+
+	var resp search.Response
+	err = r.inTx(ctx, true, func(tx *sql.Tx) error {
+		resp = search.Response{}
+		if err := tx.QueryRowContext(ctx, `SELECT count(*)`+from, tsQuery(req.Query)).Scan(&resp.TotalSize); err != nil {
+			return err
+		}
+		rows, err := tx.QueryContext(ctx,
+			`SELECT id, text, caller, trace_id, created_at, ts_rank_cd(to_tsvector('simple', text), query) AS score`+from+`
+			ORDER BY score DESC, created_at DESC, id DESC LIMIT $2 OFFSET $3`,
+			tsQuery(req.Query), size, offset)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var hit search.Hit
+			if hit.Message, err = scanMessage(scoredRow{rows, &hit.Score}, nil); err != nil {
+				return err
+			}
+			resp.Hits = append(resp.Hits, hit)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return search.Response{}, fmt.Errorf("postgres: failed to search messages: %w", err)
+	}
+	for i, hit := range resp.Hits {
+		resp.Hits[i].Highlights = search.Highlights(hit.Message, req.Query)
+	}
+	if end := offset + size; end < resp.TotalSize {
+		resp.NextPageToken = search.EncodePageToken(req.Query, end)
+	}
+	return resp, nil
+}
+
+// scoredRow scans a message row with a trailing score column.
+type scoredRow struct {
+	scanner
+	score *float64
+}
+
+func (s scoredRow) Scan(dest ...any) error {
+	return s.scanner.Scan(append(dest, s.score)...)
+}
+
+// tsQuery translates q into a tsquery in to_tsquery syntax: every clause must
+// match, and the terms are quoted lexemes, so none is read as an operator.
+func tsQuery(q search.Query) string {
+	clauses := make([]string, 0, len(q.Clauses()))
+	for _, c := range q.Clauses() {
+		lexemes := make([]string, len(c.Terms))
+		for i, term := range c.Terms {
+			lexemes[i] = "'" + strings.NewReplacer(`\`, `\\`, `'`, `''`).Replace(term) + "'"
+		}
+		clause := strings.Join(lexemes, " <-> ")
+		if c.Kind == search.PrefixClause {
+			clause += ":*"
+		}
+		clauses = append(clauses, "("+clause+")")
+	}
+	return strings.Join(clauses, " & ")
+}
@@ -0,0 +1,68 @@
+// internal/store/postgres/tx.go
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// inTx runs fn in a serializable transaction with a statement timeout derived
+// from ctx, retrying it when it fails with a retryable error.
+func (r *Repository) inTx(ctx context.Context, readOnly bool, fn func(*sql.Tx) error) error {
+	for attempt := 0; ; attempt++ {
+		err := r.tryTx(ctx, readOnly, fn)
+		if err == nil || !IsRetryable(err) || attempt >= r.maxRetries {
+			return err
+		}
+		select {
+		case <-time.After(retryBackoff(attempt)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (r *Repository) tryTx(ctx context.Context, readOnly bool, fn func(*sql.Tx) error) error {
+	timeout, err := statementTimeout(ctx, r.statementTimeout)
+	if err != nil {
+		return err
+	}
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable, ReadOnly: readOnly})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // No-op after Commit.
+
+	// SET does not accept bind parameters; the value is an integer we computed.
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("SET LOCAL statement_timeout = %d", timeout.Milliseconds())); err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// statementTimeout returns the time left until the context deadline, capped
+// at limit, rounded up to whole milliseconds.
+func statementTimeout(ctx context.Context, limit time.Duration) (time.Duration, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return limit, nil
+	}
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		return 0, context.DeadlineExceeded
+	}
+	return (min(remaining, limit) + time.Millisecond - 1).Truncate(time.Millisecond), nil
+}
+
+// retryBackoff returns the delay before retry attempt+1: exponential from
+// 10ms, capped at 500ms, with up to 50% jitter.
+func retryBackoff(attempt int) time.Duration {
+	d := min(10*time.Millisecond<<attempt, 500*time.Millisecond)
+	return d/2 + rand.N(d/2+1)
+}
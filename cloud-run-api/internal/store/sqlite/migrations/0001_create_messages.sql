@@ -0,0 +1,11 @@
+-- Echoed messages. created_at holds Unix nanoseconds (UTC) so ordering and
+-- page tokens are exact.
+CREATE TABLE messages (
+    id         TEXT PRIMARY KEY,
+    text       TEXT NOT NULL,
+    caller     TEXT NOT NULL DEFAULT '',
+    trace_id   TEXT NOT NULL DEFAULT '',
+    created_at INTEGER NOT NULL
+);
+
+CREATE INDEX messages_created_at_id ON messages (created_at DESC, id DESC);
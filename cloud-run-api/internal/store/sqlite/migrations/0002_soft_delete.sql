@@ -0,0 +1,5 @@
+-- Deleted messages are kept as tombstones so they can be reported as gone.
+-- deleted_at holds Unix nanoseconds (UTC), or NULL while the message exists.
+ALTER TABLE messages ADD COLUMN deleted_at INTEGER;
+
+CREATE INDEX messages_caller_created_at_id ON messages (caller, created_at DESC, id DESC);
@@ -0,0 +1,25 @@
+-- Full-text index over message text, searched by Repository.Search. It is
+-- an external-content table, so the text is only stored in messages; the
+-- triggers keep it in step. The tokenizer keeps diacritics and combining
+-- marks, as the in-process index does.
+CREATE VIRTUAL TABLE messages_fts USING fts5(
+    text,
+    content = 'messages',
+    content_rowid = 'rowid',
+    tokenize = "unicode61 remove_diacritics 0 categories 'L* N* Mn'"
+);
+
+INSERT INTO messages_fts (rowid, text) SELECT rowid, text FROM messages;
+
+CREATE TRIGGER messages_fts_insert AFTER INSERT ON messages BEGIN
+    INSERT INTO messages_fts (rowid, text) VALUES (new.rowid, new.text);
+END;
+
+CREATE TRIGGER messages_fts_delete AFTER DELETE ON messages BEGIN
+    INSERT INTO messages_fts (messages_fts, rowid, text) VALUES ('delete', old.rowid, old.text);
+END;
+
+CREATE TRIGGER messages_fts_update AFTER UPDATE OF text ON messages BEGIN
+    INSERT INTO messages_fts (messages_fts, rowid, text) VALUES ('delete', old.rowid, old.text);
+    INSERT INTO messages_fts (rowid, text) VALUES (new.rowid, new.text);
+END;
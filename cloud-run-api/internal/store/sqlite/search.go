@@ -0,0 +1,79 @@
+// internal/store/sqlite/search.go
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"your-module-name/internal/search"
+	"your-module-name/internal/store"
+)
+
+var _ search.Searcher = (*Repository)(nil)
+
+// Search implements search.Searcher with the FTS5 index of migration 0003,
+// so every instance sharing the database sees the same results. Hits are
+// ranked by BM25, most relevant first, and ties are broken by recency.
+func (r *Repository) Search(ctx context.Context, req search.Request) (search.Response, error) {
+	offset, err := search.DecodePageToken(req.PageToken, req.Query)
+	if err != nil {
+		return search.Response{}, err
+	}
+	match := ftsQuery(req.Query)
+	const from = ` FROM messages_fts JOIN messages m ON m.rowid = messages_fts.rowid
+		WHERE messages_fts MATCH ? AND m.deleted_at IS NULL`
+
+	var resp search.Response
+	if err := r.db.QueryRowContext(ctx, `SELECT count(*)`+from, match).Scan(&resp.TotalSize); err != nil {
+		return search.Response{}, fmt.Errorf("sqlite: failed to search messages: %w", err)
+	}
+	size := store.PageSize(req.PageSize)
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT m.id, m.text, m.caller, m.trace_id, m.created_at, -bm25(messages_fts) AS score`+from+`
+		ORDER BY score DESC, m.created_at DESC, m.id DESC LIMIT ? OFFSET ?`,
+		match, size, offset)
+	if err != nil {
+		return search.Response{}, fmt.Errorf("sqlite: failed to search messages: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var hit search.Hit
+		if hit.Message, err = scanMessage(scoredRow{rows, &hit.Score}, nil); err != nil {
+			return search.Response{}, fmt.Errorf("sqlite: failed to scan message: %w", err)
+		}
+		hit.Highlights = search.Highlights(hit.Message, req.Query)
+		resp.Hits = append(resp.Hits, hit)
+	}
+	if err := rows.Err(); err != nil {
+		return search.Response{}, fmt.Errorf("sqlite: failed to search messages: %w", err)
+	}
+	if end := offset + size; end < resp.TotalSize {
+		resp.NextPageToken = search.EncodePageToken(req.Query, end)
+	}
+	return resp, nil
+}
+
+// scoredRow scans a message row with a trailing score column.
+type scoredRow struct {
+	scanner
+	score *float64
+}
+
+func (s scoredRow) Scan(dest ...any) error {
+	return s.scanner.Scan(append(dest, s.score)...)
+}
+
+// ftsQuery translates q into an FTS5 query. Every clause must match, and
+// the terms are quoted, so none is read as an FTS5 operator.
+func ftsQuery(q search.Query) string {
+	clauses := make([]string, 0, len(q.Clauses()))
+	for _, c := range q.Clauses() {
+		phrase := `"` + strings.ReplaceAll(strings.Join(c.Terms, " "), `"`, `""`) + `"`
+		if c.Kind == search.PrefixClause {
+			phrase += " *"
+		}
+		clauses = append(clauses, phrase)
+	}
+	return strings.Join(clauses, " AND ")
+}
@@ -0,0 +1,232 @@
+// internal/store/sqlite/sqlite.go
+//
+// Package sqlite implements store.MessageRepository on SQLite using the
+// pure-Go modernc.org/sqlite driver, so binaries can be built with
+// CGO_ENABLED=0. The schema is managed by embedded migrations (see Migrate).
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	_ "modernc.org/sqlite" // Registers the "sqlite" database/sql driver.
+
+	"your-module-name/internal/store"
+	"your-module-name/internal/store/migrate"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Options configure Open.
+type Options struct {
+	// Path is the database file, or ":memory:" for a private in-memory database.
+	Path string
+	// MaxOpenConns limits open connections. Defaults to 4; always 1 for ":memory:".
+	MaxOpenConns int
+	// BusyTimeout is how long a connection waits for a lock held by another
+	// connection before failing. Defaults to 5s.
+	BusyTimeout time.Duration
+}
+
+// Repository is a SQLite-backed store.MessageRepository.
+type Repository struct {
+	db *sql.DB
+}
+
+var _ store.MessageRepository = (*Repository)(nil)
+var _ store.Pinger = (*Repository)(nil)
+
+// Open opens (creating if necessary) the database at opts.Path. It does not
+// apply migrations; call Migrate before use.
+func Open(ctx context.Context, opts Options) (*Repository, error) {
+	if opts.Path == "" {
+		return nil, errors.New("sqlite: database path is required")
+	}
+	if opts.MaxOpenConns <= 0 {
+		opts.MaxOpenConns = 4
+	}
+	if opts.BusyTimeout <= 0 {
+		opts.BusyTimeout = 5 * time.Second
+	}
+
+	params := url.Values{}
+	params.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", opts.BusyTimeout.Milliseconds()))
+	params.Add("_pragma", "foreign_keys(1)")
+	if opts.Path == ":memory:" {
+		// Every connection to ":memory:" is a separate database.
+		opts.MaxOpenConns = 1
+	} else {
+		params.Add("_pragma", "journal_mode(WAL)")
+	}
+
+	db, err := sql.Open("sqlite", "file:"+opts.Path+"?"+params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: failed to open %s: %w", opts.Path, err)
+	}
+	db.SetMaxOpenConns(opts.MaxOpenConns)
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("sqlite: failed to connect to %s: %w", opts.Path, err)
+	}
+	return &Repository{db: db}, nil
+}
+
+// Migrate applies pending schema migrations and returns their versions.
+func (r *Repository) Migrate(ctx context.Context) ([]string, error) {
+	return migrate.Run(ctx, r.db, migrations, "migrations", migrate.Options{})
+}
+
+// Ping implements store.Pinger.
+func (r *Repository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
+
+// Close closes the database.
+func (r *Repository) Close() error {
+	return r.db.Close()
+}
+
+// Create implements store.MessageRepository.
+func (r *Repository) Create(ctx context.Context, msg store.Message) (store.Message, error) {
+	if msg.ID == "" {
+		msg.ID = store.NewID()
+	}
+	if msg.CreatedAt.IsZero() {
+		msg.CreatedAt = time.Now()
+	}
+	msg.CreatedAt = msg.CreatedAt.UTC()
+
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO messages (id, text, caller, trace_id, created_at) VALUES (?, ?, ?, ?, ?)`,
+		msg.ID, msg.Text, msg.Caller, msg.TraceID, msg.CreatedAt.UnixNano())
+	if err != nil {
+		return store.Message{}, fmt.Errorf("sqlite: failed to insert message: %w", err)
+	}
+	return msg, nil
+}
+
+// Get implements store.MessageRepository.
+func (r *Repository) Get(ctx context.Context, id string) (store.Message, error) {
+	row := r.db.QueryRowContext(ctx,
+		`SELECT id, text, caller, trace_id, created_at, deleted_at FROM messages WHERE id = ?`, id)
+	var deletedAt sql.NullInt64
+	msg, err := scanMessage(row, &deletedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return store.Message{}, store.ErrNotFound
+	}
+	if err != nil {
+		return store.Message{}, fmt.Errorf("sqlite: failed to get message: %w", err)
+	}
+	if deletedAt.Valid {
+		return store.Message{}, store.ErrDeleted
+	}
+	return msg, nil
+}
+
+// List implements store.MessageRepository.
+func (r *Repository) List(ctx context.Context, opts store.ListOptions) (store.ListResult, error) {
+	cursor, err := opts.Cursor()
+	if err != nil {
+		return store.ListResult{}, err
+	}
+
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return "?"
+	}
+	query := `SELECT id, text, caller, trace_id, created_at FROM messages WHERE deleted_at IS NULL`
+	if opts.Caller != "" {
+		query += ` AND caller = ` + arg(opts.Caller)
+	}
+	if !opts.Since.IsZero() {
+		query += ` AND created_at >= ` + arg(opts.Since.UnixNano())
+	}
+	if !opts.Before.IsZero() {
+		query += ` AND created_at < ` + arg(opts.Before.UnixNano())
+	}
+	dir, cmp := "DESC", "<"
+	if opts.Order == store.OldestFirst {
+		dir, cmp = "ASC", ">"
+	}
+	if cursor != nil {
+		nanos := cursor.CreatedAt.UnixNano()
+		query += fmt.Sprintf(` AND (created_at %s %s OR (created_at = %s AND id %s %s))`,
+			cmp, arg(nanos), arg(nanos), cmp, arg(cursor.ID))
+	}
+	// Fetch one extra row to know whether there is a next page.
+	size := store.PageSize(opts.PageSize)
+	query += fmt.Sprintf(` ORDER BY created_at %s, id %s LIMIT %s`, dir, dir, arg(size+1))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return store.ListResult{}, fmt.Errorf("sqlite: failed to list messages: %w", err)
+	}
+	defer rows.Close()
+
+	var result store.ListResult
+	for rows.Next() {
+		msg, err := scanMessage(rows, nil)
+		if err != nil {
+			return store.ListResult{}, fmt.Errorf("sqlite: failed to scan message: %w", err)
+		}
+		result.Messages = append(result.Messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return store.ListResult{}, fmt.Errorf("sqlite: failed to list messages: %w", err)
+	}
+	if len(result.Messages) > size {
+		result.Messages = result.Messages[:size]
+		result.NextPageToken = store.EncodePageToken(result.Messages[size-1], opts)
+	}
+	return result, nil
+}
+
+// Delete implements store.MessageRepository. The row is kept as a tombstone.
+func (r *Repository) Delete(ctx context.Context, id string) error {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE messages SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL`, time.Now().UnixNano(), id)
+	if err != nil {
+		return fmt.Errorf("sqlite: failed to delete message: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("sqlite: failed to delete message: %w", err)
+	}
+	if n == 0 {
+		// Missing or already deleted; Get tells which.
+		if _, err := r.Get(ctx, id); err != nil {
+			return err
+		}
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanMessage scans a message row. If deletedAt is non-nil, the row has a
+// trailing deleted_at column that is scanned into it.
+func scanMessage(s scanner, deletedAt *sql.NullInt64) (store.Message, error) {
+	var (
+		msg   store.Message
+		nanos int64
+	)
+	dest := []any{&msg.ID, &msg.Text, &msg.Caller, &msg.TraceID, &nanos}
+	if deletedAt != nil {
+		dest = append(dest, deletedAt)
+	}
+	if err := s.Scan(dest...); err != nil {
+		return store.Message{}, err
+	}
+	msg.CreatedAt = time.Unix(0, nanos).UTC()
+	return msg, nil
+}
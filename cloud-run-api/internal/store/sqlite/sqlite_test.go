@@ -0,0 +1,102 @@
+// internal/store/sqlite/sqlite_test.go
+package sqlite
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"your-module-name/internal/search"
+	"your-module-name/internal/search/searchtest"
+	"your-module-name/internal/store"
+	"your-module-name/internal/store/migrate"
+	"your-module-name/internal/store/storetest"
+)
+
+func openTestRepository(t *testing.T, path string) *Repository {
+	t.Helper()
+	repo, err := Open(context.Background(), Options{Path: path})
+	require.NoError(t, err)
+	t.Cleanup(func() { repo.Close() })
+	_, err = repo.Migrate(context.Background())
+	require.NoError(t, err)
+	return repo
+}
+
+func TestRepository(t *testing.T) {
+	t.Run("InMemory", func(t *testing.T) {
+		storetest.RunConformance(t, func(t *testing.T) store.MessageRepository {
+			return openTestRepository(t, ":memory:")
+		})
+	})
+	t.Run("File", func(t *testing.T) {
+		storetest.RunConformance(t, func(t *testing.T) store.MessageRepository {
+			return openTestRepository(t, filepath.Join(t.TempDir(), "messages.db"))
+		})
+	})
+}
+
+func TestRepository_PersistsAcrossReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "messages.db")
+
+	repo, err := Open(ctx, Options{Path: path})
+	require.NoError(t, err)
+	_, err = repo.Migrate(ctx)
+	require.NoError(t, err)
+	created, err := repo.Create(ctx, store.Message{Text: "persisted"})
+	require.NoError(t, err)
+	require.NoError(t, repo.Close())
+
+	reopened := openTestRepository(t, path)
+	got, err := reopened.Get(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "persisted", got.Text)
+	assert.True(t, created.CreatedAt.Equal(got.CreatedAt))
+	assert.NoError(t, reopened.Ping(ctx))
+}
+
+func TestOpen_RequiresPath(t *testing.T) {
+	_, err := Open(context.Background(), Options{})
+	assert.Error(t, err)
+}
+
+func TestRepository_Search(t *testing.T) {
+	searchtest.RunConformance(t, func(t *testing.T) (store.MessageRepository, search.Searcher) {
+		repo := openTestRepository(t, ":memory:")
+		return repo, repo
+	})
+}
+
+func TestRepository_SearchIndexesExistingMessages(t *testing.T) {
+	ctx := context.Background()
+	repo, err := Open(ctx, Options{Path: ":memory:"})
+	require.NoError(t, err)
+	t.Cleanup(func() { repo.Close() })
+
+	// A database migrated before the search index was added.
+	before := fstest.MapFS{}
+	for _, name := range []string{"0001_create_messages.sql", "0002_soft_delete.sql"} {
+		data, err := migrations.ReadFile("migrations/" + name)
+		require.NoError(t, err)
+		before["migrations/"+name] = &fstest.MapFile{Data: data}
+	}
+	_, err = migrate.Run(ctx, repo.db, before, "migrations", migrate.Options{})
+	require.NoError(t, err)
+	_, err = repo.Create(ctx, store.Message{ID: "old", Text: "hello from before"})
+	require.NoError(t, err)
+
+	applied, err := repo.Migrate(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0003_search"}, applied)
+	q, err := search.ParseQuery("hello")
+	require.NoError(t, err)
+	resp, err := repo.Search(ctx, search.Request{Query: q})
+	require.NoError(t, err)
+	require.Len(t, resp.Hits, 1)
+	assert.Equal(t, "old", resp.Hits[0].Message.ID)
+}
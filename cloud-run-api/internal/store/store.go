@@ -0,0 +1,214 @@
+// internal/store/store.go
+//
+// Package store persists echoed messages behind the MessageRepository
+// interface. This package provides the shared types and an in-memory
+// implementation; other backends live in subpackages.
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when a message does not exist.
+var ErrNotFound = errors.New("store: message not found")
+
+// ErrDeleted is returned by Get and Delete for a message that has been
+// deleted. It wraps ErrNotFound, so callers that do not distinguish deleted
+// messages can keep checking for ErrNotFound.
+var ErrDeleted = fmt.Errorf("%w: message was deleted", ErrNotFound)
+
+// ErrInvalidPageToken is returned when a page token cannot be decoded or was
+// issued for a List call with different filters or order.
+var ErrInvalidPageToken = errors.New("store: invalid page token")
+
+// Page size limits applied by List.
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 1000
+)
+
+// Message is a stored echo.
+type Message struct {
+	ID        string
+	Text      string
+	Caller    string
+	TraceID   string
+	CreatedAt time.Time
+}
+
+// Order is the sort order of List results.
+type Order int
+
+// Supported orders. Ties on CreatedAt are broken by ID in the same direction.
+const (
+	NewestFirst Order = iota // CreatedAt descending (the default).
+	OldestFirst              // CreatedAt ascending.
+)
+
+// ListOptions control filtering, ordering and pagination for List.
+type ListOptions struct {
+	// PageSize is the maximum number of messages returned. Zero means
+	// DefaultPageSize; values above MaxPageSize are clamped.
+	PageSize int
+	// PageToken is the NextPageToken of a previous List call with the same
+	// filters and order.
+	PageToken string
+
+	// Caller, if set, restricts results to messages from this caller.
+	Caller string
+	// Since, if set, is the inclusive lower bound on CreatedAt.
+	Since time.Time
+	// Before, if set, is the exclusive upper bound on CreatedAt.
+	Before time.Time
+	// Order is the sort order. Defaults to NewestFirst.
+	Order Order
+}
+
+// Matches reports whether m passes the filters in o.
+func (o ListOptions) Matches(m Message) bool {
+	if o.Caller != "" && m.Caller != o.Caller {
+		return false
+	}
+	if !o.Since.IsZero() && m.CreatedAt.Before(o.Since) {
+		return false
+	}
+	if !o.Before.IsZero() && !m.CreatedAt.Before(o.Before) {
+		return false
+	}
+	return true
+}
+
+// Cursor decodes o.PageToken. It returns nil when there is no token.
+func (o ListOptions) Cursor() (*Cursor, error) {
+	if o.PageToken == "" {
+		return nil, nil
+	}
+	c, err := DecodePageToken(o.PageToken, o)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// fingerprint identifies the filters and order a page token belongs to.
+// The page size is excluded: it may change between pages.
+func (o ListOptions) fingerprint() string {
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%d|%s|%d|%d", o.Order, o.Caller, unixNanos(o.Since), unixNanos(o.Before))
+	return strconv.FormatUint(uint64(h.Sum32()), 36)
+}
+
+func unixNanos(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// ListResult is a page of messages, newest first.
+type ListResult struct {
+	Messages      []Message
+	NextPageToken string
+}
+
+// MessageRepository stores echoed messages. Implementations must be safe
+// for concurrent use and honour context cancellation.
+type MessageRepository interface {
+	// Create stores msg, assigning an ID and CreatedAt when they are empty,
+	// and returns the stored message.
+	Create(ctx context.Context, msg Message) (Message, error)
+	// Get returns the message with the given ID, ErrDeleted if it was
+	// deleted, or ErrNotFound.
+	Get(ctx context.Context, id string) (Message, error)
+	// List returns the messages matching opts in opts.Order. Deleted messages
+	// are never listed.
+	List(ctx context.Context, opts ListOptions) (ListResult, error)
+	// Delete marks the message with the given ID as deleted. It returns
+	// ErrDeleted if it already was, or ErrNotFound.
+	Delete(ctx context.Context, id string) error
+}
+
+// Pinger is implemented by repositories backed by an external resource, such
+// as a database, whose availability can be checked. It is used for readiness.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// NewID returns a random 128-bit message ID encoded as hex.
+func NewID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("store: failed to generate ID: %v", err))
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// PageSize normalises a requested page size.
+func PageSize(requested int) int {
+	switch {
+	case requested <= 0:
+		return DefaultPageSize
+	case requested > MaxPageSize:
+		return MaxPageSize
+	default:
+		return requested
+	}
+}
+
+// Cursor identifies the position after the last message of a page.
+// Backends encode it as an opaque page token.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// After reports whether m sorts after the cursor position in the given order.
+func (c Cursor) After(m Message, order Order) bool {
+	return Less(Message{ID: c.ID, CreatedAt: c.CreatedAt}, m, order)
+}
+
+// EncodePageToken encodes the cursor after m as an opaque page token for a
+// List call with the options opts.
+func EncodePageToken(m Message, opts ListOptions) string {
+	raw := opts.fingerprint() + ":" + strconv.FormatInt(m.CreatedAt.UnixNano(), 10) + ":" + m.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodePageToken decodes a page token produced by EncodePageToken. It fails
+// with ErrInvalidPageToken if the token was issued for different options.
+func DecodePageToken(token string, opts ListOptions) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return Cursor{}, ErrInvalidPageToken
+	}
+	fingerprint, rest, _ := strings.Cut(string(raw), ":")
+	nanos, id, ok := strings.Cut(rest, ":")
+	if !ok || id == "" || fingerprint != opts.fingerprint() {
+		return Cursor{}, ErrInvalidPageToken
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return Cursor{}, ErrInvalidPageToken
+	}
+	return Cursor{CreatedAt: time.Unix(0, n).UTC(), ID: id}, nil
+}
+
+// Less reports whether a sorts before b in the given order.
+func Less(a, b Message, order Order) bool {
+	if order == OldestFirst {
+		a, b = b, a
+	}
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.After(b.CreatedAt)
+	}
+	return a.ID > b.ID
+}
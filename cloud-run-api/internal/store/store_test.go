@@ -0,0 +1,86 @@
+// internal/store/store_test.go
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPageTokenRoundTrip(t *testing.T) {
+	m := Message{ID: "a:b", CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 6, time.UTC)}
+	opts := ListOptions{Caller: "10.0.0.1", Since: m.CreatedAt.Add(-time.Hour), Order: OldestFirst}
+	token := EncodePageToken(m, opts)
+
+	c, err := DecodePageToken(token, ListOptions{PageSize: 7, Caller: "10.0.0.1", Since: opts.Since, Order: OldestFirst})
+	require.NoError(t, err, "page size may change between pages")
+	assert.Equal(t, m.ID, c.ID)
+	assert.True(t, m.CreatedAt.Equal(c.CreatedAt))
+
+	for name, other := range map[string]ListOptions{
+		"caller": {Caller: "10.0.0.2", Since: opts.Since, Order: OldestFirst},
+		"since":  {Caller: "10.0.0.1", Order: OldestFirst},
+		"before": {Caller: "10.0.0.1", Since: opts.Since, Before: m.CreatedAt, Order: OldestFirst},
+		"order":  {Caller: "10.0.0.1", Since: opts.Since},
+	} {
+		_, err := DecodePageToken(token, other)
+		assert.ErrorIs(t, err, ErrInvalidPageToken, "token reused with a different %s", name)
+	}
+
+	for _, bad := range []string{"!!!", "bm8tY29sb24", "eDpibGFo"} { // invalid base64, "no-colon", "x:blah"
+		_, err := DecodePageToken(bad, ListOptions{})
+		assert.ErrorIs(t, err, ErrInvalidPageToken, bad)
+	}
+}
+
+func TestListOptionsMatches(t *testing.T) {
+	now := time.Now()
+	m := Message{Caller: "10.0.0.1", CreatedAt: now}
+
+	assert.True(t, ListOptions{}.Matches(m))
+	assert.True(t, ListOptions{Caller: "10.0.0.1"}.Matches(m))
+	assert.False(t, ListOptions{Caller: "10.0.0.2"}.Matches(m))
+	assert.True(t, ListOptions{Since: now}.Matches(m), "Since is inclusive")
+	assert.False(t, ListOptions{Since: now.Add(time.Nanosecond)}.Matches(m))
+	assert.False(t, ListOptions{Before: now}.Matches(m), "Before is exclusive")
+	assert.True(t, ListOptions{Before: now.Add(time.Nanosecond)}.Matches(m))
+}
+
+func TestErrDeletedIsNotFound(t *testing.T) {
+	assert.ErrorIs(t, ErrDeleted, ErrNotFound)
+	assert.NotErrorIs(t, ErrNotFound, ErrDeleted)
+}
+
+func TestPageSize(t *testing.T) {
+	assert.Equal(t, DefaultPageSize, PageSize(0))
+	assert.Equal(t, DefaultPageSize, PageSize(-3))
+	assert.Equal(t, 7, PageSize(7))
+	assert.Equal(t, MaxPageSize, PageSize(MaxPageSize+1))
+}
+
+func TestOrdering(t *testing.T) {
+	now := time.Now()
+	older := Message{ID: "z", CreatedAt: now.Add(-time.Second)}
+	newer := Message{ID: "a", CreatedAt: now}
+	sameTimeHigh := Message{ID: "b", CreatedAt: now}
+
+	assert.True(t, Less(newer, older, NewestFirst))
+	assert.True(t, Less(sameTimeHigh, newer, NewestFirst), "ties are broken by ID descending")
+	assert.True(t, Less(older, newer, OldestFirst))
+	assert.True(t, Less(newer, sameTimeHigh, OldestFirst), "ties are broken by ID ascending")
+
+	c := Cursor{CreatedAt: newer.CreatedAt, ID: newer.ID}
+	assert.True(t, c.After(older, NewestFirst))
+	assert.False(t, c.After(sameTimeHigh, NewestFirst))
+	assert.False(t, c.After(newer, NewestFirst))
+	assert.True(t, c.After(sameTimeHigh, OldestFirst))
+	assert.False(t, c.After(older, OldestFirst))
+}
+
+func TestNewID(t *testing.T) {
+	a, b := NewID(), NewID()
+	assert.Len(t, a, 32)
+	assert.NotEqual(t, a, b)
+}
@@ -0,0 +1,373 @@
+// internal/store/storetest/storetest.go
+//
+// Package storetest provides a conformance suite for store.MessageRepository
+// implementations, so every backend is held to the same contract.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"your-module-name/internal/store"
+)
+
+// Factory returns a new, empty repository. It is called once per subtest and
+// should register any cleanup with t.Cleanup.
+type Factory func(t *testing.T) store.MessageRepository
+
+// RunConformance runs the MessageRepository contract tests against
+// repositories returned by newRepo.
+//
+// Timestamps used by the suite have microsecond precision, the finest that
+// every backend stores.
+func RunConformance(t *testing.T, newRepo Factory) {
+	t.Helper()
+	for _, tc := range []struct {
+		name string
+		fn   func(*testing.T, store.MessageRepository)
+	}{
+		{"CreateAssignsIDAndTimestamp", testCreateAssignsIDAndTimestamp},
+		{"CreatePreservesFields", testCreatePreservesFields},
+		{"NotFound", testNotFound},
+		{"Delete", testDelete},
+		{"Ordering", testOrdering},
+		{"Pagination", testPagination},
+		{"DefaultPageSize", testDefaultPageSize},
+		{"CursorStableUnderWrites", testCursorStableUnderWrites},
+		{"InvalidPageToken", testInvalidPageToken},
+		{"OldestFirst", testOldestFirst},
+		{"FilterByCaller", testFilterByCaller},
+		{"FilterByTimeRange", testFilterByTimeRange},
+		{"PageTokenBoundToOptions", testPageTokenBoundToOptions},
+		{"ConcurrentWrites", testConcurrentWrites},
+		{"CanceledContext", testCanceledContext},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.fn(t, newRepo(t))
+		})
+	}
+}
+
+// baseTime is a fixed, microsecond-precision reference time.
+var baseTime = time.Date(2025, 6, 1, 12, 0, 0, 123456000, time.UTC)
+
+// seed creates messages with the given IDs, one second apart in order, and
+// returns them.
+func seed(t *testing.T, repo store.MessageRepository, ids ...string) []store.Message {
+	t.Helper()
+	msgs := make([]store.Message, len(ids))
+	for i, id := range ids {
+		m, err := repo.Create(context.Background(), store.Message{
+			ID:        id,
+			Text:      "text " + id,
+			CreatedAt: baseTime.Add(time.Duration(i) * time.Second),
+		})
+		require.NoError(t, err)
+		msgs[i] = m
+	}
+	return msgs
+}
+
+// listAll follows page tokens until the last page and returns the IDs seen.
+func listAll(t *testing.T, repo store.MessageRepository, pageSize int) []string {
+	t.Helper()
+	return listAllWith(t, repo, store.ListOptions{PageSize: pageSize})
+}
+
+// listAllWith is listAll with filters and order.
+func listAllWith(t *testing.T, repo store.MessageRepository, opts store.ListOptions) []string {
+	t.Helper()
+	var ids []string
+	pageSize := opts.PageSize
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 10000, "pagination does not terminate")
+		page, err := repo.List(context.Background(), opts)
+		require.NoError(t, err)
+		if pageSize > 0 {
+			require.LessOrEqual(t, len(page.Messages), pageSize)
+		}
+		for _, m := range page.Messages {
+			ids = append(ids, m.ID)
+		}
+		if page.NextPageToken == "" {
+			return ids
+		}
+		opts.PageToken = page.NextPageToken
+	}
+}
+
+func testCreateAssignsIDAndTimestamp(t *testing.T, repo store.MessageRepository) {
+	ctx := context.Background()
+	before := time.Now().Add(-time.Second)
+
+	a, err := repo.Create(ctx, store.Message{Text: "a"})
+	require.NoError(t, err)
+	b, err := repo.Create(ctx, store.Message{Text: "b"})
+	require.NoError(t, err)
+
+	assert.NotEmpty(t, a.ID)
+	assert.NotEqual(t, a.ID, b.ID, "IDs must be unique")
+	assert.True(t, a.CreatedAt.After(before), "CreatedAt should default to now")
+	assert.Equal(t, time.UTC, a.CreatedAt.Location())
+
+	got, err := repo.Get(ctx, a.ID)
+	require.NoError(t, err)
+	assert.Equal(t, a, got, "Get must return what Create returned")
+}
+
+func testCreatePreservesFields(t *testing.T, repo store.MessageRepository) {
+	ctx := context.Background()
+	want := store.Message{
+		ID:        "fixed-id",
+		Text:      "it's \"quoted\" — ünïcode\nand newlines",
+		Caller:    "203.0.113.7",
+		TraceID:   "4bf92f3577b34da6a3ce929d0e0e4736",
+		CreatedAt: baseTime.In(time.FixedZone("CEST", 2*60*60)),
+	}
+	created, err := repo.Create(ctx, want)
+	require.NoError(t, err)
+
+	got, err := repo.Get(ctx, "fixed-id")
+	require.NoError(t, err)
+	assert.Equal(t, created, got)
+	assert.Equal(t, want.ID, got.ID)
+	assert.Equal(t, want.Text, got.Text)
+	assert.Equal(t, want.Caller, got.Caller)
+	assert.Equal(t, want.TraceID, got.TraceID)
+	assert.True(t, want.CreatedAt.Equal(got.CreatedAt), "CreatedAt %v != %v", got.CreatedAt, want.CreatedAt)
+	assert.Equal(t, time.UTC, got.CreatedAt.Location(), "CreatedAt is normalised to UTC")
+}
+
+func testNotFound(t *testing.T, repo store.MessageRepository) {
+	ctx := context.Background()
+	_, err := repo.Get(ctx, "missing")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+	assert.ErrorIs(t, repo.Delete(ctx, "missing"), store.ErrNotFound)
+}
+
+func testDelete(t *testing.T, repo store.MessageRepository) {
+	ctx := context.Background()
+	seed(t, repo, "a", "b", "c")
+
+	require.NoError(t, repo.Delete(ctx, "b"))
+	_, err := repo.Get(ctx, "b")
+	assert.ErrorIs(t, err, store.ErrDeleted)
+	assert.ErrorIs(t, err, store.ErrNotFound, "ErrDeleted is also ErrNotFound")
+	assert.ErrorIs(t, repo.Delete(ctx, "b"), store.ErrDeleted, "second delete")
+	assert.Equal(t, []string{"c", "a"}, listAll(t, repo, 0), "deleted messages are not listed")
+
+	_, err = repo.Get(ctx, "a")
+	assert.NoError(t, err, "other messages are unaffected")
+}
+
+func testOrdering(t *testing.T, repo store.MessageRepository) {
+	ctx := context.Background()
+	// Inserted out of order; m2 and m3 share a timestamp and are ordered by ID.
+	for _, m := range []store.Message{
+		{ID: "m1", CreatedAt: baseTime.Add(1 * time.Second)},
+		{ID: "m3", CreatedAt: baseTime.Add(2 * time.Second)},
+		{ID: "m0", CreatedAt: baseTime},
+		{ID: "m2", CreatedAt: baseTime.Add(2 * time.Second)},
+		{ID: "m4", CreatedAt: baseTime.Add(3 * time.Second)},
+	} {
+		m.Text = "x"
+		_, err := repo.Create(ctx, m)
+		require.NoError(t, err)
+	}
+
+	page, err := repo.List(ctx, store.ListOptions{})
+	require.NoError(t, err)
+	var ids []string
+	for _, m := range page.Messages {
+		ids = append(ids, m.ID)
+	}
+	assert.Equal(t, []string{"m4", "m3", "m2", "m1", "m0"}, ids)
+	assert.Empty(t, page.NextPageToken, "no token on the last page")
+}
+
+func testPagination(t *testing.T, repo store.MessageRepository) {
+	var ids []string
+	for i := 0; i < 7; i++ {
+		ids = append(ids, fmt.Sprintf("m%d", i))
+	}
+	seed(t, repo, ids...)
+	want := []string{"m6", "m5", "m4", "m3", "m2", "m1", "m0"}
+
+	for _, size := range []int{1, 2, 3, 7, 8} {
+		assert.Equal(t, want, listAll(t, repo, size), "page size %d", size)
+	}
+
+	// An exactly full last page has no next token.
+	page, err := repo.List(context.Background(), store.ListOptions{PageSize: 7})
+	require.NoError(t, err)
+	assert.Len(t, page.Messages, 7)
+	assert.Empty(t, page.NextPageToken)
+}
+
+func testDefaultPageSize(t *testing.T, repo store.MessageRepository) {
+	ids := make([]string, store.DefaultPageSize+1)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("m%03d", i)
+	}
+	seed(t, repo, ids...)
+
+	page, err := repo.List(context.Background(), store.ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, page.Messages, store.DefaultPageSize)
+	assert.NotEmpty(t, page.NextPageToken)
+}
+
+func testCursorStableUnderWrites(t *testing.T, repo store.MessageRepository) {
+	ctx := context.Background()
+	seed(t, repo, "m0", "m1", "m2", "m3", "m4")
+
+	first, err := repo.List(ctx, store.ListOptions{PageSize: 2})
+	require.NoError(t, err)
+	require.Len(t, first.Messages, 2)
+	require.NotEmpty(t, first.NextPageToken)
+
+	// A newer message and the deletion of the cursor's own message must not
+	// cause skipped or repeated results on later pages.
+	_, err = repo.Create(ctx, store.Message{ID: "new", Text: "x", CreatedAt: baseTime.Add(time.Hour)})
+	require.NoError(t, err)
+	require.NoError(t, repo.Delete(ctx, "m3"))
+
+	var rest []string
+	token := first.NextPageToken
+	for token != "" {
+		page, err := repo.List(ctx, store.ListOptions{PageSize: 2, PageToken: token})
+		require.NoError(t, err)
+		for _, m := range page.Messages {
+			rest = append(rest, m.ID)
+		}
+		token = page.NextPageToken
+	}
+	assert.Equal(t, []string{"m2", "m1", "m0"}, rest)
+}
+
+func testInvalidPageToken(t *testing.T, repo store.MessageRepository) {
+	for _, token := range []string{"not a token!", "bm9jb2xvbg", "eDpt"} {
+		_, err := repo.List(context.Background(), store.ListOptions{PageToken: token})
+		assert.ErrorIs(t, err, store.ErrInvalidPageToken, "token %q", token)
+	}
+}
+
+func testOldestFirst(t *testing.T, repo store.MessageRepository) {
+	seed(t, repo, "m0", "m1", "m2", "m3", "m4")
+	_, err := repo.Create(context.Background(), store.Message{ID: "m5", Text: "x", CreatedAt: baseTime.Add(4 * time.Second)})
+	require.NoError(t, err)
+
+	want := []string{"m0", "m1", "m2", "m3", "m4", "m5"}
+	for _, size := range []int{1, 2, 4, 0} {
+		assert.Equal(t, want, listAllWith(t, repo, store.ListOptions{PageSize: size, Order: store.OldestFirst}), "page size %d", size)
+	}
+}
+
+func testFilterByCaller(t *testing.T, repo store.MessageRepository) {
+	ctx := context.Background()
+	for i, caller := range []string{"alice", "bob", "alice", "carol", "alice"} {
+		_, err := repo.Create(ctx, store.Message{
+			ID:        fmt.Sprintf("m%d", i),
+			Text:      "x",
+			Caller:    caller,
+			CreatedAt: baseTime.Add(time.Duration(i) * time.Second),
+		})
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, []string{"m4", "m2", "m0"}, listAllWith(t, repo, store.ListOptions{Caller: "alice", PageSize: 2}))
+	assert.Equal(t, []string{"m1"}, listAllWith(t, repo, store.ListOptions{Caller: "bob"}))
+	assert.Empty(t, listAllWith(t, repo, store.ListOptions{Caller: "nobody"}))
+}
+
+func testFilterByTimeRange(t *testing.T, repo store.MessageRepository) {
+	seed(t, repo, "m0", "m1", "m2", "m3", "m4") // One second apart from baseTime.
+	at := func(sec int) time.Time { return baseTime.Add(time.Duration(sec) * time.Second) }
+
+	assert.Equal(t, []string{"m4", "m3", "m2"}, listAllWith(t, repo, store.ListOptions{Since: at(2)}), "Since is inclusive")
+	assert.Equal(t, []string{"m1", "m0"}, listAllWith(t, repo, store.ListOptions{Before: at(2)}), "Before is exclusive")
+	assert.Equal(t, []string{"m1", "m2", "m3"},
+		listAllWith(t, repo, store.ListOptions{Since: at(1), Before: at(4), Order: store.OldestFirst, PageSize: 1}))
+	assert.Empty(t, listAllWith(t, repo, store.ListOptions{Since: at(3), Before: at(3)}))
+}
+
+func testPageTokenBoundToOptions(t *testing.T, repo store.MessageRepository) {
+	ctx := context.Background()
+	seed(t, repo, "m0", "m1", "m2")
+
+	page, err := repo.List(ctx, store.ListOptions{PageSize: 1})
+	require.NoError(t, err)
+	require.NotEmpty(t, page.NextPageToken)
+
+	_, err = repo.List(ctx, store.ListOptions{PageSize: 1, PageToken: page.NextPageToken, Order: store.OldestFirst})
+	assert.ErrorIs(t, err, store.ErrInvalidPageToken, "token reused with another order")
+	_, err = repo.List(ctx, store.ListOptions{PageSize: 1, PageToken: page.NextPageToken, Caller: "someone"})
+	assert.ErrorIs(t, err, store.ErrInvalidPageToken, "token reused with another filter")
+	_, err = repo.List(ctx, store.ListOptions{PageSize: 5, PageToken: page.NextPageToken})
+	assert.NoError(t, err, "the page size may change")
+}
+
+func testConcurrentWrites(t *testing.T, repo store.MessageRepository) {
+	const writers, perWriter = 8, 10
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*perWriter)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				if _, err := repo.Create(ctx, store.Message{Text: fmt.Sprintf("w%d-%d", w, i)}); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent Create failed: %v", err)
+	}
+
+	ids := listAll(t, repo, 25)
+	assert.Len(t, ids, writers*perWriter)
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		assert.False(t, seen[id], "duplicate ID %s", id)
+		seen[id] = true
+	}
+}
+
+func testCanceledContext(t *testing.T, repo store.MessageRepository) {
+	seed(t, repo, "a")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := repo.Create(ctx, store.Message{Text: "x"})
+	assertCanceled(t, err, "Create")
+	_, err = repo.Get(ctx, "a")
+	assertCanceled(t, err, "Get")
+	_, err = repo.List(ctx, store.ListOptions{})
+	assertCanceled(t, err, "List")
+	assertCanceled(t, repo.Delete(ctx, "a"), "Delete")
+
+	// Nothing was written or deleted.
+	_, err = repo.Get(context.Background(), "a")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, listAll(t, repo, 0))
+}
+
+func assertCanceled(t *testing.T, err error, op string) {
+	t.Helper()
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("%s with a canceled context: got %v, want context.Canceled", op, err)
+	}
+}
@@ -0,0 +1,173 @@
+// internal/tlsconfig/tlsconfig.go
+
+// Package tlsconfig serves TLS from certificate files that are reloaded when
+// they change, optionally verifying client certificates (mutual TLS).
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Files names the PEM files a Reloader serves.
+type Files struct {
+	// CertFile holds the server certificate chain, leaf first.
+	CertFile string
+	KeyFile  string
+	// ClientCAFile, if set, holds the CAs that sign client certificates.
+	ClientCAFile string
+	// ClientAuth is the client certificate policy when ClientCAFile is set,
+	// typically tls.RequireAndVerifyClientCert or tls.VerifyClientCertIfGiven.
+	ClientAuth tls.ClientAuthType
+}
+
+// Reloader holds the certificate and client CAs loaded from Files and
+// reloads them when the files change, so certificates can be rotated without
+// a restart. If a reload fails, the last valid files remain in effect.
+type Reloader struct {
+	files  Files
+	logger *slog.Logger
+
+	// OnReload, if set, is called by Watch after each reload that loaded
+	// changes (err is nil) or failed. Set it before starting Watch.
+	OnReload func(ctx context.Context, err error)
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	stamps    []fileStamp
+}
+
+// fileStamp identifies a version of a file.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewReloader loads files. It returns an error if the initial load fails,
+// so broken files are caught at startup.
+func NewReloader(files Files, logger *slog.Logger) (*Reloader, error) {
+	r := &Reloader{files: files, logger: logger}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Config returns a server TLS configuration that always uses the most
+// recently loaded certificate and client CAs. Each handshake gets its own
+// copy, so NextProtos is set here rather than left to http.Server.
+func (r *Reloader) Config() *tls.Config {
+	base := &tls.Config{MinVersion: tls.VersionTLS12, NextProtos: []string{"h2", "http/1.1"}}
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		cfg := base.Clone()
+		cfg.GetConfigForClient = nil
+		cfg.Certificates = []tls.Certificate{*r.cert}
+		if r.clientCAs != nil {
+			cfg.ClientCAs = r.clientCAs
+			cfg.ClientAuth = r.files.ClientAuth
+		}
+		return cfg, nil
+	}
+	return base
+}
+
+// Certificate returns the current server certificate.
+func (r *Reloader) Certificate() *tls.Certificate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert
+}
+
+// Reload re-reads the files if any of their modification times or sizes
+// changed since the last successful load. It reports whether new files
+// were loaded.
+func (r *Reloader) Reload() (bool, error) {
+	paths := []string{r.files.CertFile, r.files.KeyFile}
+	if r.files.ClientCAFile != "" {
+		paths = append(paths, r.files.ClientCAFile)
+	}
+	stamps := make([]fileStamp, len(paths))
+	for i, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return false, fmt.Errorf("tlsconfig: failed to stat %s: %w", path, err)
+		}
+		stamps[i] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+	}
+
+	r.mu.RLock()
+	unchanged := r.cert != nil && equalStamps(stamps, r.stamps)
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.files.CertFile, r.files.KeyFile)
+	if err != nil {
+		return false, fmt.Errorf("tlsconfig: failed to load %s: %w", r.files.CertFile, err)
+	}
+	var clientCAs *x509.CertPool
+	if r.files.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.files.ClientCAFile)
+		if err != nil {
+			return false, fmt.Errorf("tlsconfig: failed to read %s: %w", r.files.ClientCAFile, err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return false, fmt.Errorf("tlsconfig: no certificates in %s", r.files.ClientCAFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.clientCAs = clientCAs
+	r.stamps = stamps
+	r.mu.Unlock()
+	return true, nil
+}
+
+func equalStamps(a, b []fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].modTime.Equal(b[i].modTime) || a[i].size != b[i].size {
+			return false
+		}
+	}
+	return true
+}
+
+// Watch polls the files every interval and reloads them on change until ctx
+// is cancelled. Reload failures are logged and the previous files are kept.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := r.Reload()
+			if r.OnReload != nil && (reloaded || err != nil) {
+				r.OnReload(ctx, err)
+			}
+			if err != nil {
+				r.logger.WarnContext(ctx, "Failed to reload TLS files; keeping previous certificate", "cert_file", r.files.CertFile, "error", err)
+				continue
+			}
+			if reloaded {
+				r.logger.InfoContext(ctx, "TLS files reloaded", "cert_file", r.files.CertFile, "client_ca_file", r.files.ClientCAFile)
+			}
+		}
+	}
+}
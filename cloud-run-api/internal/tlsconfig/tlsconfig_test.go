@@ -0,0 +1,169 @@
+// internal/tlsconfig/tlsconfig_test.go
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"your-module-name/internal/tlsconfig/tlstest"
+)
+
+// testFiles writes a server certificate from serverCA and a client CA bundle
+// holding clientCA into a temporary directory.
+func testFiles(t *testing.T, serverCA, clientCA *tlstest.CA) Files {
+	t.Helper()
+	dir := t.TempDir()
+	certFile, keyFile := tlstest.WriteKeyPair(t, dir, serverCA.ServerCert(t, "127.0.0.1"))
+	caFile := filepath.Join(dir, "clients.pem")
+	tlstest.WriteFile(t, caFile, clientCA.PEM())
+	return Files{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile, ClientAuth: tls.RequireAndVerifyClientCert}
+}
+
+// startServer serves the common name of the verified client certificate.
+func startServer(t *testing.T, r *Reloader) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if len(req.TLS.VerifiedChains) > 0 {
+			io.WriteString(w, req.TLS.VerifiedChains[0][0].Subject.CommonName)
+		}
+	}))
+	srv.TLS = r.Config()
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0) // Rejected handshakes are expected.
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// get calls srv trusting roots and presenting cert, if any, even if the
+// server does not name its CA as acceptable.
+func get(srv *httptest.Server, roots *x509.CertPool, cert *tls.Certificate) (string, error) {
+	tlsConfig := &tls.Config{RootCAs: roots}
+	if cert != nil {
+		tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) { return cert, nil }
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+	defer client.CloseIdleConnections()
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+// touch moves a file's modification time forward, so a rewrite is noticed
+// even within the file system's timestamp resolution.
+func touch(t *testing.T, path string) {
+	t.Helper()
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(path, later, later))
+}
+
+func TestReloader_MutualTLS(t *testing.T) {
+	serverCA := tlstest.NewCA(t, "server CA")
+	clientCA := tlstest.NewCA(t, "client CA")
+	r, err := NewReloader(testFiles(t, serverCA, clientCA), slog.New(slog.DiscardHandler))
+	require.NoError(t, err)
+	srv := startServer(t, r)
+
+	client := clientCA.ClientCert(t, "billing", "spiffe://example.org/ns/prod/sa/billing")
+	body, err := get(srv, serverCA.Pool(), &client)
+	require.NoError(t, err)
+	assert.Equal(t, "billing", body)
+
+	_, err = get(srv, serverCA.Pool(), nil)
+	assert.Error(t, err, "a client certificate is required")
+
+	stranger := tlstest.NewCA(t, "other CA").ClientCert(t, "stranger")
+	_, err = get(srv, serverCA.Pool(), &stranger)
+	assert.Error(t, err, "certificates from other CAs are rejected")
+}
+
+func TestReloader_VerifyIfGiven(t *testing.T) {
+	serverCA := tlstest.NewCA(t, "server CA")
+	clientCA := tlstest.NewCA(t, "client CA")
+	files := testFiles(t, serverCA, clientCA)
+	files.ClientAuth = tls.VerifyClientCertIfGiven
+	r, err := NewReloader(files, slog.New(slog.DiscardHandler))
+	require.NoError(t, err)
+	srv := startServer(t, r)
+
+	body, err := get(srv, serverCA.Pool(), nil)
+	require.NoError(t, err)
+	assert.Empty(t, body)
+
+	stranger := tlstest.NewCA(t, "other CA").ClientCert(t, "stranger")
+	_, err = get(srv, serverCA.Pool(), &stranger)
+	assert.Error(t, err, "presented certificates are still verified")
+}
+
+func TestReloader_Reload(t *testing.T) {
+	serverCA := tlstest.NewCA(t, "server CA")
+	clientCA := tlstest.NewCA(t, "client CA")
+	files := testFiles(t, serverCA, clientCA)
+	r, err := NewReloader(files, slog.New(slog.DiscardHandler))
+	require.NoError(t, err)
+	srv := startServer(t, r)
+
+	reloaded, err := r.Reload()
+	require.NoError(t, err)
+	assert.False(t, reloaded, "unchanged files are not reloaded")
+
+	// Rotate the server certificate to a new CA and the client CA bundle.
+	newServerCA := tlstest.NewCA(t, "new server CA")
+	newClientCA := tlstest.NewCA(t, "new client CA")
+	tlstest.WriteKeyPair(t, filepath.Dir(files.CertFile), newServerCA.ServerCert(t, "127.0.0.1"))
+	tlstest.WriteFile(t, files.ClientCAFile, newClientCA.PEM())
+	touch(t, files.CertFile)
+	reloaded, err = r.Reload()
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+
+	client := newClientCA.ClientCert(t, "rotated")
+	body, err := get(srv, newServerCA.Pool(), &client)
+	require.NoError(t, err, "new connections use the reloaded files")
+	assert.Equal(t, "rotated", body)
+
+	oldClient := clientCA.ClientCert(t, "old")
+	_, err = get(srv, newServerCA.Pool(), &oldClient)
+	assert.Error(t, err, "the old client CA is no longer trusted")
+
+	// A broken file keeps the previous certificate in effect.
+	current := r.Certificate()
+	tlstest.WriteFile(t, files.KeyFile, []byte("not a key"))
+	touch(t, files.KeyFile)
+	_, err = r.Reload()
+	require.Error(t, err)
+	assert.Same(t, current, r.Certificate())
+	_, err = get(srv, newServerCA.Pool(), &client)
+	assert.NoError(t, err)
+}
+
+func TestNewReloader_Errors(t *testing.T) {
+	serverCA := tlstest.NewCA(t, "server CA")
+	files := testFiles(t, serverCA, serverCA)
+
+	missing := files
+	missing.CertFile = filepath.Join(t.TempDir(), "missing.crt")
+	_, err := NewReloader(missing, slog.New(slog.DiscardHandler))
+	assert.Error(t, err)
+
+	emptyCAs := files
+	emptyCAs.ClientCAFile = filepath.Join(t.TempDir(), "empty.pem")
+	tlstest.WriteFile(t, emptyCAs.ClientCAFile, nil)
+	_, err = NewReloader(emptyCAs, slog.New(slog.DiscardHandler))
+	assert.ErrorContains(t, err, "no certificates")
+}
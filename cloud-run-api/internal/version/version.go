@@ -0,0 +1,61 @@
+// internal/version/version.go
+//
+// Package version reports the build version of the service.
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+// Version is the release version, set at build time with
+//
+//	go build -ldflags "-X your-module-name/internal/version.Version=v1.2.3"
+//
+// When unset, the module version recorded by the Go toolchain is used.
+var Version string
+
+// Info describes the running build.
+type Info struct {
+	Version   string
+	Commit    string // VCS revision, if the binary was built from a checkout.
+	CommitAt  string // RFC 3339 commit time.
+	Modified  bool   // The checkout had uncommitted changes.
+	GoVersion string
+}
+
+var (
+	once sync.Once
+	info Info
+)
+
+// Get returns the build information. It is computed once.
+func Get() Info {
+	once.Do(func() {
+		info = Info{Version: Version, GoVersion: runtime.Version()}
+		defer func() {
+			if info.Version == "" {
+				info.Version = "(devel)"
+			}
+		}()
+		bi, ok := debug.ReadBuildInfo()
+		if !ok {
+			return
+		}
+		if info.Version == "" {
+			info.Version = bi.Main.Version
+		}
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				info.Commit = s.Value
+			case "vcs.time":
+				info.CommitAt = s.Value
+			case "vcs.modified":
+				info.Modified = s.Value == "true"
+			}
+		}
+	})
+	return info
+}
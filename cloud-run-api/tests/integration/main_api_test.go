@@ -18,10 +18,10 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"your-module-name/internal/api"
-	// Old import: "your-module-name/internal/cloudlogging"
-	"github.com/duizendstra/dui-go/logging/cloudlogging" // New import
 	"your-module-name/internal/config"
+	"your-module-name/internal/logging"
 	"your-module-name/internal/models"
+	"your-module-name/internal/store"
 )
 
 var (
@@ -32,6 +32,12 @@ var (
 
 // TestMain sets up the HTTP test server once for all integration tests in this package.
 func TestMain(m *testing.M) {
+	// Run in local mode unless told otherwise, so the tests need neither
+	// GOOGLE_CLOUD_PROJECT nor a metadata server.
+	if _, ok := os.LookupEnv("RUNTIME_MODE"); !ok {
+		os.Setenv("RUNTIME_MODE", config.RuntimeModeLocal)
+	}
+
 	var err error
 	appConfig, err = config.Load()
 	if err != nil {
@@ -42,18 +48,23 @@ func TestMain(m *testing.M) {
 
 	logOutput := io.Discard
 	// For the test's own logger, a simple TextHandler is fine.
-	// The actual application handler will use the dui-go cloud logger.
+	// The application handler below is built by the logging factory.
 	if os.Getenv("INTEGRATION_TEST_LOG_OUTPUT") == "stderr" {
 		logOutput = os.Stderr
 	}
 	logger = slog.New(slog.NewTextHandler(logOutput, &slog.HandlerOptions{Level: slog.LevelDebug}))
 
 	// Setup the handler and router
-	// The API handler will be configured with the dui-go logger
-	// The dui-go NewCloudLoggingHandler should handle its own LOG_LEVEL env var processing.
-	handlerLogger := slog.New(cloudlogging.NewCloudLoggingHandler(appConfig.ServiceName))
-	apiHandler := api.NewHandler(handlerLogger, appConfig)
-	httpHandler := api.SetupRoutes(apiHandler) // SetupRoutes uses dui-go's WithCloudTraceContext
+	// The API handler uses the same logging factory as the application, so
+	// LOG_FORMAT applies (text by default in local mode).
+	handler, _, err := logging.NewHandler(appConfig, logging.Options{Writer: logOutput})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "FATAL: Failed to set up logging for integration tests: %v\n", err)
+		os.Exit(1)
+	}
+	handlerLogger := slog.New(handler)
+	apiHandler := api.NewHandler(handlerLogger, appConfig, store.NewMemoryRepository())
+	httpHandler := api.SetupRoutes(apiHandler) // SetupRoutes adds logging.WithCloudTraceContext
 	testServer = httptest.NewServer(httpHandler)
 
 	logger.Info("Integration test server started", "url", testServer.URL)
@@ -113,6 +124,7 @@ func TestIntegration_APIEndpoints(t *testing.T) {
 		err = json.NewDecoder(httpResp.Body).Decode(&echoResp)
 		require.NoError(t, err)
 		assert.Equal(t, "Integration Echo Test", echoResp.ReceivedText)
+		assert.NotEmpty(t, echoResp.MessageID)
 		assert.Contains(t, echoResp.Reply, "received your message: 'Integration Echo Test'")
 		assert.NotEmpty(t, echoResp.Timestamp)
 	})